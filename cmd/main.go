@@ -2,19 +2,33 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"strings"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/elskow/go-microservice-template/config"
+	"github.com/elskow/go-microservice-template/database"
 	"github.com/elskow/go-microservice-template/middlewares"
 	"github.com/elskow/go-microservice-template/modules/account"
+	"github.com/elskow/go-microservice-template/modules/account/authorization"
+	"github.com/elskow/go-microservice-template/modules/account/service"
 	"github.com/elskow/go-microservice-template/pkg/apm"
-	"github.com/elskow/go-microservice-template/pkg/constants"
+	pkgDB "github.com/elskow/go-microservice-template/pkg/database"
+	"github.com/elskow/go-microservice-template/pkg/eventstream"
+	"github.com/elskow/go-microservice-template/pkg/jwt"
 	pkgLogger "github.com/elskow/go-microservice-template/pkg/logger"
+	"github.com/elskow/go-microservice-template/pkg/outbox"
+	"github.com/elskow/go-microservice-template/pkg/permissions"
+	"github.com/elskow/go-microservice-template/pkg/readiness"
+	"github.com/elskow/go-microservice-template/pkg/response"
 	"github.com/elskow/go-microservice-template/pkg/telemetry"
 	"github.com/elskow/go-microservice-template/providers"
 	"github.com/elskow/go-microservice-template/script"
@@ -35,25 +49,213 @@ func args(injector *do.Injector) bool {
 	return true
 }
 
-func getBlacklistPaths(cfg *config.Config) []string {
-	if cfg.LogBlacklistPaths == "" {
-		return nil
+// reloadOnHangup re-reads the configuration on SIGHUP and applies the log
+// blacklist from it, until ctx is done. The configuration returned by
+// config.Get is left as loaded at startup, so every other setting still
+// needs a restart, and a configuration that no longer parses is reported
+// and ignored.
+func reloadOnHangup(ctx context.Context, logger *slog.Logger, blacklist *middlewares.PathBlacklist) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			cfg, err := config.Parse()
+			if err != nil {
+				logger.Warn("invalid configuration, keeping the previous one", "error", err)
+				continue
+			}
+			if err := blacklist.Set(cfg.LogBlacklistPathList()); err != nil {
+				logger.Warn("invalid log blacklist, keeping the previous one", "error", err)
+				continue
+			}
+			logger.Info("configuration reloaded", "log_blacklist_paths", cfg.LogBlacklistPathList())
+		}
 	}
+}
 
-	paths := strings.Split(cfg.LogBlacklistPaths, ",")
-	for i := range paths {
-		paths[i] = strings.TrimSpace(paths[i])
+// ensureMigrations refuses to start the server against a database that is
+// behind the embedded migrations, unless AUTO_MIGRATE applies them first.
+func ensureMigrations(injector *do.Injector, logger *slog.Logger, cfg *config.Config) {
+	if !cfg.CheckMigrationsOnStartup && !cfg.AutoMigrate {
+		return
 	}
-	return paths
+
+	db := do.MustInvokeNamed[*pkgDB.TracedDB](injector, "db")
+	if err := database.CheckMigrations(db.DB, cfg.AutoMigrate); err != nil {
+		logger.Error("database is not migrated, run with --migrate or set AUTO_MIGRATE=true", "error", err)
+		os.Exit(1)
+	}
+
+	logger.Info("database migrations up to date")
 }
 
-func isPathBlacklisted(path string, blacklist []string) bool {
-	for _, blacklisted := range blacklist {
-		if blacklisted == path {
-			return true
-		}
+// ensureRBACTables logs an error naming every RBAC table that does not
+// exist, since permission checks against them would all fail with a 403.
+func ensureRBACTables(ctx context.Context, injector *do.Injector, logger *slog.Logger, cfg *config.Config) {
+	if !cfg.CheckRBACTables {
+		return
+	}
+
+	authorizer := do.MustInvokeNamed[*authorization.Authorizer](injector, "authorizer")
+	missing, err := authorizer.MissingTables(ctx, authorization.RequiredTables)
+	if err != nil {
+		logger.Warn("failed to check rbac tables", "error", err)
+		return
+	}
+
+	if len(missing) > 0 {
+		logger.Error("rbac tables are missing, every permission check will fail; run with --migrate", "tables", missing)
+		return
+	}
+	logger.Info("rbac tables present")
+}
+
+// ensureDefaultRolePermissions grants the role assigned on registration
+// the configured permissions it lacks, so seed data missing them does not
+// leave new users unable to manage their own profile.
+func ensureDefaultRolePermissions(ctx context.Context, injector *do.Injector, logger *slog.Logger, cfg *config.Config) {
+	names := cfg.DefaultRolePermissionList()
+	if len(names) == 0 {
+		return
+	}
+
+	required := make([]permissions.Permission, len(names))
+	for i, name := range names {
+		required[i] = permissions.Permission(name)
+	}
+
+	authorizer := do.MustInvokeNamed[*authorization.Authorizer](injector, "authorizer")
+	granted, missing, err := authorizer.EnsureRolePermissions(ctx, authorization.DefaultRole, required)
+	if err != nil {
+		logger.Warn("failed to ensure default role permissions", "role", authorization.DefaultRole, "error", err)
+		return
+	}
+
+	for _, permission := range missing {
+		logger.Warn("default role permission does not exist", "role", authorization.DefaultRole, "permission", permission.String())
+	}
+	if len(granted) > 0 {
+		logger.Info("granted default role permissions", "role", authorization.DefaultRole, "permissions", granted)
+	}
+}
+
+// rbacReadiness reports whether the RBAC tables exist. Only confirmed
+// missing tables fail readiness; a failed lookup is reported as unknown.
+func rbacReadiness(ctx context.Context, authorizer *authorization.Authorizer) (gin.H, bool) {
+	missing, err := authorizer.MissingTables(ctx, authorization.RequiredTables)
+	switch {
+	case err != nil:
+		return gin.H{"status": "unknown"}, true
+	case len(missing) > 0:
+		return gin.H{"status": "missing", "tables": missing}, false
+	default:
+		return gin.H{"status": "ok"}, true
+	}
+}
+
+// startupReadiness reports the startup initializers still running; the
+// service is not ready until there are none.
+func startupReadiness(gate *readiness.Gate) (gin.H, bool) {
+	if pending := gate.Pending(); len(pending) > 0 {
+		return gin.H{"status": "starting", "pending": pending}, false
+	}
+	return gin.H{"status": "ok"}, true
+}
+
+// startMaintenanceSync publishes MAINTENANCE_MODE=true to the maintenance
+// flag shared by every replica, or else adopts its current state, then keeps
+// this replica in step with it. A failed first load leaves the configured
+// state in place until a later sync succeeds.
+func startMaintenanceSync(ctx context.Context, mode *middlewares.MaintenanceMode, logger *slog.Logger, cfg *config.Config) {
+	var err error
+	if cfg.MaintenanceMode {
+		err = mode.SetEnabled(ctx, true)
+	} else {
+		err = mode.Sync(ctx)
+	}
+	if err != nil {
+		logger.Warn("failed to load shared maintenance mode", "error", err)
+	}
+	mode.StartSync(ctx, cfg.MaintenanceSyncInterval(), logger)
+}
+
+// ensureEmailConfig refuses to start the server with email enabled but no
+// PUBLIC_BASE_URL to build the emailed links on.
+func ensureEmailConfig(logger *slog.Logger, cfg *config.Config) {
+	if err := cfg.ValidatePublicBaseURL(); err != nil {
+		logger.Error("invalid email configuration", "error", err)
+		os.Exit(1)
+	}
+}
+
+// warnTwoFactorConfig flags a missing TWO_FACTOR_ENCRYPTION_KEY, without
+// which two-factor enrollment and logins are refused.
+func warnTwoFactorConfig(logger *slog.Logger, cfg *config.Config) {
+	if cfg.TwoFactorKey() == "" {
+		logger.Warn("TWO_FACTOR_ENCRYPTION_KEY is not set, two-factor authentication is disabled")
+	}
+}
+
+// ensurePermissions warns about permissions checked by handlers that were
+// never seeded, when VALIDATE_PERMISSIONS_ON_STARTUP is set.
+func ensurePermissions(ctx context.Context, injector *do.Injector, logger *slog.Logger, cfg *config.Config) {
+	if !cfg.ValidatePermissionsOnStartup {
+		return
+	}
+
+	authorizer := do.MustInvokeNamed[*authorization.Authorizer](injector, "authorizer")
+	missing, err := authorizer.ValidatePermissions(ctx, authorization.RequiredPermissions)
+	if err != nil {
+		logger.Warn("failed to validate permissions", "error", err)
+		return
+	}
+
+	if len(missing) == 0 {
+		logger.Info("required permissions present")
+	}
+}
+
+// warmAuthorizerCache preloads the permissions of recently active users so
+// the first requests after a restart do not each reload them.
+func warmAuthorizerCache(ctx context.Context, injector *do.Injector, logger *slog.Logger, cfg *config.Config) {
+	limit := cfg.AuthzWarmupUserCount()
+	if limit == 0 {
+		return
+	}
+
+	authorizer := do.MustInvokeNamed[*authorization.Authorizer](injector, "authorizer")
+	start := time.Now()
+	warmed, err := authorizer.WarmCache(ctx, limit)
+	if err != nil {
+		logger.Warn("failed to warm permission cache", "error", err)
+		return
+	}
+	logger.Info("permission cache warmed", "users", warmed, "duration", time.Since(start))
+}
+
+// newShutdownContext returns the context bounding graceful shutdown, using
+// the configured SHUTDOWN_TIMEOUT_SECONDS.
+func newShutdownContext(cfg *config.Config) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), cfg.ShutdownTimeout())
+}
+
+// shutdownWithin runs shutdown, giving up once ctx is done so a dependency
+// waiting on in-flight work cannot outlast the shutdown deadline.
+func shutdownWithin(ctx context.Context, shutdown func() error) error {
+	done := make(chan error, 1)
+	go func() { done <- shutdown() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("shutdown did not finish in time: %w", ctx.Err())
 	}
-	return false
 }
 
 const (
@@ -62,17 +264,27 @@ const (
 	allInterfaces = "0.0.0.0"
 )
 
-func run(server *gin.Engine, logger *slog.Logger, cfg *config.Config) {
-	var serve string
+// serveAddress is the address the HTTP server listens on.
+func serveAddress(cfg *config.Config) string {
 	if cfg.IsLocalhost() {
-		serve = allInterfaces + ":" + cfg.Port
-	} else {
-		serve = ":" + cfg.Port
+		return allInterfaces + ":" + cfg.Port
 	}
+	return ":" + cfg.Port
+}
 
-	logger.Info("server starting", "address", serve)
+// newHTTPServer builds the server for handler on addr. Event streams stay
+// open until the broker closes them, so it is closed as soon as the drain
+// starts rather than with the other dependencies afterwards.
+func newHTTPServer(handler http.Handler, addr string, broker *eventstream.Broker) *http.Server {
+	srv := &http.Server{Addr: addr, Handler: handler}
+	srv.RegisterOnShutdown(func() { _ = broker.Shutdown() })
+	return srv
+}
+
+func run(srv *http.Server, logger *slog.Logger) {
+	logger.Info("server starting", "address", srv.Addr)
 
-	if err := server.Run(serve); err != nil {
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		logger.Error("server failed", "error", err)
 		os.Exit(1)
 	}
@@ -85,10 +297,15 @@ func main() {
 
 	// Load configuration first
 	cfg := config.Load()
+	response.SetInt64AsString(cfg.JSONInt64AsString)
 
 	providers.RegisterDependencies(injector)
 
 	logger := do.MustInvokeNamed[*slog.Logger](injector, "logger")
+	if err := providers.Validate(injector); err != nil {
+		logger.Error("invalid dependency configuration", "error", err)
+		os.Exit(1)
+	}
 	tel := do.MustInvokeNamed[*telemetry.Telemetry](injector, "telemetry")
 	apmCollector := do.MustInvokeNamed[*apm.MetricsCollector](injector, "apm")
 
@@ -98,6 +315,7 @@ func main() {
 	)
 
 	argsStr := strings.Join(os.Args, " ")
+	// seedFlag also matches --verify-seed
 	isMigrationCommand := len(os.Args) > 1 && (strings.Contains(argsStr, migrateFlag) || strings.Contains(argsStr, seedFlag))
 
 	if cfg.EnableProfiling && !isMigrationCommand {
@@ -135,66 +353,203 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	// One deadline, taken when the signal arrives, bounds the whole shutdown:
+	// the HTTP drain, dependencies, logger and telemetry all share it.
+	// Commands that exit without a signal take theirs at cleanup.
+	var (
+		shutdownCtx    context.Context
+		cancelShutdown context.CancelFunc
+	)
+	var report shutdownReport
 	defer func() {
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), constants.DefaultShutdownTimeout)
-		defer cancel()
+		if shutdownCtx == nil {
+			shutdownCtx, cancelShutdown = newShutdownContext(cfg)
+		}
+		defer cancelShutdown()
 
-		if err := pkgLogger.Shutdown(shutdownCtx); err != nil {
+		// Closes invoked services implementing do.Shutdownable (database, APM collector, outbox publisher)
+		err := shutdownWithin(shutdownCtx, injector.Shutdown)
+		if err != nil {
+			logger.Error("failed to shutdown dependencies", "error", err)
+		}
+		report.record("dependencies", err)
+
+		// Read before the logger stops, while the buffer still reports it
+		logsDropped := pkgLogger.Status().Dropped
+		err = pkgLogger.Shutdown(shutdownCtx)
+		if err != nil {
 			logger.Error("failed to shutdown logger", "error", err)
 		}
+		report.record("logger", err)
 
-		if err := tel.Shutdown(shutdownCtx); err != nil {
+		err = tel.Shutdown(shutdownCtx)
+		if err != nil {
 			logger.Error("failed to shutdown telemetry", "error", err)
 		}
+		report.record("telemetry", err)
+
+		// OTLP export has stopped by now; the summary still reaches stdout
+		if report.started() {
+			logger.Info("shutdown complete", report.attrs(time.Now(), logsDropped)...)
+		}
 	}()
 
 	if !args(injector) {
 		return
 	}
 
+	ensureEmailConfig(logger, cfg)
+	warnTwoFactorConfig(logger, cfg)
+	ensureMigrations(injector, logger, cfg)
+	maintenance := do.MustInvokeNamed[*middlewares.MaintenanceMode](injector, "maintenance-mode")
+	startMaintenanceSync(ctx, maintenance, logger, cfg)
+
+	// The database answered when the injector built it and migrations have
+	// run, so the server starts accepting connections while the RBAC setup
+	// and cache warm-up run; /ready answers 503 until both are done
+	gate := do.MustInvokeNamed[*readiness.Gate](injector, "readiness-gate")
+	rbacDone := gate.Register("rbac")
+	cacheDone := gate.Register("authz-cache")
+	go func() {
+		ensureRBACTables(ctx, injector, logger, cfg)
+		ensureDefaultRolePermissions(ctx, injector, logger, cfg)
+		ensurePermissions(ctx, injector, logger, cfg)
+		rbacDone()
+		warmAuthorizerCache(ctx, injector, logger, cfg)
+		cacheDone()
+	}()
+
+	// Starts polling the outbox; the injector stops it before closing the
+	// database since it was invoked after it.
+	do.MustInvokeNamed[*outbox.Publisher](injector, "outbox-publisher")
+	// Feeds events published by any replica to this one's streams
+	do.MustInvokeNamed[*eventstream.Relay](injector, "event-relay")
+
 	gin.DefaultWriter = io.Discard
 	gin.DefaultErrorWriter = io.Discard
 
 	server := gin.New()
+	// ClientIP only honours forwarding headers set by these proxies
+	if err := server.SetTrustedProxies(cfg.TrustedProxyList()); err != nil {
+		logger.Error("invalid trusted proxies", "error", err)
+		os.Exit(1)
+	}
+	// Lets the admin route listing read the registered routes
+	do.ProvideNamedValue(injector, "router", server)
 	server.Use(gin.Recovery())
+	envelope := response.EnvelopeWrapped
+	if cfg.ResponseEnvelope == config.EnvelopeBare {
+		envelope = response.EnvelopeBare
+	}
+	// Before every middleware that writes a response
+	server.Use(response.UseEnvelope(envelope))
 	server.Use(middlewares.RequestIDMiddleware())
+	var inFlight atomic.Int64
+	server.Use(middlewares.CountInFlight(&inFlight))
 
-	blacklistPaths := getBlacklistPaths(cfg)
-
-	server.Use(otelgin.Middleware(
-		cfg.AppName,
-		otelgin.WithGinFilter(func(c *gin.Context) bool {
-			return !isPathBlacklisted(c.Request.URL.Path, blacklistPaths)
-		}),
-	))
+	requireHTTPS, err := middlewares.RequireHTTPS(cfg.EnforceHTTPS, cfg.HTTPSRedirect, cfg.TrustedProxyList(), cfg.HTTPSExemptPathList())
+	if err != nil {
+		logger.Error("invalid https configuration", "error", err)
+		os.Exit(1)
+	}
+	server.Use(requireHTTPS)
 
-	server.Use(middlewares.SlogMiddleware(logger))
+	blacklist, err := middlewares.NewPathBlacklist(cfg.LogBlacklistPathList())
+	if err != nil {
+		logger.Error("invalid log blacklist", "error", err)
+		os.Exit(1)
+	}
+	go reloadOnHangup(ctx, logger, blacklist)
+
+	server.Use(otelgin.Middleware(cfg.AppName, otelgin.WithGinFilter(blacklist.Traced)))
+	server.Use(middlewares.LegacyTraceLink(cfg.OTELLegacyTraceHeader))
+	server.Use(middlewares.ErrorBodyCapture(cfg.ShouldTraceErrorBodies(), cfg.LogRedactKeyList()))
+
+	server.Use(middlewares.SlogMiddleware(logger, blacklist))
+	// Ahead of the error handler, load shedding, authentication and the
+	// timeout so the statuses they answer with are recorded too
+	server.Use(middlewares.HTTPMetricsMiddleware(apmCollector, cfg.MetricsStreamRouteList()))
+	// Renders errors handlers attach with c.Error; inside the logger so the
+	// access log sees the final status
+	server.Use(middlewares.ErrorHandler())
 	server.Use(middlewares.CORSMiddleware())
 
-	server.Use(middlewares.HTTPMetricsMiddleware(apmCollector))
+	if cfg.MaxInFlightRequests > 0 {
+		server.Use(middlewares.LoadShedding(cfg.MaxInFlightRequests, apmCollector, middlewares.RetryAfterFormatFor(cfg.RetryAfterHTTPDate)))
+	}
+
+	server.Use(maintenance.Middleware())
+
+	server.Use(middlewares.Timeout(cfg.RequestTimeout(), cfg.RequestTimeoutExemptPathList()))
+
+	if cfg.EnableGlobalAuth {
+		jwtService := do.MustInvokeNamed[jwt.Service](injector, "jwt-service")
+		tokenVersions := do.MustInvokeNamed[service.Service](injector, "service")
+		server.Use(middlewares.AuthenticateExcept(jwtService, tokenVersions, cfg.AccessTokenCookie(), apmCollector, cfg.AuthPublicPathList()))
+	}
+
+	server.Use(middlewares.TenantMiddleware(cfg.TenantHeader, cfg.TenantBaseDomain))
 
 	server.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
 	const (
 		statusOK       = 200
 		statusNotFound = 404
+
+		statusUnavailable = 503
 	)
 
 	server.GET("/health", func(c *gin.Context) {
 		c.JSON(statusOK, gin.H{"status": "ok"})
 	})
 
+	// A degraded log buffer still serves traffic, so it is reported in the
+	// detail without failing readiness; missing RBAC tables do fail it.
+	authorizer := do.MustInvokeNamed[*authorization.Authorizer](injector, "authorizer")
+	server.GET("/ready", func(c *gin.Context) {
+		detail := gin.H{"logging": pkgLogger.Status()}
+		status, code := "ok", statusOK
+		startup, started := startupReadiness(gate)
+		detail["startup"] = startup
+		if !started {
+			status, code = "unavailable", statusUnavailable
+		}
+		if cfg.CheckRBACTables {
+			rbac, ready := rbacReadiness(c.Request.Context(), authorizer)
+			detail["rbac"] = rbac
+			if !ready {
+				status, code = "unavailable", statusUnavailable
+			}
+		}
+		c.JSON(code, gin.H{
+			"status": status,
+			"detail": detail,
+		})
+	})
+
 	api := server.Group("/api")
 	{
-		account.RegisterRoutes(api, injector)
+		if err := account.RegisterRoutes(api, account.NewContainer(injector)); err != nil {
+			logger.Error("failed to register account routes", "error", err)
+			os.Exit(1)
+		}
 	}
 
 	server.NoRoute(func(c *gin.Context) {
 		c.String(statusNotFound, "")
 	})
 
-	go run(server, logger, cfg)
+	srv := newHTTPServer(server, serveAddress(cfg), do.MustInvokeNamed[*eventstream.Broker](injector, "event-broker"))
+	go run(srv, logger)
 
 	<-ctx.Done()
+	report.begin(time.Now(), inFlight.Load())
 	logger.Info("shutting down server")
+
+	shutdownCtx, cancelShutdown = newShutdownContext(cfg)
+	err = srv.Shutdown(shutdownCtx)
+	if err != nil {
+		logger.Error("failed to drain http server", "error", err)
+	}
+	report.drainHTTP(err)
 }
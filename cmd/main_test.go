@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/elskow/go-microservice-template/config"
+	"github.com/elskow/go-microservice-template/modules/account/authorization"
+	"github.com/elskow/go-microservice-template/pkg/constants"
+	"github.com/elskow/go-microservice-template/pkg/database/dbtest"
+	"github.com/elskow/go-microservice-template/pkg/eventstream"
+	"github.com/elskow/go-microservice-template/pkg/readiness"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewShutdownContext_UsesConfiguredTimeout(t *testing.T) {
+	cfg := &config.Config{ShutdownTimeoutSeconds: 30}
+
+	start := time.Now()
+	ctx, cancel := newShutdownContext(cfg)
+	defer cancel()
+
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok)
+	assert.WithinDuration(t, start.Add(30*time.Second), deadline, time.Second)
+}
+
+func TestNewShutdownContext_DefaultsWhenUnset(t *testing.T) {
+	cfg := &config.Config{}
+
+	start := time.Now()
+	ctx, cancel := newShutdownContext(cfg)
+	defer cancel()
+
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok)
+	assert.WithinDuration(t, start.Add(constants.DefaultShutdownTimeout), deadline, time.Second)
+}
+
+func TestShutdownWithin(t *testing.T) {
+	failed := errors.New("close failed")
+	err := shutdownWithin(context.Background(), func() error { return failed })
+	assert.ErrorIs(t, err, failed)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	release := make(chan struct{})
+	defer close(release)
+
+	start := time.Now()
+	err = shutdownWithin(ctx, func() error {
+		<-release
+		return nil
+	})
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestNewHTTPServer_ShutdownEndsOpenStreams(t *testing.T) {
+	broker := eventstream.NewBroker(eventstream.DefaultBufferSize)
+	subscribed := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		events, unsubscribe := broker.Subscribe("user-a")
+		defer unsubscribe()
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		close(subscribed)
+		for range events {
+		}
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := newHTTPServer(handler, ln.Addr().String(), broker)
+	go func() { _ = srv.Serve(ln) }()
+
+	resp, err := http.Get("http://" + ln.Addr().String())
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	<-subscribed
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	assert.NoError(t, srv.Shutdown(ctx))
+}
+
+func TestStartupReadiness_FlipsOnceInitializersFinish(t *testing.T) {
+	gate := readiness.NewGate()
+	rbacDone := gate.Register("rbac")
+	cacheDone := gate.Register("authz-cache")
+
+	detail, ready := startupReadiness(gate)
+	assert.False(t, ready)
+	assert.Equal(t, gin.H{"status": "starting", "pending": []string{"authz-cache", "rbac"}}, detail)
+
+	rbacDone()
+	detail, ready = startupReadiness(gate)
+	assert.False(t, ready)
+	assert.Equal(t, gin.H{"status": "starting", "pending": []string{"authz-cache"}}, detail)
+
+	cacheDone()
+	detail, ready = startupReadiness(gate)
+	assert.True(t, ready)
+	assert.Equal(t, gin.H{"status": "ok"}, detail)
+}
+
+func TestRBACReadiness(t *testing.T) {
+	const query = `SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema()`
+
+	tests := []struct {
+		name      string
+		tables    []string
+		err       error
+		wantReady bool
+		wantState string
+	}{
+		{name: "all tables present", tables: authorization.RequiredTables, wantReady: true, wantState: "ok"},
+		{name: "tables missing", tables: []string{"users"}, wantReady: false, wantState: "missing"},
+		{name: "lookup failing", err: errors.New("connection refused"), wantReady: true, wantState: "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
+			defer cleanup()
+			authorizer := authorization.NewAuthorizer(db, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			expect := mock.ExpectQuery(query)
+			if tt.err != nil {
+				expect.WillReturnError(tt.err)
+			} else {
+				rows := sqlmock.NewRows([]string{"table_name"})
+				for _, table := range tt.tables {
+					rows.AddRow(table)
+				}
+				expect.WillReturnRows(rows)
+			}
+
+			detail, ready := rbacReadiness(context.Background(), authorizer)
+
+			assert.Equal(t, tt.wantReady, ready)
+			assert.Equal(t, tt.wantState, detail["status"])
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+)
+
+const (
+	subsystemOK     = "ok"
+	subsystemFailed = "failed"
+)
+
+// shutdownReport collects the outcome of graceful shutdown so it can be
+// logged as a single summary once every subsystem has stopped.
+type shutdownReport struct {
+	start            time.Time
+	inFlightAtSignal int64
+	httpErr          error
+	subsystems       []subsystemResult
+}
+
+type subsystemResult struct {
+	name string
+	err  error
+}
+
+// begin marks the moment the shutdown signal arrived.
+func (r *shutdownReport) begin(start time.Time, inFlight int64) {
+	r.start = start
+	r.inFlightAtSignal = inFlight
+}
+
+// started reports whether the server was signalled; one-off commands such
+// as --migrate exit without serving and have nothing to report.
+func (r *shutdownReport) started() bool {
+	return !r.start.IsZero()
+}
+
+func (r *shutdownReport) drainHTTP(err error) {
+	r.httpErr = err
+	r.record("http", err)
+}
+
+func (r *shutdownReport) record(name string, err error) {
+	r.subsystems = append(r.subsystems, subsystemResult{name: name, err: err})
+}
+
+// attrs assembles the summary log attributes: total time since the signal,
+// whether HTTP drained, in-flight requests at the signal, logs dropped by
+// the async log buffer and each subsystem's status.
+func (r *shutdownReport) attrs(end time.Time, logsDropped int64) []any {
+	clean := true
+	subsystems := make([]any, 0, len(r.subsystems))
+	for _, s := range r.subsystems {
+		status := subsystemOK
+		if s.err != nil {
+			status = subsystemFailed
+			clean = false
+		}
+		subsystems = append(subsystems, slog.String(s.name, status))
+	}
+
+	return []any{
+		"clean", clean,
+		"duration_ms", end.Sub(r.start).Milliseconds(),
+		"http_drained", r.httpErr == nil,
+		"in_flight_at_signal", r.inFlightAtSignal,
+		"logs_dropped", logsDropped,
+		slog.Group("subsystems", subsystems...),
+	}
+}
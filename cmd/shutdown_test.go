@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func logShutdownReport(t *testing.T, report *shutdownReport, end time.Time, logsDropped int64) map[string]any {
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("shutdown complete", report.attrs(end, logsDropped)...)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	return entry
+}
+
+func TestShutdownReport_Clean(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var report shutdownReport
+	report.begin(start, 3)
+	report.drainHTTP(nil)
+	report.record("dependencies", nil)
+	report.record("logger", nil)
+
+	entry := logShutdownReport(t, &report, start.Add(1500*time.Millisecond), 0)
+
+	assert.Equal(t, true, entry["clean"])
+	assert.Equal(t, float64(1500), entry["duration_ms"])
+	assert.Equal(t, true, entry["http_drained"])
+	assert.Equal(t, float64(3), entry["in_flight_at_signal"])
+	assert.Equal(t, float64(0), entry["logs_dropped"])
+	assert.Equal(t, map[string]any{"http": "ok", "dependencies": "ok", "logger": "ok"}, entry["subsystems"])
+}
+
+func TestShutdownReport_Failures(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var report shutdownReport
+	report.begin(start, 7)
+	report.drainHTTP(errors.New("context deadline exceeded"))
+	report.record("dependencies", nil)
+	report.record("telemetry", errors.New("exporter unreachable"))
+
+	entry := logShutdownReport(t, &report, start.Add(30*time.Second), 42)
+
+	assert.Equal(t, false, entry["clean"])
+	assert.Equal(t, false, entry["http_drained"])
+	assert.Equal(t, float64(7), entry["in_flight_at_signal"])
+	assert.Equal(t, float64(42), entry["logs_dropped"])
+	assert.Equal(t, map[string]any{"http": "failed", "dependencies": "ok", "telemetry": "failed"}, entry["subsystems"])
+}
+
+func TestShutdownReport_NotStartedForCommands(t *testing.T) {
+	var report shutdownReport
+	assert.False(t, report.started())
+
+	report.begin(time.Now(), 0)
+	assert.True(t, report.started())
+}
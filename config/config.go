@@ -1,11 +1,17 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/caarlos0/env/v11"
+	"github.com/elskow/go-microservice-template/pkg/constants"
 	"github.com/joho/godotenv"
 )
 
@@ -17,10 +23,215 @@ type Config struct {
 	AppEnv     string `env:"APP_ENV" envDefault:"localhost"`
 	Port       string `env:"GOLANG_PORT" envDefault:"8888"`
 
+	// ServiceNamespace groups related services in shared observability
+	// backends (service.namespace). Omitted from resources when empty.
+	ServiceNamespace string `env:"SERVICE_NAMESPACE" envDefault:""`
+
+	// PublicBaseURL is the externally reachable origin of the service, e.g.
+	// https://app.example.com. Links in emails are built on it, so it is
+	// required while EmailEnabled; see ValidatePublicBaseURL.
+	PublicBaseURL string `env:"PUBLIC_BASE_URL" envDefault:""`
+
+	// ShutdownTimeoutSeconds bounds how long graceful shutdown may take to
+	// drain in-flight work and flush logs and telemetry.
+	ShutdownTimeoutSeconds int `env:"SHUTDOWN_TIMEOUT_SECONDS" envDefault:"5"`
+
+	// ExposeErrorDetails includes the underlying error in 500 responses.
+	// Only honoured in development; see ShouldExposeErrorDetails.
+	ExposeErrorDetails bool `env:"EXPOSE_ERROR_DETAILS" envDefault:"false"`
+
+	// TraceErrorBodies attaches the redacted JSON request body to the
+	// request span of 5xx responses. Only honoured in development, staging
+	// and localhost; see ShouldTraceErrorBodies.
+	TraceErrorBodies bool `env:"TRACE_ERROR_BODIES" envDefault:"false"`
+
+	// EnableDebugGC registers POST /api/account/admin/debug/gc, which forces
+	// a garbage collection. Only honoured in development, staging and
+	// localhost; see ShouldEnableDebugGC.
+	EnableDebugGC bool `env:"DEBUG_GC_ENDPOINT" envDefault:"false"`
+
+	// JSONInt64AsString serializes large integer response fields (counters,
+	// totals) as strings so JavaScript clients do not lose precision.
+	JSONInt64AsString bool `env:"JSON_INT64_AS_STRING" envDefault:"false"`
+
+	// RetryAfterHTTPDate sends Retry-After on 429 and 503 responses as an
+	// HTTP-date instead of delay seconds, for clients that only parse dates.
+	RetryAfterHTTPDate bool `env:"RETRY_AFTER_HTTP_DATE" envDefault:"false"`
+
+	// ResponseEnvelope selects how responses are serialized: "wrapped" puts
+	// the resource under "output" and errors under "error"; "bare" writes
+	// the resource directly and errors as a flat object.
+	ResponseEnvelope string `env:"RESPONSE_ENVELOPE" envDefault:"wrapped"`
+
 	// Security Settings
 	JWTSecret  string `env:"JWT_SECRET" envDefault:"Template"`
 	BcryptCost int    `env:"BCRYPT_COST" envDefault:"12"`
 
+	// SeedBcryptCost overrides BcryptCost for seeders only, so local seeding
+	// can trade hash strength for speed. Zero means use BcryptCost.
+	SeedBcryptCost int `env:"SEED_BCRYPT_COST" envDefault:"0"`
+
+	// SeedBatchSize is the number of users inserted per statement by the
+	// seeder; progress is logged after each batch.
+	SeedBatchSize int `env:"SEED_BATCH_SIZE" envDefault:"100"`
+
+	// RefreshSessionMaxAgeDays caps how long refresh-token rotation can keep
+	// a session alive after login. Zero disables the cap.
+	RefreshSessionMaxAgeDays int `env:"REFRESH_SESSION_MAX_AGE_DAYS" envDefault:"30"`
+
+	// JWTAllowedAlgorithms is the comma-separated set of "alg" header values
+	// accepted when validating tokens. Anything else (including "none") is rejected.
+	JWTAllowedAlgorithms string `env:"JWT_ALLOWED_ALGORITHMS" envDefault:"HS256"`
+
+	// AdminTokenAudience, when set, is minted into the "aud" claim of
+	// admin-role access tokens and required on the /account/admin routes;
+	// other tokens get a 403 there. Tokens naming any other audience are
+	// rejected outright.
+	AdminTokenAudience string `env:"ADMIN_TOKEN_AUDIENCE" envDefault:""`
+
+	// TokenVersionCacheTTLSeconds is how long a user's token version is
+	// cached by authentication. A bump is seen at once by this instance but
+	// may take up to the TTL on others. Zero disables the cache.
+	TokenVersionCacheTTLSeconds int `env:"TOKEN_VERSION_CACHE_TTL_SECONDS" envDefault:"10"`
+
+	// UserCacheTTLSeconds is how long GetUserByID results, such as the
+	// profile /me returns, are cached. Updates and deletes on this instance
+	// drop the entry; others may serve the old profile for up to the TTL.
+	// Zero, the default, disables the cache.
+	UserCacheTTLSeconds int `env:"USER_CACHE_TTL_SECONDS" envDefault:"0"`
+
+	// Cookie-based auth. When AuthCookieMode is on, login, registration and
+	// refresh also set the tokens in the named HttpOnly, SameSite=Strict
+	// cookies; requests without an Authorization header authenticate with
+	// the access cookie, refresh accepts the refresh cookie in place of a
+	// body, and logout expires both. AuthCookieDomain empty scopes them to
+	// the host.
+	AuthCookieMode    bool   `env:"AUTH_COOKIE_MODE" envDefault:"false"`
+	AuthAccessCookie  string `env:"AUTH_ACCESS_COOKIE" envDefault:"access_token"`
+	AuthRefreshCookie string `env:"AUTH_REFRESH_COOKIE" envDefault:"refresh_token"`
+	AuthCookieDomain  string `env:"AUTH_COOKIE_DOMAIN" envDefault:""`
+	AuthCookieSecure  bool   `env:"AUTH_COOKIE_SECURE" envDefault:"true"`
+
+	// Two-factor authentication. TwoFactorEncryptionKey encrypts stored TOTP
+	// secrets; while it is empty enrollment and two-factor logins are
+	// refused. Deployments that relied on the old JWTSecret fallback must set
+	// it to that secret. TwoFactorIssuer is the account label shown in
+	// authenticator apps and defaults to AppName.
+	TwoFactorEncryptionKey string `env:"TWO_FACTOR_ENCRYPTION_KEY" envDefault:""`
+	TwoFactorIssuer        string `env:"TWO_FACTOR_ISSUER" envDefault:""`
+
+	// Global authentication: when enabled every route requires a valid token
+	// unless its path is listed in AuthPublicPaths.
+	EnableGlobalAuth bool   `env:"ENABLE_GLOBAL_AUTH" envDefault:"false"`
+	AuthPublicPaths  string `env:"AUTH_PUBLIC_PATHS" envDefault:"/api/account/register,/api/account/login,/api/account/refresh,/api/account/verify,/api/account/password/reset-request,/api/account/password/reset,/health,/ready,/metrics"`
+
+	// Tenancy Settings
+	// TenantHeader carries the tenant ID for unauthenticated requests; for
+	// authenticated ones it must match the token's tenant_id claim.
+	// TenantBaseDomain, when set, also resolves the tenant from the
+	// subdomain (acme.example.com -> acme).
+	TenantHeader     string `env:"TENANT_HEADER" envDefault:"X-Tenant-ID"`
+	TenantBaseDomain string `env:"TENANT_BASE_DOMAIN" envDefault:""`
+	// UserEmailScope decides whether an email may be registered once per
+	// tenant ("tenant") or once across all tenants ("global").
+	UserEmailScope string `env:"USER_EMAIL_SCOPE" envDefault:"tenant"`
+
+	// MaxInFlightRequests caps concurrently served requests; excess requests
+	// are shed with a 503. 0 disables load shedding.
+	MaxInFlightRequests int `env:"MAX_IN_FLIGHT_REQUESTS" envDefault:"0"`
+
+	// RequestTimeoutSeconds bounds each request with a context deadline; 0
+	// disables it. Paths in RequestTimeoutExemptPaths, such as streaming
+	// exports and the event stream, run without one.
+	RequestTimeoutSeconds     int    `env:"REQUEST_TIMEOUT_SECONDS" envDefault:"30"`
+	RequestTimeoutExemptPaths string `env:"REQUEST_TIMEOUT_EXEMPT_PATHS" envDefault:"/api/account/users/export,/api/account/events"`
+
+	// HTTPS enforcement behind a TLS-terminating proxy: when enabled,
+	// requests whose X-Forwarded-Proto is not https are redirected (or
+	// rejected with a 400 when HTTPSRedirect is false). The header is only
+	// trusted from TrustedProxies, comma-separated IPs or CIDRs; paths in
+	// HTTPSExemptPaths, such as probes hitting the pod directly, are let
+	// through. TrustedProxies also decides whose X-Forwarded-For the
+	// client IP is read from.
+	EnforceHTTPS     bool   `env:"ENFORCE_HTTPS" envDefault:"false"`
+	HTTPSRedirect    bool   `env:"HTTPS_REDIRECT" envDefault:"true"`
+	TrustedProxies   string `env:"TRUSTED_PROXIES" envDefault:"127.0.0.1,::1"`
+	HTTPSExemptPaths string `env:"HTTPS_EXEMPT_PATHS" envDefault:"/health,/ready,/metrics"`
+
+	// MaintenanceMode makes the service reject writes with a 503 telling
+	// clients to retry after MaintenanceRetryAfterSeconds. The flag is kept
+	// in the database and shared by every replica: setting it here turns
+	// maintenance on for all of them when this one starts, and admins can
+	// toggle it at runtime through PUT /account/admin/maintenance. Replicas
+	// pick up a change within MaintenanceSyncIntervalSeconds. Login, token
+	// refresh and logout stay exempt by default so users can keep reading
+	// once their access token expires, and admins can still sign in to turn
+	// maintenance off.
+	MaintenanceMode                bool   `env:"MAINTENANCE_MODE" envDefault:"false"`
+	MaintenanceRetryAfterSeconds   int    `env:"MAINTENANCE_RETRY_AFTER_SECONDS" envDefault:"300"`
+	MaintenanceSyncIntervalSeconds int    `env:"MAINTENANCE_SYNC_INTERVAL_SECONDS" envDefault:"5"`
+	MaintenanceExemptPaths         string `env:"MAINTENANCE_EXEMPT_PATHS" envDefault:"/api/account/admin/maintenance,/api/account/login,/api/account/refresh,/api/account/logout"`
+
+	// MetricsStreamRoutes lists long-lived streaming routes, as
+	// "METHOD /full/path", counted as open connections instead of being
+	// recorded in the request duration histogram.
+	MetricsStreamRoutes string `env:"METRICS_STREAM_ROUTES" envDefault:"GET /api/account/events"`
+
+	// EventStreamBufferSize is how many events are queued per event-stream
+	// client; a client falling further behind is disconnected.
+	EventStreamBufferSize int `env:"EVENT_STREAM_BUFFER_SIZE" envDefault:"16"`
+
+	// Login throttling: per-IP attempts allowed on POST /account/login within
+	// each window, separate from any general rate limiting. 0 disables it.
+	LoginThrottleLimit         int `env:"LOGIN_THROTTLE_LIMIT" envDefault:"5"`
+	LoginThrottleWindowSeconds int `env:"LOGIN_THROTTLE_WINDOW_SECONDS" envDefault:"60"`
+
+	// Password reset throttling on POST /account/password/reset-request,
+	// counted per client IP and per requested email within each window. A
+	// limit of 0 disables that half.
+	PasswordResetThrottleIPLimit       int `env:"PASSWORD_RESET_THROTTLE_IP_LIMIT" envDefault:"10"`
+	PasswordResetThrottleEmailLimit    int `env:"PASSWORD_RESET_THROTTLE_EMAIL_LIMIT" envDefault:"3"`
+	PasswordResetThrottleWindowSeconds int `env:"PASSWORD_RESET_THROTTLE_WINDOW_SECONDS" envDefault:"3600"`
+
+	// CAPTCHA verification on register/login. CaptchaVerifyURL is any
+	// reCAPTCHA-compatible siteverify endpoint (reCAPTCHA, hCaptcha, Turnstile).
+	CaptchaEnabled   bool   `env:"CAPTCHA_ENABLED" envDefault:"false"`
+	CaptchaVerifyURL string `env:"CAPTCHA_VERIFY_URL" envDefault:"https://www.google.com/recaptcha/api/siteverify"`
+	CaptchaSecret    string `env:"CAPTCHA_SECRET" envDefault:""`
+
+	// Security event webhooks. When enabled, events such as logins from a
+	// new device are POSTed as JSON, signed with WebhookSecret, to every URL
+	// in the comma-separated WebhookURLs.
+	WebhookEnabled        bool   `env:"WEBHOOK_ENABLED" envDefault:"false"`
+	WebhookURLs           string `env:"WEBHOOK_URLS" envDefault:""`
+	WebhookSecret         string `env:"WEBHOOK_SECRET" envDefault:""`
+	WebhookTimeoutSeconds int    `env:"WEBHOOK_TIMEOUT_SECONDS" envDefault:"5"`
+
+	// Transactional outbox. Events are written to the outbox table with the
+	// change that raised them and published to the webhooks by a poller
+	// that sends up to OutboxBatchSize events every OutboxPollIntervalSeconds.
+	// A claimed batch is not picked up again for OutboxClaimLeaseSeconds,
+	// which should cover delivering it; events whose lease runs out are
+	// delivered again. Sent events are deleted after OutboxRetentionHours;
+	// 0 keeps them.
+	OutboxPollIntervalSeconds int `env:"OUTBOX_POLL_INTERVAL_SECONDS" envDefault:"5"`
+	OutboxBatchSize           int `env:"OUTBOX_BATCH_SIZE" envDefault:"100"`
+	OutboxClaimLeaseSeconds   int `env:"OUTBOX_CLAIM_LEASE_SECONDS" envDefault:"300"`
+	OutboxRetentionHours      int `env:"OUTBOX_RETENTION_HOURS" envDefault:"168"`
+
+	// Transactional email (address verification, password reset). While
+	// disabled, messages are discarded instead of relayed through SMTP.
+	EmailEnabled       bool   `env:"EMAIL_ENABLED" envDefault:"false"`
+	EmailFrom          string `env:"EMAIL_FROM" envDefault:"noreply@example.com"`
+	SMTPHost           string `env:"SMTP_HOST" envDefault:"localhost"`
+	SMTPPort           int    `env:"SMTP_PORT" envDefault:"587"`
+	SMTPUsername       string `env:"SMTP_USERNAME" envDefault:""`
+	SMTPPassword       string `env:"SMTP_PASSWORD" envDefault:""`
+	SMTPTimeoutSeconds int    `env:"SMTP_TIMEOUT_SECONDS" envDefault:"10"`
+	// EmailTemplateDir may hold verify_email.html, password_reset.html or
+	// notification.html to replace the embedded templates of the same name.
+	EmailTemplateDir string `env:"EMAIL_TEMPLATE_DIR" envDefault:""`
+
 	// Database Settings
 	DBHost               string `env:"DB_HOST" envDefault:"localhost"`
 	DBPort               string `env:"DB_PORT" envDefault:"5432"`
@@ -32,21 +243,106 @@ type Config struct {
 	DBConnMaxLifetimeMin int    `env:"DB_CONN_MAX_LIFETIME_MIN" envDefault:"0"`
 	DBConnMaxIdleTimeMin int    `env:"DB_CONN_MAX_IDLE_TIME_MIN" envDefault:"0"`
 
+	// Database circuit breaker: after DBBreakerFailureThreshold consecutive
+	// failures queries fast-fail for DBBreakerCooldownSeconds before a probe.
+	DBBreakerEnabled          bool `env:"DB_BREAKER_ENABLED" envDefault:"false"`
+	DBBreakerFailureThreshold int  `env:"DB_BREAKER_FAILURE_THRESHOLD" envDefault:"5"`
+	DBBreakerCooldownSeconds  int  `env:"DB_BREAKER_COOLDOWN_SECONDS" envDefault:"30"`
+
+	// Read queries in the repository are retried on transient errors.
+	DBReadRetryAttempts  int `env:"DB_READ_RETRY_ATTEMPTS" envDefault:"3"`
+	DBReadRetryBackoffMs int `env:"DB_READ_RETRY_BACKOFF_MS" envDefault:"50"`
+
+	// Migration Settings
+	CheckMigrationsOnStartup bool `env:"CHECK_MIGRATIONS_ON_STARTUP" envDefault:"false"`
+	AutoMigrate              bool `env:"AUTO_MIGRATE" envDefault:"false"`
+
+	// CheckRBACTables verifies at startup and on /ready that the roles and
+	// permissions tables exist; without them every permission check fails.
+	CheckRBACTables bool `env:"CHECK_RBAC_TABLES" envDefault:"true"`
+
+	// DefaultRolePermissions are granted at startup to the role every new
+	// user receives, when it lacks them, so users of a fresh deployment can
+	// at least manage their own profile. Empty skips the check.
+	DefaultRolePermissions string `env:"DEFAULT_ROLE_PERMISSIONS" envDefault:"user.read,user.update"`
+
+	// ValidatePermissionsOnStartup warns about permissions checked by
+	// handlers that do not exist in the database.
+	ValidatePermissionsOnStartup bool `env:"VALIDATE_PERMISSIONS_ON_STARTUP" envDefault:"false"`
+
 	// Cache Configuration
 	CacheTTLMinutes             int `env:"CACHE_TTL_MINUTES" envDefault:"5"`
 	CacheCleanupIntervalMinutes int `env:"CACHE_CLEANUP_INTERVAL_MINUTES" envDefault:"10"`
 
+	// AuthzServeStaleOnDBError lets permission checks fall back to an
+	// expired cache entry when the database cannot be queried, instead of
+	// failing. Revocations are missed until the database recovers.
+	AuthzServeStaleOnDBError bool `env:"AUTHZ_SERVE_STALE_ON_DB_ERROR" envDefault:"false"`
+
+	// AuthzMaxStaleMinutes is how old a cached entry may grow and still be
+	// served under AuthzServeStaleOnDBError. Cache cleanup keeps expired
+	// entries until then instead of dropping them at the TTL. It is raised
+	// to CacheTTLMinutes when set below it.
+	AuthzMaxStaleMinutes int `env:"AUTHZ_MAX_STALE_MINUTES" envDefault:"60"`
+
+	// AuthzFailOpenPermissions lists the permissions whose checks are
+	// granted, with a warning, when the user's permissions cannot be
+	// loaded. Every other check fails closed.
+	AuthzFailOpenPermissions string `env:"AUTHZ_FAIL_OPEN_PERMISSIONS" envDefault:""`
+
+	// AuthzWarmupUsers is how many of the most recently logged-in users
+	// have their permissions cached at startup; 0 disables the warm-up.
+	// See AuthzWarmupUserCount for the upper bound.
+	AuthzWarmupUsers int `env:"AUTHZ_WARMUP_USERS" envDefault:"0"`
+
 	// Observability Settings
-	OTELExporterEndpoint string  `env:"OTEL_EXPORTER_OTLP_ENDPOINT" envDefault:"alloy:4318"`
+	OTELExporterEndpoint string `env:"OTEL_EXPORTER_OTLP_ENDPOINT" envDefault:"alloy:4318"`
+	// OTELLogsEndpoint is the host:port OTLP logs are sent to. When empty it
+	// is derived from OTELExporterEndpoint, swapping port 4318 for 4319.
+	OTELLogsEndpoint     string  `env:"OTEL_LOGS_ENDPOINT" envDefault:""`
 	OTELSamplingStrategy string  `env:"OTEL_SAMPLING_STRATEGY" envDefault:"ratio"`
 	OTELSamplingRate     float64 `env:"OTEL_SAMPLING_RATE" envDefault:"0.1"`
+	// OTELSampleAlwaysPaths and OTELSampleNeverPaths override the sampling
+	// strategy for requests to those routes, comma-separated.
+	OTELSampleAlwaysPaths string `env:"OTEL_SAMPLE_ALWAYS_PATHS" envDefault:""`
+	OTELSampleNeverPaths  string `env:"OTEL_SAMPLE_NEVER_PATHS" envDefault:""`
+
+	// OTELAllowPartialInit lets the service start with traces only when the
+	// metric exporters fail to initialize, instead of exiting. Spans are
+	// then tagged telemetry.degraded=true.
+	OTELAllowPartialInit bool `env:"OTEL_ALLOW_PARTIAL_INIT" envDefault:"false"`
+
+	// OTELLegacyTraceHeader names a non-W3C header, such as uber-trace-id,
+	// whose trace the request span is linked to. Empty disables it.
+	OTELLegacyTraceHeader string `env:"OTEL_LEGACY_TRACE_HEADER" envDefault:""`
+
+	// OTELSpanAttributes are key=value pairs set on every span, such as
+	// region=eu-west-1,cluster=prod-a.
+	OTELSpanAttributes string `env:"OTEL_SPAN_ATTRIBUTES" envDefault:""`
+
+	// OTELSpanAttributeValueLimit caps the length of string attribute values
+	// on spans; longer values are truncated. Zero or less disables the cap.
+	OTELSpanAttributeValueLimit int `env:"OTEL_SPAN_ATTRIBUTE_VALUE_LIMIT" envDefault:"4096"`
 
 	// Logging Settings
 	EnableStdoutLogs  bool   `env:"ENABLE_STDOUT_LOGS" envDefault:"true"`
 	EnableOTLPLogs    bool   `env:"ENABLE_OTLP_LOGS" envDefault:"true"`
 	LogBufferSize     int    `env:"LOG_BUFFER_SIZE" envDefault:"5000"`
+	LogWorkers        int    `env:"LOG_WORKERS" envDefault:"1"`
 	LogDropOnFull     bool   `env:"LOG_DROP_ON_FULL" envDefault:"true"`
 	LogBlacklistPaths string `env:"LOG_BLACKLIST_PATHS" envDefault:""`
+	// LogAddSource attaches the caller's file and line to stdout logs. It
+	// only takes effect in development.
+	LogAddSource bool `env:"LOG_ADD_SOURCE" envDefault:"false"`
+	// LogRedactKeys are attribute keys whose values are replaced before
+	// stdout logs are written, matched case-insensitively.
+	LogRedactKeys string `env:"LOG_REDACT_KEYS" envDefault:"password,token,access_token,refresh_token,authorization,secret,code,totp_code,backup_code,backup_codes"`
+	// LogRenameKeys renames top-level attribute keys, as old=new pairs
+	// (e.g. "msg=message,time=timestamp").
+	LogRenameKeys string `env:"LOG_RENAME_KEYS" envDefault:""`
+	// LogBufferHighWaterPercent is the buffer utilization at which a warning
+	// is logged, ahead of records being dropped. 0 disables the check.
+	LogBufferHighWaterPercent int `env:"LOG_BUFFER_HIGH_WATER_PERCENT" envDefault:"80"`
 
 	// Profiling Settings
 	EnableProfiling     bool   `env:"ENABLE_PROFILING" envDefault:"true"`
@@ -54,21 +350,78 @@ type Config struct {
 
 	// Performance Configuration
 	MetricsCollectionIntervalSeconds int `env:"METRICS_COLLECTION_INTERVAL_SECONDS" envDefault:"15"`
+	// RuntimeStatsSource selects how heap and GC stats are read:
+	// "memstats" uses runtime.ReadMemStats, which briefly stops the world,
+	// "runtime-metrics" the lighter runtime/metrics package.
+	RuntimeStatsSource string `env:"RUNTIME_STATS_SOURCE" envDefault:"memstats"`
 }
 
-var appConfig *Config
+var (
+	// configMu guards appConfig so concurrent Get calls during startup load
+	// the configuration once.
+	configMu  sync.RWMutex
+	appConfig *Config
+)
+
+// Values accepted by RESPONSE_ENVELOPE.
+const (
+	EnvelopeWrapped = "wrapped"
+	EnvelopeBare    = "bare"
+)
+
+// Values accepted by RUNTIME_STATS_SOURCE.
+const (
+	RuntimeStatsMemStats       = "memstats"
+	RuntimeStatsRuntimeMetrics = "runtime-metrics"
+)
+
+// Values accepted by USER_EMAIL_SCOPE.
+const (
+	EmailScopeTenant = "tenant"
+	EmailScopeGlobal = "global"
+)
 
 // Load loads configuration from environment variables
 // It always reloads the configuration (useful for tests)
 func Load() *Config {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	return loadLocked()
+}
+
+// loadLocked parses the configuration and caches it, exiting when it does
+// not parse. configMu must be held for writing.
+func loadLocked() *Config {
+	cfg, err := parseLocked()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	appConfig = cfg
+	return cfg
+}
+
+// Parse reads the configuration like Load but returns parse errors instead
+// of exiting, and leaves the configuration returned by Get untouched.
+func Parse() (*Config, error) {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	return parseLocked()
+}
+
+// parseLocked reads and normalizes the configuration. configMu must be
+// held for writing, since it updates the environment from .env.
+func parseLocked() (*Config, error) {
 	// Load .env file if not running in docker
 	if os.Getenv("APP_ENV") != "docker" {
-		_ = godotenv.Load(".env")
+		loadEnvFile(".env")
 	}
 
 	cfg := &Config{}
 	if err := env.Parse(cfg); err != nil {
-		log.Fatalf("Failed to parse configuration: %v", err)
+		return nil, fmt.Errorf("failed to parse configuration: %w", err)
 	}
 
 	// Validate sampling rate
@@ -84,41 +437,462 @@ func Load() *Config {
 		cfg.BcryptCost = 31
 	}
 
-	appConfig = cfg
-	return cfg
+	// The seed override skips the production minimum but must stay within
+	// what bcrypt accepts.
+	if cfg.SeedBcryptCost < 0 {
+		cfg.SeedBcryptCost = 0
+	}
+	if cfg.SeedBcryptCost > 0 && cfg.SeedBcryptCost < 4 {
+		cfg.SeedBcryptCost = 4
+	}
+	if cfg.SeedBcryptCost > 31 {
+		cfg.SeedBcryptCost = 31
+	}
+	if cfg.SeedBatchSize < 1 {
+		cfg.SeedBatchSize = 100
+	}
+
+	if cfg.LogBufferHighWaterPercent < 0 || cfg.LogBufferHighWaterPercent > 100 {
+		log.Printf("config: LOG_BUFFER_HIGH_WATER_PERCENT=%d is outside 0-100, using 80", cfg.LogBufferHighWaterPercent)
+		cfg.LogBufferHighWaterPercent = 80
+	}
+
+	if cfg.RuntimeStatsSource != RuntimeStatsMemStats && cfg.RuntimeStatsSource != RuntimeStatsRuntimeMetrics {
+		log.Printf("config: RUNTIME_STATS_SOURCE=%q is not memstats or runtime-metrics, using memstats", cfg.RuntimeStatsSource)
+		cfg.RuntimeStatsSource = RuntimeStatsMemStats
+	}
+
+	if cfg.ResponseEnvelope != EnvelopeWrapped && cfg.ResponseEnvelope != EnvelopeBare {
+		log.Printf("config: RESPONSE_ENVELOPE=%q is not wrapped or bare, using wrapped", cfg.ResponseEnvelope)
+		cfg.ResponseEnvelope = EnvelopeWrapped
+	}
+
+	if cfg.UserEmailScope != EmailScopeTenant && cfg.UserEmailScope != EmailScopeGlobal {
+		log.Printf("config: USER_EMAIL_SCOPE=%q is not tenant or global, using tenant", cfg.UserEmailScope)
+		cfg.UserEmailScope = EmailScopeTenant
+	}
+
+	clampDurations(cfg)
+
+	return cfg, nil
+}
+
+// envFileKeys records the variables taken from the .env file rather than
+// the process environment. configMu guards it.
+var envFileKeys = map[string]bool{}
+
+// loadEnvFile sets the variables in path that the process environment does
+// not define, like godotenv.Load, but also refreshes ones an earlier load
+// took from the file, so edits to it are seen when Load runs again.
+func loadEnvFile(path string) {
+	values, err := godotenv.Read(path)
+	if err != nil {
+		return
+	}
+	for key, value := range values {
+		if _, set := os.LookupEnv(key); set && !envFileKeys[key] {
+			continue
+		}
+		_ = os.Setenv(key, value)
+		envFileKeys[key] = true
+	}
 }
 
 // Reset resets the configuration cache (useful for testing)
 func Reset() {
+	configMu.Lock()
+	defer configMu.Unlock()
+
 	appConfig = nil
 }
 
-// Get returns the loaded configuration
+// Get returns the loaded configuration, loading it on first use
 func Get() *Config {
+	configMu.RLock()
+	cfg := appConfig
+	configMu.RUnlock()
+	if cfg != nil {
+		return cfg
+	}
+
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	// Another caller may have loaded it while we waited for the lock
 	if appConfig == nil {
-		return Load()
+		return loadLocked()
 	}
 	return appConfig
 }
 
 // Convenience getters for commonly used values
 
+// durationSetting is an integer env value backing a duration getter. Load
+// raises values below min, since zero or negative durations silently break
+// the feature using them (a 0 cache TTL means every entry is already expired).
+type durationSetting struct {
+	env   string
+	value *int
+	min   int
+}
+
+func (c *Config) durationSettings() []durationSetting {
+	return []durationSetting{
+		{env: "SHUTDOWN_TIMEOUT_SECONDS", value: &c.ShutdownTimeoutSeconds, min: 1},
+		{env: "LOGIN_THROTTLE_WINDOW_SECONDS", value: &c.LoginThrottleWindowSeconds, min: 1},
+		{env: "PASSWORD_RESET_THROTTLE_WINDOW_SECONDS", value: &c.PasswordResetThrottleWindowSeconds, min: 1},
+		{env: "DB_BREAKER_COOLDOWN_SECONDS", value: &c.DBBreakerCooldownSeconds, min: 1},
+		{env: "DB_READ_RETRY_BACKOFF_MS", value: &c.DBReadRetryBackoffMs, min: 0},
+		{env: "CACHE_TTL_MINUTES", value: &c.CacheTTLMinutes, min: 1},
+		{env: "CACHE_CLEANUP_INTERVAL_MINUTES", value: &c.CacheCleanupIntervalMinutes, min: 1},
+		// An entry is only stale once past the TTL, so a shorter bound would
+		// drop entries before they could ever be served stale
+		{env: "AUTHZ_MAX_STALE_MINUTES", value: &c.AuthzMaxStaleMinutes, min: max(c.CacheTTLMinutes, 1)},
+		{env: "METRICS_COLLECTION_INTERVAL_SECONDS", value: &c.MetricsCollectionIntervalSeconds, min: 1},
+		{env: "WEBHOOK_TIMEOUT_SECONDS", value: &c.WebhookTimeoutSeconds, min: 1},
+		{env: "OUTBOX_POLL_INTERVAL_SECONDS", value: &c.OutboxPollIntervalSeconds, min: 1},
+		{env: "OUTBOX_CLAIM_LEASE_SECONDS", value: &c.OutboxClaimLeaseSeconds, min: 1},
+		{env: "OUTBOX_RETENTION_HOURS", value: &c.OutboxRetentionHours, min: 0},
+		{env: "SMTP_TIMEOUT_SECONDS", value: &c.SMTPTimeoutSeconds, min: 1},
+		{env: "TOKEN_VERSION_CACHE_TTL_SECONDS", value: &c.TokenVersionCacheTTLSeconds, min: 0},
+		{env: "USER_CACHE_TTL_SECONDS", value: &c.UserCacheTTLSeconds, min: 0},
+		{env: "REQUEST_TIMEOUT_SECONDS", value: &c.RequestTimeoutSeconds, min: 0},
+		{env: "MAINTENANCE_RETRY_AFTER_SECONDS", value: &c.MaintenanceRetryAfterSeconds, min: 1},
+		{env: "MAINTENANCE_SYNC_INTERVAL_SECONDS", value: &c.MaintenanceSyncIntervalSeconds, min: 1},
+	}
+}
+
+// clampDurations raises every duration setting below its minimum, logging
+// each adjustment.
+func clampDurations(cfg *Config) {
+	for _, s := range cfg.durationSettings() {
+		if *s.value < s.min {
+			log.Printf("config: %s=%d is below the minimum, using %d", s.env, *s.value, s.min)
+			*s.value = s.min
+		}
+	}
+}
+
+func duration(value int, unit time.Duration) time.Duration {
+	return time.Duration(value) * unit
+}
+
 func (c *Config) CacheTTL() time.Duration {
-	return time.Duration(c.CacheTTLMinutes) * time.Minute
+	return duration(c.CacheTTLMinutes, time.Minute)
 }
 
 func (c *Config) CacheCleanupInterval() time.Duration {
-	return time.Duration(c.CacheCleanupIntervalMinutes) * time.Minute
+	return duration(c.CacheCleanupIntervalMinutes, time.Minute)
+}
+
+func (c *Config) AuthzMaxStale() time.Duration {
+	return duration(c.AuthzMaxStaleMinutes, time.Minute)
+}
+
+func (c *Config) LoginThrottleWindow() time.Duration {
+	return duration(c.LoginThrottleWindowSeconds, time.Second)
+}
+
+func (c *Config) PasswordResetThrottleWindow() time.Duration {
+	return duration(c.PasswordResetThrottleWindowSeconds, time.Second)
+}
+
+func (c *Config) WebhookTimeout() time.Duration {
+	return duration(c.WebhookTimeoutSeconds, time.Second)
+}
+
+// WebhookURLList returns the endpoints security events are delivered to.
+func (c *Config) WebhookURLList() []string {
+	return splitList(c.WebhookURLs)
+}
+
+func (c *Config) OutboxPollInterval() time.Duration {
+	return duration(c.OutboxPollIntervalSeconds, time.Second)
+}
+
+func (c *Config) OutboxClaimLease() time.Duration {
+	return duration(c.OutboxClaimLeaseSeconds, time.Second)
+}
+
+func (c *Config) OutboxRetention() time.Duration {
+	return duration(c.OutboxRetentionHours, time.Hour)
+}
+
+func (c *Config) SMTPTimeout() time.Duration {
+	return duration(c.SMTPTimeoutSeconds, time.Second)
 }
 
 func (c *Config) MetricsCollectionInterval() time.Duration {
-	return time.Duration(c.MetricsCollectionIntervalSeconds) * time.Second
+	return duration(c.MetricsCollectionIntervalSeconds, time.Second)
+}
+
+// ShutdownTimeout returns the graceful shutdown deadline, falling back to
+// constants.DefaultShutdownTimeout when the configured value is not positive.
+func (c *Config) ShutdownTimeout() time.Duration {
+	if c.ShutdownTimeoutSeconds > 0 {
+		return duration(c.ShutdownTimeoutSeconds, time.Second)
+	}
+	return constants.DefaultShutdownTimeout
+}
+
+// SeederBcryptCost returns the bcrypt cost used when hashing seeded
+// passwords: SEED_BCRYPT_COST when set, BCRYPT_COST otherwise.
+func (c *Config) SeederBcryptCost() int {
+	if c.SeedBcryptCost > 0 {
+		return c.SeedBcryptCost
+	}
+	return c.BcryptCost
+}
+
+func (c *Config) RequestTimeout() time.Duration {
+	return duration(c.RequestTimeoutSeconds, time.Second)
+}
+
+// RequestTimeoutExemptPathList returns the paths that run without a request
+// deadline.
+func (c *Config) RequestTimeoutExemptPathList() []string {
+	return splitList(c.RequestTimeoutExemptPaths)
+}
+
+// MaintenanceRetryAfter returns how long clients blocked by maintenance mode
+// are told to wait.
+func (c *Config) MaintenanceRetryAfter() time.Duration {
+	return duration(c.MaintenanceRetryAfterSeconds, time.Second)
+}
+
+// MaintenanceSyncInterval returns how often the shared maintenance flag is
+// reloaded from the database.
+func (c *Config) MaintenanceSyncInterval() time.Duration {
+	return duration(c.MaintenanceSyncIntervalSeconds, time.Second)
+}
+
+// MaintenanceExemptPathList returns the paths accepting writes during
+// maintenance.
+func (c *Config) MaintenanceExemptPathList() []string {
+	return splitList(c.MaintenanceExemptPaths)
+}
+
+// DefaultRolePermissionList returns the permission names ensured on the
+// default role.
+func (c *Config) DefaultRolePermissionList() []string {
+	return splitList(c.DefaultRolePermissions)
+}
+
+// AuthzFailOpenPermissionList returns the permissions whose checks fail
+// open.
+func (c *Config) AuthzFailOpenPermissionList() []string {
+	return splitList(c.AuthzFailOpenPermissions)
+}
+
+// maxAuthzWarmupUsers bounds AUTHZ_WARMUP_USERS so a misconfigured value
+// cannot pull a whole users table into the permission cache at startup.
+const maxAuthzWarmupUsers = 10000
+
+// AuthzWarmupUserCount returns how many users to warm the permission cache
+// with, between 0 and 10000.
+func (c *Config) AuthzWarmupUserCount() int {
+	return min(max(c.AuthzWarmupUsers, 0), maxAuthzWarmupUsers)
+}
+
+// OTELSpanAttributeValueLengthLimit returns the span attribute value length
+// limit in the SDK's terms, where -1 means unlimited.
+func (c *Config) OTELSpanAttributeValueLengthLimit() int {
+	if c.OTELSpanAttributeValueLimit <= 0 {
+		return -1
+	}
+	return c.OTELSpanAttributeValueLimit
+}
+
+// MetricsStreamRouteList returns the routes counted as open streams rather
+// than timed as requests.
+func (c *Config) MetricsStreamRouteList() []string {
+	return splitList(c.MetricsStreamRoutes)
+}
+
+// TrustedProxyList returns the proxies whose forwarding headers are trusted.
+func (c *Config) TrustedProxyList() []string {
+	return splitList(c.TrustedProxies)
+}
+
+// HTTPSExemptPathList returns the paths served over plain HTTP even while
+// HTTPS is enforced.
+func (c *Config) HTTPSExemptPathList() []string {
+	return splitList(c.HTTPSExemptPaths)
+}
+
+// AuthPublicPathList returns the paths that bypass global authentication.
+func (c *Config) AuthPublicPathList() []string {
+	return splitList(c.AuthPublicPaths)
+}
+
+// AccessTokenCookie returns the cookie access tokens are read from, or
+// empty outside cookie auth mode.
+func (c *Config) AccessTokenCookie() string {
+	if !c.AuthCookieMode {
+		return ""
+	}
+	return c.AuthAccessCookie
+}
+
+// RefreshSessionMaxAge returns the absolute session lifetime enforced on
+// refresh, or zero when uncapped.
+func (c *Config) RefreshSessionMaxAge() time.Duration {
+	if c.RefreshSessionMaxAgeDays <= 0 {
+		return 0
+	}
+	return time.Duration(c.RefreshSessionMaxAgeDays) * 24 * time.Hour
+}
+
+func (c *Config) TokenVersionCacheTTL() time.Duration {
+	return duration(c.TokenVersionCacheTTLSeconds, time.Second)
+}
+
+// UserCacheTTL is how long GetUserByID results are cached; zero disables
+// the cache.
+func (c *Config) UserCacheTTL() time.Duration {
+	return duration(c.UserCacheTTLSeconds, time.Second)
+}
+
+// LogBlacklistPathList returns the paths excluded from access logs and
+// traces.
+func (c *Config) LogBlacklistPathList() []string {
+	return splitList(c.LogBlacklistPaths)
+}
+
+// OTELSampleAlwaysPathList returns the routes traced whatever the sampling
+// strategy decides.
+func (c *Config) OTELSampleAlwaysPathList() []string {
+	return splitList(c.OTELSampleAlwaysPaths)
+}
+
+// OTELSampleNeverPathList returns the routes never traced.
+func (c *Config) OTELSampleNeverPathList() []string {
+	return splitList(c.OTELSampleNeverPaths)
+}
+
+// JWTAllowedAlgorithmList returns the accepted JWT signing algorithms.
+func (c *Config) JWTAllowedAlgorithmList() []string {
+	return splitList(c.JWTAllowedAlgorithms)
+}
+
+// TwoFactorKey returns the key TOTP secrets are encrypted with, empty when
+// two-factor authentication is not configured. It deliberately does not
+// fall back to JWTSecret, so leaking one secret does not expose the other.
+func (c *Config) TwoFactorKey() string {
+	return c.TwoFactorEncryptionKey
+}
+
+// ErrPublicBaseURLRequired is returned by ValidatePublicBaseURL when email
+// is enabled without an absolute http(s) PUBLIC_BASE_URL.
+var ErrPublicBaseURLRequired = errors.New("PUBLIC_BASE_URL must be an absolute http(s) URL when EMAIL_ENABLED=true")
+
+// ValidatePublicBaseURL reports whether PublicBaseURL can be used to build
+// the links sent by email. It is only required while email is enabled.
+func (c *Config) ValidatePublicBaseURL() error {
+	if !c.EmailEnabled {
+		return nil
+	}
+	u, err := url.Parse(c.PublicBaseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrPublicBaseURLRequired
+	}
+	return nil
+}
+
+// PublicURL returns path on PublicBaseURL. path must start with "/".
+func (c *Config) PublicURL(path string) string {
+	return strings.TrimRight(c.PublicBaseURL, "/") + path
+}
+
+// TwoFactorIssuerName returns the issuer shown in authenticator apps.
+func (c *Config) TwoFactorIssuerName() string {
+	if c.TwoFactorIssuer != "" {
+		return c.TwoFactorIssuer
+	}
+	return c.AppName
+}
+
+// LogRedactKeyList returns the attribute keys redacted from stdout logs.
+func (c *Config) LogRedactKeyList() []string {
+	return splitList(c.LogRedactKeys)
+}
+
+// LogRenameKeyMap returns the attribute key renames applied to stdout logs,
+// skipping entries that are not old=new pairs.
+func (c *Config) LogRenameKeyMap() map[string]string {
+	renames := make(map[string]string)
+	for _, pair := range splitList(c.LogRenameKeys) {
+		from, to, ok := strings.Cut(pair, "=")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || from == "" || to == "" {
+			continue
+		}
+		renames[from] = to
+	}
+	return renames
+}
+
+// OTELSpanAttributeMap returns the attributes set on every span, skipping
+// entries that are not key=value pairs. Values may be empty.
+func (c *Config) OTELSpanAttributeMap() map[string]string {
+	attrs := make(map[string]string)
+	for _, pair := range splitList(c.OTELSpanAttributes) {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			continue
+		}
+		attrs[key] = strings.TrimSpace(value)
+	}
+	return attrs
+}
+
+// splitList parses a comma-separated env value, trimming whitespace and
+// dropping empty entries.
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+
+	parts := strings.Split(value, ",")
+	items := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if trimmed := strings.TrimSpace(part); trimmed != "" {
+			items = append(items, trimmed)
+		}
+	}
+	return items
 }
 
 func (c *Config) IsDevelopment() bool {
 	return c.AppEnv == "dev" || c.AppEnv == "development"
 }
 
+// ShouldExposeErrorDetails reports whether internal error details may be
+// returned to clients. It is always false outside development.
+func (c *Config) ShouldExposeErrorDetails() bool {
+	return c.ExposeErrorDetails && c.IsDevelopment()
+}
+
+// IsPreProduction reports whether AppEnv names a development, staging or
+// localhost environment. Any other value, including an unrecognised one, is
+// treated as production.
+func (c *Config) IsPreProduction() bool {
+	return c.IsDevelopment() || c.AppEnv == "staging" || c.IsLocalhost()
+}
+
+// ShouldTraceErrorBodies reports whether request bodies of 5xx responses
+// are attached to traces. It is only true in pre-production environments.
+func (c *Config) ShouldTraceErrorBodies() bool {
+	return c.TraceErrorBodies && c.IsPreProduction()
+}
+
+// ShouldEnableDebugGC reports whether the manual garbage collection
+// endpoint is registered. It is only true in pre-production environments.
+func (c *Config) ShouldEnableDebugGC() bool {
+	return c.EnableDebugGC && c.IsPreProduction()
+}
+
 func (c *Config) IsLocalhost() bool {
 	return c.AppEnv == "localhost"
 }
@@ -130,6 +904,14 @@ func (c *Config) DBConnMaxLifetime() time.Duration {
 	return 0
 }
 
+func (c *Config) DBBreakerCooldown() time.Duration {
+	return duration(c.DBBreakerCooldownSeconds, time.Second)
+}
+
+func (c *Config) DBReadRetryBackoff() time.Duration {
+	return duration(c.DBReadRetryBackoffMs, time.Millisecond)
+}
+
 func (c *Config) DBConnMaxIdleTime() time.Duration {
 	if c.DBConnMaxIdleTimeMin > 0 {
 		return time.Duration(c.DBConnMaxIdleTimeMin) * time.Minute
@@ -0,0 +1,304 @@
+package config
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Run with -race: concurrent first calls to Get must not race on appConfig
+// and must all observe the same configuration.
+func TestGet_Concurrent(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+
+	const goroutines = 50
+	results := make([]*Config, goroutines)
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			results[i] = Get()
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	for _, cfg := range results {
+		assert.Same(t, results[0], cfg)
+	}
+}
+
+func TestReset_ReloadsOnNextGet(t *testing.T) {
+	t.Setenv("APP_NAME", "first")
+	t.Cleanup(Reset)
+
+	first := Load()
+	assert.Same(t, first, Get())
+
+	t.Setenv("APP_NAME", "second")
+	Reset()
+
+	assert.Equal(t, "second", Get().AppName)
+}
+
+func TestParse_LeavesLoadedConfigInPlace(t *testing.T) {
+	t.Setenv("APP_NAME", "first")
+	t.Cleanup(Reset)
+	loaded := Load()
+
+	t.Setenv("APP_NAME", "second")
+	parsed, err := Parse()
+
+	require.NoError(t, err)
+	assert.Equal(t, "second", parsed.AppName)
+	assert.Same(t, loaded, Get())
+}
+
+func TestParse_ReturnsErrorInsteadOfExiting(t *testing.T) {
+	t.Setenv("BCRYPT_COST", "twelve")
+
+	_, err := Parse()
+
+	assert.Error(t, err)
+}
+
+func TestLoad_ClampsNonPositiveDurations(t *testing.T) {
+	for _, value := range []string{"0", "-5"} {
+		t.Run(value, func(t *testing.T) {
+			t.Setenv("CACHE_TTL_MINUTES", value)
+			t.Setenv("CACHE_CLEANUP_INTERVAL_MINUTES", value)
+			t.Setenv("LOGIN_THROTTLE_WINDOW_SECONDS", value)
+			t.Setenv("METRICS_COLLECTION_INTERVAL_SECONDS", value)
+			t.Setenv("DB_BREAKER_COOLDOWN_SECONDS", value)
+			t.Setenv("SHUTDOWN_TIMEOUT_SECONDS", value)
+			t.Setenv("DB_READ_RETRY_BACKOFF_MS", value)
+			t.Cleanup(Reset)
+
+			cfg := Load()
+
+			assert.Equal(t, time.Minute, cfg.CacheTTL())
+			assert.Equal(t, time.Minute, cfg.CacheCleanupInterval())
+			assert.Equal(t, time.Second, cfg.LoginThrottleWindow())
+			assert.Equal(t, time.Second, cfg.MetricsCollectionInterval())
+			assert.Equal(t, time.Second, cfg.DBBreakerCooldown())
+			assert.Equal(t, time.Second, cfg.ShutdownTimeout())
+			assert.Equal(t, time.Duration(0), cfg.DBReadRetryBackoff())
+		})
+	}
+}
+
+func TestLoad_ClampsAuthzMaxStaleToCacheTTL(t *testing.T) {
+	tests := []struct {
+		name     string
+		cacheTTL string
+		maxStale string
+		want     time.Duration
+	}{
+		{name: "below the cache TTL", cacheTTL: "10", maxStale: "3", want: 10 * time.Minute},
+		{name: "non-positive", cacheTTL: "5", maxStale: "0", want: 5 * time.Minute},
+		{name: "non-positive cache TTL", cacheTTL: "0", maxStale: "-1", want: time.Minute},
+		{name: "above the cache TTL", cacheTTL: "5", maxStale: "30", want: 30 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CACHE_TTL_MINUTES", tt.cacheTTL)
+			t.Setenv("AUTHZ_MAX_STALE_MINUTES", tt.maxStale)
+			t.Cleanup(Reset)
+
+			cfg := Load()
+
+			assert.Equal(t, tt.want, cfg.AuthzMaxStale())
+		})
+	}
+}
+
+func TestLoad_KeepsPositiveDurations(t *testing.T) {
+	t.Setenv("CACHE_TTL_MINUTES", "10")
+	t.Setenv("DB_READ_RETRY_BACKOFF_MS", "25")
+	t.Cleanup(Reset)
+
+	cfg := Load()
+
+	assert.Equal(t, 10*time.Minute, cfg.CacheTTL())
+	assert.Equal(t, 25*time.Millisecond, cfg.DBReadRetryBackoff())
+}
+
+func TestLogRenameKeyMap_SkipsMalformedPairs(t *testing.T) {
+	cfg := &Config{LogRenameKeys: "msg=message, time = timestamp,level,=x,y="}
+
+	assert.Equal(t, map[string]string{"msg": "message", "time": "timestamp"}, cfg.LogRenameKeyMap())
+}
+
+func TestLoad_InvalidUserEmailScopeFallsBackToTenant(t *testing.T) {
+	t.Setenv("USER_EMAIL_SCOPE", "per-region")
+	t.Cleanup(Reset)
+
+	assert.Equal(t, EmailScopeTenant, Load().UserEmailScope)
+}
+
+func TestLoad_InvalidResponseEnvelopeFallsBackToWrapped(t *testing.T) {
+	t.Setenv("RESPONSE_ENVELOPE", "jsonapi")
+	t.Cleanup(Reset)
+
+	assert.Equal(t, EnvelopeWrapped, Load().ResponseEnvelope)
+}
+
+func TestTwoFactorFallbacks(t *testing.T) {
+	cfg := &Config{JWTSecret: "jwt-secret", AppName: "app"}
+
+	assert.Empty(t, cfg.TwoFactorKey())
+	assert.Equal(t, "app", cfg.TwoFactorIssuerName())
+
+	cfg.TwoFactorEncryptionKey = "2fa-key"
+	cfg.TwoFactorIssuer = "Acme"
+
+	assert.Equal(t, "2fa-key", cfg.TwoFactorKey())
+	assert.Equal(t, "Acme", cfg.TwoFactorIssuerName())
+}
+
+func TestValidatePublicBaseURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "email disabled", cfg: Config{}},
+		{name: "set", cfg: Config{EmailEnabled: true, PublicBaseURL: "https://app.example.com"}},
+		{name: "unset", cfg: Config{EmailEnabled: true}, wantErr: true},
+		{name: "relative", cfg: Config{EmailEnabled: true, PublicBaseURL: "app.example.com"}, wantErr: true},
+		{name: "other scheme", cfg: Config{EmailEnabled: true, PublicBaseURL: "ftp://app.example.com"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.ValidatePublicBaseURL()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrPublicBaseURLRequired)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPublicURL(t *testing.T) {
+	assert.Equal(t, "https://app.example.com/api/account/verify",
+		(&Config{PublicBaseURL: "https://app.example.com/"}).PublicURL("/api/account/verify"))
+	assert.Equal(t, "https://app.example.com/base/account/password/reset",
+		(&Config{PublicBaseURL: "https://app.example.com/base"}).PublicURL("/account/password/reset"))
+}
+
+func TestLoad_RereadsEnvFile(t *testing.T) {
+	t.Chdir(t.TempDir())
+	// Restored afterwards; unset so only the .env file provides them
+	t.Setenv("LOG_BLACKLIST_PATHS", "")
+	t.Setenv("APP_NAME", "from-env")
+	require.NoError(t, os.Unsetenv("LOG_BLACKLIST_PATHS"))
+	t.Cleanup(func() {
+		delete(envFileKeys, "LOG_BLACKLIST_PATHS")
+		Reset()
+	})
+
+	writeEnv := func(content string) {
+		require.NoError(t, os.WriteFile(".env", []byte(content), 0o600))
+	}
+
+	writeEnv("LOG_BLACKLIST_PATHS=/health\nAPP_NAME=from-file\n")
+	cfg := Load()
+	assert.Equal(t, []string{"/health"}, cfg.LogBlacklistPathList())
+	assert.Equal(t, "from-env", cfg.AppName)
+
+	writeEnv("LOG_BLACKLIST_PATHS=/health, /metrics\nAPP_NAME=from-file\n")
+	cfg = Load()
+	assert.Equal(t, []string{"/health", "/metrics"}, cfg.LogBlacklistPathList())
+	assert.Equal(t, "from-env", cfg.AppName)
+}
+
+func TestLoad_InvalidRuntimeStatsSourceFallsBackToMemStats(t *testing.T) {
+	t.Setenv("RUNTIME_STATS_SOURCE", "pprof")
+	t.Cleanup(Reset)
+
+	assert.Equal(t, RuntimeStatsMemStats, Load().RuntimeStatsSource)
+}
+
+func TestAuthzWarmupUserCount_Bounded(t *testing.T) {
+	for _, tc := range []struct{ configured, want int }{
+		{configured: -5, want: 0},
+		{configured: 0, want: 0},
+		{configured: 500, want: 500},
+		{configured: 1_000_000, want: maxAuthzWarmupUsers},
+	} {
+		cfg := &Config{AuthzWarmupUsers: tc.configured}
+		assert.Equal(t, tc.want, cfg.AuthzWarmupUserCount(), "AUTHZ_WARMUP_USERS=%d", tc.configured)
+	}
+}
+
+func TestShouldTraceErrorBodies_OnlyInPreProduction(t *testing.T) {
+	for _, tc := range []struct {
+		env     string
+		enabled bool
+		want    bool
+	}{
+		{env: "development", enabled: true, want: true},
+		{env: "staging", enabled: true, want: true},
+		{env: "localhost", enabled: true, want: true},
+		{env: "development", enabled: false, want: false},
+		{env: "production", enabled: true, want: false},
+		{env: "prod", enabled: true, want: false},
+		{env: "Production", enabled: true, want: false},
+		{env: "docker", enabled: true, want: false},
+		{env: "", enabled: true, want: false},
+	} {
+		cfg := &Config{AppEnv: tc.env, TraceErrorBodies: tc.enabled}
+		assert.Equal(t, tc.want, cfg.ShouldTraceErrorBodies(), "APP_ENV=%s TRACE_ERROR_BODIES=%t", tc.env, tc.enabled)
+	}
+}
+
+func TestShouldEnableDebugGC_OnlyInPreProduction(t *testing.T) {
+	for _, tc := range []struct {
+		env  string
+		want bool
+	}{
+		{env: "dev", want: true},
+		{env: "staging", want: true},
+		{env: "production", want: false},
+		{env: "prd", want: false},
+	} {
+		cfg := &Config{AppEnv: tc.env, EnableDebugGC: true}
+		assert.Equal(t, tc.want, cfg.ShouldEnableDebugGC(), "APP_ENV=%s", tc.env)
+	}
+}
+
+func TestOTELSpanAttributeMap_SkipsMalformedPairs(t *testing.T) {
+	cfg := &Config{OTELSpanAttributes: "region=eu-west-1, cluster = prod-a ,bare,=nokey,empty="}
+	assert.Equal(t, map[string]string{
+		"region":  "eu-west-1",
+		"cluster": "prod-a",
+		"empty":   "",
+	}, cfg.OTELSpanAttributeMap())
+}
+
+func TestOTELSpanAttributeValueLengthLimit(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		value int
+		want  int
+	}{
+		{name: "positive", value: 4096, want: 4096},
+		{name: "zero disables", value: 0, want: -1},
+		{name: "negative disables", value: -5, want: -1},
+	} {
+		cfg := &Config{OTELSpanAttributeValueLimit: tc.value}
+		assert.Equal(t, tc.want, cfg.OTELSpanAttributeValueLengthLimit(), tc.name)
+	}
+}
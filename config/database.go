@@ -7,13 +7,16 @@ import (
 	_ "github.com/lib/pq"
 )
 
+// DatabaseDSN returns the lib/pq connection string for the database.
+func (c *Config) DatabaseDSN() string {
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		c.DBHost, c.DBPort, c.DBUser, c.DBPass, c.DBName)
+}
+
 func SetUpDatabaseConnection() *sqlx.DB {
 	cfg := Get()
 
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPass, cfg.DBName)
-
-	db, err := sqlx.Connect("postgres", dsn)
+	db, err := sqlx.Connect("postgres", cfg.DatabaseDSN())
 	if err != nil {
 		panic(fmt.Sprintf("failed to connect to database: %v (dsn: host=%s port=%s user=%s dbname=%s)",
 			err, cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBName))
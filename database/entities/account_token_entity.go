@@ -0,0 +1,26 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Account token purposes. A token only redeems for the purpose it was
+// issued for.
+const (
+	AccountTokenPurposeVerifyEmail   = "verify_email"
+	AccountTokenPurposePasswordReset = "password_reset"
+)
+
+// AccountToken is a single-use token emailed to a user to confirm their
+// address or reset their password. Only the SHA-256 hash is stored.
+type AccountToken struct {
+	ID        uuid.UUID  `db:"id" json:"id"`
+	UserID    uuid.UUID  `db:"user_id" json:"user_id"`
+	Purpose   string     `db:"purpose" json:"purpose"`
+	TokenHash string     `db:"token_hash" json:"-"`
+	ExpiresAt time.Time  `db:"expires_at" json:"expires_at"`
+	UsedAt    *time.Time `db:"used_at" json:"used_at,omitempty"`
+	CreatedAt time.Time  `db:"created_at" json:"created_at"`
+}
@@ -9,6 +9,17 @@ type Timestamp struct {
 	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
 }
 
+// Touch stamps a write happening now, for rows timestamped in Go rather than
+// with SQL NOW(). UpdatedAt is always set; CreatedAt only when still zero, so
+// timestamps supplied by the caller (e.g. from seed data) are kept.
+func (t *Timestamp) Touch() {
+	now := time.Now().UTC()
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = now
+	}
+	t.UpdatedAt = now
+}
+
 type Authorization struct {
 	Token string `json:"token" binding:"required"`
 	Role  string `json:"role" binding:"required,oneof=user admin"`
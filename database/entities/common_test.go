@@ -0,0 +1,28 @@
+package entities
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimestamp_Touch_New(t *testing.T) {
+	var ts Timestamp
+
+	ts.Touch()
+
+	assert.WithinDuration(t, time.Now(), ts.CreatedAt, time.Second)
+	assert.Equal(t, ts.CreatedAt, ts.UpdatedAt)
+	assert.Equal(t, time.UTC, ts.CreatedAt.Location())
+}
+
+func TestTimestamp_Touch_KeepsCreatedAt(t *testing.T) {
+	created := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	ts := Timestamp{CreatedAt: created, UpdatedAt: created}
+
+	ts.Touch()
+
+	assert.Equal(t, created, ts.CreatedAt)
+	assert.True(t, ts.UpdatedAt.After(created))
+}
@@ -11,6 +11,11 @@ type RefreshToken struct {
 	UserID    uuid.UUID `db:"user_id" json:"user_id"`
 	Token     string    `db:"token" json:"token"`
 	ExpiresAt time.Time `db:"expires_at" json:"expires_at"`
+	// SessionStartedAt is when the login that began this token's rotation
+	// chain happened; rotation keeps it unchanged.
+	SessionStartedAt time.Time `db:"session_started_at" json:"session_started_at"`
+	UserAgent        string    `db:"user_agent" json:"user_agent"`
+	IPAddress        string    `db:"ip_address" json:"ip_address"`
 
 	Timestamp
 }
@@ -0,0 +1,15 @@
+package entities
+
+import (
+	"github.com/google/uuid"
+)
+
+// UserTwoFactor holds a user's TOTP enrollment. Secret is encrypted at rest;
+// Enabled is set once the user confirms enrollment with a valid code.
+type UserTwoFactor struct {
+	UserID  uuid.UUID `db:"user_id" json:"user_id"`
+	Secret  string    `db:"secret" json:"-"`
+	Enabled bool      `db:"enabled" json:"enabled"`
+
+	Timestamp
+}
@@ -6,9 +6,25 @@ import (
 
 type User struct {
 	ID       uuid.UUID `db:"id" json:"id"`
+	TenantID string    `db:"tenant_id" json:"-"`
 	Name     string    `db:"name" json:"name"`
 	Email    string    `db:"email" json:"email"`
 	Password string    `db:"password" json:"-"`
+	Status   string    `db:"status" json:"status"`
+	// TokenVersion is embedded in access tokens; bumping it invalidates
+	// every access token issued before the bump.
+	TokenVersion int `db:"token_version" json:"-"`
 
 	Timestamp
 }
+
+// User account statuses. Suspended users can neither log in nor refresh.
+const (
+	UserStatusActive    = "active"
+	UserStatusSuspended = "suspended"
+)
+
+// IsSuspended reports whether the account has been suspended.
+func (u *User) IsSuspended() bool {
+	return u.Status == UserStatusSuspended
+}
@@ -2,6 +2,10 @@ package database
 
 import (
 	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/pressly/goose/v3"
@@ -10,17 +14,112 @@ import (
 //go:embed migrations/*.sql
 var embedMigrations embed.FS
 
+// ErrPendingMigrations is returned by CheckMigrations when the database is
+// behind the embedded migrations and auto-migration is disabled.
+var ErrPendingMigrations = errors.New("database has pending migrations")
+
+// migrate is swapped in tests so the auto-migrate path can run against sqlmock.
+var migrate = Migrate
+
+// Migrate applies the migrations embedded in this package.
 func Migrate(db *sqlx.DB) error {
-	goose.SetBaseFS(embedMigrations)
+	return MigrateFS(db, embedMigrations, "migrations")
+}
+
+// MigrateFS applies the migrations found in dir within fsys. It lets tests
+// and services that layer their own migrations supply a different source.
+func MigrateFS(db *sqlx.DB, fsys fs.FS, dir string) error {
+	goose.SetBaseFS(fsys)
 
 	if err := goose.SetDialect("postgres"); err != nil {
 		return err
 	}
 
 	sqlDB := db.DB
-	if err := goose.Up(sqlDB, "migrations"); err != nil {
+	if err := goose.Up(sqlDB, dir); err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// CheckMigrations compares the embedded migration versions with the version
+// applied to db. Pending migrations are applied when autoMigrate is true,
+// otherwise ErrPendingMigrations is returned listing them.
+func CheckMigrations(db *sqlx.DB, autoMigrate bool) error {
+	pending, err := PendingMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	if !autoMigrate {
+		return fmt.Errorf("%w: versions %v", ErrPendingMigrations, pending)
+	}
+
+	return migrate(db)
+}
+
+// PendingMigrations returns the embedded migration versions newer than the
+// version currently applied to db.
+func PendingMigrations(db *sqlx.DB) ([]int64, error) {
+	applied, err := appliedVersion(db)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := embeddedVersions(embedMigrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []int64
+	for _, v := range versions {
+		if v > applied {
+			pending = append(pending, v)
+		}
+	}
+
+	return pending, nil
+}
+
+func appliedVersion(db *sqlx.DB) (int64, error) {
+	var exists bool
+	err := db.Get(&exists, `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'goose_db_version')`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check migration table: %w", err)
+	}
+
+	if !exists {
+		return 0, nil
+	}
+
+	var version int64
+	err = db.Get(&version, `SELECT COALESCE(MAX(version_id), 0) FROM goose_db_version WHERE is_applied = true`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get applied migration version: %w", err)
+	}
+
+	return version, nil
+}
+
+func embeddedVersions(fsys fs.FS, dir string) ([]int64, error) {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]int64, 0, len(files))
+	for _, file := range files {
+		version, err := goose.NumericComponent(file)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration file name %s: %w", file, err)
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, nil
+}
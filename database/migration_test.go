@@ -0,0 +1,142 @@
+package database
+
+import (
+	"io/fs"
+	"regexp"
+	"testing"
+	"testing/fstest"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/elskow/go-microservice-template/pkg/permissions"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	migrationTableExistsQuery = `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'goose_db_version')`
+	appliedVersionQuery       = `SELECT COALESCE(MAX(version_id), 0) FROM goose_db_version WHERE is_applied = true`
+)
+
+func expectAppliedVersion(mock sqlmock.Sqlmock, version int64) {
+	mock.ExpectQuery(regexp.QuoteMeta(migrationTableExistsQuery)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(regexp.QuoteMeta(appliedVersionQuery)).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(version))
+}
+
+func stubMigrate(t *testing.T) *bool {
+	called := false
+	original := migrate
+	migrate = func(db *sqlx.DB) error {
+		called = true
+		return nil
+	}
+	t.Cleanup(func() { migrate = original })
+	return &called
+}
+
+func latestEmbeddedVersion(t *testing.T) int64 {
+	versions, err := embeddedVersions(embedMigrations, "migrations")
+	require.NoError(t, err)
+	require.NotEmpty(t, versions)
+	return versions[len(versions)-1]
+}
+
+func TestCheckMigrations_UpToDate(t *testing.T) {
+	db, mock := setupMockDB(t)
+	expectAppliedVersion(mock, latestEmbeddedVersion(t))
+	called := stubMigrate(t)
+
+	err := CheckMigrations(db, false)
+
+	assert.NoError(t, err)
+	assert.False(t, *called)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCheckMigrations_PendingDetected(t *testing.T) {
+	db, mock := setupMockDB(t)
+	latest := latestEmbeddedVersion(t)
+	expectAppliedVersion(mock, latest-1)
+	called := stubMigrate(t)
+
+	err := CheckMigrations(db, false)
+
+	assert.ErrorIs(t, err, ErrPendingMigrations)
+	assert.False(t, *called)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCheckMigrations_FreshDatabase(t *testing.T) {
+	db, mock := setupMockDB(t)
+	mock.ExpectQuery(regexp.QuoteMeta(migrationTableExistsQuery)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	pending, err := PendingMigrations(db)
+
+	require.NoError(t, err)
+	assert.Contains(t, pending, latestEmbeddedVersion(t))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCheckMigrations_AutoMigrate(t *testing.T) {
+	db, mock := setupMockDB(t)
+	expectAppliedVersion(mock, 0)
+	called := stubMigrate(t)
+
+	err := CheckMigrations(db, true)
+
+	assert.NoError(t, err)
+	assert.True(t, *called)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrateFS_InMemorySource(t *testing.T) {
+	db, mock := setupMockDB(t)
+
+	fsys := fstest.MapFS{
+		"schema/00001_create_widgets.sql": &fstest.MapFile{Data: []byte(`-- +goose Up
+CREATE TABLE widgets (id INT PRIMARY KEY);
+
+-- +goose Down
+DROP TABLE widgets;
+`)},
+	}
+
+	listVersions := regexp.QuoteMeta(`SELECT version_id, is_applied from goose_db_version ORDER BY id DESC`)
+	versionRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"version_id", "is_applied"}).AddRow(0, true)
+	}
+
+	mock.ExpectQuery(listVersions).WillReturnRows(versionRows())
+	mock.ExpectQuery(listVersions).WillReturnRows(versionRows())
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE widgets (id INT PRIMARY KEY);`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO goose_db_version (version_id, is_applied) VALUES ($1, $2)`)).
+		WithArgs(int64(1), true).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err := MigrateFS(db, fsys, "schema")
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrations_SeedEveryRegisteredPermission(t *testing.T) {
+	files, err := fs.Glob(embedMigrations, "migrations/*.sql")
+	require.NoError(t, err)
+
+	var sql string
+	for _, file := range files {
+		data, err := fs.ReadFile(embedMigrations, file)
+		require.NoError(t, err)
+		sql += string(data)
+	}
+
+	for _, p := range permissions.All() {
+		assert.Contains(t, sql, "('"+p.String()+"',", "permission %q is not seeded by any migration", p)
+	}
+}
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE user_two_factor ADD COLUMN IF NOT EXISTS last_used_step BIGINT NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_two_factor DROP COLUMN IF EXISTS last_used_step;
+-- +goose StatementEnd
@@ -1,12 +1,14 @@
 package database
 
 import (
+	"log/slog"
+
 	"github.com/elskow/go-microservice-template/database/seeders/seeds"
 	"github.com/jmoiron/sqlx"
 )
 
-func Seeder(db *sqlx.DB) error {
-	if err := seeds.ListUserSeeder(db); err != nil {
+func Seeder(db *sqlx.DB, logger *slog.Logger) error {
+	if err := seeds.ListUserSeeder(db, logger); err != nil {
 		return err
 	}
 
@@ -1,19 +1,21 @@
 package seeds
 
 import (
-	"database/sql"
 	"encoding/json"
-	"errors"
 	"io"
+	"log/slog"
 	"os"
+	"strings"
 
+	"github.com/elskow/go-microservice-template/config"
 	"github.com/elskow/go-microservice-template/database/entities"
 	"github.com/elskow/go-microservice-template/pkg/helpers"
+	"github.com/elskow/go-microservice-template/pkg/tenant"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 )
 
-func ListUserSeeder(db *sqlx.DB) error {
+func ListUserSeeder(db *sqlx.DB, logger *slog.Logger) error {
 	jsonFile, err := os.Open("./database/seeders/json/users.json")
 	if err != nil {
 		return err
@@ -30,32 +32,100 @@ func ListUserSeeder(db *sqlx.DB) error {
 		return err
 	}
 
-	for _, data := range listUser {
-		var existingUser entities.User
-		err := db.Get(&existingUser, "SELECT id FROM users WHERE email = $1", data.Email)
-
-		if errors.Is(err, sql.ErrNoRows) {
-			hashedPassword, err := helpers.HashPassword(data.Password)
-			if err != nil {
-				return err
-			}
-
-			if data.ID == uuid.Nil {
-				data.ID = uuid.New()
-			}
-
-			query := `
-				INSERT INTO users (id, name, email, password, created_at, updated_at)
-				VALUES ($1, $2, $3, $4, NOW(), NOW())
-			`
-			_, err = db.Exec(query, data.ID, data.Name, data.Email, hashedPassword)
-			if err != nil {
-				return err
-			}
-		} else if err != nil {
+	cfg := config.Get()
+	return seedUsers(db, logger, listUser, userSeedOptions{
+		cost:      cfg.SeederBcryptCost(),
+		batchSize: cfg.SeedBatchSize,
+	})
+}
+
+// userSeedOptions controls how seedUsers hashes and batches users.
+type userSeedOptions struct {
+	cost      int
+	batchSize int
+}
+
+// seedUsers inserts every user whose email is not already present, hashing
+// passwords with the configured bcrypt cost. Users are written in chunks of
+// batchSize with one multi-row INSERT per chunk, logging progress after each.
+func seedUsers(db *sqlx.DB, logger *slog.Logger, listUser []entities.User, opts userSeedOptions) error {
+	seen := make(map[string]bool, len(listUser))
+	inserted := 0
+
+	for start := 0; start < len(listUser); start += opts.batchSize {
+		end := min(start+opts.batchSize, len(listUser))
+		n, err := seedUserBatch(db, listUser[start:end], opts.cost, seen)
+		if err != nil {
 			return err
 		}
+		inserted += n
+
+		logger.Info("seeding users",
+			"processed", end,
+			"total", len(listUser),
+			"inserted", inserted,
+		)
 	}
 
 	return nil
 }
+
+// seedUserBatch inserts the users in batch whose email is neither in the
+// database nor in seen, recording every email it handles in seen so
+// duplicates later in the seed file are skipped too. It returns the number
+// of users inserted.
+func seedUserBatch(db *sqlx.DB, batch []entities.User, cost int, seen map[string]bool) (int, error) {
+	emails := make([]string, 0, len(batch))
+	for _, data := range batch {
+		emails = append(emails, data.Email)
+	}
+
+	// Seed users land in the default tenant through the column default
+	query, args, err := sqlx.In("SELECT email FROM users WHERE tenant_id = ? AND email IN (?)", tenant.DefaultID, emails)
+	if err != nil {
+		return 0, err
+	}
+
+	var existing []string
+	if err := db.Select(&existing, db.Rebind(query), args...); err != nil {
+		return 0, err
+	}
+	for _, email := range existing {
+		seen[email] = true
+	}
+
+	values := make([]string, 0, len(batch))
+	args = make([]interface{}, 0, len(batch)*6)
+	for _, data := range batch {
+		if seen[data.Email] {
+			continue
+		}
+		seen[data.Email] = true
+
+		hashedPassword, err := helpers.HashPasswordWithCost(data.Password, cost)
+		if err != nil {
+			return 0, err
+		}
+
+		if data.ID == uuid.Nil {
+			data.ID = uuid.New()
+		}
+
+		data.Touch()
+
+		values = append(values, "(?, ?, ?, ?, ?, ?)")
+		args = append(args, data.ID, data.Name, data.Email, hashedPassword, data.CreatedAt, data.UpdatedAt)
+	}
+
+	if len(values) == 0 {
+		return 0, nil
+	}
+
+	query = "INSERT INTO users (id, name, email, password, created_at, updated_at) VALUES " +
+		strings.Join(values, ", ")
+	if _, err := db.Exec(db.Rebind(query), args...); err != nil {
+		return 0, err
+	}
+
+	return len(values), nil
+}
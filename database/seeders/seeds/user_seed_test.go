@@ -0,0 +1,189 @@
+package seeds
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/elskow/go-microservice-template/config"
+	"github.com/elskow/go-microservice-template/database/entities"
+	"github.com/elskow/go-microservice-template/pkg/tenant"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcryptCost matches a bcrypt hash argument generated with the given cost.
+type bcryptCost int
+
+func (c bcryptCost) Match(v driver.Value) bool {
+	hash, ok := v.(string)
+	if !ok {
+		return false
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err == nil && cost == int(c)
+}
+
+func TestSeederBcryptCost_Override(t *testing.T) {
+	t.Setenv("BCRYPT_COST", "12")
+	t.Setenv("SEED_BCRYPT_COST", "4")
+	t.Cleanup(config.Reset)
+
+	cfg := config.Load()
+
+	assert.Equal(t, 12, cfg.BcryptCost)
+	assert.Equal(t, 4, cfg.SeederBcryptCost())
+}
+
+func TestSeederBcryptCost_FallsBackToBcryptCost(t *testing.T) {
+	t.Setenv("BCRYPT_COST", "11")
+	t.Setenv("SEED_BCRYPT_COST", "")
+	t.Cleanup(config.Reset)
+
+	cfg := config.Load()
+
+	assert.Equal(t, 11, cfg.SeederBcryptCost())
+}
+
+func TestSeedUsers_UsesSeedCost(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { mockDB.Close() })
+	db := sqlx.NewDb(mockDB, "sqlmock")
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT email FROM users WHERE tenant_id = ? AND email IN (?)")).
+		WithArgs(tenant.DefaultID, "seed@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"email"}))
+	mock.ExpectExec("INSERT INTO users").
+		WithArgs(sqlmock.AnyArg(), "Seed User", "seed@example.com", bcryptCost(4), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	users := []entities.User{{Name: "Seed User", Email: "seed@example.com", Password: "seed-password"}}
+	err = seedUsers(db, discardLogger(), users, userSeedOptions{cost: 4, batchSize: 100})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSeedBatchSize_Config(t *testing.T) {
+	t.Setenv("SEED_BATCH_SIZE", "250")
+	t.Cleanup(config.Reset)
+
+	assert.Equal(t, 250, config.Load().SeedBatchSize)
+
+	t.Setenv("SEED_BATCH_SIZE", "0")
+	assert.Equal(t, 100, config.Load().SeedBatchSize)
+}
+
+func TestSeedUsers_BatchesInserts(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { mockDB.Close() })
+	db := sqlx.NewDb(mockDB, "sqlmock")
+
+	const batchSize = 4
+	users := make([]entities.User, 2*batchSize+1)
+	for i := range users {
+		users[i] = entities.User{
+			Name:     fmt.Sprintf("User %d", i),
+			Email:    fmt.Sprintf("user%d@example.com", i),
+			Password: "seed-password",
+		}
+	}
+
+	// one SELECT and one INSERT per chunk; the second chunk has one user
+	// that already exists and is skipped
+	chunks := []struct {
+		size     int
+		existing []string
+	}{
+		{size: batchSize},
+		{size: batchSize, existing: []string{users[batchSize].Email}},
+		{size: 1},
+	}
+	for _, chunk := range chunks {
+		existing := sqlmock.NewRows([]string{"email"})
+		for _, email := range chunk.existing {
+			existing.AddRow(email)
+		}
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT email FROM users WHERE tenant_id = ? AND email IN (")).
+			WithArgs(anyArgs(chunk.size + 1)...).
+			WillReturnRows(existing)
+
+		inserted := chunk.size - len(chunk.existing)
+		mock.ExpectExec("INSERT INTO users").
+			WithArgs(anyArgs(inserted * 6)...).
+			WillReturnResult(sqlmock.NewResult(0, int64(inserted)))
+	}
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+
+	err = seedUsers(db, logger, users, userSeedOptions{cost: 4, batchSize: batchSize})
+
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	var progress []map[string]any
+	decoder := json.NewDecoder(&logs)
+	for decoder.More() {
+		var entry map[string]any
+		require.NoError(t, decoder.Decode(&entry))
+		progress = append(progress, entry)
+	}
+	require.Len(t, progress, len(chunks))
+	assert.EqualValues(t, 4, progress[0]["processed"])
+	assert.EqualValues(t, 8, progress[1]["processed"])
+	assert.EqualValues(t, 7, progress[1]["inserted"])
+	assert.EqualValues(t, 9, progress[2]["processed"])
+	assert.EqualValues(t, 9, progress[2]["total"])
+	assert.EqualValues(t, 8, progress[2]["inserted"])
+}
+
+func TestSeedUsers_KeepsSuppliedCreatedAt(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { mockDB.Close() })
+	db := sqlx.NewDb(mockDB, "sqlmock")
+
+	created := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT email FROM users WHERE tenant_id = ? AND email IN (?)")).
+		WithArgs(tenant.DefaultID, "seed@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"email"}))
+	mock.ExpectExec("INSERT INTO users").
+		WithArgs(sqlmock.AnyArg(), "Seed User", "seed@example.com", bcryptCost(4), created, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	users := []entities.User{{
+		Name:      "Seed User",
+		Email:     "seed@example.com",
+		Password:  "seed-password",
+		Timestamp: entities.Timestamp{CreatedAt: created},
+	}}
+	err = seedUsers(db, discardLogger(), users, userSeedOptions{cost: 4, batchSize: 100})
+
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func anyArgs(n int) []driver.Value {
+	args := make([]driver.Value, n)
+	for i := range args {
+		args[i] = sqlmock.AnyArg()
+	}
+	return args
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SeedCheck is the outcome of a single seed invariant.
+type SeedCheck struct {
+	Name   string
+	Passed bool
+	Detail string
+}
+
+// SeedReport collects the outcome of every seed invariant.
+type SeedReport struct {
+	Checks []SeedCheck
+}
+
+// Passed reports whether every check in the report passed.
+func (r SeedReport) Passed() bool {
+	for _, check := range r.Checks {
+		if !check.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+type seedInvariant struct {
+	name   string
+	query  string
+	expect func(count int) bool
+	want   string
+}
+
+func atLeastOne(count int) bool { return count >= 1 }
+func none(count int) bool       { return count == 0 }
+
+var seedInvariants = []seedInvariant{
+	{
+		name:   "admin role exists",
+		query:  `SELECT COUNT(*) FROM roles WHERE name = 'admin'`,
+		expect: atLeastOne,
+		want:   "at least 1",
+	},
+	{
+		name:   "default user role exists",
+		query:  `SELECT COUNT(*) FROM roles WHERE name = 'user'`,
+		expect: atLeastOne,
+		want:   "at least 1",
+	},
+	{
+		name: "default user role has permissions",
+		query: `
+			SELECT COUNT(*)
+			FROM role_permissions rp
+			JOIN roles r ON rp.role_id = r.id
+			WHERE r.name = 'user'
+		`,
+		expect: atLeastOne,
+		want:   "at least 1",
+	},
+	{
+		name: "admin role has every permission",
+		query: `
+			SELECT COUNT(*)
+			FROM permissions p
+			WHERE NOT EXISTS (
+				SELECT 1
+				FROM role_permissions rp
+				JOIN roles r ON rp.role_id = r.id
+				WHERE r.name = 'admin' AND rp.permission_id = p.id
+			)
+		`,
+		expect: none,
+		want:   "0 unlinked permissions",
+	},
+	{
+		name:   "seed users exist",
+		query:  `SELECT COUNT(*) FROM users`,
+		expect: atLeastOne,
+		want:   "at least 1",
+	},
+}
+
+// VerifySeed checks that the seeded data satisfies the invariants the
+// application relies on. A returned error means a check could not be run;
+// failing checks are reported in the SeedReport instead.
+func VerifySeed(db *sqlx.DB) (SeedReport, error) {
+	report := SeedReport{Checks: make([]SeedCheck, 0, len(seedInvariants))}
+
+	for _, inv := range seedInvariants {
+		var count int
+		if err := db.Get(&count, inv.query); err != nil {
+			return report, fmt.Errorf("seed check %q: %w", inv.name, err)
+		}
+
+		report.Checks = append(report.Checks, SeedCheck{
+			Name:   inv.name,
+			Passed: inv.expect(count),
+			Detail: fmt.Sprintf("got %d, want %s", count, inv.want),
+		})
+	}
+
+	return report, nil
+}
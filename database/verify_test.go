@@ -0,0 +1,70 @@
+package database
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupMockDB(t *testing.T) (*sqlx.DB, sqlmock.Sqlmock) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { mockDB.Close() })
+
+	return sqlx.NewDb(mockDB, "sqlmock"), mock
+}
+
+func expectSeedCounts(mock sqlmock.Sqlmock, counts ...int) {
+	for i, count := range counts {
+		mock.ExpectQuery(regexp.QuoteMeta(seedInvariants[i].query)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(count))
+	}
+}
+
+func TestVerifySeed_Healthy(t *testing.T) {
+	db, mock := setupMockDB(t)
+	expectSeedCounts(mock, 1, 1, 2, 0, 2)
+
+	report, err := VerifySeed(db)
+
+	require.NoError(t, err)
+	assert.True(t, report.Passed())
+	assert.Len(t, report.Checks, len(seedInvariants))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestVerifySeed_Broken(t *testing.T) {
+	db, mock := setupMockDB(t)
+	// no admin role, and three permissions not linked to admin
+	expectSeedCounts(mock, 0, 1, 2, 3, 2)
+
+	report, err := VerifySeed(db)
+
+	require.NoError(t, err)
+	assert.False(t, report.Passed())
+
+	var failed []string
+	for _, check := range report.Checks {
+		if !check.Passed {
+			failed = append(failed, check.Name)
+		}
+	}
+	assert.Equal(t, []string{"admin role exists", "admin role has every permission"}, failed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestVerifySeed_QueryError(t *testing.T) {
+	db, mock := setupMockDB(t)
+	mock.ExpectQuery(regexp.QuoteMeta(seedInvariants[0].query)).
+		WillReturnError(errors.New("relation \"roles\" does not exist"))
+
+	_, err := VerifySeed(db)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "admin role exists")
+}
@@ -108,7 +108,21 @@ func shouldSkipMetrics(path string) bool {
 	return false
 }
 
-func HTTPMetricsMiddleware(metricsCollector *apm.MetricsCollector) gin.HandlerFunc {
+// HTTPMetricsMiddleware records duration, size and throughput for every
+// request outside the skipped paths. Routes in streamRoutes, given as
+// "METHOD /full/path" such as "GET /api/account/events", hold their
+// connection open for as long as the client listens, so instead of skewing
+// the duration histogram they are counted in the open-streams gauge.
+//
+// The http.path attribute is the matched route template, such as
+// "/users/:id", so every request to one endpoint lands in the same series.
+// Requests matching no route fall back to the request path.
+func HTTPMetricsMiddleware(metricsCollector *apm.MetricsCollector, streamRoutes []string) gin.HandlerFunc {
+	streams := make(map[string]struct{}, len(streamRoutes))
+	for _, route := range streamRoutes {
+		streams[route] = struct{}{}
+	}
+
 	return func(c *gin.Context) {
 		path := c.Request.URL.Path
 
@@ -117,6 +131,11 @@ func HTTPMetricsMiddleware(metricsCollector *apm.MetricsCollector) gin.HandlerFu
 			return
 		}
 
+		if _, ok := streams[c.Request.Method+" "+c.FullPath()]; ok {
+			trackStream(c, metricsCollector)
+			return
+		}
+
 		startTime := time.Now()
 
 		ctx := c.Request.Context()
@@ -140,8 +159,30 @@ func HTTPMetricsMiddleware(metricsCollector *apm.MetricsCollector) gin.HandlerFu
 		duration := time.Since(startTime)
 		responseSize := int64(c.Writer.Size())
 
-		recordHTTPMetrics(ctx, metricsCollector, c.Request.Method, path, statusCode, duration, responseSize)
+		route := c.FullPath()
+		if route == "" {
+			route = path
+		}
+
+		recordHTTPMetrics(ctx, metricsCollector, c.Request.Method, route, statusCode, duration, responseSize)
+	}
+}
+
+func trackStream(c *gin.Context, mc *apm.MetricsCollector) {
+	if !mc.IsEnabled() {
+		c.Next()
+		return
 	}
+
+	ctx := c.Request.Context()
+	attrs := metric.WithAttributes(
+		attribute.String("http.method", c.Request.Method),
+		attribute.String("http.path", normalizePath(c.FullPath())),
+	)
+	mc.HttpStreams.Add(ctx, 1, attrs)
+	defer mc.HttpStreams.Add(context.WithoutCancel(ctx), -1, attrs)
+
+	c.Next()
 }
 
 type responseWriter struct {
@@ -167,14 +208,21 @@ func (w *responseWriter) WriteString(s string) (int, error) {
 	return size, err
 }
 
-func recordHTTPMetrics(ctx context.Context, mc *apm.MetricsCollector, method string, path string, statusCode int, duration time.Duration, responseSize int64) {
+// Size returns the number of body bytes written, counting every chunk of a
+// streamed response. Unlike gin's writer it reports 0, not -1, when nothing
+// was written.
+func (w *responseWriter) Size() int {
+	return w.size
+}
+
+func recordHTTPMetrics(ctx context.Context, mc *apm.MetricsCollector, method string, route string, statusCode int, duration time.Duration, responseSize int64) {
 	if !mc.IsEnabled() {
 		return
 	}
 
 	durationMs := float64(duration.Milliseconds())
 	statusCategory := getStatusCategory(statusCode)
-	normalizedPath := normalizePath(path)
+	normalizedPath := normalizePath(route)
 
 	attrs := getAttributeSlice()
 	defer putAttributeSlice(attrs)
@@ -0,0 +1,125 @@
+package middlewares
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/elskow/go-microservice-template/pkg/apm"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// newTestMeterReader installs a global meter provider backed by a manual
+// reader for the duration of the test.
+func newTestMeterReader(t *testing.T) *sdkmetric.ManualReader {
+	reader := sdkmetric.NewManualReader()
+	previous := otel.GetMeterProvider()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	t.Cleanup(func() { otel.SetMeterProvider(previous) })
+	return reader
+}
+
+// newTestCollector returns a collector whose instruments record into a
+// manual reader.
+func newTestCollector(t *testing.T) (*apm.MetricsCollector, *sdkmetric.ManualReader) {
+	reader := newTestMeterReader(t)
+
+	collector, err := apm.NewMetricsCollector(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+	return collector, reader
+}
+
+func collectMetrics(t *testing.T, reader *sdkmetric.ManualReader) map[string]metricdata.Aggregation {
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+
+	metrics := make(map[string]metricdata.Aggregation)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m.Data
+		}
+	}
+	return metrics
+}
+
+func TestHTTPMetricsMiddleware_StreamRoutesSkipDuration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	collector, reader := newTestCollector(t)
+
+	openStreams := int64(-1)
+	router := gin.New()
+	router.Use(HTTPMetricsMiddleware(collector, []string{"GET /events/:topic"}))
+	router.GET("/events/:topic", func(c *gin.Context) {
+		// The connection is counted while the stream is being served
+		sum, ok := collectMetrics(t, reader)["http_stream_connections"].(metricdata.Sum[int64])
+		if ok && len(sum.DataPoints) == 1 {
+			openStreams = sum.DataPoints[0].Value
+		}
+		c.Header("Content-Type", "text/event-stream")
+		c.String(http.StatusOK, "data: hello\n\n")
+	})
+	router.GET("/users", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, path := range []string{"/events/sessions", "/users"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	metrics := collectMetrics(t, reader)
+
+	histogram, ok := metrics["http_request_duration_ms"].(metricdata.Histogram[float64])
+	require.True(t, ok)
+	require.Len(t, histogram.DataPoints, 1)
+	path, _ := histogram.DataPoints[0].Attributes.Value("http.path")
+	assert.Equal(t, "/users", path.AsString())
+
+	assert.Equal(t, int64(1), openStreams)
+	streams, ok := metrics["http_stream_connections"].(metricdata.Sum[int64])
+	require.True(t, ok)
+	require.Len(t, streams.DataPoints, 1)
+	assert.Equal(t, int64(0), streams.DataPoints[0].Value)
+}
+
+func TestHTTPMetricsMiddleware_ResponseSizeByRouteTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	collector, reader := newTestCollector(t)
+
+	router := gin.New()
+	router.Use(HTTPMetricsMiddleware(collector, nil))
+	router.GET("/users/:id", func(c *gin.Context) {
+		c.String(http.StatusOK, "user "+c.Param("id"))
+	})
+
+	var written int64
+	for _, path := range []string{"/users/1", "/users/42"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		written += int64(w.Body.Len())
+	}
+
+	metrics := collectMetrics(t, reader)
+
+	sizes, ok := metrics["http_response_size_bytes"].(metricdata.Histogram[int64])
+	require.True(t, ok)
+	require.Len(t, sizes.DataPoints, 1, "both requests share the route template series")
+	path, _ := sizes.DataPoints[0].Attributes.Value("http.path")
+	assert.Equal(t, "/users/:id", path.AsString())
+	assert.Equal(t, uint64(2), sizes.DataPoints[0].Count)
+	assert.Equal(t, written, sizes.DataPoints[0].Sum)
+
+	durations, ok := metrics["http_request_duration_ms"].(metricdata.Histogram[float64])
+	require.True(t, ok)
+	require.Len(t, durations.DataPoints, 1)
+	path, _ = durations.DataPoints[0].Attributes.Value("http.path")
+	assert.Equal(t, "/users/:id", path.AsString())
+}
@@ -0,0 +1,53 @@
+package middlewares
+
+import (
+	"github.com/elskow/go-microservice-template/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAudience lets through only requests whose token lists audience in
+// its "aud" claim, answering 403 to others. It must run after Authenticate.
+// An empty audience disables the check, so a route group can opt in through
+// configuration.
+func RequireAudience(audience string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if audience == "" {
+			ctx.Next()
+			return
+		}
+
+		auth, ok := GetAuthContext(ctx)
+		if !ok {
+			status, body := response.Unauthorized[any]("authentication required")
+			response.AbortWithJSON(ctx, status, body)
+			return
+		}
+
+		for _, aud := range auth.Audience {
+			if aud == audience {
+				ctx.Next()
+				return
+			}
+		}
+		status, body := response.Forbidden[any]("token was not issued for this audience")
+		response.AbortWithJSON(ctx, status, body)
+	}
+}
+
+// tokenAudience reads the "aud" claim, which RFC 7519 allows to be a single
+// string or an array of strings.
+func tokenAudience(claim any) []string {
+	switch aud := claim.(type) {
+	case string:
+		return []string{aud}
+	case []any:
+		audience := make([]string, 0, len(aud))
+		for _, v := range aud {
+			if s, ok := v.(string); ok {
+				audience = append(audience, s)
+			}
+		}
+		return audience
+	}
+	return nil
+}
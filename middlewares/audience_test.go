@@ -0,0 +1,95 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/elskow/go-microservice-template/config"
+	"github.com/elskow/go-microservice-template/pkg/jwt"
+	"github.com/elskow/go-microservice-template/pkg/response"
+	"github.com/gin-gonic/gin"
+	gojwt "github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func performAudienceRequest(t *testing.T, audience, token string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	admin := router.Group("/admin", Authenticate(jwt.NewService(), nil, "", nil), RequireAudience(audience))
+	admin.GET("/routes", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/routes", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRequireAudience(t *testing.T) {
+	tests := []struct {
+		name     string
+		audience string
+		role     string
+		wantCode int
+	}{
+		{name: "admin token carries the audience", audience: "admin-api", role: "admin", wantCode: http.StatusOK},
+		{name: "user token lacks the audience", audience: "admin-api", role: "user", wantCode: http.StatusForbidden},
+		{name: "check disabled", audience: "", role: "user", wantCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ADMIN_TOKEN_AUDIENCE", tt.audience)
+			config.Load()
+			t.Cleanup(config.Reset)
+
+			token, err := jwt.NewService().GenerateSessionAccessToken(uuid.NewString(), tt.role, "", "", 0)
+			require.NoError(t, err)
+
+			w := performAudienceRequest(t, tt.audience, token)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode == http.StatusForbidden {
+				assert.Equal(t, response.ErrCodeForbidden, decodeErrorCode(t, w))
+			}
+		})
+	}
+}
+
+func TestRequireAudience_ForeignAudienceRejectedByAuthenticate(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN_AUDIENCE", "admin-api")
+	config.Load()
+	t.Cleanup(config.Reset)
+
+	token := signTestToken(t, gojwt.MapClaims{
+		"user_id": uuid.NewString(),
+		"aud":     "web",
+		"iat":     time.Now().Unix(),
+		"exp":     time.Now().Add(time.Minute).Unix(),
+	})
+
+	w := performAudienceRequest(t, "admin-api", token)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestRequireAudience_Unauthenticated(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/admin/routes", RequireAudience("admin"), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/routes", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestTokenAudience(t *testing.T) {
+	assert.Equal(t, []string{"admin-api"}, tokenAudience("admin-api"))
+	assert.Equal(t, []string{"web", "admin-api"}, tokenAudience([]any{"web", "admin-api"}))
+	assert.Nil(t, tokenAudience(nil))
+}
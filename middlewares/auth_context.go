@@ -0,0 +1,35 @@
+package middlewares
+
+import (
+	"github.com/elskow/go-microservice-template/pkg/constants"
+	"github.com/gin-gonic/gin"
+)
+
+// AuthContext describes the caller authenticated by Authenticate.
+type AuthContext struct {
+	UserID   string
+	Role     string
+	Roles    []string
+	TenantID string
+	// SessionID is the session the access token was minted for; empty for
+	// tokens issued without one.
+	SessionID string
+	// Audience holds the token's "aud" claim; see RequireAudience.
+	Audience []string
+}
+
+func setAuthContext(c *gin.Context, auth AuthContext) {
+	c.Set(constants.CtxKeyAuth, auth)
+}
+
+// GetAuthContext returns the AuthContext stored by Authenticate. The boolean
+// is false when the request was not authenticated.
+func GetAuthContext(c *gin.Context) (AuthContext, bool) {
+	value, exists := c.Get(constants.CtxKeyAuth)
+	if !exists {
+		return AuthContext{}, false
+	}
+
+	auth, ok := value.(AuthContext)
+	return auth, ok
+}
@@ -0,0 +1,73 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/elskow/go-microservice-template/pkg/jwt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAuthContext_PopulatedByAuthenticate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jwtService := jwt.NewService()
+
+	userID := uuid.NewString()
+	token, err := jwtService.GenerateAccessToken(userID, "admin")
+	require.NoError(t, err)
+
+	var (
+		got   AuthContext
+		found bool
+	)
+	router := gin.New()
+	router.GET("/", Authenticate(jwtService, nil, "", nil), func(c *gin.Context) {
+		got, found = GetAuthContext(c)
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.True(t, found)
+	assert.Equal(t, AuthContext{UserID: userID, Role: "admin", Roles: []string{"admin"}}, got)
+}
+
+func TestGetAuthContext_Missing(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	auth, ok := GetAuthContext(c)
+
+	assert.False(t, ok)
+	assert.Equal(t, AuthContext{}, auth)
+}
+
+func TestGetAuthContext_SessionID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jwtService := jwt.NewService()
+
+	token, err := jwtService.GenerateSessionAccessToken(uuid.NewString(), "user", "", "session-id", 0)
+	require.NoError(t, err)
+
+	var got AuthContext
+	router := gin.New()
+	router.GET("/", Authenticate(jwtService, nil, "", nil), func(c *gin.Context) {
+		got, _ = GetAuthContext(c)
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "session-id", got.SessionID)
+}
@@ -1,64 +1,211 @@
 package middlewares
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"net/http"
 	"strings"
 
+	"github.com/elskow/go-microservice-template/pkg/apm"
 	"github.com/elskow/go-microservice-template/pkg/constants"
 	"github.com/elskow/go-microservice-template/pkg/jwt"
 	"github.com/elskow/go-microservice-template/pkg/response"
+	"github.com/elskow/go-microservice-template/pkg/tenant"
 	"github.com/gin-gonic/gin"
+	gojwt "github.com/golang-jwt/jwt/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 )
 
-func Authenticate(jwtService jwt.Service) gin.HandlerFunc {
+// Reasons recorded on auth_token_validation_failures_total. The set is
+// fixed so the label stays low-cardinality whatever the token contains.
+const (
+	tokenFailureMissing     = "missing"
+	tokenFailureMalformed   = "malformed"
+	tokenFailureExpired     = "expired"
+	tokenFailureRevoked     = "revoked"
+	tokenFailureInvalid     = "invalid"
+	tokenFailureUnknownUser = "unknown_user"
+)
+
+// TokenVersions looks up a user's current access-token version. Tokens
+// carrying an older version were issued before a password change, reset or
+// suspension and are rejected.
+type TokenVersions interface {
+	TokenVersion(ctx context.Context, userID string) (int, error)
+}
+
+// Authenticate validates the bearer token and, when versions is non-nil,
+// checks its version claim against the user's current one. When
+// accessCookie is set, a request without an Authorization header is
+// authenticated with the token in that cookie instead. Rejected tokens are
+// counted by reason on the collector's AuthTokenFailures; collector may be
+// nil.
+func Authenticate(jwtService jwt.Service, versions TokenVersions, accessCookie string, collector *apm.MetricsCollector) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		recordFailure := func(reason string) {
+			if collector != nil && collector.IsEnabled() {
+				collector.AuthTokenFailures.Add(ctx.Request.Context(), 1, metric.WithAttributes(attribute.String("reason", reason)))
+			}
+		}
+
+		// Already authenticated further up the chain (e.g. by AuthenticateExcept)
+		if _, exists := ctx.Get(constants.CtxKeyUserID); exists {
+			ctx.Next()
+			return
+		}
+
 		authHeader := ctx.GetHeader("Authorization")
+		if authHeader == "" && accessCookie != "" {
+			if token, err := ctx.Cookie(accessCookie); err == nil && token != "" {
+				authHeader = "Bearer " + token
+			}
+		}
 
 		if authHeader == "" {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, response.Error[any](
-				response.ErrCodeUnauthorized,
-				"token not found",
-			))
+			// No credentials at all: RFC 6750 says the challenge carries no error code
+			recordFailure(tokenFailureMissing)
+			abortUnauthorized(ctx, "token not found", `Bearer realm="api"`)
 			return
 		}
 
 		if !strings.Contains(authHeader, "Bearer ") {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, response.Error[any](
-				response.ErrCodeUnauthorized,
-				"invalid token format",
-			))
+			recordFailure(tokenFailureMalformed)
+			abortUnauthorized(ctx, "invalid token format",
+				bearerChallenge("invalid_request", "authorization header must use the Bearer scheme"))
 			return
 		}
 
 		authHeader = strings.TrimPrefix(authHeader, "Bearer ")
 		token, err := jwtService.ValidateToken(authHeader)
+		if isTokenExpired(err) {
+			// Distinct from other failures so clients know to refresh rather than re-login
+			recordFailure(tokenFailureExpired)
+			ctx.Header("WWW-Authenticate", bearerChallenge("invalid_token", "token expired"))
+			response.AbortWithJSON(ctx, http.StatusUnauthorized, response.Error[any](
+				response.ErrCodeTokenExpired, "token expired"))
+			return
+		}
+		if errors.Is(err, jwt.ErrTokenRevoked) {
+			recordFailure(tokenFailureRevoked)
+			abortUnauthorized(ctx, "token revoked",
+				bearerChallenge("invalid_token", "token revoked"))
+			return
+		}
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, response.Error[any](
-				response.ErrCodeUnauthorized,
-				"invalid token",
-			))
+			recordFailure(tokenFailureMalformed)
+			abortUnauthorized(ctx, "invalid token",
+				bearerChallenge("invalid_token", "token is malformed or has an invalid signature"))
 			return
 		}
 
 		if !token.Valid {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, response.Error[any](
-				response.ErrCodeUnauthorized,
-				"access denied",
-			))
+			recordFailure(tokenFailureInvalid)
+			abortUnauthorized(ctx, "access denied",
+				bearerChallenge("invalid_token", "token is no longer valid"))
 			return
 		}
 
 		userID, err := jwtService.GetUserIDByToken(authHeader)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, response.Error[any](
-				response.ErrCodeUnauthorized,
-				err.Error(),
-			))
+			recordFailure(tokenFailureInvalid)
+			abortUnauthorized(ctx, err.Error(),
+				bearerChallenge("invalid_token", "token does not identify a user"))
 			return
 		}
 
+		var role, tenantID, sessionID string
+		var audience []string
+		var tokenVersion int
+		if claims, ok := token.Claims.(gojwt.MapClaims); ok {
+			role, _ = claims["role"].(string)
+			tenantID, _ = claims["tenant_id"].(string)
+			sessionID, _ = claims["sid"].(string)
+			audience = tokenAudience(claims["aud"])
+			tokenVersion = jwt.TokenVersion(claims)
+		}
+
+		if !tenant.Valid(tenantID) {
+			tenantID = ""
+		}
+		// The token's tenant scopes the version lookup below; one resolved
+		// earlier from the header or subdomain must agree with it
+		if tenantID != "" {
+			if resolved, ok := tenant.FromContext(ctx.Request.Context()); ok && resolved != tenantID {
+				status, body := response.Forbidden[any](tenantMismatchMessage)
+				response.AbortWithJSON(ctx, status, body)
+				return
+			}
+			setTenant(ctx, tenantID)
+		}
+
+		if versions != nil {
+			current, err := versions.TokenVersion(ctx.Request.Context(), userID)
+			if errors.Is(err, sql.ErrNoRows) {
+				recordFailure(tokenFailureUnknownUser)
+				abortUnauthorized(ctx, "user not found",
+					bearerChallenge("invalid_token", "token does not identify a user"))
+				return
+			}
+			if err != nil {
+				response.AbortWithJSON(ctx, http.StatusServiceUnavailable, response.Error[any](
+					response.ErrCodeServiceUnavailable, "unable to verify token"))
+				return
+			}
+			if tokenVersion < current {
+				recordFailure(tokenFailureRevoked)
+				abortUnauthorized(ctx, "token revoked",
+					bearerChallenge("invalid_token", "token revoked"))
+				return
+			}
+		}
+
+		var roles []string
+		if role != "" {
+			roles = []string{role}
+		}
+
 		ctx.Set(constants.CtxKeyToken, authHeader)
 		ctx.Set(constants.CtxKeyUserID, userID)
+		setAuthContext(ctx, AuthContext{UserID: userID, Role: role, Roles: roles, TenantID: tenantID, SessionID: sessionID, Audience: audience})
 		ctx.Next()
 	}
 }
+
+// abortUnauthorized writes a 401 with the WWW-Authenticate challenge clients
+// following RFC 7235 expect.
+func abortUnauthorized(ctx *gin.Context, message, challenge string) {
+	ctx.Header("WWW-Authenticate", challenge)
+	status, body := response.Unauthorized[any](message)
+	response.AbortWithJSON(ctx, status, body)
+}
+
+func bearerChallenge(errCode, description string) string {
+	return `Bearer realm="api", error="` + errCode + `", error_description="` + description + `"`
+}
+
+func isTokenExpired(err error) bool {
+	var validationErr *gojwt.ValidationError
+	return errors.As(err, &validationErr) && validationErr.Errors&gojwt.ValidationErrorExpired != 0
+}
+
+// AuthenticateExcept protects every route by default. Requests whose path is
+// in publicPaths pass through untouched; all others go through Authenticate.
+func AuthenticateExcept(jwtService jwt.Service, versions TokenVersions, accessCookie string, collector *apm.MetricsCollector, publicPaths []string) gin.HandlerFunc {
+	public := make(map[string]struct{}, len(publicPaths))
+	for _, path := range publicPaths {
+		public[path] = struct{}{}
+	}
+
+	authenticate := Authenticate(jwtService, versions, accessCookie, collector)
+
+	return func(ctx *gin.Context) {
+		if _, ok := public[ctx.Request.URL.Path]; ok {
+			ctx.Next()
+			return
+		}
+
+		authenticate(ctx)
+	}
+}
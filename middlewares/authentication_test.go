@@ -0,0 +1,344 @@
+package middlewares
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/elskow/go-microservice-template/config"
+	"github.com/elskow/go-microservice-template/pkg/apm"
+	"github.com/elskow/go-microservice-template/pkg/constants"
+	"github.com/elskow/go-microservice-template/pkg/jwt"
+	"github.com/elskow/go-microservice-template/pkg/response"
+	"github.com/gin-gonic/gin"
+	gojwt "github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func setupGlobalAuthRouter(jwtService jwt.Service) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(AuthenticateExcept(jwtService, nil, "", nil, []string{"/api/account/login", "/health"}))
+
+	ok := func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString(constants.CtxKeyUserID))
+	}
+	router.GET("/health", ok)
+	router.POST("/api/account/login", ok)
+	router.GET("/api/orders", ok)
+
+	return router
+}
+
+func TestAuthenticateExcept_PublicPathSkipsAuth(t *testing.T) {
+	router := setupGlobalAuthRouter(jwt.NewService())
+
+	for _, tc := range []struct{ method, path string }{
+		{http.MethodGet, "/health"},
+		{http.MethodPost, "/api/account/login"},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code, tc.path)
+	}
+}
+
+func TestAuthenticateExcept_UnlistedPathRequiresAuth(t *testing.T) {
+	router := setupGlobalAuthRouter(jwt.NewService())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/orders", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestAuthenticateExcept_UnlistedPathWithValidToken(t *testing.T) {
+	jwtService := jwt.NewService()
+	router := setupGlobalAuthRouter(jwtService)
+
+	userID := uuid.NewString()
+	token, err := jwtService.GenerateAccessToken(userID, "user")
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/orders", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, userID, w.Body.String())
+}
+
+func signTestToken(t *testing.T, claims gojwt.MapClaims) string {
+	token, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, claims).
+		SignedString([]byte(config.Get().JWTSecret))
+	require.NoError(t, err)
+	return token
+}
+
+func performAuthRequest(jwtService jwt.Service, authHeader string) *httptest.ResponseRecorder {
+	return performVersionedAuthRequest(jwtService, nil, authHeader)
+}
+
+func performVersionedAuthRequest(jwtService jwt.Service, versions TokenVersions, authHeader string) *httptest.ResponseRecorder {
+	return performCountedAuthRequest(jwtService, versions, nil, authHeader)
+}
+
+func performCountedAuthRequest(jwtService jwt.Service, versions TokenVersions, collector *apm.MetricsCollector, authHeader string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", Authenticate(jwtService, versions, "", collector), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if authHeader != "" {
+		req.Header.Set("Authorization", authHeader)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+type tokenVersionsFunc func(ctx context.Context, userID string) (int, error)
+
+func (f tokenVersionsFunc) TokenVersion(ctx context.Context, userID string) (int, error) {
+	return f(ctx, userID)
+}
+
+func decodeErrorCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	var resp response.Response[any]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.NotNil(t, resp.Error)
+	return resp.Error.ErrorCode
+}
+
+func TestAuthenticate_AccessCookie(t *testing.T) {
+	jwtService := jwt.NewService()
+	token, err := jwtService.GenerateAccessToken(uuid.NewString(), "user")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		accessCookie string
+		cookie       string
+		want         int
+	}{
+		{name: "cookie mode reads the cookie", accessCookie: "access_token", cookie: token, want: http.StatusOK},
+		{name: "cookie mode with an invalid cookie", accessCookie: "access_token", cookie: "garbage", want: http.StatusUnauthorized},
+		{name: "cookie mode without the cookie", accessCookie: "access_token", want: http.StatusUnauthorized},
+		{name: "cookie ignored outside cookie mode", cookie: token, want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/", Authenticate(jwtService, nil, tt.accessCookie, nil), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "access_token", Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
+
+func TestAuthenticate_ExpiredToken(t *testing.T) {
+	token := signTestToken(t, gojwt.MapClaims{
+		"user_id": uuid.NewString(),
+		"role":    "user",
+		"iat":     time.Now().Add(-time.Hour).Unix(),
+		"exp":     time.Now().Add(-time.Minute).Unix(),
+	})
+
+	w := performAuthRequest(jwt.NewService(), "Bearer "+token)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, response.ErrCodeTokenExpired, decodeErrorCode(t, w))
+	assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+}
+
+func TestAuthenticate_MalformedToken(t *testing.T) {
+	w := performAuthRequest(jwt.NewService(), "Bearer not-a-jwt")
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, response.ErrCodeUnauthorized, decodeErrorCode(t, w))
+}
+
+func TestAuthenticate_WWWAuthenticateHeader(t *testing.T) {
+	expired := signTestToken(t, gojwt.MapClaims{
+		"user_id": uuid.NewString(),
+		"iat":     time.Now().Add(-time.Hour).Unix(),
+		"exp":     time.Now().Add(-time.Minute).Unix(),
+	})
+
+	tests := []struct {
+		name       string
+		authHeader string
+		want       string
+	}{
+		{
+			name:       "missing token",
+			authHeader: "",
+			want:       `Bearer realm="api"`,
+		},
+		{
+			name:       "invalid format",
+			authHeader: "Token abc",
+			want:       `Bearer realm="api", error="invalid_request", error_description="authorization header must use the Bearer scheme"`,
+		},
+		{
+			name:       "expired",
+			authHeader: "Bearer " + expired,
+			want:       `Bearer realm="api", error="invalid_token", error_description="token expired"`,
+		},
+		{
+			name:       "malformed",
+			authHeader: "Bearer not-a-jwt",
+			want:       `Bearer realm="api", error="invalid_token", error_description="token is malformed or has an invalid signature"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := performAuthRequest(jwt.NewService(), tt.authHeader)
+
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.Equal(t, tt.want, w.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
+
+func TestAuthenticate_RevokedToken(t *testing.T) {
+	jwtService := jwt.NewService()
+
+	token, err := jwtService.GenerateSessionAccessToken(uuid.NewString(), "user", "", "session-id", 0)
+	require.NoError(t, err)
+	jwtService.RevokeSession("session-id")
+
+	w := performAuthRequest(jwtService, "Bearer "+token)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Bearer realm="api", error="invalid_token", error_description="token revoked"`,
+		w.Header().Get("WWW-Authenticate"))
+}
+
+func TestAuthenticate_TokenVersion(t *testing.T) {
+	jwtService := jwt.NewService()
+	userID := uuid.NewString()
+
+	token, err := jwtService.GenerateSessionAccessToken(userID, "user", "", "session-id", 1)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		current  int
+		err      error
+		wantCode int
+	}{
+		{name: "current version", current: 1, wantCode: http.StatusOK},
+		{name: "version bumped", current: 2, wantCode: http.StatusUnauthorized},
+		{name: "unknown user", err: sql.ErrNoRows, wantCode: http.StatusUnauthorized},
+		{name: "lookup failure", err: errors.New("connection refused"), wantCode: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			versions := tokenVersionsFunc(func(_ context.Context, id string) (int, error) {
+				assert.Equal(t, userID, id)
+				return tt.current, tt.err
+			})
+
+			w := performVersionedAuthRequest(jwtService, versions, "Bearer "+token)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+		})
+	}
+}
+
+func TestAuthenticate_CountsValidationFailuresByReason(t *testing.T) {
+	jwtService := jwt.NewService()
+	userID := uuid.NewString()
+	valid, err := jwtService.GenerateAccessToken(userID, "user")
+	require.NoError(t, err)
+	expired := signTestToken(t, gojwt.MapClaims{
+		"user_id": userID,
+		"role":    "user",
+		"iat":     time.Now().Add(-time.Hour).Unix(),
+		"exp":     time.Now().Add(-time.Minute).Unix(),
+	})
+
+	tests := []struct {
+		name       string
+		authHeader string
+		versions   TokenVersions
+		wantReason string
+	}{
+		{name: "missing", authHeader: "", wantReason: "missing"},
+		{name: "wrong scheme", authHeader: "Basic dXNlcjpwYXNz", wantReason: "malformed"},
+		{name: "malformed", authHeader: "Bearer not-a-jwt", wantReason: "malformed"},
+		{name: "expired", authHeader: "Bearer " + expired, wantReason: "expired"},
+		{
+			name:       "revoked by version",
+			authHeader: "Bearer " + valid,
+			versions: tokenVersionsFunc(func(context.Context, string) (int, error) {
+				return 5, nil
+			}),
+			wantReason: "revoked",
+		},
+		{
+			name:       "unknown user",
+			authHeader: "Bearer " + valid,
+			versions: tokenVersionsFunc(func(context.Context, string) (int, error) {
+				return 0, sql.ErrNoRows
+			}),
+			wantReason: "unknown_user",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector, reader := newTestCollector(t)
+
+			w := performCountedAuthRequest(jwtService, tt.versions, collector, tt.authHeader)
+			require.Equal(t, http.StatusUnauthorized, w.Code)
+
+			sum, ok := collectMetrics(t, reader)["auth_token_validation_failures_total"].(metricdata.Sum[int64])
+			require.True(t, ok)
+			require.Len(t, sum.DataPoints, 1)
+			reason, _ := sum.DataPoints[0].Attributes.Value("reason")
+			assert.Equal(t, tt.wantReason, reason.AsString())
+			assert.Equal(t, int64(1), sum.DataPoints[0].Value)
+		})
+	}
+}
+
+func TestAuthenticate_ValidTokenRecordsNoFailure(t *testing.T) {
+	collector, reader := newTestCollector(t)
+	jwtService := jwt.NewService()
+	token, err := jwtService.GenerateAccessToken(uuid.NewString(), "user")
+	require.NoError(t, err)
+
+	w := performCountedAuthRequest(jwtService, nil, collector, "Bearer "+token)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	_, recorded := collectMetrics(t, reader)["auth_token_validation_failures_total"]
+	assert.False(t, recorded)
+}
@@ -0,0 +1,113 @@
+package middlewares
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PathBlacklist holds the request paths left out of access logs and traces.
+// The slog and otelgin middlewares share one instance, and Set swaps the
+// paths for both while requests are in flight.
+//
+// Each entry is matched as:
+//   - "re:<expr>", a regular expression, e.g. "re:^/v[0-9]+/health$";
+//   - a prefix when it ends in "*" and has no other wildcard, e.g.
+//     "/internal/*" for everything under /internal/;
+//   - a path.Match glob when it contains *, ? or [, e.g. "/users/*/avatar";
+//   - otherwise the exact path.
+type PathBlacklist struct {
+	matcher atomic.Pointer[pathMatcher]
+}
+
+// NewPathBlacklist returns a blacklist holding paths, or an error naming the
+// first invalid pattern.
+func NewPathBlacklist(paths []string) (*PathBlacklist, error) {
+	b := &PathBlacklist{}
+	if err := b.Set(paths); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Set replaces the blacklisted paths. On an invalid pattern it returns an
+// error and keeps the previous paths.
+func (b *PathBlacklist) Set(paths []string) error {
+	matcher, err := newPathMatcher(paths)
+	if err != nil {
+		return err
+	}
+	b.matcher.Store(matcher)
+	return nil
+}
+
+// Contains reports whether path is blacklisted. A nil blacklist holds
+// nothing.
+func (b *PathBlacklist) Contains(path string) bool {
+	if b == nil {
+		return false
+	}
+	return b.matcher.Load().match(path)
+}
+
+// Traced is an otelgin.GinFilter that skips tracing blacklisted paths.
+func (b *PathBlacklist) Traced(c *gin.Context) bool {
+	return !b.Contains(c.Request.URL.Path)
+}
+
+type pathMatcher struct {
+	exact    map[string]struct{}
+	prefixes []string
+	globs    []string
+	patterns []*regexp.Regexp
+}
+
+func newPathMatcher(paths []string) (*pathMatcher, error) {
+	m := &pathMatcher{exact: make(map[string]struct{}, len(paths))}
+	for _, p := range paths {
+		switch {
+		case strings.HasPrefix(p, "re:"):
+			pattern, err := regexp.Compile(strings.TrimPrefix(p, "re:"))
+			if err != nil {
+				return nil, fmt.Errorf("blacklist path %q: %w", p, err)
+			}
+			m.patterns = append(m.patterns, pattern)
+		case strings.HasSuffix(p, "*") && !strings.ContainsAny(p[:len(p)-1], "*?[\\"):
+			m.prefixes = append(m.prefixes, strings.TrimSuffix(p, "*"))
+		case strings.ContainsAny(p, "*?[\\"):
+			if _, err := path.Match(p, ""); err != nil {
+				return nil, fmt.Errorf("blacklist path %q: %w", p, err)
+			}
+			m.globs = append(m.globs, p)
+		default:
+			m.exact[p] = struct{}{}
+		}
+	}
+	return m, nil
+}
+
+func (m *pathMatcher) match(p string) bool {
+	if _, ok := m.exact[p]; ok {
+		return true
+	}
+	for _, prefix := range m.prefixes {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	for _, glob := range m.globs {
+		if ok, _ := path.Match(glob, p); ok {
+			return true
+		}
+	}
+	for _, pattern := range m.patterns {
+		if pattern.MatchString(p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package middlewares
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestPathBlacklist_Contains(t *testing.T) {
+	blacklist, err := NewPathBlacklist([]string{
+		"/health",
+		"/internal/*",
+		"/users/*/avatar",
+		`re:^/v[0-9]+/ready$`,
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "/health", want: true},
+		{path: "/health/live", want: false},
+		{path: "/internal/", want: true},
+		{path: "/internal/debug/pprof", want: true},
+		{path: "/internal", want: false},
+		{path: "/users/42/avatar", want: true},
+		{path: "/users/42/posts/avatar", want: false},
+		{path: "/v2/ready", want: true},
+		{path: "/v2/ready/now", want: false},
+		{path: "/api/account/me", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.want, blacklist.Contains(tt.path))
+		})
+	}
+}
+
+func TestPathBlacklist_Set(t *testing.T) {
+	blacklist, err := NewPathBlacklist([]string{"/health"})
+	require.NoError(t, err)
+	assert.True(t, blacklist.Contains("/health"))
+	assert.False(t, blacklist.Contains("/metrics"))
+
+	require.NoError(t, blacklist.Set([]string{"/metrics"}))
+	assert.False(t, blacklist.Contains("/health"))
+	assert.True(t, blacklist.Contains("/metrics"))
+
+	// An invalid pattern leaves the previous paths in place
+	assert.Error(t, blacklist.Set([]string{"/health", "re:("}))
+	assert.Error(t, blacklist.Set([]string{"/users/[/avatar"}))
+	assert.False(t, blacklist.Contains("/health"))
+	assert.True(t, blacklist.Contains("/metrics"))
+
+	var none *PathBlacklist
+	assert.False(t, none.Contains("/health"))
+}
+
+// The access log and the tracer share one blacklist, so a reload applies to
+// both without restarting the server.
+func TestPathBlacklist_SharedBySlogAndOtelgin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var logs bytes.Buffer
+	spans := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	blacklist, err := NewPathBlacklist([]string{"/health"})
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.Use(otelgin.Middleware("test",
+		otelgin.WithTracerProvider(provider),
+		otelgin.WithGinFilter(blacklist.Traced),
+	))
+	router.Use(SlogMiddleware(slog.New(slog.NewJSONHandler(&logs, nil)), blacklist))
+	router.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/ready", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	request := func(path string) {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	request("/health")
+	assert.Empty(t, logs.String())
+	assert.Empty(t, spans.Ended())
+
+	request("/ready")
+	assert.Contains(t, logs.String(), `"/ready"`)
+	assert.Len(t, spans.Ended(), 1)
+
+	require.NoError(t, blacklist.Set([]string{"/ready"}))
+	logs.Reset()
+
+	request("/ready")
+	assert.Empty(t, logs.String())
+	assert.Len(t, spans.Ended(), 1)
+
+	request("/health")
+	assert.Contains(t, logs.String(), `"/health"`)
+	assert.Len(t, spans.Ended(), 2)
+}
@@ -0,0 +1,73 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/elskow/go-microservice-template/pkg/captcha"
+	"github.com/elskow/go-microservice-template/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+// maxCaptchaBody bounds how much of the body Captcha reads; a token past it
+// is treated as missing.
+const maxCaptchaBody = 64 << 10
+
+type captchaPayload struct {
+	CaptchaToken string `json:"captcha_token"`
+}
+
+// Captcha verifies the captcha_token field of a JSON body before the handler
+// runs. The body is restored so handlers can still bind it. When enabled is
+// false the middleware is a no-op.
+func Captcha(verifier captcha.Verifier, enabled bool) gin.HandlerFunc {
+	if !enabled || verifier == nil {
+		return func(ctx *gin.Context) {
+			ctx.Next()
+		}
+	}
+
+	return func(ctx *gin.Context) {
+		body, err := io.ReadAll(io.LimitReader(ctx.Request.Body, maxCaptchaBody))
+		ctx.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), ctx.Request.Body))
+		if err != nil {
+			response.AbortWithJSON(ctx, http.StatusBadRequest, response.Error[any](
+				response.ErrCodeValidationFailed,
+				"failed to read request body",
+			))
+			return
+		}
+
+		var payload captchaPayload
+		_ = json.Unmarshal(body, &payload)
+
+		if payload.CaptchaToken == "" {
+			response.AbortWithJSON(ctx, http.StatusBadRequest, response.Error[any](
+				response.ErrCodeCaptchaFailed,
+				"captcha_token is required",
+			))
+			return
+		}
+
+		err = verifier.Verify(ctx.Request.Context(), payload.CaptchaToken, ctx.ClientIP())
+		if errors.Is(err, captcha.ErrInvalidToken) {
+			response.AbortWithJSON(ctx, http.StatusBadRequest, response.Error[any](
+				response.ErrCodeCaptchaFailed,
+				"captcha verification failed",
+			))
+			return
+		}
+		if err != nil {
+			response.AbortWithJSON(ctx, http.StatusServiceUnavailable, response.Error[any](
+				response.ErrCodeInternalServerError,
+				"captcha verification is unavailable, please try again later",
+			))
+			return
+		}
+
+		ctx.Next()
+	}
+}
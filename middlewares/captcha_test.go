@@ -0,0 +1,94 @@
+package middlewares
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/elskow/go-microservice-template/pkg/captcha"
+	"github.com/elskow/go-microservice-template/pkg/response"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubVerifier struct {
+	valid  string
+	called bool
+}
+
+func (s *stubVerifier) Verify(ctx context.Context, token, remoteIP string) error {
+	s.called = true
+	if token != s.valid {
+		return captcha.ErrInvalidToken
+	}
+	return nil
+}
+
+func performCaptchaRequest(verifier captcha.Verifier, enabled bool, body string) (*httptest.ResponseRecorder, string) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	var handlerBody string
+	router.POST("/", Captcha(verifier, enabled), func(c *gin.Context) {
+		raw, _ := io.ReadAll(c.Request.Body)
+		handlerBody = string(raw)
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w, handlerBody
+}
+
+func TestCaptcha_Pass(t *testing.T) {
+	verifier := &stubVerifier{valid: "ok-token"}
+	body := `{"email":"john@example.com","captcha_token":"ok-token"}`
+
+	w, handlerBody := performCaptchaRequest(verifier, true, body)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, verifier.called)
+	assert.Equal(t, body, handlerBody)
+}
+
+func TestCaptcha_Fail(t *testing.T) {
+	verifier := &stubVerifier{valid: "ok-token"}
+
+	w, _ := performCaptchaRequest(verifier, true, `{"captcha_token":"forged"}`)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, response.ErrCodeCaptchaFailed, decodeErrorCode(t, w))
+}
+
+func TestCaptcha_MissingToken(t *testing.T) {
+	verifier := &stubVerifier{valid: "ok-token"}
+
+	w, _ := performCaptchaRequest(verifier, true, `{"email":"john@example.com"}`)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.False(t, verifier.called)
+}
+
+func TestCaptcha_Disabled(t *testing.T) {
+	verifier := &stubVerifier{valid: "ok-token"}
+
+	w, _ := performCaptchaRequest(verifier, false, `{"email":"john@example.com"}`)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, verifier.called)
+}
+
+func TestCaptcha_OversizedBodyIsNotBuffered(t *testing.T) {
+	verifier := &stubVerifier{valid: "ok-token"}
+	body := `{"padding":"` + strings.Repeat("x", maxCaptchaBody) + `","captcha_token":"ok-token"}`
+
+	w, _ := performCaptchaRequest(verifier, true, body)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.False(t, verifier.called)
+}
@@ -0,0 +1,130 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// maxErrorBodyRead bounds how much of the body ErrorBodyCapture buffers;
+	// larger bodies cannot be parsed for redaction and are not captured.
+	maxErrorBodyRead = 64 << 10
+	// maxErrorBodySnippet bounds the redacted body attached to the span.
+	maxErrorBodySnippet = 2 << 10
+
+	redactedBodyValue = "[REDACTED]"
+)
+
+// credentialBodyKeys are the credential fields the request DTOs accept.
+// ErrorBodyCapture always redacts them, whatever redactKeys holds.
+var credentialBodyKeys = []string{
+	"password", "token", "refresh_token", "code", "totp_code", "backup_code",
+}
+
+// ErrorBodyCapture attaches the request body to the request span as
+// http.request.body when the response is a 5xx, to help reproduce server
+// errors. Only JSON bodies are captured, with the values of redactKeys and
+// credentialBodyKeys replaced at any depth; others leave just http.request.body.omitted. The
+// body is restored so handlers can still bind it. When enabled is false the
+// middleware is a no-op.
+//
+// It must run inside otelgin so the request span is on the context.
+func ErrorBodyCapture(enabled bool, redactKeys []string) gin.HandlerFunc {
+	if !enabled {
+		return func(ctx *gin.Context) {
+			ctx.Next()
+		}
+	}
+
+	redact := make(map[string]struct{}, len(redactKeys)+len(credentialBodyKeys))
+	for _, key := range slices.Concat(credentialBodyKeys, redactKeys) {
+		redact[strings.ToLower(key)] = struct{}{}
+	}
+
+	return func(ctx *gin.Context) {
+		span := trace.SpanFromContext(ctx.Request.Context())
+		if !span.IsRecording() || ctx.Request.Body == nil || ctx.Request.Body == http.NoBody {
+			ctx.Next()
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(ctx.Request.Body, maxErrorBodyRead+1))
+		ctx.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), ctx.Request.Body))
+
+		ctx.Next()
+
+		if ctx.Writer.Status() < http.StatusInternalServerError {
+			return
+		}
+
+		switch {
+		case err != nil:
+			span.SetAttributes(attribute.String("http.request.body.omitted", "unreadable"))
+		case len(body) > maxErrorBodyRead:
+			span.SetAttributes(attribute.String("http.request.body.omitted", "too large"))
+		default:
+			snippet, ok := redactBody(body, redact)
+			if !ok {
+				span.SetAttributes(attribute.String("http.request.body.omitted", "not json"))
+				return
+			}
+			truncated := len(snippet) > maxErrorBodySnippet
+			if truncated {
+				// Cut on a rune boundary so the attribute stays valid UTF-8
+				end := maxErrorBodySnippet
+				for end > 0 && !utf8.RuneStart(snippet[end]) {
+					end--
+				}
+				snippet = snippet[:end]
+			}
+			span.SetAttributes(
+				attribute.String("http.request.body", string(snippet)),
+				attribute.Bool("http.request.body.truncated", truncated),
+			)
+		}
+	}
+}
+
+// redactBody re-encodes the JSON body with the values of redact keys
+// replaced. It reports false when body is not JSON.
+func redactBody(body []byte, redact map[string]struct{}) ([]byte, bool) {
+	var payload any
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&payload); err != nil {
+		return nil, false
+	}
+
+	redacted, err := json.Marshal(redactValue(payload, redact))
+	if err != nil {
+		return nil, false
+	}
+	return redacted, true
+}
+
+func redactValue(value any, redact map[string]struct{}) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if _, ok := redact[strings.ToLower(key)]; ok {
+				v[key] = redactedBodyValue
+				continue
+			}
+			v[key] = redactValue(item, redact)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = redactValue(item, redact)
+		}
+	}
+	return value
+}
@@ -0,0 +1,124 @@
+package middlewares
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// serveErrorBody sends body to a handler answering status behind otelgin and
+// ErrorBodyCapture, returning the attributes of the request span and the
+// body the handler read.
+func serveErrorBody(t *testing.T, enabled bool, status int, body string) (map[attribute.Key]attribute.Value, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	spans := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+
+	var bound string
+	router := gin.New()
+	router.Use(otelgin.Middleware("test", otelgin.WithTracerProvider(provider)))
+	router.Use(ErrorBodyCapture(enabled, []string{"password", "token"}))
+	router.POST("/login", func(c *gin.Context) {
+		raw, err := io.ReadAll(c.Request.Body)
+		require.NoError(t, err)
+		bound = string(raw)
+		c.Status(status)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	ended := spans.Ended()
+	require.Len(t, ended, 1)
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range ended[0].Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs, bound
+}
+
+func TestErrorBodyCapture_AttachesRedactedBodyOn5xx(t *testing.T) {
+	body := `{"email":"jane@example.com","password":"hunter2","device":{"token":"abc","name":"laptop"}}`
+
+	attrs, bound := serveErrorBody(t, true, http.StatusInternalServerError, body)
+
+	assert.Equal(t, body, bound, "the handler still reads the full body")
+	captured, ok := attrs["http.request.body"]
+	require.True(t, ok)
+	assert.JSONEq(t,
+		`{"email":"jane@example.com","password":"[REDACTED]","device":{"token":"[REDACTED]","name":"laptop"}}`,
+		captured.AsString(),
+	)
+	assert.NotContains(t, captured.AsString(), "hunter2")
+	assert.False(t, attrs["http.request.body.truncated"].AsBool())
+}
+
+func TestErrorBodyCapture_RedactsLoginSecondFactor(t *testing.T) {
+	body := `{"email":"jane@example.com","password":"hunter2","totp_code":"123456","backup_code":"a1b2-c3d4"}`
+
+	attrs, _ := serveErrorBody(t, true, http.StatusInternalServerError, body)
+
+	captured, ok := attrs["http.request.body"]
+	require.True(t, ok)
+	assert.JSONEq(t,
+		`{"email":"jane@example.com","password":"[REDACTED]","totp_code":"[REDACTED]","backup_code":"[REDACTED]"}`,
+		captured.AsString(),
+	)
+	assert.NotContains(t, captured.AsString(), "a1b2-c3d4")
+}
+
+func TestErrorBodyCapture_AbsentWhenDisabled(t *testing.T) {
+	attrs, bound := serveErrorBody(t, false, http.StatusInternalServerError, `{"email":"jane@example.com"}`)
+
+	assert.Equal(t, `{"email":"jane@example.com"}`, bound)
+	assert.NotContains(t, attrs, attribute.Key("http.request.body"))
+	assert.NotContains(t, attrs, attribute.Key("http.request.body.omitted"))
+}
+
+func TestErrorBodyCapture_AbsentBelow5xx(t *testing.T) {
+	for _, status := range []int{http.StatusOK, http.StatusBadRequest, http.StatusUnauthorized} {
+		attrs, _ := serveErrorBody(t, true, status, `{"email":"jane@example.com"}`)
+		assert.NotContains(t, attrs, attribute.Key("http.request.body"), "status %d", status)
+	}
+}
+
+func TestErrorBodyCapture_OmitsUnparseableBodies(t *testing.T) {
+	attrs, bound := serveErrorBody(t, true, http.StatusBadGateway, "password=hunter2")
+
+	assert.Equal(t, "password=hunter2", bound)
+	assert.NotContains(t, attrs, attribute.Key("http.request.body"))
+	assert.Equal(t, "not json", attrs["http.request.body.omitted"].AsString())
+
+	large := `{"note":"` + strings.Repeat("x", maxErrorBodyRead) + `"}`
+	attrs, bound = serveErrorBody(t, true, http.StatusInternalServerError, large)
+
+	assert.Equal(t, large, bound)
+	assert.NotContains(t, attrs, attribute.Key("http.request.body"))
+	assert.Equal(t, "too large", attrs["http.request.body.omitted"].AsString())
+}
+
+func TestErrorBodyCapture_TruncatesSnippet(t *testing.T) {
+	body := `{"note":"` + strings.Repeat("é", maxErrorBodySnippet) + `"}`
+
+	attrs, _ := serveErrorBody(t, true, http.StatusInternalServerError, body)
+
+	captured := attrs["http.request.body"].AsString()
+	assert.LessOrEqual(t, len(captured), maxErrorBodySnippet)
+	assert.True(t, strings.HasPrefix(captured, `{"note":"éé`))
+	assert.True(t, attrs["http.request.body.truncated"].AsBool())
+	assert.True(t, utf8.ValidString(captured))
+}
@@ -0,0 +1,37 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/elskow/go-microservice-template/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+const msgUnexpectedError = "An unexpected error occurred. Please try again later."
+
+// ErrorHandler renders the error envelope for handlers that attach an error
+// with c.Error instead of writing a response. The last attached error wins:
+// a *response.HTTPError is rendered with its own status, code and detail,
+// while any other error becomes a 500 that does not leak its text. Handlers
+// that already wrote a response are left alone.
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		var httpErr *response.HTTPError
+		if errors.As(c.Errors.Last().Err, &httpErr) {
+			response.JSON(c, httpErr.StatusCode, response.ErrorWithDetail[any](httpErr.Code, httpErr.Message, httpErr.Detail))
+			return
+		}
+
+		response.JSON(c, http.StatusInternalServerError, response.Error[any](
+			response.ErrCodeInternalServerError,
+			msgUnexpectedError,
+		))
+	}
+}
@@ -0,0 +1,95 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/elskow/go-microservice-template/pkg/response"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		handler    gin.HandlerFunc
+		wantStatus int
+		wantCode   string
+		wantDetail string
+	}{
+		{
+			name: "http error keeps its status, code and detail",
+			handler: func(c *gin.Context) {
+				_ = c.Error(&response.HTTPError{StatusCode: http.StatusNotFound, Code: response.ErrCodeNotFound, Message: "user not found", Detail: "id=42"})
+			},
+			wantStatus: http.StatusNotFound,
+			wantCode:   response.ErrCodeNotFound,
+			wantDetail: "id=42",
+		},
+		{
+			name: "wrapped http error is unwrapped",
+			handler: func(c *gin.Context) {
+				_ = c.Error(errors.Join(errors.New("lookup"), &response.HTTPError{StatusCode: http.StatusConflict, Code: response.ErrCodeConflict, Message: "taken"}))
+			},
+			wantStatus: http.StatusConflict,
+			wantCode:   response.ErrCodeConflict,
+		},
+		{
+			name: "last error wins",
+			handler: func(c *gin.Context) {
+				_ = c.Error(errors.New("first"))
+				_ = c.Error(&response.HTTPError{StatusCode: http.StatusBadRequest, Code: response.ErrCodeValidationFailed, Message: "bad"})
+			},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   response.ErrCodeValidationFailed,
+		},
+		{
+			name: "plain error becomes a 500 without its text",
+			handler: func(c *gin.Context) {
+				_ = c.Error(errors.New("pq: connection refused"))
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   response.ErrCodeInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(ErrorHandler())
+			router.GET("/", tt.handler)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			var resp response.Response[any]
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			require.NotNil(t, resp.Error)
+			assert.Equal(t, tt.wantCode, resp.Error.ErrorCode)
+			assert.Equal(t, tt.wantDetail, resp.Error.ErrorDetail)
+			assert.NotContains(t, w.Body.String(), "connection refused")
+		})
+	}
+}
+
+func TestErrorHandler_LeavesWrittenResponses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.GET("/", func(c *gin.Context) {
+		_ = c.Error(errors.New("logged only"))
+		c.JSON(http.StatusAccepted, response.Success("queued"))
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Contains(t, w.Body.String(), "queued")
+}
@@ -0,0 +1,124 @@
+package middlewares
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/elskow/go-microservice-template/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireHTTPS redirects plaintext requests to https, or rejects them with a
+// 400 when redirect is false. A request counts as https when it arrived over
+// TLS or when a proxy in trustedProxies (IPs or CIDRs) forwarded it with
+// X-Forwarded-Proto: https; the header is ignored from anyone else. Requests
+// whose path is in exemptPaths, such as health probes, are let through. When
+// enabled is false the middleware does nothing.
+func RequireHTTPS(enabled, redirect bool, trustedProxies, exemptPaths []string) (gin.HandlerFunc, error) {
+	proxies := make([]*net.IPNet, 0, len(trustedProxies))
+	for _, proxy := range trustedProxies {
+		network, err := parseNetwork(proxy)
+		if err != nil {
+			return nil, err
+		}
+		proxies = append(proxies, network)
+	}
+
+	exempt := make(map[string]struct{}, len(exemptPaths))
+	for _, path := range exemptPaths {
+		exempt[path] = struct{}{}
+	}
+
+	return func(c *gin.Context) {
+		if !enabled {
+			c.Next()
+			return
+		}
+		if _, ok := exempt[c.Request.URL.Path]; ok {
+			c.Next()
+			return
+		}
+		if isHTTPS(c.Request, proxies) {
+			c.Next()
+			return
+		}
+
+		if !redirect {
+			response.AbortWithJSON(c, http.StatusBadRequest, response.Error[any](
+				response.ErrCodeHTTPSRequired,
+				"https is required",
+			))
+			return
+		}
+
+		// 308 keeps the method and body of non-idempotent requests, which a
+		// 301 would let clients turn into a GET
+		status := http.StatusPermanentRedirect
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			status = http.StatusMovedPermanently
+		}
+		c.Redirect(status, "https://"+c.Request.Host+c.Request.URL.RequestURI())
+		c.Abort()
+	}, nil
+}
+
+func isHTTPS(r *http.Request, proxies []*net.IPNet) bool {
+	if r.TLS != nil {
+		return true
+	}
+	if !fromTrustedProxy(r.RemoteAddr, proxies) {
+		return false
+	}
+	// Chained proxies append their own scheme, so only the last element was
+	// written by the trusted proxy in front of us; earlier ones may have
+	// come from the client
+	values := r.Header.Values("X-Forwarded-Proto")
+	if len(values) == 0 {
+		return false
+	}
+	last := values[len(values)-1]
+	if i := strings.LastIndexByte(last, ','); i != -1 {
+		last = last[i+1:]
+	}
+	return strings.EqualFold(strings.TrimSpace(last), "https")
+}
+
+func fromTrustedProxy(remoteAddr string, proxies []*net.IPNet) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range proxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseNetwork parses a CIDR, or a bare IP as a single-address network.
+func parseNetwork(value string) (*net.IPNet, error) {
+	if strings.Contains(value, "/") {
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+		}
+		return network, nil
+	}
+
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid trusted proxy %q", value)
+	}
+	bits := 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
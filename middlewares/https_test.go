@@ -0,0 +1,75 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/elskow/go-microservice-template/pkg/response"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireHTTPS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(t *testing.T, enabled, redirect bool) *gin.Engine {
+		mw, err := RequireHTTPS(enabled, redirect, []string{"10.0.0.0/8", "::1"}, []string{"/health"})
+		require.NoError(t, err)
+
+		router := gin.New()
+		router.Use(mw)
+		router.Any("/users", func(c *gin.Context) { c.Status(http.StatusOK) })
+		router.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+		return router
+	}
+
+	tests := []struct {
+		name         string
+		enabled      bool
+		redirect     bool
+		method       string
+		path         string
+		remoteAddr   string
+		proto        string
+		wantCode     int
+		wantLocation string
+	}{
+		{name: "https from trusted proxy passes", enabled: true, redirect: true, method: http.MethodGet, path: "/users", remoteAddr: "10.1.2.3:1234", proto: "https", wantCode: http.StatusOK},
+		{name: "client-supplied proto before the proxy's is ignored", enabled: true, redirect: true, method: http.MethodGet, path: "/users", remoteAddr: "[::1]:1234", proto: "https, http", wantCode: http.StatusMovedPermanently, wantLocation: "https://example.com/users"},
+		{name: "last forwarded proto is used", enabled: true, redirect: true, method: http.MethodGet, path: "/users", remoteAddr: "[::1]:1234", proto: "http, https", wantCode: http.StatusOK},
+		{name: "http get is redirected", enabled: true, redirect: true, method: http.MethodGet, path: "/users?page=2", remoteAddr: "10.1.2.3:1234", proto: "http", wantCode: http.StatusMovedPermanently, wantLocation: "https://example.com/users?page=2"},
+		{name: "http post is redirected keeping the method", enabled: true, redirect: true, method: http.MethodPost, path: "/users", remoteAddr: "10.1.2.3:1234", proto: "http", wantCode: http.StatusPermanentRedirect, wantLocation: "https://example.com/users"},
+		{name: "http is rejected without redirect", enabled: true, redirect: false, method: http.MethodGet, path: "/users", remoteAddr: "10.1.2.3:1234", proto: "http", wantCode: http.StatusBadRequest},
+		{name: "header from untrusted client is ignored", enabled: true, redirect: false, method: http.MethodGet, path: "/users", remoteAddr: "203.0.113.7:1234", proto: "https", wantCode: http.StatusBadRequest},
+		{name: "exempt path is served over http", enabled: true, redirect: false, method: http.MethodGet, path: "/health", remoteAddr: "203.0.113.7:1234", wantCode: http.StatusOK},
+		{name: "disabled lets http through", enabled: false, redirect: false, method: http.MethodGet, path: "/users", remoteAddr: "203.0.113.7:1234", proto: "http", wantCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newRouter(t, tt.enabled, tt.redirect)
+
+			req := httptest.NewRequest(tt.method, "http://example.com"+tt.path, nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantLocation != "" {
+				assert.Equal(t, tt.wantLocation, w.Header().Get("Location"))
+			}
+			if tt.wantCode == http.StatusBadRequest {
+				assert.Equal(t, response.ErrCodeHTTPSRequired, decodeErrorCode(t, w))
+			}
+		})
+	}
+}
+
+func TestRequireHTTPS_InvalidTrustedProxy(t *testing.T) {
+	_, err := RequireHTTPS(true, true, []string{"not-an-ip"}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,17 @@
+package middlewares
+
+import (
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CountInFlight keeps counter at the number of requests currently being
+// served, e.g. for reporting how many were cut off by shutdown.
+func CountInFlight(counter *atomic.Int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		counter.Add(1)
+		defer counter.Add(-1)
+		c.Next()
+	}
+}
@@ -0,0 +1,71 @@
+package middlewares
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// LegacyTraceLink links the request span to the trace named in header, for
+// callers that propagate trace context in their own header rather than W3C
+// traceparent. The value is "<trace-id>[:<span-id>[:...]]" in hex, as sent
+// by Jaeger's uber-trace-id; 64-bit trace IDs are zero-padded. Values that
+// do not parse are ignored. When header is empty the middleware is a no-op.
+//
+// It must run inside otelgin so the request span is on the context. The
+// caller's trace is linked rather than adopted as parent, so the span keeps
+// the trace otelgin started or continued.
+func LegacyTraceLink(header string) gin.HandlerFunc {
+	if header == "" {
+		return func(ctx *gin.Context) {
+			ctx.Next()
+		}
+	}
+
+	source := attribute.String("link.source", header)
+	return func(ctx *gin.Context) {
+		if value := ctx.GetHeader(header); value != "" {
+			if linked, ok := parseLegacyTrace(value); ok {
+				trace.SpanFromContext(ctx.Request.Context()).AddLink(trace.Link{
+					SpanContext: linked,
+					Attributes:  []attribute.KeyValue{source},
+				})
+			}
+		}
+		ctx.Next()
+	}
+}
+
+const (
+	traceIDHexLen = 32
+	spanIDHexLen  = 16
+)
+
+func parseLegacyTrace(value string) (trace.SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+
+	traceHex := parts[0]
+	if len(traceHex) < traceIDHexLen {
+		traceHex = strings.Repeat("0", traceIDHexLen-len(traceHex)) + traceHex
+	}
+	traceID, err := trace.TraceIDFromHex(traceHex)
+	if err != nil {
+		return trace.SpanContext{}, false
+	}
+
+	config := trace.SpanContextConfig{TraceID: traceID, Remote: true}
+	if len(parts) > 1 {
+		spanHex := parts[1]
+		if len(spanHex) < spanIDHexLen {
+			spanHex = strings.Repeat("0", spanIDHexLen-len(spanHex)) + spanHex
+		}
+		spanID, err := trace.SpanIDFromHex(spanHex)
+		if err != nil {
+			return trace.SpanContext{}, false
+		}
+		config.SpanID = spanID
+	}
+	return trace.NewSpanContext(config), true
+}
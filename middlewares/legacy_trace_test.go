@@ -0,0 +1,86 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// serveLegacyTrace sends headers to a route behind otelgin and
+// LegacyTraceLink, returning the request span.
+func serveLegacyTrace(t *testing.T, header string, headers map[string]string) sdktrace.ReadOnlySpan {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	spans := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+
+	router := gin.New()
+	router.Use(otelgin.Middleware("test", otelgin.WithTracerProvider(provider)))
+	router.Use(LegacyTraceLink(header))
+	router.GET("/orders", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	ended := spans.Ended()
+	require.Len(t, ended, 1)
+	return ended[0]
+}
+
+func TestLegacyTraceLink_LinksProvidedTrace(t *testing.T) {
+	span := serveLegacyTrace(t, "uber-trace-id", map[string]string{
+		"uber-trace-id": "4bf92f3577b34da6a3ce929d0e0e4736:00f067aa0ba902b7:0:1",
+	})
+
+	links := span.Links()
+	require.Len(t, links, 1)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", links[0].SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", links[0].SpanContext.SpanID().String())
+	assert.True(t, links[0].SpanContext.IsRemote())
+	assert.Contains(t, links[0].Attributes, attribute.String("link.source", "uber-trace-id"))
+	assert.NotEqual(t, links[0].SpanContext.TraceID(), span.SpanContext().TraceID(), "the legacy trace is linked, not adopted")
+}
+
+func TestLegacyTraceLink_PadsShortIDs(t *testing.T) {
+	span := serveLegacyTrace(t, "X-Trace-Id", map[string]string{"X-Trace-Id": "a3ce929d0e0e4736"})
+
+	links := span.Links()
+	require.Len(t, links, 1)
+	assert.Equal(t, "0000000000000000a3ce929d0e0e4736", links[0].SpanContext.TraceID().String())
+	assert.False(t, links[0].SpanContext.SpanID().IsValid())
+}
+
+func TestLegacyTraceLink_IgnoresInvalidOrMissing(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		headers map[string]string
+	}{
+		{name: "disabled", header: "", headers: map[string]string{"uber-trace-id": "4bf92f3577b34da6a3ce929d0e0e4736"}},
+		{name: "missing", header: "uber-trace-id"},
+		{name: "not hex", header: "uber-trace-id", headers: map[string]string{"uber-trace-id": "not-a-trace"}},
+		{name: "zero trace", header: "uber-trace-id", headers: map[string]string{"uber-trace-id": "0"}},
+		{name: "bad span id", header: "uber-trace-id", headers: map[string]string{"uber-trace-id": "4bf92f3577b34da6a3ce929d0e0e4736:xyz"}},
+		{name: "too long id", header: "uber-trace-id", headers: map[string]string{"uber-trace-id": "14bf92f3577b34da6a3ce929d0e0e4736"}},
+		{name: "other header", header: "uber-trace-id", headers: map[string]string{"X-Trace-Id": "4bf92f3577b34da6a3ce929d0e0e4736"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			span := serveLegacyTrace(t, tc.header, tc.headers)
+			assert.Empty(t, span.Links())
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package middlewares
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/elskow/go-microservice-template/pkg/apm"
+	"github.com/elskow/go-microservice-template/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+const loadSheddingRetryAfter = time.Second
+
+// LoadShedding serves at most maxInFlight requests concurrently and rejects
+// the rest with 503 and Retry-After instead of queueing them. Admitted and
+// shed requests are reported through the collector's in-flight gauge and
+// shed counter; collector may be nil. Retry-After is written in format.
+func LoadShedding(maxInFlight int, collector *apm.MetricsCollector, format RetryAfterFormat) gin.HandlerFunc {
+	semaphore := make(chan struct{}, maxInFlight)
+
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		select {
+		case semaphore <- struct{}{}:
+		default:
+			if collector != nil && collector.IsEnabled() {
+				collector.HttpShedCount.Add(ctx, 1)
+			}
+			format.set(c, loadSheddingRetryAfter)
+			response.AbortWithJSON(c, http.StatusServiceUnavailable, response.Error[any](
+				response.ErrCodeServiceUnavailable,
+				"server is busy, please retry shortly",
+			))
+			return
+		}
+		defer func() { <-semaphore }()
+
+		if collector != nil && collector.IsEnabled() {
+			collector.HttpInFlight.Add(ctx, 1)
+			defer collector.HttpInFlight.Add(ctx, -1)
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,60 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadShedding_RejectsExcessRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const maxInFlight = 2
+	started := make(chan struct{}, maxInFlight)
+	release := make(chan struct{})
+
+	router := gin.New()
+	router.Use(LoadShedding(maxInFlight, nil, RetryAfterSeconds))
+	router.GET("/slow", func(c *gin.Context) {
+		started <- struct{}{}
+		<-release
+		c.Status(http.StatusOK)
+	})
+
+	var wg sync.WaitGroup
+	codes := make([]int, maxInFlight)
+	for i := 0; i < maxInFlight; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+			codes[i] = w.Code
+		}(i)
+	}
+	for i := 0; i < maxInFlight; i++ {
+		<-started
+	}
+
+	// limiter is saturated: further requests are shed
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	}
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, []int{http.StatusOK, http.StatusOK}, codes)
+
+	// capacity is returned once the in-flight requests finish
+	started = make(chan struct{}, 1)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,129 @@
+package middlewares
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/elskow/go-microservice-template/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+// MaintenanceStore holds the maintenance flag shared by every replica.
+type MaintenanceStore interface {
+	MaintenanceEnabled(ctx context.Context) (bool, error)
+	SetMaintenanceEnabled(ctx context.Context, enabled bool) error
+}
+
+// MaintenanceMode is a runtime switch that blocks writes, e.g. while the
+// database is being migrated, and can be flipped without a restart.
+type MaintenanceMode struct {
+	enabled     atomic.Bool
+	retryAfter  time.Duration
+	format      RetryAfterFormat
+	exemptPaths map[string]struct{}
+	store       MaintenanceStore
+}
+
+// NewMaintenanceMode returns a switch starting in the given state. Blocked
+// requests are told to retry after retryAfter, written in format; requests
+// whose path is in exemptPaths, such as login or the endpoint turning
+// maintenance off, always pass. With a non-nil store the flag is shared:
+// SetEnabled writes it there and Sync picks up changes made by other
+// replicas.
+func NewMaintenanceMode(enabled bool, retryAfter time.Duration, format RetryAfterFormat, exemptPaths []string, store MaintenanceStore) *MaintenanceMode {
+	exempt := make(map[string]struct{}, len(exemptPaths))
+	for _, path := range exemptPaths {
+		exempt[path] = struct{}{}
+	}
+
+	m := &MaintenanceMode{
+		retryAfter:  retryAfter,
+		format:      format,
+		exemptPaths: exempt,
+		store:       store,
+	}
+	m.enabled.Store(enabled)
+	return m
+}
+
+func (m *MaintenanceMode) Enabled() bool { return m.enabled.Load() }
+
+// SetEnabled turns maintenance on or off, in the shared store first so a
+// failed write leaves this replica agreeing with the others.
+func (m *MaintenanceMode) SetEnabled(ctx context.Context, enabled bool) error {
+	if m.store != nil {
+		if err := m.store.SetMaintenanceEnabled(ctx, enabled); err != nil {
+			return err
+		}
+	}
+	m.enabled.Store(enabled)
+	return nil
+}
+
+// Sync loads the shared flag from the store. It does nothing without one.
+func (m *MaintenanceMode) Sync(ctx context.Context) error {
+	if m.store == nil {
+		return nil
+	}
+	enabled, err := m.store.MaintenanceEnabled(ctx)
+	if err != nil {
+		return err
+	}
+	m.enabled.Store(enabled)
+	return nil
+}
+
+// StartSync calls Sync every interval until ctx ends, so a toggle made on
+// another replica applies here within one interval. Failed syncs keep the
+// last known state.
+func (m *MaintenanceMode) StartSync(ctx context.Context, interval time.Duration, logger *slog.Logger) {
+	if m.store == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := m.Sync(ctx); err != nil {
+					logger.Warn("failed to sync maintenance mode", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// Middleware rejects POST, PUT, PATCH and DELETE requests with 503 and
+// Retry-After while maintenance is enabled; reads are still served.
+func (m *MaintenanceMode) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !m.Enabled() || !isWriteMethod(c.Request.Method) {
+			c.Next()
+			return
+		}
+		if _, ok := m.exemptPaths[c.Request.URL.Path]; ok {
+			c.Next()
+			return
+		}
+
+		m.format.set(c, m.retryAfter)
+		response.AbortWithJSON(c, http.StatusServiceUnavailable, response.Error[any](
+			response.ErrCodeServiceUnavailable,
+			"service is under maintenance, changes are temporarily disabled",
+		))
+	}
+}
+
+func isWriteMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/elskow/go-microservice-template/config"
+	"github.com/elskow/go-microservice-template/pkg/response"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mode := NewMaintenanceMode(true, 2*time.Minute, RetryAfterSeconds, []string{"/admin/maintenance"}, nil)
+	router := gin.New()
+	router.Use(mode.Middleware())
+	router.Any("/users", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.PUT("/admin/maintenance", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	t.Run("write is blocked", func(t *testing.T) {
+		w := serve(http.MethodPost, "/users")
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "120", w.Header().Get("Retry-After"))
+		assert.Equal(t, response.ErrCodeServiceUnavailable, decodeErrorCode(t, w))
+	})
+
+	t.Run("read is allowed", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/users").Code)
+	})
+
+	t.Run("exempt path accepts writes", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve(http.MethodPut, "/admin/maintenance").Code)
+	})
+
+	t.Run("writes resume once disabled", func(t *testing.T) {
+		require.NoError(t, mode.SetEnabled(context.Background(), false))
+		t.Cleanup(func() { _ = mode.SetEnabled(context.Background(), true) })
+
+		assert.Equal(t, http.StatusOK, serve(http.MethodDelete, "/users").Code)
+	})
+}
+
+func TestMaintenanceMode_DefaultExemptionsKeepUsersSignedIn(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config.Load()
+	t.Cleanup(config.Reset)
+
+	mode := NewMaintenanceMode(true, time.Minute, RetryAfterSeconds, config.Get().MaintenanceExemptPathList(), nil)
+	router := gin.New()
+	router.Use(mode.Middleware())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.POST("/api/account/login", ok)
+	router.POST("/api/account/refresh", ok)
+	router.POST("/api/account/logout", ok)
+	router.POST("/api/account/register", ok)
+	router.GET("/api/account/me", ok)
+
+	serve := func(method, path string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w.Code
+	}
+
+	// A logged-out user signs in, reads, renews their token and signs out
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/api/account/login"))
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/api/account/me"))
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/api/account/refresh"))
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/api/account/logout"))
+	assert.Equal(t, http.StatusServiceUnavailable, serve(http.MethodPost, "/api/account/register"))
+}
+
+// memoryMaintenanceStore stands in for the database shared by replicas.
+type memoryMaintenanceStore struct {
+	enabled bool
+	err     error
+}
+
+func (s *memoryMaintenanceStore) MaintenanceEnabled(context.Context) (bool, error) {
+	return s.enabled, s.err
+}
+
+func (s *memoryMaintenanceStore) SetMaintenanceEnabled(_ context.Context, enabled bool) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.enabled = enabled
+	return nil
+}
+
+func TestMaintenanceMode_SharedAcrossReplicas(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryMaintenanceStore{}
+	first := NewMaintenanceMode(false, time.Minute, RetryAfterSeconds, nil, store)
+	second := NewMaintenanceMode(false, time.Minute, RetryAfterSeconds, nil, store)
+
+	require.NoError(t, first.SetEnabled(ctx, true))
+	assert.False(t, second.Enabled(), "a replica only sees the change once it syncs")
+
+	require.NoError(t, second.Sync(ctx))
+	assert.True(t, second.Enabled())
+
+	store.err = errors.New("database unavailable")
+	assert.Error(t, second.SetEnabled(ctx, false))
+	assert.Error(t, second.Sync(ctx))
+	assert.True(t, second.Enabled(), "a failed write or sync keeps the last known state")
+}
@@ -0,0 +1,51 @@
+package middlewares
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RetryAfterFormat selects how the rate limiting, load shedding and
+// maintenance middlewares write Retry-After.
+type RetryAfterFormat int
+
+const (
+	// RetryAfterSeconds sends the delay in seconds.
+	RetryAfterSeconds RetryAfterFormat = iota
+	// RetryAfterHTTPDate sends the HTTP-date the wait ends at, which some
+	// clients only understand.
+	RetryAfterHTTPDate
+)
+
+// RetryAfterFormatFor returns RetryAfterHTTPDate when httpDate is set and
+// RetryAfterSeconds otherwise.
+func RetryAfterFormatFor(httpDate bool) RetryAfterFormat {
+	if httpDate {
+		return RetryAfterHTTPDate
+	}
+	return RetryAfterSeconds
+}
+
+// retryAfterNow is replaced in tests to pin the HTTP-date form.
+var retryAfterNow = time.Now
+
+func (f RetryAfterFormat) set(c *gin.Context, wait time.Duration) {
+	c.Header("Retry-After", formatRetryAfter(wait, retryAfterNow(), f == RetryAfterHTTPDate))
+}
+
+// formatRetryAfter renders wait rounded up to whole seconds, either as a
+// delay or as the HTTP-date that many seconds after now.
+func formatRetryAfter(wait time.Duration, now time.Time, httpDate bool) string {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 0 {
+		seconds = 0
+	}
+	if httpDate {
+		return now.Add(time.Duration(seconds) * time.Second).UTC().Format(http.TimeFormat)
+	}
+	return strconv.Itoa(seconds)
+}
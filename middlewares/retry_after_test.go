@@ -0,0 +1,53 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatRetryAfter(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+
+	tests := []struct {
+		name     string
+		wait     time.Duration
+		httpDate bool
+		want     string
+	}{
+		{name: "seconds", wait: 2 * time.Minute, want: "120"},
+		{name: "seconds round up", wait: 1500 * time.Millisecond, want: "2"},
+		{name: "negative wait is zero", wait: -time.Second, want: "0"},
+		{name: "http-date in GMT", wait: 2 * time.Minute, httpDate: true, want: "Fri, 01 Mar 2024 11:02:00 GMT"},
+		{name: "http-date rounds up", wait: 1500 * time.Millisecond, httpDate: true, want: "Fri, 01 Mar 2024 11:00:02 GMT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, formatRetryAfter(tt.wait, now, tt.httpDate))
+		})
+	}
+}
+
+func TestRetryAfterHTTPDate_AppliesToMiddlewares(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	retryAfterNow = func() time.Time { return now }
+	t.Cleanup(func() { retryAfterNow = time.Now })
+
+	mode := NewMaintenanceMode(true, 5*time.Minute, RetryAfterFormatFor(true), nil, nil)
+	router := gin.New()
+	router.Use(mode.Middleware())
+	router.POST("/users", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/users", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "Fri, 01 Mar 2024 12:05:00 GMT", w.Header().Get("Retry-After"))
+	_, err := http.ParseTime(w.Header().Get("Retry-After"))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,112 @@
+package middlewares
+
+import (
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/elskow/go-microservice-template/config"
+	"github.com/gin-gonic/gin"
+)
+
+// Authentication requirements reported by RouteAuth.Requirement.
+const (
+	RouteAuthNone   = "none"
+	RouteAuthToken  = "token"
+	RouteAuthGlobal = "global"
+)
+
+// RouteAuth records which routes are registered behind Authenticate. gin
+// only exposes a route's final handler, so the route listing relies on this
+// to report whether a route requires a token.
+type RouteAuth struct {
+	mu        sync.RWMutex
+	protected map[string]struct{}
+	// global mirrors ENABLE_GLOBAL_AUTH: every path not in public goes
+	// through AuthenticateExcept.
+	global bool
+	public map[string]struct{}
+}
+
+func NewRouteAuth() *RouteAuth {
+	cfg := config.Get()
+	public := make(map[string]struct{})
+	for _, p := range cfg.AuthPublicPathList() {
+		public[p] = struct{}{}
+	}
+	return &RouteAuth{
+		protected: make(map[string]struct{}),
+		global:    cfg.EnableGlobalAuth,
+		public:    public,
+	}
+}
+
+// Protect returns a wrapper registering routes on group, which must already
+// use Authenticate, and recording them as requiring a token.
+func (r *RouteAuth) Protect(group *gin.RouterGroup) *ProtectedGroup {
+	return &ProtectedGroup{group: group, routeAuth: r}
+}
+
+// Requirement reports how the route with the given method and full path is
+// authenticated: RouteAuthToken when registered through Protect,
+// RouteAuthGlobal when only global authentication covers it, and
+// RouteAuthNone otherwise.
+func (r *RouteAuth) Requirement(method, fullPath string) string {
+	r.mu.RLock()
+	_, protected := r.protected[method+" "+fullPath]
+	r.mu.RUnlock()
+
+	if protected {
+		return RouteAuthToken
+	}
+	if _, public := r.public[fullPath]; r.global && !public {
+		return RouteAuthGlobal
+	}
+	return RouteAuthNone
+}
+
+func (r *RouteAuth) record(method, fullPath string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.protected[method+" "+fullPath] = struct{}{}
+}
+
+// ProtectedGroup registers authenticated routes on a gin router group.
+type ProtectedGroup struct {
+	group     *gin.RouterGroup
+	routeAuth *RouteAuth
+}
+
+func (g *ProtectedGroup) Handle(method, relativePath string, handlers ...gin.HandlerFunc) {
+	g.routeAuth.record(method, joinPaths(g.group.BasePath(), relativePath))
+	g.group.Handle(method, relativePath, handlers...)
+}
+
+func (g *ProtectedGroup) GET(relativePath string, handlers ...gin.HandlerFunc) {
+	g.Handle(http.MethodGet, relativePath, handlers...)
+}
+
+func (g *ProtectedGroup) POST(relativePath string, handlers ...gin.HandlerFunc) {
+	g.Handle(http.MethodPost, relativePath, handlers...)
+}
+
+func (g *ProtectedGroup) PUT(relativePath string, handlers ...gin.HandlerFunc) {
+	g.Handle(http.MethodPut, relativePath, handlers...)
+}
+
+func (g *ProtectedGroup) DELETE(relativePath string, handlers ...gin.HandlerFunc) {
+	g.Handle(http.MethodDelete, relativePath, handlers...)
+}
+
+// joinPaths joins paths the way gin builds a route's full path.
+func joinPaths(base, relative string) string {
+	if relative == "" {
+		return base
+	}
+	joined := path.Join(base, relative)
+	if strings.HasSuffix(relative, "/") && !strings.HasSuffix(joined, "/") {
+		return joined + "/"
+	}
+	return joined
+}
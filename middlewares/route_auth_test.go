@@ -0,0 +1,31 @@
+package middlewares
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/elskow/go-microservice-template/config"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteAuth_Requirement(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ENABLE_GLOBAL_AUTH", "true")
+	t.Setenv("AUTH_PUBLIC_PATHS", "/api/account/login")
+	config.Load()
+	t.Cleanup(config.Reset)
+
+	router := gin.New()
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	routeAuth := NewRouteAuth()
+	api := router.Group("/api")
+	api.POST("/account/login", ok)
+	api.GET("/orders", ok)
+	routeAuth.Protect(api.Group("/account")).GET("/me", ok)
+
+	assert.Equal(t, RouteAuthToken, routeAuth.Requirement(http.MethodGet, "/api/account/me"))
+	assert.Equal(t, RouteAuthGlobal, routeAuth.Requirement(http.MethodGet, "/api/orders"))
+	assert.Equal(t, RouteAuthNone, routeAuth.Requirement(http.MethodPost, "/api/account/login"))
+	assert.Len(t, router.Routes(), 3)
+}
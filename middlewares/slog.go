@@ -2,21 +2,14 @@ package middlewares
 
 import (
 	"log/slog"
-	"strings"
 	"sync"
 	"time"
 
-	"github.com/elskow/go-microservice-template/config"
 	"github.com/elskow/go-microservice-template/pkg/constants"
 	"github.com/gin-gonic/gin"
 	"go.opentelemetry.io/otel/trace"
 )
 
-var (
-	blacklistPaths     []string
-	blacklistPathsOnce sync.Once
-)
-
 const maxAttributesCapacity = 12
 
 var slogAttrPool = sync.Pool{
@@ -26,28 +19,6 @@ var slogAttrPool = sync.Pool{
 	},
 }
 
-func loadBlacklistPaths() {
-	blacklistPathsOnce.Do(func() {
-		cfg := config.Get()
-		if cfg.LogBlacklistPaths != "" {
-			blacklistPaths = strings.Split(cfg.LogBlacklistPaths, ",")
-			for i := range blacklistPaths {
-				blacklistPaths[i] = strings.TrimSpace(blacklistPaths[i])
-			}
-		}
-	})
-}
-
-func isBlacklisted(path string) bool {
-	loadBlacklistPaths()
-	for _, blacklisted := range blacklistPaths {
-		if blacklisted == path {
-			return true
-		}
-	}
-	return false
-}
-
 func normalizeRequestPath(c *gin.Context, actualPath string) string {
 	routePattern := c.FullPath()
 
@@ -58,14 +29,16 @@ func normalizeRequestPath(c *gin.Context, actualPath string) string {
 	return actualPath
 }
 
-func SlogMiddleware(logger *slog.Logger) gin.HandlerFunc {
+// SlogMiddleware writes an access log line per request, skipping paths in
+// blacklist.
+func SlogMiddleware(logger *slog.Logger, blacklist *PathBlacklist) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
 		path := c.Request.URL.Path
 
 		c.Next()
 
-		if isBlacklisted(path) {
+		if blacklist.Contains(path) {
 			return
 		}
 
@@ -96,6 +69,10 @@ func SlogMiddleware(logger *slog.Logger) gin.HandlerFunc {
 			)
 		}
 
+		if tenantID := c.GetString(constants.CtxKeyTenantID); tenantID != "" {
+			*attrs = append(*attrs, constants.AttrKeyTenantID, tenantID)
+		}
+
 		if len(c.Errors) > 0 {
 			*attrs = append(*attrs, "error", c.Errors[0].Error())
 		}
@@ -0,0 +1,82 @@
+package middlewares
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/elskow/go-microservice-template/pkg/constants"
+	"github.com/elskow/go-microservice-template/pkg/response"
+	"github.com/elskow/go-microservice-template/pkg/tenant"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TenantMiddleware resolves the tenant a request is scoped to from header,
+// then from the subdomain of baseDomain when one is configured. For an
+// already authenticated caller the tenant_id claim wins, and a header or
+// subdomain naming another tenant is rejected with 403. Requests with no
+// tenant pass through unscoped; a malformed header is rejected.
+func TenantMiddleware(header, baseDomain string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(header)
+		if id != "" && !tenant.Valid(id) {
+			response.AbortWithJSON(c, http.StatusBadRequest,
+				response.Error[any](response.ErrCodeValidationFailed, "invalid tenant id"))
+			return
+		}
+		if id == "" {
+			if sub := subdomainTenant(c.Request.Host, baseDomain); tenant.Valid(sub) {
+				id = sub
+			}
+		}
+
+		if auth, ok := GetAuthContext(c); ok && auth.TenantID != "" {
+			if id != "" && id != auth.TenantID {
+				status, body := response.Forbidden[any](tenantMismatchMessage)
+				response.AbortWithJSON(c, status, body)
+				return
+			}
+			id = auth.TenantID
+		}
+
+		if id != "" {
+			setTenant(c, id)
+		}
+
+		c.Next()
+	}
+}
+
+const tenantMismatchMessage = "tenant does not match the access token"
+
+// GetTenantID returns the tenant resolved for the request.
+func GetTenantID(c *gin.Context) (string, bool) {
+	return tenant.FromContext(c.Request.Context())
+}
+
+// setTenant scopes the request context, its span and its access log to id.
+func setTenant(c *gin.Context, id string) {
+	c.Set(constants.CtxKeyTenantID, id)
+	c.Request = c.Request.WithContext(tenant.WithTenantID(c.Request.Context(), id))
+	trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String(constants.AttrKeyTenantID, id))
+}
+
+// subdomainTenant returns the single label in front of baseDomain in host,
+// e.g. "acme" for "acme.example.com".
+func subdomainTenant(host, baseDomain string) string {
+	if baseDomain == "" {
+		return ""
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	sub, ok := strings.CutSuffix(strings.ToLower(host), "."+strings.ToLower(baseDomain))
+	if !ok || strings.Contains(sub, ".") {
+		return ""
+	}
+	return sub
+}
@@ -0,0 +1,178 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/elskow/go-microservice-template/config"
+	"github.com/elskow/go-microservice-template/pkg/jwt"
+	"github.com/elskow/go-microservice-template/pkg/tenant"
+	"github.com/gin-gonic/gin"
+	gojwt "github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+// tenantRouter serves "/" behind handlers, recording the tenant seen by the
+// final handler.
+func tenantRouter(got *string, handlers ...gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handlers = append(handlers, func(c *gin.Context) {
+		*got, _ = tenant.FromContext(c.Request.Context())
+		c.Status(http.StatusOK)
+	})
+	router.GET("/", handlers...)
+	return router
+}
+
+func TestTenantMiddleware_Header(t *testing.T) {
+	var got string
+	var bag baggage.Baggage
+	router := tenantRouter(&got, TenantMiddleware("X-Tenant-ID", ""), func(c *gin.Context) {
+		bag = baggage.FromContext(c.Request.Context())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Tenant-ID", "acme")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "acme", got)
+	assert.Equal(t, "acme", bag.Member(tenant.BaggageKey).Value())
+}
+
+func TestTenantMiddleware_InvalidHeader(t *testing.T) {
+	var got string
+	router := tenantRouter(&got, TenantMiddleware("X-Tenant-ID", ""))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Tenant-ID", "acme;corp")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, got)
+}
+
+func TestTenantMiddleware_Claim(t *testing.T) {
+	var got string
+	router := tenantRouter(&got,
+		func(c *gin.Context) { setAuthContext(c, AuthContext{UserID: "user-1", TenantID: "from-claim"}) },
+		TenantMiddleware("X-Tenant-ID", ""),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "from-claim", got)
+}
+
+func TestTenantMiddleware_ClaimMismatchForbidden(t *testing.T) {
+	var got string
+	router := tenantRouter(&got,
+		func(c *gin.Context) { setAuthContext(c, AuthContext{UserID: "user-1", TenantID: "from-claim"}) },
+		TenantMiddleware("X-Tenant-ID", ""),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Tenant-ID", "from-header")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, got)
+}
+
+func TestTenantMiddleware_Subdomain(t *testing.T) {
+	var got string
+	router := tenantRouter(&got, TenantMiddleware("X-Tenant-ID", "example.com"))
+
+	req := httptest.NewRequest(http.MethodGet, "http://acme.example.com:8888/", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "acme", got)
+}
+
+func TestTenantMiddleware_NoTenant(t *testing.T) {
+	var got string
+	router := tenantRouter(&got, TenantMiddleware("X-Tenant-ID", "example.com"))
+
+	req := httptest.NewRequest(http.MethodGet, "http://a.b.example.com/", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, got)
+}
+
+func TestAuthenticate_TenantClaim(t *testing.T) {
+	jwtService := jwt.NewService()
+	token, err := jwtService.GenerateSessionAccessToken(uuid.NewString(), "user", "acme", "session-id", 0)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		header   string
+		wantCode int
+		wantID   string
+	}{
+		{name: "no header", wantCode: http.StatusOK, wantID: "acme"},
+		{name: "matching header", header: "acme", wantCode: http.StatusOK, wantID: "acme"},
+		{name: "other tenant", header: "other", wantCode: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			router := tenantRouter(&got, TenantMiddleware("X-Tenant-ID", ""), Authenticate(jwtService, nil, "", nil))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			if tt.header != "" {
+				req.Header.Set("X-Tenant-ID", tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantID, got)
+		})
+	}
+}
+
+func TestAuthenticate_TenantClaimScopesVersionLookup(t *testing.T) {
+	token, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, gojwt.MapClaims{
+		"user_id":   uuid.NewString(),
+		"role":      "user",
+		"tenant_id": "acme",
+		"exp":       time.Now().Add(time.Minute).Unix(),
+	}).SignedString([]byte(config.Get().JWTSecret))
+	require.NoError(t, err)
+
+	var lookedUp string
+	versions := tokenVersionsFunc(func(ctx context.Context, _ string) (int, error) {
+		lookedUp, _ = tenant.FromContext(ctx)
+		return 0, nil
+	})
+
+	var got string
+	router := tenantRouter(&got, Authenticate(jwt.NewService(), versions, "", nil))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "acme", lookedUp)
+}
@@ -0,0 +1,166 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupThrottledRouter(limit int, window time.Duration) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.POST("/account/login", Throttle(limit, window, RetryAfterSeconds), ok)
+	router.POST("/account/register", ok)
+
+	return router
+}
+
+func doRequest(router *gin.Engine, method, path, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestThrottle_LoginOverLimit(t *testing.T) {
+	router := setupThrottledRouter(2, time.Minute)
+	const client = "203.0.113.7:1234"
+
+	for i := 0; i < 2; i++ {
+		w := doRequest(router, http.MethodPost, "/account/login", client)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	w := doRequest(router, http.MethodPost, "/account/login", client)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	// other routes and other clients are unaffected
+	w = doRequest(router, http.MethodPost, "/account/register", client)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = doRequest(router, http.MethodPost, "/account/login", "198.51.100.9:1234")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestThrottle_WindowResets(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	throttle := newKeyThrottle(1, time.Minute)
+	throttle.nowFunc = func() time.Time { return now }
+
+	allowed, _ := throttle.allow("203.0.113.7")
+	assert.True(t, allowed)
+
+	now = now.Add(20 * time.Second)
+	allowed, retryAfter := throttle.allow("203.0.113.7")
+	assert.False(t, allowed)
+	assert.Equal(t, 40*time.Second, retryAfter)
+
+	now = now.Add(40 * time.Second)
+	allowed, _ = throttle.allow("203.0.113.7")
+	assert.True(t, allowed)
+}
+
+func TestThrottle_Disabled(t *testing.T) {
+	router := setupThrottledRouter(0, time.Minute)
+
+	for i := 0; i < 10; i++ {
+		w := doRequest(router, http.MethodPost, "/account/login", "203.0.113.7:1234")
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+}
+
+// setupResetRouter mirrors the reset-request route: per-IP and per-email
+// throttles in front of a handler that answers 202 whether or not the
+// email is registered.
+func setupResetRouter(ipLimit, emailLimit int) (*gin.Engine, *[]string) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	var received []string
+	router.POST("/account/password/reset-request",
+		Throttle(ipLimit, time.Hour, RetryAfterSeconds),
+		ThrottleByEmail(emailLimit, time.Hour, RetryAfterSeconds),
+		func(c *gin.Context) {
+			var req struct {
+				Email string `json:"email" binding:"required,email"`
+			}
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.Status(http.StatusBadRequest)
+				return
+			}
+			received = append(received, req.Email)
+			c.Status(http.StatusAccepted)
+		},
+	)
+
+	return router, &received
+}
+
+func requestReset(router *gin.Engine, email, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/account/password/reset-request",
+		strings.NewReader(`{"email":"`+email+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestThrottleByEmail_ResetOverEmailLimit(t *testing.T) {
+	router, received := setupResetRouter(100, 2)
+
+	// The same victim targeted from different IPs
+	assert.Equal(t, http.StatusAccepted, requestReset(router, "victim@example.com", "203.0.113.1:1234").Code)
+	assert.Equal(t, http.StatusAccepted, requestReset(router, "Victim@Example.com", "203.0.113.2:1234").Code)
+
+	w := requestReset(router, "victim@example.com", "203.0.113.3:1234")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "3600", w.Header().Get("Retry-After"))
+
+	// The body reaches the handler intact for requests that pass
+	assert.Equal(t, []string{"victim@example.com", "Victim@Example.com"}, *received)
+
+	// Other addresses are unaffected
+	assert.Equal(t, http.StatusAccepted, requestReset(router, "other@example.com", "203.0.113.3:1234").Code)
+}
+
+func TestThrottleByEmail_ResetOverIPLimit(t *testing.T) {
+	router, _ := setupResetRouter(2, 100)
+	const client = "203.0.113.7:1234"
+
+	assert.Equal(t, http.StatusAccepted, requestReset(router, "a@example.com", client).Code)
+	assert.Equal(t, http.StatusAccepted, requestReset(router, "b@example.com", client).Code)
+	assert.Equal(t, http.StatusTooManyRequests, requestReset(router, "c@example.com", client).Code)
+}
+
+func TestThrottleByEmail_SameResponseForUnknownEmail(t *testing.T) {
+	router, _ := setupResetRouter(100, 1)
+
+	// Whether or not an account exists, the first request is accepted and
+	// the second throttled, so responses reveal nothing
+	for _, email := range []string{"registered@example.com", "unknown@example.com"} {
+		assert.Equal(t, http.StatusAccepted, requestReset(router, email, "203.0.113.7:1234").Code)
+		assert.Equal(t, http.StatusTooManyRequests, requestReset(router, email, "203.0.113.7:1234").Code)
+	}
+}
+
+func TestThrottleByEmail_MissingEmailPassesThrough(t *testing.T) {
+	router, _ := setupResetRouter(100, 1)
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/account/password/reset-request", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+}
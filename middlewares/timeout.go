@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/elskow/go-microservice-template/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+// Timeout bounds every request with a context deadline of timeout, so
+// database calls and other context-aware work stop once it passes. A
+// handler cut off before writing anything gets a 504. Requests whose path is
+// in exemptPaths, such as streaming exports, run without a deadline. A
+// timeout of zero or less disables the middleware.
+func Timeout(timeout time.Duration, exemptPaths []string) gin.HandlerFunc {
+	exempt := make(map[string]struct{}, len(exemptPaths))
+	for _, path := range exemptPaths {
+		exempt[path] = struct{}{}
+	}
+
+	return func(c *gin.Context) {
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+		if _, ok := exempt[c.Request.URL.Path]; ok {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			response.AbortWithJSON(c, http.StatusGatewayTimeout, response.Error[any](
+				response.ErrCodeRequestTimeout,
+				"request timed out",
+			))
+		}
+	}
+}
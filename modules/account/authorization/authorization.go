@@ -6,12 +6,20 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/elskow/go-microservice-template/config"
 	"github.com/elskow/go-microservice-template/pkg/database"
+	"github.com/elskow/go-microservice-template/pkg/permissions"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
 )
 
 type Permission struct {
@@ -25,6 +33,29 @@ type UserPermissions struct {
 	LoadedAt    time.Time
 }
 
+// RequiredPermissions lists the permission names checked by handlers. A
+// missing one makes every check for it fail with a 403, so they are
+// validated at startup; see ValidatePermissions.
+var RequiredPermissions = []permissions.Permission{
+	permissions.UserUpdate,
+	permissions.UserDelete,
+	permissions.UserList,
+	permissions.UserManage,
+	permissions.PermissionManage,
+}
+
+// DefaultRole is the role Register assigns to every new user.
+const DefaultRole = "user"
+
+// ErrRoleNotFound is returned when a named role has no row in the roles
+// table.
+var ErrRoleNotFound = errors.New("role not found")
+
+// RequiredTables lists the RBAC tables permission checks query. When one is
+// missing every check fails and handlers answer 403, so they are verified at
+// startup and on readiness; see MissingTables.
+var RequiredTables = []string{"roles", "permissions", "role_permissions", "user_roles"}
+
 type Authorizer struct {
 	db            *database.TracedDB
 	logger        *slog.Logger
@@ -32,6 +63,43 @@ type Authorizer struct {
 	cacheMutex    sync.RWMutex
 	cacheTTL      time.Duration
 	enableCaching bool
+
+	// serveStaleOnError answers checks from expired cache entries when
+	// loading permissions fails; see AUTHZ_SERVE_STALE_ON_DB_ERROR.
+	serveStaleOnError bool
+	// maxStale caps the age of an entry served under serveStaleOnError;
+	// cleanup keeps expired entries that long.
+	maxStale        time.Duration
+	failurePolicies map[permissions.Permission]FailurePolicy
+
+	cacheHits    atomic.Int64
+	cacheMisses  atomic.Int64
+	cacheLookups metric.Int64Counter
+
+	// reportedHits and reportedMisses are the counts at the last hit-ratio
+	// report; only the cleanup goroutine touches them.
+	reportedHits   int64
+	reportedMisses int64
+}
+
+// FailurePolicy decides what HasPermission answers when a user's
+// permissions cannot be loaded.
+type FailurePolicy int
+
+const (
+	// FailClosed returns the load error, which handlers turn into a 500.
+	FailClosed FailurePolicy = iota
+	// FailOpen grants the permission and logs a warning. Only meant for
+	// checks guarding non-sensitive actions.
+	FailOpen
+)
+
+// CacheStats is a point-in-time view of the permission cache.
+type CacheStats struct {
+	Size   int
+	Hits   int64
+	Misses int64
+	TTL    time.Duration
 }
 
 func NewAuthorizer(db *database.TracedDB, logger *slog.Logger) *Authorizer {
@@ -42,6 +110,45 @@ func NewAuthorizer(db *database.TracedDB, logger *slog.Logger) *Authorizer {
 		cache:         make(map[string]*UserPermissions),
 		cacheTTL:      cfg.CacheTTL(),
 		enableCaching: true,
+		cacheLookups:  newCacheLookupCounter(otel.Meter("account/authorization")),
+
+		serveStaleOnError: cfg.AuthzServeStaleOnDBError,
+		maxStale:          cfg.AuthzMaxStale(),
+		failurePolicies:   failOpenPolicies(cfg.AuthzFailOpenPermissionList()),
+	}
+}
+
+func failOpenPolicies(names []string) map[permissions.Permission]FailurePolicy {
+	policies := make(map[permissions.Permission]FailurePolicy, len(names))
+	for _, name := range names {
+		policies[permissions.Permission(name)] = FailOpen
+	}
+	return policies
+}
+
+func newCacheLookupCounter(meter metric.Meter) metric.Int64Counter {
+	counter, err := meter.Int64Counter(
+		"authz_cache_lookups_total",
+		metric.WithDescription("Total number of permission cache lookups by result"),
+	)
+	if err != nil {
+		return noop.Int64Counter{}
+	}
+	return counter
+}
+
+// recordCacheLookup counts a cache lookup as a hit or a miss.
+func (a *Authorizer) recordCacheLookup(ctx context.Context, hit bool) {
+	result := "miss"
+	if hit {
+		a.cacheHits.Add(1)
+		result = "hit"
+	} else {
+		a.cacheMisses.Add(1)
+	}
+
+	if a.cacheLookups != nil {
+		a.cacheLookups.Add(ctx, 1, metric.WithAttributes(attribute.String("result", result)))
 	}
 }
 
@@ -57,39 +164,203 @@ func (a *Authorizer) EnableCache() {
 	a.enableCaching = true
 }
 
-func (a *Authorizer) HasPermission(ctx context.Context, userID string, permissionName string) (bool, error) {
+// SetFailurePolicy sets how checks for permission behave when loading
+// permissions fails. Call it before serving requests.
+func (a *Authorizer) SetFailurePolicy(permission permissions.Permission, policy FailurePolicy) {
+	a.failurePolicies[permission] = policy
+}
+
+// FailurePolicy returns the policy applied to failed checks for permission,
+// FailClosed unless configured otherwise.
+func (a *Authorizer) FailurePolicy(permission permissions.Permission) FailurePolicy {
+	return a.failurePolicies[permission]
+}
+
+func (a *Authorizer) HasPermission(ctx context.Context, userID string, permission permissions.Permission) (bool, error) {
 	uid, err := uuid.Parse(userID)
 	if err != nil {
 		return false, fmt.Errorf("invalid user ID: %w", err)
 	}
 
 	if a.enableCaching {
-		if hasPermission, found := a.checkCache(userID, permissionName); found {
+		hasPermission, found := a.checkCache(userID, permission.String())
+		a.recordCacheLookup(ctx, found)
+		if found {
 			return hasPermission, nil
 		}
 	}
 
-	permissions, err := a.loadUserPermissions(ctx, uid)
+	userPermissions, err := a.loadUserPermissions(ctx, uid)
+	if err != nil {
+		stale, ok := a.stalePermissions(ctx, userID, err)
+		if !ok {
+			if a.FailurePolicy(permission) == FailOpen {
+				a.logger.WarnContext(ctx, "permission check failed open",
+					"user_id", userID,
+					"permission", permission.String(),
+					"error", err,
+				)
+				return true, nil
+			}
+			return false, fmt.Errorf("failed to load user permissions: %w", err)
+		}
+		userPermissions = stale
+	} else if a.enableCaching {
+		a.updateCache(userID, userPermissions)
+	}
+
+	for _, p := range userPermissions {
+		if p.Name == permission.String() {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// ListPermissions returns the names of userID's effective permissions in
+// name order, sharing the cache HasPermission fills.
+func (a *Authorizer) ListPermissions(ctx context.Context, userID string) ([]string, error) {
+	uid, err := uuid.Parse(userID)
 	if err != nil {
-		return false, fmt.Errorf("failed to load user permissions: %w", err)
+		return nil, fmt.Errorf("invalid user ID: %w", err)
 	}
 
+	var userPermissions []Permission
+	found := false
 	if a.enableCaching {
-		a.updateCache(userID, permissions)
+		userPermissions, found = a.cachedPermissions(userID)
+		a.recordCacheLookup(ctx, found)
 	}
 
-	for _, p := range permissions {
-		if p.Name == permissionName {
-			return true, nil
+	if !found {
+		userPermissions, err = a.loadUserPermissions(ctx, uid)
+		if err != nil {
+			stale, ok := a.stalePermissions(ctx, userID, err)
+			if !ok {
+				return nil, fmt.Errorf("failed to load user permissions: %w", err)
+			}
+			userPermissions = stale
+		} else if a.enableCaching {
+			a.updateCache(userID, userPermissions)
 		}
 	}
 
-	return false, nil
+	names := make([]string, len(userPermissions))
+	for i, p := range userPermissions {
+		names[i] = p.Name
+	}
+	return names, nil
 }
 
-func (a *Authorizer) HasAnyPermission(ctx context.Context, userID string, permissionNames []string) (bool, error) {
-	for _, permissionName := range permissionNames {
-		hasPermission, err := a.HasPermission(ctx, userID, permissionName)
+// PreloadPermissions loads the permissions of every user in userIDs with one
+// query and caches them, so screens checking many users at once do not run
+// a query per user. Users holding no permissions are cached as such. It is
+// a no-op while caching is disabled.
+func (a *Authorizer) PreloadPermissions(ctx context.Context, userIDs []string) error {
+	if !a.enableCaching || len(userIDs) == 0 {
+		return nil
+	}
+
+	// Cache keys are the IDs as given, matching the lookups HasPermission
+	// makes with them
+	keys := make(map[uuid.UUID]string, len(userIDs))
+	ids := make([]string, 0, len(userIDs))
+	for _, userID := range userIDs {
+		uid, err := uuid.Parse(userID)
+		if err != nil {
+			return fmt.Errorf("invalid user ID: %w", err)
+		}
+		if _, seen := keys[uid]; !seen {
+			ids = append(ids, uid.String())
+		}
+		keys[uid] = userID
+	}
+
+	query := `
+		SELECT DISTINCT ur.user_id, p.name, p.resource, p.action
+		FROM permissions p
+		JOIN role_permissions rp ON p.id = rp.permission_id
+		JOIN roles r ON rp.role_id = r.id
+		JOIN user_roles ur ON r.id = ur.role_id
+		WHERE ur.user_id = ANY($1)
+		ORDER BY ur.user_id, p.name
+	`
+
+	var rows []struct {
+		UserID uuid.UUID `db:"user_id"`
+		Permission
+	}
+	if err := a.db.SelectContext(ctx, &rows, query, pq.Array(ids)); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to preload user permissions: %w", err)
+	}
+
+	loaded := make(map[uuid.UUID][]Permission, len(keys))
+	for _, row := range rows {
+		loaded[row.UserID] = append(loaded[row.UserID], row.Permission)
+	}
+
+	now := time.Now()
+	a.cacheMutex.Lock()
+	defer a.cacheMutex.Unlock()
+	for uid, key := range keys {
+		a.cache[key] = &UserPermissions{Permissions: loaded[uid], LoadedAt: now}
+	}
+	return nil
+}
+
+// WarmCache preloads the permissions of the limit users who logged in most
+// recently, so a restarted instance does not reload them one request at a
+// time. It returns how many users were cached, and is a no-op while caching
+// is disabled.
+func (a *Authorizer) WarmCache(ctx context.Context, limit int) (int, error) {
+	if !a.enableCaching || limit <= 0 {
+		return 0, nil
+	}
+
+	query := `
+		SELECT id
+		FROM users
+		WHERE last_login_at IS NOT NULL
+		ORDER BY last_login_at DESC
+		LIMIT $1
+	`
+
+	var userIDs []string
+	if err := a.db.SelectContext(ctx, &userIDs, query, limit); err != nil {
+		return 0, fmt.Errorf("failed to list recently active users: %w", err)
+	}
+
+	if err := a.PreloadPermissions(ctx, userIDs); err != nil {
+		return 0, err
+	}
+	return len(userIDs), nil
+}
+
+// HasPermissions reports for each entry of required whether userID holds
+// it, loading the user's permissions at most once. A load failure is
+// returned whatever the failure policies of required.
+func (a *Authorizer) HasPermissions(ctx context.Context, userID string, required []permissions.Permission) (map[permissions.Permission]bool, error) {
+	names, err := a.ListPermissions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	held := make(map[string]bool, len(names))
+	for _, name := range names {
+		held[name] = true
+	}
+
+	result := make(map[permissions.Permission]bool, len(required))
+	for _, permission := range required {
+		result[permission] = held[permission.String()]
+	}
+	return result, nil
+}
+
+func (a *Authorizer) HasAnyPermission(ctx context.Context, userID string, required []permissions.Permission) (bool, error) {
+	for _, permission := range required {
+		hasPermission, err := a.HasPermission(ctx, userID, permission)
 		if err != nil {
 			return false, err
 		}
@@ -100,9 +371,9 @@ func (a *Authorizer) HasAnyPermission(ctx context.Context, userID string, permis
 	return false, nil
 }
 
-func (a *Authorizer) HasAllPermissions(ctx context.Context, userID string, permissionNames []string) (bool, error) {
-	for _, permissionName := range permissionNames {
-		hasPermission, err := a.HasPermission(ctx, userID, permissionName)
+func (a *Authorizer) HasAllPermissions(ctx context.Context, userID string, required []permissions.Permission) (bool, error) {
+	for _, permission := range required {
+		hasPermission, err := a.HasPermission(ctx, userID, permission)
 		if err != nil {
 			return false, err
 		}
@@ -160,6 +431,31 @@ func (a *Authorizer) GetUserRoles(ctx context.Context, userID string) ([]string,
 	return roles, nil
 }
 
+// GetRolePermissions returns the permissions granted to roleName in name
+// order, independent of any user. A role with no grants yields an empty
+// slice; an unknown role fails with ErrRoleNotFound.
+func (a *Authorizer) GetRolePermissions(ctx context.Context, roleName string) ([]Permission, error) {
+	if err := a.requireRole(ctx, roleName); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT p.name, p.resource, p.action
+		FROM permissions p
+		JOIN role_permissions rp ON p.id = rp.permission_id
+		JOIN roles r ON rp.role_id = r.id
+		WHERE r.name = $1
+		ORDER BY p.name
+	`
+
+	rolePermissions := []Permission{}
+	if err := a.db.SelectContext(ctx, &rolePermissions, query, roleName); err != nil {
+		return nil, fmt.Errorf("failed to get role permissions: %w", err)
+	}
+
+	return rolePermissions, nil
+}
+
 func (a *Authorizer) AssignRole(ctx context.Context, userID string, roleName string) error {
 	uid, err := uuid.Parse(userID)
 	if err != nil {
@@ -177,7 +473,7 @@ func (a *Authorizer) AssignRole(ctx context.Context, userID string, roleName str
 		return fmt.Errorf("failed to assign role: %w", err)
 	}
 
-	a.invalidateCache(userID)
+	a.InvalidateUserCache(userID)
 
 	return nil
 }
@@ -198,11 +494,173 @@ func (a *Authorizer) RemoveRole(ctx context.Context, userID string, roleName str
 		return fmt.Errorf("failed to remove role: %w", err)
 	}
 
-	a.invalidateCache(userID)
+	a.InvalidateUserCache(userID)
 
 	return nil
 }
 
+// MissingPermissions returns the entries of required that have no row in
+// the permissions table.
+func (a *Authorizer) MissingPermissions(ctx context.Context, required []permissions.Permission) ([]permissions.Permission, error) {
+	var existing []string
+	err := a.db.SelectContext(ctx, &existing, `SELECT name FROM permissions`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list permissions: %w", err)
+	}
+
+	known := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		known[name] = true
+	}
+
+	var missing []permissions.Permission
+	for _, permission := range required {
+		if !known[permission.String()] {
+			missing = append(missing, permission)
+		}
+	}
+
+	return missing, nil
+}
+
+// EnsureRolePermissions grants role each permission in required it does not
+// have yet and returns the ones it granted. Entries with no row in the
+// permissions table cannot be granted and are returned as missing.
+func (a *Authorizer) EnsureRolePermissions(ctx context.Context, role string, required []permissions.Permission) (granted, missing []permissions.Permission, err error) {
+	if err := a.requireRole(ctx, role); err != nil {
+		return nil, nil, err
+	}
+
+	missing, err = a.MissingPermissions(ctx, required)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names := make([]string, len(required))
+	for i, permission := range required {
+		names[i] = permission.String()
+	}
+
+	query := `
+		WITH granted AS (
+			INSERT INTO role_permissions (role_id, permission_id)
+			SELECT r.id, p.id
+			FROM roles r
+			CROSS JOIN permissions p
+			WHERE r.name = $1 AND p.name = ANY($2)
+			ON CONFLICT (role_id, permission_id) DO NOTHING
+			RETURNING permission_id
+		)
+		SELECT p.name FROM permissions p JOIN granted g ON g.permission_id = p.id ORDER BY p.name
+	`
+
+	var grantedNames []string
+	if err := a.db.SelectContext(ctx, &grantedNames, query, role, pq.Array(names)); err != nil {
+		return nil, nil, fmt.Errorf("failed to grant role permissions: %w", err)
+	}
+
+	for _, name := range grantedNames {
+		granted = append(granted, permissions.Permission(name))
+	}
+	if len(granted) > 0 {
+		// Users holding the role may have cached the smaller set
+		a.InvalidateAllCache()
+	}
+
+	return granted, missing, nil
+}
+
+// requireRole fails with ErrRoleNotFound when role has no row in the roles
+// table.
+func (a *Authorizer) requireRole(ctx context.Context, role string) error {
+	var exists bool
+	if err := a.db.GetContext(ctx, &exists, `SELECT EXISTS (SELECT 1 FROM roles WHERE name = $1)`, role); err != nil {
+		return fmt.Errorf("failed to look up role: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrRoleNotFound, role)
+	}
+	return nil
+}
+
+// RoleSimulation is the effect granting a role would have on a user's
+// effective permissions. All lists are in name order.
+type RoleSimulation struct {
+	Current   []string
+	Resulting []string
+	Gained    []string
+}
+
+// SimulateRoleGrant computes the permissions userID would hold if granted
+// role, without assigning it. Unknown roles fail with ErrRoleNotFound.
+func (a *Authorizer) SimulateRoleGrant(ctx context.Context, userID, role string) (RoleSimulation, error) {
+	current, err := a.ListPermissions(ctx, userID)
+	if err != nil {
+		return RoleSimulation{}, err
+	}
+	rolePermissions, err := a.GetRolePermissions(ctx, role)
+	if err != nil {
+		return RoleSimulation{}, err
+	}
+
+	held := make(map[string]bool, len(current))
+	for _, name := range current {
+		held[name] = true
+	}
+
+	sim := RoleSimulation{Current: current, Resulting: append([]string{}, current...), Gained: []string{}}
+	for _, p := range rolePermissions {
+		if !held[p.Name] {
+			held[p.Name] = true
+			sim.Gained = append(sim.Gained, p.Name)
+			sim.Resulting = append(sim.Resulting, p.Name)
+		}
+	}
+	sort.Strings(sim.Resulting)
+
+	return sim, nil
+}
+
+// MissingTables returns the entries of required that do not exist in the
+// connection's current schema.
+func (a *Authorizer) MissingTables(ctx context.Context, required []string) ([]string, error) {
+	var existing []string
+	err := a.db.SelectContext(ctx, &existing,
+		`SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema()`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+
+	known := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		known[name] = true
+	}
+
+	var missing []string
+	for _, table := range required {
+		if !known[table] {
+			missing = append(missing, table)
+		}
+	}
+
+	return missing, nil
+}
+
+// ValidatePermissions logs a warning for every entry of required missing
+// from the database and returns them.
+func (a *Authorizer) ValidatePermissions(ctx context.Context, required []permissions.Permission) ([]permissions.Permission, error) {
+	missing, err := a.MissingPermissions(ctx, required)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, permission := range missing {
+		a.logger.Warn("permission checked by handlers is not seeded", "permission", permission.String())
+	}
+
+	return missing, nil
+}
+
 func (a *Authorizer) loadUserPermissions(ctx context.Context, userID uuid.UUID) ([]Permission, error) {
 	query := `
 		SELECT DISTINCT p.name, p.resource, p.action
@@ -245,6 +703,42 @@ func (a *Authorizer) checkCache(userID string, permissionName string) (bool, boo
 	return false, true
 }
 
+// cachedPermissions returns the unexpired cached permissions of userID.
+func (a *Authorizer) cachedPermissions(userID string) ([]Permission, bool) {
+	a.cacheMutex.RLock()
+	defer a.cacheMutex.RUnlock()
+
+	userPerms, exists := a.cache[userID]
+	if !exists || time.Since(userPerms.LoadedAt) > a.cacheTTL {
+		return nil, false
+	}
+	return userPerms.Permissions, true
+}
+
+// stalePermissions returns the cached permissions of userID up to maxStale
+// old, for use when loading them failed with loadErr. It only answers when
+// serveStaleOnError is set, and logs each use since a revoked permission may
+// still be granted until the database is back.
+func (a *Authorizer) stalePermissions(ctx context.Context, userID string, loadErr error) ([]Permission, bool) {
+	if !a.serveStaleOnError || !a.enableCaching {
+		return nil, false
+	}
+
+	a.cacheMutex.RLock()
+	userPerms, exists := a.cache[userID]
+	a.cacheMutex.RUnlock()
+	if !exists || time.Since(userPerms.LoadedAt) > a.maxStale {
+		return nil, false
+	}
+
+	a.logger.WarnContext(ctx, "serving cached permissions after load failure",
+		"user_id", userID,
+		"age", time.Since(userPerms.LoadedAt),
+		"error", loadErr,
+	)
+	return userPerms.Permissions, true
+}
+
 func (a *Authorizer) updateCache(userID string, permissions []Permission) {
 	a.cacheMutex.Lock()
 	defer a.cacheMutex.Unlock()
@@ -255,20 +749,50 @@ func (a *Authorizer) updateCache(userID string, permissions []Permission) {
 	}
 }
 
-func (a *Authorizer) invalidateCache(userID string) {
+// InvalidateUserCache drops the cached permissions of userID so the next
+// check reloads them.
+func (a *Authorizer) InvalidateUserCache(userID string) {
 	a.cacheMutex.Lock()
 	defer a.cacheMutex.Unlock()
 
 	delete(a.cache, userID)
 }
 
-func (a *Authorizer) InvalidateAllCache() {
+// CacheSize returns the number of users with cached permissions, including
+// entries that have expired but not yet been cleaned up.
+func (a *Authorizer) CacheSize() int {
+	a.cacheMutex.RLock()
+	defer a.cacheMutex.RUnlock()
+
+	return len(a.cache)
+}
+
+// CacheTTL returns how long cached permissions are trusted.
+func (a *Authorizer) CacheTTL() time.Duration {
+	return a.cacheTTL
+}
+
+// CacheStats returns the cache size, hit/miss counts since startup and TTL.
+func (a *Authorizer) CacheStats() CacheStats {
+	return CacheStats{
+		Size:   a.CacheSize(),
+		Hits:   a.cacheHits.Load(),
+		Misses: a.cacheMisses.Load(),
+		TTL:    a.cacheTTL,
+	}
+}
+
+// InvalidateAllCache drops every cached entry and returns how many were
+// cleared.
+func (a *Authorizer) InvalidateAllCache() int {
 	a.cacheMutex.Lock()
 	defer a.cacheMutex.Unlock()
 
+	cleared := len(a.cache)
 	for k := range a.cache {
 		delete(a.cache, k)
 	}
+	return cleared
 }
 
 func (a *Authorizer) StartCacheCleanup(ctx context.Context, interval time.Duration) {
@@ -281,18 +805,48 @@ func (a *Authorizer) StartCacheCleanup(ctx context.Context, interval time.Durati
 				return
 			case <-ticker.C:
 				a.cleanExpiredCache()
+				a.reportHitRatio()
 			}
 		}
 	}()
 }
 
+// reportHitRatio logs the share of cache lookups served from the cache
+// since the previous report, so effectiveness is visible without deriving
+// it from the authz_cache_lookups_total counter. Intervals without lookups
+// are not reported.
+func (a *Authorizer) reportHitRatio() {
+	hits, misses := a.cacheHits.Load(), a.cacheMisses.Load()
+	intervalHits, intervalMisses := hits-a.reportedHits, misses-a.reportedMisses
+	a.reportedHits, a.reportedMisses = hits, misses
+
+	lookups := intervalHits + intervalMisses
+	if lookups <= 0 {
+		return
+	}
+
+	a.logger.Info("permission cache hit ratio",
+		"hit_ratio", float64(intervalHits)/float64(lookups),
+		"hits", intervalHits,
+		"misses", intervalMisses,
+		"entries", a.CacheSize(),
+	)
+}
+
+// cleanExpiredCache drops entries past the TTL, or past maxStale when
+// expired entries may still be served on a load failure.
 func (a *Authorizer) cleanExpiredCache() {
 	a.cacheMutex.Lock()
 	defer a.cacheMutex.Unlock()
 
+	retention := a.cacheTTL
+	if a.serveStaleOnError && a.maxStale > retention {
+		retention = a.maxStale
+	}
+
 	now := time.Now()
 	for userID, userPerms := range a.cache {
-		if now.Sub(userPerms.LoadedAt) > a.cacheTTL {
+		if now.Sub(userPerms.LoadedAt) > retention {
 			delete(a.cache, userID)
 		}
 	}
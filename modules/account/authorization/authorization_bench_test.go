@@ -8,20 +8,14 @@ import (
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
-	"github.com/elskow/go-microservice-template/pkg/database"
+	"github.com/elskow/go-microservice-template/pkg/database/dbtest"
+	"github.com/elskow/go-microservice-template/pkg/permissions"
 	"github.com/google/uuid"
-	"github.com/jmoiron/sqlx"
 )
 
 func BenchmarkAuthorizer_HasPermission_CacheHit(b *testing.B) {
-	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-	if err != nil {
-		b.Fatal(err)
-	}
-	defer mockDB.Close()
-
-	sqlxDB := sqlx.NewDb(mockDB, "sqlmock")
-	tracedDB := database.NewTracedDB(sqlxDB)
+	tracedDB, mock, cleanup := dbtest.NewMockTracedDBExact(b)
+	defer cleanup()
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	authorizer := NewAuthorizer(tracedDB, logger)
 
@@ -55,14 +49,8 @@ func BenchmarkAuthorizer_HasPermission_CacheHit(b *testing.B) {
 }
 
 func BenchmarkAuthorizer_HasPermission_CacheMiss(b *testing.B) {
-	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-	if err != nil {
-		b.Fatal(err)
-	}
-	defer mockDB.Close()
-
-	sqlxDB := sqlx.NewDb(mockDB, "sqlmock")
-	tracedDB := database.NewTracedDB(sqlxDB)
+	tracedDB, mock, cleanup := dbtest.NewMockTracedDBExact(b)
+	defer cleanup()
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	authorizer := NewAuthorizer(tracedDB, logger)
 	authorizer.DisableCache()
@@ -95,14 +83,8 @@ func BenchmarkAuthorizer_HasPermission_CacheMiss(b *testing.B) {
 }
 
 func BenchmarkAuthorizer_HasAnyPermission(b *testing.B) {
-	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-	if err != nil {
-		b.Fatal(err)
-	}
-	defer mockDB.Close()
-
-	sqlxDB := sqlx.NewDb(mockDB, "sqlmock")
-	tracedDB := database.NewTracedDB(sqlxDB)
+	tracedDB, mock, cleanup := dbtest.NewMockTracedDBExact(b)
+	defer cleanup()
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	authorizer := NewAuthorizer(tracedDB, logger)
 
@@ -128,23 +110,17 @@ func BenchmarkAuthorizer_HasAnyPermission(b *testing.B) {
 	// Warm up cache
 	_, _ = authorizer.HasPermission(ctx, userID.String(), "read:users")
 
-	permissions := []string{"delete:users", "write:users", "update:users"}
+	required := []permissions.Permission{"delete:users", "write:users", "update:users"}
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, _ = authorizer.HasAnyPermission(ctx, userID.String(), permissions)
+		_, _ = authorizer.HasAnyPermission(ctx, userID.String(), required)
 	}
 }
 
 func BenchmarkAuthorizer_HasAllPermissions(b *testing.B) {
-	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-	if err != nil {
-		b.Fatal(err)
-	}
-	defer mockDB.Close()
-
-	sqlxDB := sqlx.NewDb(mockDB, "sqlmock")
-	tracedDB := database.NewTracedDB(sqlxDB)
+	tracedDB, mock, cleanup := dbtest.NewMockTracedDBExact(b)
+	defer cleanup()
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	authorizer := NewAuthorizer(tracedDB, logger)
 
@@ -171,23 +147,17 @@ func BenchmarkAuthorizer_HasAllPermissions(b *testing.B) {
 	// Warm up cache
 	_, _ = authorizer.HasPermission(ctx, userID.String(), "read:users")
 
-	permissions := []string{"read:users", "write:users"}
+	required := []permissions.Permission{"read:users", "write:users"}
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, _ = authorizer.HasAllPermissions(ctx, userID.String(), permissions)
+		_, _ = authorizer.HasAllPermissions(ctx, userID.String(), required)
 	}
 }
 
 func BenchmarkAuthorizer_CacheInvalidation(b *testing.B) {
-	mockDB, _, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-	if err != nil {
-		b.Fatal(err)
-	}
-	defer mockDB.Close()
-
-	sqlxDB := sqlx.NewDb(mockDB, "sqlmock")
-	tracedDB := database.NewTracedDB(sqlxDB)
+	tracedDB, _, cleanup := dbtest.NewMockTracedDBExact(b)
+	defer cleanup()
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	authorizer := NewAuthorizer(tracedDB, logger)
 
@@ -204,19 +174,13 @@ func BenchmarkAuthorizer_CacheInvalidation(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		authorizer.invalidateCache(userID.String())
+		authorizer.InvalidateUserCache(userID.String())
 	}
 }
 
 func BenchmarkAuthorizer_InvalidateAllCache(b *testing.B) {
-	mockDB, _, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-	if err != nil {
-		b.Fatal(err)
-	}
-	defer mockDB.Close()
-
-	sqlxDB := sqlx.NewDb(mockDB, "sqlmock")
-	tracedDB := database.NewTracedDB(sqlxDB)
+	tracedDB, _, cleanup := dbtest.NewMockTracedDBExact(b)
+	defer cleanup()
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	authorizer := NewAuthorizer(tracedDB, logger)
 
@@ -238,14 +202,8 @@ func BenchmarkAuthorizer_InvalidateAllCache(b *testing.B) {
 }
 
 func BenchmarkAuthorizer_CleanExpiredCache(b *testing.B) {
-	mockDB, _, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-	if err != nil {
-		b.Fatal(err)
-	}
-	defer mockDB.Close()
-
-	sqlxDB := sqlx.NewDb(mockDB, "sqlmock")
-	tracedDB := database.NewTracedDB(sqlxDB)
+	tracedDB, _, cleanup := dbtest.NewMockTracedDBExact(b)
+	defer cleanup()
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	authorizer := NewAuthorizer(tracedDB, logger)
 
@@ -269,14 +227,8 @@ func BenchmarkAuthorizer_CleanExpiredCache(b *testing.B) {
 }
 
 func BenchmarkAuthorizer_ConcurrentReads(b *testing.B) {
-	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-	if err != nil {
-		b.Fatal(err)
-	}
-	defer mockDB.Close()
-
-	sqlxDB := sqlx.NewDb(mockDB, "sqlmock")
-	tracedDB := database.NewTracedDB(sqlxDB)
+	tracedDB, mock, cleanup := dbtest.NewMockTracedDBExact(b)
+	defer cleanup()
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	authorizer := NewAuthorizer(tracedDB, logger)
 
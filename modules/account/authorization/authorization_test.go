@@ -1,8 +1,11 @@
 package authorization
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"log/slog"
 	"os"
 	"testing"
@@ -10,37 +13,25 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/elskow/go-microservice-template/pkg/constants"
-	"github.com/elskow/go-microservice-template/pkg/database"
+	"github.com/elskow/go-microservice-template/pkg/database/dbtest"
+	"github.com/elskow/go-microservice-template/pkg/permissions"
 	"github.com/google/uuid"
-	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-func setupMockDB(t *testing.T) (*database.TracedDB, sqlmock.Sqlmock) {
-	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-	require.NoError(t, err)
-
-	sqlxDB := sqlx.NewDb(mockDB, "sqlmock")
-	tracedDB := database.NewTracedDB(sqlxDB)
-	return tracedDB, mock
-}
-
 func setupAuthorizer(t *testing.T) (*Authorizer, sqlmock.Sqlmock, func()) {
-	db, mock := setupMockDB(t)
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	authorizer := NewAuthorizer(db, logger)
 
-	cleanup := func() {
-		db.DB.Close()
-	}
-
 	return authorizer, mock, cleanup
 }
 
 func TestNewAuthorizer(t *testing.T) {
-	db, _ := setupMockDB(t)
-	defer db.DB.Close()
+	db, _, cleanup := dbtest.NewMockTracedDBExact(t)
+	defer cleanup()
 
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	authorizer := NewAuthorizer(db, logger)
@@ -282,8 +273,8 @@ func TestAuthorizer_HasAnyPermission(t *testing.T) {
 		WithArgs(userID).
 		WillReturnRows(rows)
 
-	permissions := []string{"delete:users", "read:users", "write:users"}
-	hasAny, err := authorizer.HasAnyPermission(ctx, userID.String(), permissions)
+	required := []permissions.Permission{"delete:users", "read:users", "write:users"}
+	hasAny, err := authorizer.HasAnyPermission(ctx, userID.String(), required)
 
 	assert.NoError(t, err)
 	assert.True(t, hasAny)
@@ -314,8 +305,8 @@ func TestAuthorizer_HasAnyPermission_NoMatch(t *testing.T) {
 		WithArgs(userID).
 		WillReturnRows(rows)
 
-	permissions := []string{"delete:users", "write:users"}
-	hasAny, err := authorizer.HasAnyPermission(ctx, userID.String(), permissions)
+	required := []permissions.Permission{"delete:users", "write:users"}
+	hasAny, err := authorizer.HasAnyPermission(ctx, userID.String(), required)
 
 	assert.NoError(t, err)
 	assert.False(t, hasAny)
@@ -347,8 +338,8 @@ func TestAuthorizer_HasAllPermissions(t *testing.T) {
 		WithArgs(userID).
 		WillReturnRows(rows)
 
-	permissions := []string{"read:users", "write:users"}
-	hasAll, err := authorizer.HasAllPermissions(ctx, userID.String(), permissions)
+	required := []permissions.Permission{"read:users", "write:users"}
+	hasAll, err := authorizer.HasAllPermissions(ctx, userID.String(), required)
 
 	assert.NoError(t, err)
 	assert.True(t, hasAll)
@@ -379,8 +370,8 @@ func TestAuthorizer_HasAllPermissions_MissingOne(t *testing.T) {
 		WithArgs(userID).
 		WillReturnRows(rows)
 
-	permissions := []string{"read:users", "write:users", "delete:users"}
-	hasAll, err := authorizer.HasAllPermissions(ctx, userID.String(), permissions)
+	required := []permissions.Permission{"read:users", "write:users", "delete:users"}
+	hasAll, err := authorizer.HasAllPermissions(ctx, userID.String(), required)
 
 	assert.NoError(t, err)
 	assert.False(t, hasAll)
@@ -470,6 +461,74 @@ func TestAuthorizer_GetUserRoles_InvalidUserID(t *testing.T) {
 	assert.Contains(t, err.Error(), "invalid user ID")
 }
 
+func TestAuthorizer_GetRolePermissions(t *testing.T) {
+	authorizer, mock, cleanup := setupAuthorizer(t)
+	defer cleanup()
+
+	mock.ExpectQuery(roleExistsQuery).WithArgs("editor").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(rolePermissionsQuery).WithArgs("editor").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "resource", "action"}).
+			AddRow("user.read", "user", "read").
+			AddRow("user.update", "user", "update"))
+
+	perms, err := authorizer.GetRolePermissions(context.Background(), "editor")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Permission{
+		{Name: "user.read", Resource: "user", Action: "read"},
+		{Name: "user.update", Resource: "user", Action: "update"},
+	}, perms)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthorizer_GetRolePermissions_NoGrants(t *testing.T) {
+	authorizer, mock, cleanup := setupAuthorizer(t)
+	defer cleanup()
+
+	mock.ExpectQuery(roleExistsQuery).WithArgs("guest").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(rolePermissionsQuery).WithArgs("guest").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "resource", "action"}))
+
+	perms, err := authorizer.GetRolePermissions(context.Background(), "guest")
+
+	assert.NoError(t, err)
+	assert.NotNil(t, perms)
+	assert.Empty(t, perms)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthorizer_GetRolePermissions_UnknownRole(t *testing.T) {
+	authorizer, mock, cleanup := setupAuthorizer(t)
+	defer cleanup()
+
+	mock.ExpectQuery(roleExistsQuery).WithArgs("ghost").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	perms, err := authorizer.GetRolePermissions(context.Background(), "ghost")
+
+	assert.ErrorIs(t, err, ErrRoleNotFound)
+	assert.Nil(t, perms)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthorizer_GetRolePermissions_QueryError(t *testing.T) {
+	authorizer, mock, cleanup := setupAuthorizer(t)
+	defer cleanup()
+
+	mock.ExpectQuery(roleExistsQuery).WithArgs("editor").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(rolePermissionsQuery).WithArgs("editor").
+		WillReturnError(errors.New("connection reset"))
+
+	_, err := authorizer.GetRolePermissions(context.Background(), "editor")
+
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrRoleNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestAuthorizer_AssignRole(t *testing.T) {
 	authorizer, mock, cleanup := setupAuthorizer(t)
 	defer cleanup()
@@ -584,19 +643,14 @@ func TestAuthorizer_InvalidateAllCache(t *testing.T) {
 	_, _ = authorizer.HasPermission(ctx, userID.String(), "read:users")
 
 	// Verify cache has entry
-	authorizer.cacheMutex.RLock()
-	lenBefore := len(authorizer.cache)
-	authorizer.cacheMutex.RUnlock()
-	assert.Equal(t, 1, lenBefore)
+	assert.Equal(t, 1, authorizer.CacheSize())
 
 	// Invalidate all
-	authorizer.InvalidateAllCache()
+	cleared := authorizer.InvalidateAllCache()
 
 	// Verify cache is empty
-	authorizer.cacheMutex.RLock()
-	lenAfter := len(authorizer.cache)
-	authorizer.cacheMutex.RUnlock()
-	assert.Equal(t, 0, lenAfter)
+	assert.Equal(t, 1, cleared)
+	assert.Equal(t, 0, authorizer.CacheSize())
 }
 
 func TestAuthorizer_CacheExpiry(t *testing.T) {
@@ -733,6 +787,44 @@ func TestAuthorizer_StartCacheCleanup(t *testing.T) {
 	assert.Equal(t, 0, lenAfter)
 }
 
+func TestAuthorizer_ReportHitRatio(t *testing.T) {
+	var buf bytes.Buffer
+	authorizer := NewAuthorizer(nil, slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	readReport := func() map[string]any {
+		defer buf.Reset()
+		if buf.Len() == 0 {
+			return nil
+		}
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		return entry
+	}
+
+	authorizer.cacheHits.Store(30)
+	authorizer.cacheMisses.Store(10)
+	authorizer.reportHitRatio()
+
+	entry := readReport()
+	require.NotNil(t, entry)
+	assert.Equal(t, "permission cache hit ratio", entry["msg"])
+	assert.Equal(t, 0.75, entry["hit_ratio"])
+	assert.Equal(t, float64(30), entry["hits"])
+	assert.Equal(t, float64(10), entry["misses"])
+
+	// The next report only covers lookups since the previous one
+	authorizer.cacheHits.Add(1)
+	authorizer.cacheMisses.Add(3)
+	authorizer.reportHitRatio()
+
+	entry = readReport()
+	require.NotNil(t, entry)
+	assert.Equal(t, 0.25, entry["hit_ratio"])
+
+	authorizer.reportHitRatio()
+	assert.Nil(t, readReport(), "idle interval should not be reported")
+}
+
 func TestAuthorizer_DatabaseError(t *testing.T) {
 	authorizer, mock, cleanup := setupAuthorizer(t)
 	defer cleanup()
@@ -761,3 +853,569 @@ func TestAuthorizer_DatabaseError(t *testing.T) {
 	assert.Contains(t, err.Error(), "failed to load user permissions")
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestAuthorizer_ValidatePermissions_AllPresent(t *testing.T) {
+	authorizer, mock, cleanup := setupAuthorizer(t)
+	defer cleanup()
+
+	rows := sqlmock.NewRows([]string{"name"})
+	for _, name := range RequiredPermissions {
+		rows.AddRow(name.String())
+	}
+	mock.ExpectQuery(`SELECT name FROM permissions`).WillReturnRows(rows)
+
+	missing, err := authorizer.ValidatePermissions(context.Background(), RequiredPermissions)
+
+	assert.NoError(t, err)
+	assert.Empty(t, missing)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthorizer_ValidatePermissions_Missing(t *testing.T) {
+	authorizer, mock, cleanup := setupAuthorizer(t)
+	defer cleanup()
+
+	mock.ExpectQuery(`SELECT name FROM permissions`).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("user.update").AddRow("user.list"))
+
+	missing, err := authorizer.ValidatePermissions(context.Background(),
+		[]permissions.Permission{permissions.UserUpdate, permissions.UserDelete, permissions.UserList, permissions.UserManage})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []permissions.Permission{permissions.UserDelete, permissions.UserManage}, missing)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthorizer_MissingTables_AllPresent(t *testing.T) {
+	authorizer, mock, cleanup := setupAuthorizer(t)
+	defer cleanup()
+
+	rows := sqlmock.NewRows([]string{"table_name"}).AddRow("users")
+	for _, table := range RequiredTables {
+		rows.AddRow(table)
+	}
+	mock.ExpectQuery(`SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema()`).WillReturnRows(rows)
+
+	missing, err := authorizer.MissingTables(context.Background(), RequiredTables)
+
+	assert.NoError(t, err)
+	assert.Empty(t, missing)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthorizer_MissingTables_Missing(t *testing.T) {
+	authorizer, mock, cleanup := setupAuthorizer(t)
+	defer cleanup()
+
+	mock.ExpectQuery(`SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema()`).
+		WillReturnRows(sqlmock.NewRows([]string{"table_name"}).AddRow("users").AddRow("roles"))
+
+	missing, err := authorizer.MissingTables(context.Background(), RequiredTables)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"permissions", "role_permissions", "user_roles"}, missing)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthorizer_MissingTables_QueryError(t *testing.T) {
+	authorizer, mock, cleanup := setupAuthorizer(t)
+	defer cleanup()
+
+	mock.ExpectQuery(`SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema()`).WillReturnError(errors.New("connection refused"))
+
+	_, err := authorizer.MissingTables(context.Background(), RequiredTables)
+
+	assert.Error(t, err)
+}
+
+const (
+	roleExistsQuery       = `SELECT EXISTS (SELECT 1 FROM roles WHERE name = $1)`
+	grantPermissionsQuery = `
+		WITH granted AS (
+			INSERT INTO role_permissions (role_id, permission_id)
+			SELECT r.id, p.id
+			FROM roles r
+			CROSS JOIN permissions p
+			WHERE r.name = $1 AND p.name = ANY($2)
+			ON CONFLICT (role_id, permission_id) DO NOTHING
+			RETURNING permission_id
+		)
+		SELECT p.name FROM permissions p JOIN granted g ON g.permission_id = p.id ORDER BY p.name
+	`
+)
+
+func TestAuthorizer_EnsureRolePermissions_GrantsMissing(t *testing.T) {
+	authorizer, mock, cleanup := setupAuthorizer(t)
+	defer cleanup()
+	authorizer.updateCache(uuid.NewString(), nil)
+
+	required := []permissions.Permission{permissions.UserRead, permissions.UserUpdate, permissions.Permission("user.audit")}
+	mock.ExpectQuery(roleExistsQuery).WithArgs(DefaultRole).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`SELECT name FROM permissions`).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("user.read").AddRow("user.update"))
+	mock.ExpectQuery(grantPermissionsQuery).
+		WithArgs(DefaultRole, pq.Array([]string{"user.read", "user.update", "user.audit"})).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("user.update"))
+
+	granted, missing, err := authorizer.EnsureRolePermissions(context.Background(), DefaultRole, required)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []permissions.Permission{permissions.UserUpdate}, granted)
+	assert.Equal(t, []permissions.Permission{"user.audit"}, missing)
+	assert.Equal(t, 0, authorizer.CacheSize())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthorizer_EnsureRolePermissions_AlreadyGranted(t *testing.T) {
+	authorizer, mock, cleanup := setupAuthorizer(t)
+	defer cleanup()
+	authorizer.updateCache(uuid.NewString(), nil)
+
+	mock.ExpectQuery(roleExistsQuery).WithArgs(DefaultRole).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`SELECT name FROM permissions`).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("user.read"))
+	mock.ExpectQuery(grantPermissionsQuery).
+		WithArgs(DefaultRole, pq.Array([]string{"user.read"})).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}))
+
+	granted, missing, err := authorizer.EnsureRolePermissions(context.Background(), DefaultRole, []permissions.Permission{permissions.UserRead})
+
+	assert.NoError(t, err)
+	assert.Empty(t, granted)
+	assert.Empty(t, missing)
+	assert.Equal(t, 1, authorizer.CacheSize())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthorizer_EnsureRolePermissions_UnknownRole(t *testing.T) {
+	authorizer, mock, cleanup := setupAuthorizer(t)
+	defer cleanup()
+
+	mock.ExpectQuery(roleExistsQuery).WithArgs("guest").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	_, _, err := authorizer.EnsureRolePermissions(context.Background(), "guest", []permissions.Permission{permissions.UserRead})
+
+	assert.ErrorIs(t, err, ErrRoleNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+const rolePermissionsQuery = `
+		SELECT p.name, p.resource, p.action
+		FROM permissions p
+		JOIN role_permissions rp ON p.id = rp.permission_id
+		JOIN roles r ON rp.role_id = r.id
+		WHERE r.name = $1
+		ORDER BY p.name
+	`
+
+func TestAuthorizer_SimulateRoleGrant(t *testing.T) {
+	authorizer, mock, cleanup := setupAuthorizer(t)
+	defer cleanup()
+
+	userID := uuid.New()
+	mock.ExpectQuery(`
+		SELECT DISTINCT p.name, p.resource, p.action
+		FROM permissions p
+		JOIN role_permissions rp ON p.id = rp.permission_id
+		JOIN roles r ON rp.role_id = r.id
+		JOIN user_roles ur ON r.id = ur.role_id
+		WHERE ur.user_id = $1
+		ORDER BY p.name
+	`).WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "resource", "action"}).
+			AddRow("user.read", "user", "read").
+			AddRow("user.update", "user", "update"))
+	mock.ExpectQuery(roleExistsQuery).WithArgs("moderator").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(rolePermissionsQuery).WithArgs("moderator").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "resource", "action"}).
+			AddRow("user.delete", "user", "delete").
+			AddRow("user.list", "user", "list").
+			AddRow("user.read", "user", "read"))
+
+	sim, err := authorizer.SimulateRoleGrant(context.Background(), userID.String(), "moderator")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"user.read", "user.update"}, sim.Current)
+	assert.Equal(t, []string{"user.delete", "user.list"}, sim.Gained)
+	assert.Equal(t, []string{"user.delete", "user.list", "user.read", "user.update"}, sim.Resulting)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthorizer_ListPermissions_UsesCache(t *testing.T) {
+	authorizer, mock, cleanup := setupAuthorizer(t)
+	defer cleanup()
+
+	userID := uuid.New()
+	query := `
+		SELECT DISTINCT p.name, p.resource, p.action
+		FROM permissions p
+		JOIN role_permissions rp ON p.id = rp.permission_id
+		JOIN roles r ON rp.role_id = r.id
+		JOIN user_roles ur ON r.id = ur.role_id
+		WHERE ur.user_id = $1
+		ORDER BY p.name
+	`
+	mock.ExpectQuery(query).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "resource", "action"}).
+			AddRow("user.read", "user", "read").
+			AddRow("user.update", "user", "update"))
+
+	ctx := context.Background()
+	first, err := authorizer.ListPermissions(ctx, userID.String())
+	assert.NoError(t, err)
+	second, err := authorizer.ListPermissions(ctx, userID.String())
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"user.read", "user.update"}, first)
+	assert.Equal(t, first, second)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+const preloadPermissionsQuery = `
+		SELECT DISTINCT ur.user_id, p.name, p.resource, p.action
+		FROM permissions p
+		JOIN role_permissions rp ON p.id = rp.permission_id
+		JOIN roles r ON rp.role_id = r.id
+		JOIN user_roles ur ON r.id = ur.role_id
+		WHERE ur.user_id = ANY($1)
+		ORDER BY ur.user_id, p.name
+	`
+
+func TestAuthorizer_PreloadPermissions(t *testing.T) {
+	authorizer, mock, cleanup := setupAuthorizer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	admin, member, guest := uuid.New(), uuid.New(), uuid.New()
+	userIDs := []string{admin.String(), member.String(), guest.String()}
+
+	mock.ExpectQuery(preloadPermissionsQuery).
+		WithArgs(pq.Array(userIDs)).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "name", "resource", "action"}).
+			AddRow(admin, "user.delete", "user", "delete").
+			AddRow(admin, "user.read", "user", "read").
+			AddRow(member, "user.read", "user", "read"))
+
+	require.NoError(t, authorizer.PreloadPermissions(ctx, userIDs))
+	assert.Equal(t, 3, authorizer.CacheSize())
+
+	// No further queries are expected: every check below is a cache hit
+	checks := []struct {
+		userID     uuid.UUID
+		permission permissions.Permission
+		want       bool
+	}{
+		{userID: admin, permission: "user.delete", want: true},
+		{userID: admin, permission: "user.read", want: true},
+		{userID: member, permission: "user.read", want: true},
+		{userID: member, permission: "user.delete", want: false},
+		{userID: guest, permission: "user.read", want: false},
+	}
+	for _, check := range checks {
+		has, err := authorizer.HasPermission(ctx, check.userID.String(), check.permission)
+		require.NoError(t, err)
+		assert.Equal(t, check.want, has, "%s %s", check.userID, check.permission)
+	}
+
+	assert.Equal(t, int64(len(checks)), authorizer.CacheStats().Hits)
+	assert.Zero(t, authorizer.CacheStats().Misses)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthorizer_PreloadPermissions_InvalidUserID(t *testing.T) {
+	authorizer, mock, cleanup := setupAuthorizer(t)
+	defer cleanup()
+
+	err := authorizer.PreloadPermissions(context.Background(), []string{uuid.NewString(), "invalid-uuid"})
+
+	assert.ErrorContains(t, err, "invalid user ID")
+	assert.Zero(t, authorizer.CacheSize())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthorizer_PreloadPermissions_QueryError(t *testing.T) {
+	authorizer, mock, cleanup := setupAuthorizer(t)
+	defer cleanup()
+
+	userID := uuid.NewString()
+	mock.ExpectQuery(preloadPermissionsQuery).
+		WithArgs(pq.Array([]string{userID})).
+		WillReturnError(errors.New("connection refused"))
+
+	err := authorizer.PreloadPermissions(context.Background(), []string{userID})
+
+	assert.ErrorContains(t, err, "failed to preload user permissions")
+	assert.Zero(t, authorizer.CacheSize())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthorizer_ServeStaleOnError_UsesExpiredEntry(t *testing.T) {
+	authorizer, mock, cleanup := setupAuthorizer(t)
+	defer cleanup()
+
+	var logs bytes.Buffer
+	authorizer.logger = slog.New(slog.NewJSONHandler(&logs, nil))
+	authorizer.serveStaleOnError = true
+	authorizer.maxStale = 2 * time.Hour
+	authorizer.SetCacheTTL(time.Minute)
+
+	userID := uuid.New()
+	ctx := context.Background()
+	authorizer.cache[userID.String()] = &UserPermissions{
+		Permissions: []Permission{{Name: "read:users", Resource: "users", Action: "read"}},
+		LoadedAt:    time.Now().Add(-time.Hour),
+	}
+
+	query := `
+		SELECT DISTINCT p.name, p.resource, p.action
+		FROM permissions p
+		JOIN role_permissions rp ON p.id = rp.permission_id
+		JOIN roles r ON rp.role_id = r.id
+		JOIN user_roles ur ON r.id = ur.role_id
+		WHERE ur.user_id = $1
+		ORDER BY p.name
+	`
+	mock.ExpectQuery(query).WithArgs(userID).WillReturnError(sql.ErrConnDone)
+	mock.ExpectQuery(query).WithArgs(userID).WillReturnError(sql.ErrConnDone)
+	mock.ExpectQuery(query).WithArgs(userID).WillReturnError(sql.ErrConnDone)
+
+	hasPermission, err := authorizer.HasPermission(ctx, userID.String(), "read:users")
+	require.NoError(t, err)
+	assert.True(t, hasPermission)
+
+	hasPermission, err = authorizer.HasPermission(ctx, userID.String(), "delete:users")
+	require.NoError(t, err)
+	assert.False(t, hasPermission)
+
+	names, err := authorizer.ListPermissions(ctx, userID.String())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"read:users"}, names)
+
+	assert.Contains(t, logs.String(), "serving cached permissions after load failure")
+	assert.True(t, time.Since(authorizer.cache[userID.String()].LoadedAt) > time.Minute, "stale entry must not be refreshed")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthorizer_ServeStaleOnError_Disabled(t *testing.T) {
+	authorizer, mock, cleanup := setupAuthorizer(t)
+	defer cleanup()
+
+	authorizer.SetCacheTTL(time.Minute)
+
+	userID := uuid.New()
+	authorizer.cache[userID.String()] = &UserPermissions{
+		Permissions: []Permission{{Name: "read:users", Resource: "users", Action: "read"}},
+		LoadedAt:    time.Now().Add(-time.Hour),
+	}
+
+	query := `
+		SELECT DISTINCT p.name, p.resource, p.action
+		FROM permissions p
+		JOIN role_permissions rp ON p.id = rp.permission_id
+		JOIN roles r ON rp.role_id = r.id
+		JOIN user_roles ur ON r.id = ur.role_id
+		WHERE ur.user_id = $1
+		ORDER BY p.name
+	`
+	mock.ExpectQuery(query).WithArgs(userID).WillReturnError(sql.ErrConnDone)
+
+	hasPermission, err := authorizer.HasPermission(context.Background(), userID.String(), "read:users")
+	assert.Error(t, err)
+	assert.False(t, hasPermission)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthorizer_ServeStaleOnError_NoEntry(t *testing.T) {
+	authorizer, mock, cleanup := setupAuthorizer(t)
+	defer cleanup()
+
+	authorizer.serveStaleOnError = true
+
+	userID := uuid.New()
+	query := `
+		SELECT DISTINCT p.name, p.resource, p.action
+		FROM permissions p
+		JOIN role_permissions rp ON p.id = rp.permission_id
+		JOIN roles r ON rp.role_id = r.id
+		JOIN user_roles ur ON r.id = ur.role_id
+		WHERE ur.user_id = $1
+		ORDER BY p.name
+	`
+	mock.ExpectQuery(query).WithArgs(userID).WillReturnError(sql.ErrConnDone)
+
+	hasPermission, err := authorizer.HasPermission(context.Background(), userID.String(), "read:users")
+	assert.Error(t, err)
+	assert.False(t, hasPermission)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthorizer_ServeStaleOnError_BeyondMaxStale(t *testing.T) {
+	authorizer, mock, cleanup := setupAuthorizer(t)
+	defer cleanup()
+
+	authorizer.serveStaleOnError = true
+	authorizer.maxStale = 30 * time.Minute
+	authorizer.SetCacheTTL(time.Minute)
+
+	userID := uuid.New()
+	authorizer.cache[userID.String()] = &UserPermissions{
+		Permissions: []Permission{{Name: "read:users", Resource: "users", Action: "read"}},
+		LoadedAt:    time.Now().Add(-time.Hour),
+	}
+
+	query := `
+		SELECT DISTINCT p.name, p.resource, p.action
+		FROM permissions p
+		JOIN role_permissions rp ON p.id = rp.permission_id
+		JOIN roles r ON rp.role_id = r.id
+		JOIN user_roles ur ON r.id = ur.role_id
+		WHERE ur.user_id = $1
+		ORDER BY p.name
+	`
+	mock.ExpectQuery(query).WithArgs(userID).WillReturnError(sql.ErrConnDone)
+
+	hasPermission, err := authorizer.HasPermission(context.Background(), userID.String(), "read:users")
+	assert.Error(t, err)
+	assert.False(t, hasPermission)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthorizer_CleanExpiredCache_KeepsStaleEntries(t *testing.T) {
+	authorizer, _, cleanup := setupAuthorizer(t)
+	defer cleanup()
+
+	authorizer.serveStaleOnError = true
+	authorizer.maxStale = time.Hour
+	authorizer.SetCacheTTL(time.Minute)
+
+	perms := []Permission{{Name: "read:users", Resource: "users", Action: "read"}}
+	authorizer.cache["expired"] = &UserPermissions{Permissions: perms, LoadedAt: time.Now().Add(-10 * time.Minute)}
+	authorizer.cache["too-stale"] = &UserPermissions{Permissions: perms, LoadedAt: time.Now().Add(-2 * time.Hour)}
+
+	authorizer.cleanExpiredCache()
+	assert.Contains(t, authorizer.cache, "expired")
+	assert.NotContains(t, authorizer.cache, "too-stale")
+
+	authorizer.serveStaleOnError = false
+	authorizer.cleanExpiredCache()
+	assert.Empty(t, authorizer.cache)
+}
+
+func TestAuthorizer_FailurePolicy_DefaultsToFailClosed(t *testing.T) {
+	authorizer, _, cleanup := setupAuthorizer(t)
+	defer cleanup()
+
+	assert.Equal(t, FailClosed, authorizer.FailurePolicy(permissions.UserList))
+	authorizer.SetFailurePolicy(permissions.UserList, FailOpen)
+	assert.Equal(t, FailOpen, authorizer.FailurePolicy(permissions.UserList))
+	assert.Equal(t, FailClosed, authorizer.FailurePolicy(permissions.UserDelete))
+}
+
+func TestAuthorizer_FailOpen_GrantsOnLoadError(t *testing.T) {
+	authorizer, mock, cleanup := setupAuthorizer(t)
+	defer cleanup()
+
+	var logs bytes.Buffer
+	authorizer.logger = slog.New(slog.NewJSONHandler(&logs, nil))
+	authorizer.SetFailurePolicy(permissions.UserList, FailOpen)
+
+	userID := uuid.New()
+	ctx := context.Background()
+	query := `
+		SELECT DISTINCT p.name, p.resource, p.action
+		FROM permissions p
+		JOIN role_permissions rp ON p.id = rp.permission_id
+		JOIN roles r ON rp.role_id = r.id
+		JOIN user_roles ur ON r.id = ur.role_id
+		WHERE ur.user_id = $1
+		ORDER BY p.name
+	`
+	mock.ExpectQuery(query).WithArgs(userID).WillReturnError(sql.ErrConnDone)
+	mock.ExpectQuery(query).WithArgs(userID).WillReturnError(sql.ErrConnDone)
+
+	hasPermission, err := authorizer.HasPermission(ctx, userID.String(), permissions.UserList)
+	require.NoError(t, err)
+	assert.True(t, hasPermission)
+	assert.Contains(t, logs.String(), "permission check failed open")
+	assert.Contains(t, logs.String(), `"permission":"user.list"`)
+
+	hasPermission, err = authorizer.HasPermission(ctx, userID.String(), permissions.UserDelete)
+	assert.Error(t, err)
+	assert.False(t, hasPermission)
+
+	assert.Zero(t, authorizer.CacheSize(), "a failed load must not be cached")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+const warmCacheQuery = `
+		SELECT id
+		FROM users
+		WHERE last_login_at IS NOT NULL
+		ORDER BY last_login_at DESC
+		LIMIT $1
+	`
+
+func TestAuthorizer_WarmCache(t *testing.T) {
+	authorizer, mock, cleanup := setupAuthorizer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	recent, older := uuid.New(), uuid.New()
+	userIDs := []string{recent.String(), older.String()}
+
+	mock.ExpectQuery(warmCacheQuery).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(recent.String()).AddRow(older.String()))
+	mock.ExpectQuery(preloadPermissionsQuery).
+		WithArgs(pq.Array(userIDs)).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "name", "resource", "action"}).
+			AddRow(recent, "user.read", "user", "read"))
+
+	warmed, err := authorizer.WarmCache(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 2, warmed)
+	assert.Equal(t, 2, authorizer.CacheSize())
+
+	cached, ok := authorizer.cachedPermissions(recent.String())
+	require.True(t, ok)
+	assert.Equal(t, []Permission{{Name: "user.read", Resource: "user", Action: "read"}}, cached)
+	cached, ok = authorizer.cachedPermissions(older.String())
+	require.True(t, ok)
+	assert.Empty(t, cached)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthorizer_WarmCache_Disabled(t *testing.T) {
+	authorizer, mock, cleanup := setupAuthorizer(t)
+	defer cleanup()
+
+	warmed, err := authorizer.WarmCache(context.Background(), 0)
+	require.NoError(t, err)
+	assert.Zero(t, warmed)
+
+	authorizer.DisableCache()
+	warmed, err = authorizer.WarmCache(context.Background(), 10)
+	require.NoError(t, err)
+	assert.Zero(t, warmed)
+
+	assert.Zero(t, authorizer.CacheSize())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthorizer_WarmCache_QueryError(t *testing.T) {
+	authorizer, mock, cleanup := setupAuthorizer(t)
+	defer cleanup()
+
+	mock.ExpectQuery(warmCacheQuery).WithArgs(5).WillReturnError(sql.ErrConnDone)
+
+	_, err := authorizer.WarmCache(context.Background(), 5)
+	assert.ErrorIs(t, err, sql.ErrConnDone)
+	assert.Zero(t, authorizer.CacheSize())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
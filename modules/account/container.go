@@ -0,0 +1,67 @@
+package account
+
+import (
+	"github.com/elskow/go-microservice-template/middlewares"
+	"github.com/elskow/go-microservice-template/modules/account/controller"
+	"github.com/elskow/go-microservice-template/modules/account/service"
+	"github.com/elskow/go-microservice-template/pkg/apm"
+	"github.com/elskow/go-microservice-template/pkg/captcha"
+	"github.com/elskow/go-microservice-template/pkg/jwt"
+	"github.com/gin-gonic/gin"
+	"github.com/samber/do"
+)
+
+// Container resolves the dependencies the account module needs, so routes
+// can be registered against fakes in tests instead of a full injector.
+type Container interface {
+	Controller() (*controller.Controller, error)
+	JWTService() (jwt.Service, error)
+	CaptchaVerifier() (captcha.Verifier, error)
+	Service() (service.Service, error)
+	RouteAuth() (*middlewares.RouteAuth, error)
+	Router() (*gin.Engine, error)
+	MaintenanceMode() (*middlewares.MaintenanceMode, error)
+	MetricsCollector() (*apm.MetricsCollector, error)
+}
+
+type injectorContainer struct {
+	injector *do.Injector
+}
+
+// NewContainer returns a Container backed by the providers registered on
+// injector.
+func NewContainer(injector *do.Injector) Container {
+	return injectorContainer{injector: injector}
+}
+
+func (c injectorContainer) Controller() (*controller.Controller, error) {
+	return do.InvokeNamed[*controller.Controller](c.injector, "controller")
+}
+
+func (c injectorContainer) JWTService() (jwt.Service, error) {
+	return do.InvokeNamed[jwt.Service](c.injector, "jwt-service")
+}
+
+func (c injectorContainer) CaptchaVerifier() (captcha.Verifier, error) {
+	return do.InvokeNamed[captcha.Verifier](c.injector, "captcha-verifier")
+}
+
+func (c injectorContainer) Service() (service.Service, error) {
+	return do.InvokeNamed[service.Service](c.injector, "service")
+}
+
+func (c injectorContainer) RouteAuth() (*middlewares.RouteAuth, error) {
+	return do.InvokeNamed[*middlewares.RouteAuth](c.injector, "route-auth")
+}
+
+func (c injectorContainer) Router() (*gin.Engine, error) {
+	return do.InvokeNamed[*gin.Engine](c.injector, "router")
+}
+
+func (c injectorContainer) MaintenanceMode() (*middlewares.MaintenanceMode, error) {
+	return do.InvokeNamed[*middlewares.MaintenanceMode](c.injector, "maintenance-mode")
+}
+
+func (c injectorContainer) MetricsCollector() (*apm.MetricsCollector, error) {
+	return do.InvokeNamed[*apm.MetricsCollector](c.injector, "apm")
+}
@@ -1,17 +1,27 @@
 package controller
 
 import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"runtime"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/elskow/go-microservice-template/config"
+	"github.com/elskow/go-microservice-template/middlewares"
 	"github.com/elskow/go-microservice-template/modules/account/authorization"
 	"github.com/elskow/go-microservice-template/modules/account/dto"
 	"github.com/elskow/go-microservice-template/modules/account/service"
 	"github.com/elskow/go-microservice-template/pkg/constants"
 	pkgerrors "github.com/elskow/go-microservice-template/pkg/errors"
+	"github.com/elskow/go-microservice-template/pkg/permissions"
 	"github.com/elskow/go-microservice-template/pkg/response"
 	"github.com/elskow/go-microservice-template/pkg/tracing"
 	"github.com/gin-gonic/gin"
@@ -19,21 +29,198 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
+// ErrNilAuthorizer is returned by NewController when no authorizer is supplied.
+var ErrNilAuthorizer = pkgerrors.New("controller: authorizer must not be nil")
+
 type Controller struct {
-	service       service.Service
-	logger        *slog.Logger
-	authorizer    *authorization.Authorizer
-	isDevelopment bool
+	service            service.Service
+	logger             *slog.Logger
+	authorizer         *authorization.Authorizer
+	isDevelopment      bool
+	exposeErrorDetails bool
+	cookies            authCookies
+}
+
+// authCookies describes the cookies carrying tokens in cookie auth mode.
+// refreshMaxAge bounds the refresh cookie, zero leaving it a session cookie.
+type authCookies struct {
+	enabled       bool
+	access        string
+	refresh       string
+	domain        string
+	secure        bool
+	refreshMaxAge time.Duration
 }
 
-func NewController(service service.Service, logger *slog.Logger, authorizer *authorization.Authorizer) *Controller {
+// NewController fails fast when the authorizer is missing so a misconfigured
+// provider surfaces at startup instead of as a nil-pointer panic per request.
+func NewController(service service.Service, logger *slog.Logger, authorizer *authorization.Authorizer) (*Controller, error) {
+	if authorizer == nil {
+		return nil, ErrNilAuthorizer
+	}
+
 	cfg := config.Get()
 	return &Controller{
-		service:       service,
-		logger:        logger,
-		authorizer:    authorizer,
-		isDevelopment: cfg.IsDevelopment(),
+		service:            service,
+		logger:             logger,
+		authorizer:         authorizer,
+		isDevelopment:      cfg.IsDevelopment(),
+		exposeErrorDetails: cfg.ShouldExposeErrorDetails(),
+		cookies: authCookies{
+			enabled: cfg.AuthCookieMode,
+			access:  cfg.AuthAccessCookie,
+			refresh: cfg.AuthRefreshCookie,
+			domain:  cfg.AuthCookieDomain,
+			secure:  cfg.AuthCookieSecure,
+			// The refresh token itself expires sooner; the server rejects it then
+			refreshMaxAge: cfg.RefreshSessionMaxAge(),
+		},
+	}, nil
+}
+
+const msgUnexpectedError = "An unexpected error occurred. Please try again later."
+
+type errorMapping struct {
+	target  error
+	respond func(message string) (int, response.Response[any])
+}
+
+// errorWithCode builds a responder for codes without a dedicated helper in
+// the response package.
+func errorWithCode(status int, code string) func(message string) (int, response.Response[any]) {
+	return func(message string) (int, response.Response[any]) {
+		return status, response.Error[any](code, message)
+	}
+}
+
+// errorMappings lists the service errors that translate to a client-facing
+// status. Anything not matched here is reported as a 500.
+var errorMappings = []errorMapping{
+	{target: dto.ErrEmailAlreadyExists, respond: response.Conflict[any]},
+	{target: dto.ErrInvalidCredentials, respond: response.InvalidCredentials[any]},
+	{target: dto.ErrUserNotFound, respond: response.NotFound[any]},
+	{target: dto.ErrTokenNotFound, respond: response.NotFound[any]},
+	{target: dto.ErrSessionExpired, respond: errorWithCode(http.StatusUnauthorized, response.ErrCodeSessionExpired)},
+	{target: dto.ErrAccountSuspended, respond: errorWithCode(http.StatusForbidden, response.ErrCodeAccountSuspended)},
+	{target: dto.ErrInvalidUserStatus, respond: response.ValidationFailed[any]},
+	{target: dto.ErrSessionNotFound, respond: response.NotFound[any]},
+	{target: dto.ErrInvalidListQuery, respond: response.ValidationFailed[any]},
+	{target: authorization.ErrRoleNotFound, respond: response.NotFound[any]},
+	{target: dto.ErrTwoFactorRequired, respond: errorWithCode(http.StatusUnauthorized, response.ErrCodeTwoFactorRequired)},
+	{target: dto.ErrInvalidTwoFactorCode, respond: errorWithCode(http.StatusUnauthorized, response.ErrCodeInvalidTwoFactor)},
+	{target: dto.ErrTwoFactorAlreadyEnabled, respond: response.Conflict[any]},
+	{target: dto.ErrTwoFactorNotEnrolled, respond: response.NotFound[any]},
+	{target: dto.ErrTwoFactorUnavailable, respond: errorWithCode(http.StatusServiceUnavailable, response.ErrCodeServiceUnavailable)},
+	{target: dto.ErrInvalidAccountToken, respond: errorWithCode(http.StatusBadRequest, response.ErrCodeInvalidToken)},
+}
+
+// timeoutMapping answers a request cut off by its deadline with the 504 the
+// Timeout middleware writes for handlers that never respond, rather than
+// reporting the cancelled database call as a 500.
+var timeoutMapping = errorMapping{
+	target: context.DeadlineExceeded,
+	respond: func(string) (int, response.Response[any]) {
+		return http.StatusGatewayTimeout, response.Error[any](response.ErrCodeRequestTimeout, "request timed out")
+	},
+}
+
+// mappingFor returns the mapping err translates to, false when err is
+// unexpected. Any error from a request past its deadline is a timeout,
+// since drivers do not always wrap context.DeadlineExceeded.
+func mappingFor(ginCtx *gin.Context, err error) (errorMapping, bool) {
+	if pkgerrors.Is(err, context.DeadlineExceeded) || pkgerrors.Is(ginCtx.Request.Context().Err(), context.DeadlineExceeded) {
+		return timeoutMapping, true
+	}
+	for _, m := range errorMappings {
+		if pkgerrors.Is(err, m.target) {
+			return m, true
+		}
+	}
+	return errorMapping{}, false
+}
+
+// respondError logs err, records it on the span and writes the error envelope
+// with the status and code mapped from err.
+func (c *Controller) respondError(ginCtx *gin.Context, span *tracing.Span, msg, userID, email string, err error) {
+	c.logError(ginCtx, msg, userID, email, err)
+	pkgerrors.RecordError(span.Span, err)
+
+	if m, ok := mappingFor(ginCtx, err); ok {
+		status, body := m.respond(m.target.Error())
+		response.JSON(ginCtx, status, body)
+		return
+	}
+
+	status, body := response.InternalError[any](
+		msgUnexpectedError,
+		c.errorDetail(err),
+	)
+	response.JSON(ginCtx, status, body)
+}
+
+// attachError logs err, records it on the span and attaches it to the
+// request as a *response.HTTPError mapped the same way respondError maps
+// it, leaving the envelope to the ErrorHandler middleware.
+func (c *Controller) attachError(ginCtx *gin.Context, span *tracing.Span, msg, userID, email string, err error) {
+	c.logError(ginCtx, msg, userID, email, err)
+	pkgerrors.RecordError(span.Span, err)
+
+	httpErr := &response.HTTPError{
+		StatusCode: http.StatusInternalServerError,
+		Code:       response.ErrCodeInternalServerError,
+		Message:    msgUnexpectedError,
+		Detail:     c.errorDetail(err),
+	}
+	if m, ok := mappingFor(ginCtx, err); ok {
+		status, body := m.respond(m.target.Error())
+		httpErr = &response.HTTPError{StatusCode: status, Code: body.Error.ErrorCode, Message: body.Error.ErrorMessage}
+	}
+
+	_ = ginCtx.Error(httpErr)
+}
+
+// requireAuth returns the caller's AuthContext, writing a 401 when the route
+// was reached without going through Authenticate.
+func (c *Controller) requireAuth(ginCtx *gin.Context) (middlewares.AuthContext, bool) {
+	auth, ok := middlewares.GetAuthContext(ginCtx)
+	if !ok {
+		status, body := response.Unauthorized[any]("authentication required")
+		response.AbortWithJSON(ginCtx, status, body)
+	}
+	return auth, ok
+}
+
+// requirePermission returns the caller's AuthContext when they hold perm,
+// tagging span with their user ID. Otherwise it writes a 401 for an
+// unauthenticated request, a 500 when the check itself fails or a 403.
+func (c *Controller) requirePermission(ginCtx *gin.Context, span *tracing.Span, perm permissions.Permission) (middlewares.AuthContext, bool) {
+	auth, ok := c.requireAuth(ginCtx)
+	if !ok {
+		return auth, false
+	}
+	span.SetAttributes(attribute.String(constants.AttrKeyUserID, auth.UserID))
+
+	ctx := trace.ContextWithSpan(ginCtx.Request.Context(), span.Span)
+	hasPermission, err := c.authorizer.HasPermission(ctx, auth.UserID, perm)
+	if err != nil {
+		c.logError(ginCtx, "permission check failed", auth.UserID, "", err)
+		pkgerrors.RecordError(span.Span, err)
+		status, body := response.InternalError[any](
+			"Failed to verify permissions",
+			c.errorDetail(err),
+		)
+		response.AbortWithJSON(ginCtx, status, body)
+		return auth, false
 	}
+
+	if !hasPermission {
+		c.logError(ginCtx, "permission denied", auth.UserID, "", pkgerrors.New("permission denied"))
+		status, body := response.Forbidden[any]("You do not have permission to perform this action.")
+		response.AbortWithJSON(ginCtx, status, body)
+		return auth, false
+	}
+
+	return auth, true
 }
 
 func buildErrorMessage(prefix, errMsg string) string {
@@ -45,6 +232,25 @@ func buildErrorMessage(prefix, errMsg string) string {
 	return builder.String()
 }
 
+// invalidBody maps a failed JSON bind to its response: 413 when the body
+// was cut off by http.MaxBytesReader, 400 with the bind error otherwise.
+func invalidBody[T any](err error) (int, response.Response[T]) {
+	var tooLarge *http.MaxBytesError
+	if pkgerrors.As(err, &tooLarge) {
+		return response.PayloadTooLarge[T](fmt.Sprintf("Request body exceeds the %d byte limit", tooLarge.Limit))
+	}
+	return response.ValidationFailed[T](buildErrorMessage("Invalid request body", err.Error()))
+}
+
+// errorDetail returns the underlying error text for 500 responses when
+// detail exposure is enabled, and an empty string otherwise.
+func (c *Controller) errorDetail(err error) string {
+	if !c.exposeErrorDetails || err == nil {
+		return ""
+	}
+	return fmt.Sprintf("%+v", err)
+}
+
 func (c *Controller) logError(ginCtx *gin.Context, msg, userID, email string, err error) {
 	spanCtx := trace.SpanContextFromContext(ginCtx.Request.Context())
 
@@ -82,6 +288,15 @@ func (c *Controller) logError(ginCtx *gin.Context, msg, userID, email string, er
 	c.logger.Error(msg, attrs...)
 }
 
+// clientInfo describes the device making the request, stored with the
+// session it starts.
+func clientInfo(ginCtx *gin.Context) dto.ClientInfo {
+	return dto.ClientInfo{
+		UserAgent: ginCtx.Request.UserAgent(),
+		IPAddress: ginCtx.ClientIP(),
+	}
+}
+
 func (c *Controller) Register(ginCtx *gin.Context) {
 	ctx, span := tracing.Auto(ginCtx.Request.Context())
 	defer span.End()
@@ -90,35 +305,22 @@ func (c *Controller) Register(ginCtx *gin.Context) {
 	if err := ginCtx.ShouldBindJSON(&req); err != nil {
 		c.logError(ginCtx, "invalid request body", "", req.Email, err)
 		pkgerrors.RecordError(span.Span, err)
-		ginCtx.JSON(http.StatusBadRequest, response.Error[dto.RegisterResponse](
-			response.ErrCodeValidationFailed,
-			buildErrorMessage("Invalid request body", err.Error()),
-		))
+		status, body := invalidBody[dto.RegisterResponse](err)
+		response.JSON(ginCtx, status, body)
 		return
 	}
 
 	span.SetAttributes(attribute.String(constants.AttrKeyEmail, req.Email))
+	req.Client = clientInfo(ginCtx)
 
 	result, err := c.service.Register(ctx, req)
 	if err != nil {
-		c.logError(ginCtx, "registration failed", "", req.Email, err)
-		pkgerrors.RecordError(span.Span, err)
-		switch {
-		case pkgerrors.Is(err, dto.ErrEmailAlreadyExists):
-			ginCtx.JSON(http.StatusConflict, response.Error[dto.RegisterResponse](
-				response.ErrCodeConflict,
-				err.Error(),
-			))
-		default:
-			ginCtx.JSON(http.StatusInternalServerError, response.Error[dto.RegisterResponse](
-				response.ErrCodeInternalServerError,
-				"An unexpected error occurred. Please try again later.",
-			))
-		}
+		c.respondError(ginCtx, span, "registration failed", "", req.Email, err)
 		return
 	}
 
-	ginCtx.JSON(http.StatusCreated, response.Success(result))
+	c.setAuthCookies(ginCtx, result.Token)
+	response.JSON(ginCtx, http.StatusCreated, response.Success(result))
 }
 
 func (c *Controller) Login(ginCtx *gin.Context) {
@@ -129,229 +331,956 @@ func (c *Controller) Login(ginCtx *gin.Context) {
 	if err := ginCtx.ShouldBindJSON(&req); err != nil {
 		c.logError(ginCtx, "invalid request body", "", req.Email, err)
 		pkgerrors.RecordError(span.Span, err)
-		ginCtx.JSON(http.StatusBadRequest, response.Error[dto.LoginResponse](
-			response.ErrCodeValidationFailed,
-			buildErrorMessage("Invalid request body", err.Error()),
-		))
+		status, body := invalidBody[dto.LoginResponse](err)
+		response.JSON(ginCtx, status, body)
 		return
 	}
 
 	span.SetAttributes(attribute.String(constants.AttrKeyEmail, req.Email))
+	req.Client = clientInfo(ginCtx)
 
 	result, err := c.service.Login(ctx, req)
 	if err != nil {
-		c.logError(ginCtx, "login failed", "", req.Email, err)
-		pkgerrors.RecordError(span.Span, err)
-		switch {
-		case pkgerrors.Is(err, dto.ErrInvalidCredentials):
-			ginCtx.JSON(http.StatusUnauthorized, response.Error[dto.LoginResponse](
-				response.ErrCodeInvalidCredentials,
-				err.Error(),
-			))
-		default:
-			ginCtx.JSON(http.StatusInternalServerError, response.Error[dto.LoginResponse](
-				response.ErrCodeInternalServerError,
-				"An unexpected error occurred. Please try again later.",
-			))
-		}
+		c.respondError(ginCtx, span, "login failed", "", req.Email, err)
 		return
 	}
 
-	ginCtx.JSON(http.StatusOK, response.Success(result))
+	c.setAuthCookies(ginCtx, result.Token)
+	response.JSON(ginCtx, http.StatusOK, response.Success(result))
 }
 
 func (c *Controller) RefreshToken(ginCtx *gin.Context) {
 	ctx, span := tracing.Auto(ginCtx.Request.Context())
 	defer span.End()
 
+	// In cookie auth mode the refresh cookie stands in for the body
 	var req dto.RefreshTokenRequest
-	if err := ginCtx.ShouldBindJSON(&req); err != nil {
+	if token, ok := c.refreshCookie(ginCtx); ok {
+		req.RefreshToken = token
+	} else if err := ginCtx.ShouldBindJSON(&req); err != nil {
 		c.logError(ginCtx, "invalid request body", "", "", err)
 		pkgerrors.RecordError(span.Span, err)
-		ginCtx.JSON(http.StatusBadRequest, response.Error[dto.RefreshTokenResponse](
-			response.ErrCodeValidationFailed,
-			buildErrorMessage("Invalid request body", err.Error()),
-		))
+		status, body := invalidBody[dto.RefreshTokenResponse](err)
+		response.JSON(ginCtx, status, body)
 		return
 	}
 
 	result, err := c.service.RefreshToken(ctx, req)
 	if err != nil {
-		c.logError(ginCtx, "token refresh failed", "", "", err)
+		c.respondError(ginCtx, span, "token refresh failed", "", "", err)
+		return
+	}
+
+	c.setAuthCookies(ginCtx, result.Token)
+	response.JSON(ginCtx, http.StatusOK, response.Success(result))
+}
+
+func (c *Controller) VerifyEmail(ginCtx *gin.Context) {
+	ctx, span := tracing.Auto(ginCtx.Request.Context())
+	defer span.End()
+
+	var req dto.VerifyEmailRequest
+	if err := ginCtx.ShouldBindQuery(&req); err != nil {
+		c.logError(ginCtx, "invalid request query", "", "", err)
 		pkgerrors.RecordError(span.Span, err)
-		switch {
-		case pkgerrors.Is(err, dto.ErrTokenNotFound):
-			ginCtx.JSON(http.StatusNotFound, response.Error[dto.RefreshTokenResponse](
-				response.ErrCodeNotFound,
-				err.Error(),
-			))
-		default:
-			ginCtx.JSON(http.StatusInternalServerError, response.Error[dto.RefreshTokenResponse](
-				response.ErrCodeInternalServerError,
-				"An unexpected error occurred. Please try again later.",
-			))
-		}
+		status, body := response.ValidationFailed[any](buildErrorMessage("Invalid request query", err.Error()))
+		response.JSON(ginCtx, status, body)
+		return
+	}
+
+	if err := c.service.VerifyEmail(ctx, req); err != nil {
+		c.respondError(ginCtx, span, "email verification failed", "", "", err)
 		return
 	}
 
-	ginCtx.JSON(http.StatusOK, response.Success(result))
+	response.JSON(ginCtx, http.StatusOK, response.Success(map[string]string{"message": "email verified"}))
+}
+
+// RequestPasswordReset answers the same way whether or not the email is
+// registered.
+func (c *Controller) RequestPasswordReset(ginCtx *gin.Context) {
+	ctx, span := tracing.Auto(ginCtx.Request.Context())
+	defer span.End()
+
+	var req dto.PasswordResetRequest
+	if err := ginCtx.ShouldBindJSON(&req); err != nil {
+		c.logError(ginCtx, "invalid request body", "", req.Email, err)
+		pkgerrors.RecordError(span.Span, err)
+		status, body := invalidBody[any](err)
+		response.JSON(ginCtx, status, body)
+		return
+	}
+
+	span.SetAttributes(attribute.String(constants.AttrKeyEmail, req.Email))
+
+	if err := c.service.RequestPasswordReset(ctx, req); err != nil {
+		c.respondError(ginCtx, span, "password reset request failed", "", req.Email, err)
+		return
+	}
+
+	response.JSON(ginCtx, http.StatusAccepted, response.Success(map[string]string{
+		"message": "if the email is registered, a reset link has been sent",
+	}))
+}
+
+func (c *Controller) ResetPassword(ginCtx *gin.Context) {
+	ctx, span := tracing.Auto(ginCtx.Request.Context())
+	defer span.End()
+
+	var req dto.ResetPasswordRequest
+	if err := ginCtx.ShouldBindJSON(&req); err != nil {
+		c.logError(ginCtx, "invalid request body", "", "", err)
+		pkgerrors.RecordError(span.Span, err)
+		status, body := invalidBody[any](err)
+		response.JSON(ginCtx, status, body)
+		return
+	}
+
+	if err := c.service.ResetPassword(ctx, req); err != nil {
+		c.respondError(ginCtx, span, "password reset failed", "", "", err)
+		return
+	}
+
+	response.JSON(ginCtx, http.StatusOK, response.Success(map[string]string{"message": "password reset"}))
 }
 
 func (c *Controller) Logout(ginCtx *gin.Context) {
 	ctx, span := tracing.Auto(ginCtx.Request.Context())
 	defer span.End()
 
-	userID := ginCtx.MustGet(constants.CtxKeyUserID).(string)
+	auth, ok := c.requireAuth(ginCtx)
+	if !ok {
+		return
+	}
+	userID := auth.UserID
 	span.SetAttributes(attribute.String(constants.AttrKeyUserID, userID))
 
+	// The client is signed out even if revoking the server-side tokens fails
+	c.clearAuthCookies(ginCtx)
+
 	err := c.service.Logout(ctx, userID)
 	if err != nil {
-		c.logError(ginCtx, "logout failed", userID, "", err)
-		pkgerrors.RecordError(span.Span, err)
-		ginCtx.JSON(http.StatusInternalServerError, response.Error[any](
-			response.ErrCodeInternalServerError,
-			"An unexpected error occurred. Please try again later.",
-		))
+		c.respondError(ginCtx, span, "logout failed", userID, "", err)
 		return
 	}
 
-	ginCtx.JSON(http.StatusOK, response.Success(map[string]string{"message": "logout successful"}))
+	response.JSON(ginCtx, http.StatusOK, response.Success(map[string]string{"message": "logout successful"}))
+}
+
+// setAuthCookies stores token in the access and refresh cookies in cookie
+// auth mode. The cookies are HttpOnly and SameSite=Strict, so scripts cannot
+// read them and cross-site requests do not carry them.
+func (c *Controller) setAuthCookies(ginCtx *gin.Context, token dto.TokenResponse) {
+	if !c.cookies.enabled {
+		return
+	}
+	c.setCookie(ginCtx, c.cookies.access, token.AccessToken, int(token.ExpiresIn))
+	c.setCookie(ginCtx, c.cookies.refresh, token.RefreshToken, int(c.cookies.refreshMaxAge.Seconds()))
+}
+
+// clearAuthCookies expires the access and refresh cookies in cookie auth
+// mode so the browser stops sending tokens that were just revoked.
+func (c *Controller) clearAuthCookies(ginCtx *gin.Context) {
+	if !c.cookies.enabled {
+		return
+	}
+	for _, name := range []string{c.cookies.access, c.cookies.refresh} {
+		c.setCookie(ginCtx, name, "", -1)
+	}
+}
+
+func (c *Controller) setCookie(ginCtx *gin.Context, name, value string, maxAge int) {
+	ginCtx.SetSameSite(http.SameSiteStrictMode)
+	ginCtx.SetCookie(name, value, maxAge, "/", c.cookies.domain, c.cookies.secure, true)
+}
+
+// refreshCookie returns the refresh token from its cookie in cookie auth
+// mode.
+func (c *Controller) refreshCookie(ginCtx *gin.Context) (string, bool) {
+	if !c.cookies.enabled {
+		return "", false
+	}
+	token, err := ginCtx.Cookie(c.cookies.refresh)
+	if err != nil || token == "" {
+		return "", false
+	}
+	return token, true
 }
 
 func (c *Controller) Me(ginCtx *gin.Context) {
 	ctx, span := tracing.Auto(ginCtx.Request.Context())
 	defer span.End()
 
-	userID := ginCtx.MustGet(constants.CtxKeyUserID).(string)
+	auth, ok := c.requireAuth(ginCtx)
+	if !ok {
+		return
+	}
+	userID := auth.UserID
 	span.SetAttributes(attribute.String(constants.AttrKeyUserID, userID))
 
 	result, err := c.service.GetUserByID(ctx, userID)
 	if err != nil {
-		c.logError(ginCtx, "get user failed", userID, "", err)
+		c.attachError(ginCtx, span, "get user failed", userID, "", err)
+		return
+	}
+
+	response.JSON(ginCtx, http.StatusOK, response.Success(result))
+}
+
+// MyPermissions returns the names of the current user's effective
+// permissions, letting clients decide what to show without the profile.
+func (c *Controller) MyPermissions(ginCtx *gin.Context) {
+	ctx, span := tracing.Auto(ginCtx.Request.Context())
+	defer span.End()
+
+	auth, ok := c.requireAuth(ginCtx)
+	if !ok {
+		return
+	}
+	userID := auth.UserID
+	span.SetAttributes(attribute.String(constants.AttrKeyUserID, userID))
+
+	names, err := c.authorizer.ListPermissions(ctx, userID)
+	if err != nil {
+		c.logError(ginCtx, "list permissions failed", userID, "", err)
 		pkgerrors.RecordError(span.Span, err)
-		switch {
-		case pkgerrors.Is(err, dto.ErrUserNotFound):
-			ginCtx.JSON(http.StatusNotFound, response.Error[dto.UserResponse](
-				response.ErrCodeNotFound,
-				err.Error(),
-			))
-		default:
-			ginCtx.JSON(http.StatusInternalServerError, response.Error[dto.UserResponse](
-				response.ErrCodeInternalServerError,
-				"An unexpected error occurred. Please try again later.",
-			))
-		}
+		status, body := response.InternalError[dto.PermissionsResponse](
+			"Failed to load permissions",
+			c.errorDetail(err),
+		)
+		response.JSON(ginCtx, status, body)
 		return
 	}
 
-	ginCtx.JSON(http.StatusOK, response.Success(result))
+	response.JSON(ginCtx, http.StatusOK, response.Success(dto.PermissionsResponse{Permissions: names}))
 }
 
-func (c *Controller) UpdateUser(ginCtx *gin.Context) {
+// CompareUsers diffs the effective permissions of users a and b, answering
+// "why can A do this but not B" without reading the role tables by hand.
+func (c *Controller) CompareUsers(ginCtx *gin.Context) {
+	ctx, span := tracing.Auto(ginCtx.Request.Context())
+	defer span.End()
+
+	auth, ok := c.requirePermission(ginCtx, span, permissions.PermissionManage)
+	if !ok {
+		return
+	}
+	userID := auth.UserID
+
+	var req dto.CompareUsersRequest
+	if err := ginCtx.ShouldBindQuery(&req); err != nil {
+		c.logError(ginCtx, "invalid request query", userID, "", err)
+		pkgerrors.RecordError(span.Span, err)
+		status, body := response.ValidationFailed[dto.PermissionDiffResponse](buildErrorMessage("Invalid request query", err.Error()))
+		response.JSON(ginCtx, status, body)
+		return
+	}
+	span.SetAttributes(
+		attribute.String("compare.user_a", req.A),
+		attribute.String("compare.user_b", req.B),
+	)
+
+	permsA, err := c.authorizer.ListPermissions(ctx, req.A)
+	var permsB []string
+	if err == nil {
+		permsB, err = c.authorizer.ListPermissions(ctx, req.B)
+	}
+	if err != nil {
+		c.logError(ginCtx, "list permissions failed", userID, "", err)
+		pkgerrors.RecordError(span.Span, err)
+		status, body := response.InternalError[dto.PermissionDiffResponse](
+			"Failed to load permissions",
+			c.errorDetail(err),
+		)
+		response.JSON(ginCtx, status, body)
+		return
+	}
+
+	response.JSON(ginCtx, http.StatusOK, response.Success(diffPermissions(permsA, permsB)))
+}
+
+// SimulateRole reports the permissions a user would gain from a role
+// without assigning it, so RBAC changes can be checked before they are made.
+func (c *Controller) SimulateRole(ginCtx *gin.Context) {
 	ctx, span := tracing.Auto(ginCtx.Request.Context())
 	defer span.End()
 
-	userID := ginCtx.MustGet(constants.CtxKeyUserID).(string)
+	auth, ok := c.requirePermission(ginCtx, span, permissions.PermissionManage)
+	if !ok {
+		return
+	}
+	userID := auth.UserID
+
+	var req dto.SimulateRoleRequest
+	if err := ginCtx.ShouldBindJSON(&req); err != nil {
+		c.logError(ginCtx, "invalid request body", userID, "", err)
+		pkgerrors.RecordError(span.Span, err)
+		status, body := invalidBody[dto.SimulateRoleResponse](err)
+		response.JSON(ginCtx, status, body)
+		return
+	}
+	span.SetAttributes(
+		attribute.String("simulate.user_id", req.UserID),
+		attribute.String("simulate.role", req.Role),
+	)
+
+	sim, err := c.authorizer.SimulateRoleGrant(ctx, req.UserID, req.Role)
+	if err != nil {
+		c.respondError(ginCtx, span, "simulate role failed", userID, "", err)
+		return
+	}
+
+	response.JSON(ginCtx, http.StatusOK, response.Success(dto.SimulateRoleResponse{
+		Current:   sim.Current,
+		Resulting: sim.Resulting,
+		Gained:    sim.Gained,
+	}))
+}
+
+// diffPermissions splits two name-ordered permission lists; each part stays
+// in name order.
+func diffPermissions(a, b []string) dto.PermissionDiffResponse {
+	inB := make(map[string]struct{}, len(b))
+	for _, name := range b {
+		inB[name] = struct{}{}
+	}
+
+	diff := dto.PermissionDiffResponse{OnlyA: []string{}, OnlyB: []string{}, Shared: []string{}}
+	inA := make(map[string]struct{}, len(a))
+	for _, name := range a {
+		inA[name] = struct{}{}
+		if _, ok := inB[name]; ok {
+			diff.Shared = append(diff.Shared, name)
+		} else {
+			diff.OnlyA = append(diff.OnlyA, name)
+		}
+	}
+	for _, name := range b {
+		if _, ok := inA[name]; !ok {
+			diff.OnlyB = append(diff.OnlyB, name)
+		}
+	}
+	return diff
+}
+
+// CheckMyPermissions reports, for each requested permission, whether the
+// current user holds it, replacing one request per check.
+func (c *Controller) CheckMyPermissions(ginCtx *gin.Context) {
+	ctx, span := tracing.Auto(ginCtx.Request.Context())
+	defer span.End()
+
+	auth, ok := c.requireAuth(ginCtx)
+	if !ok {
+		return
+	}
+	userID := auth.UserID
 	span.SetAttributes(attribute.String(constants.AttrKeyUserID, userID))
 
-	var req dto.UpdateUserRequest
+	var req dto.PermissionCheckRequest
 	if err := ginCtx.ShouldBindJSON(&req); err != nil {
 		c.logError(ginCtx, "invalid request body", userID, "", err)
 		pkgerrors.RecordError(span.Span, err)
-		ginCtx.JSON(http.StatusBadRequest, response.Error[dto.UserResponse](
-			response.ErrCodeValidationFailed,
-			buildErrorMessage("Invalid request body", err.Error()),
-		))
+		status, body := invalidBody[dto.PermissionCheckResponse](err)
+		response.JSON(ginCtx, status, body)
 		return
 	}
 
-	hasPermission, err := c.authorizer.HasPermission(ctx, userID, "user.update")
+	required := make([]permissions.Permission, len(req.Permissions))
+	for i, name := range req.Permissions {
+		required[i] = permissions.Permission(name)
+	}
+	span.SetAttributes(attribute.Int("permissions.checked", len(required)))
+
+	held, err := c.authorizer.HasPermissions(ctx, userID, required)
 	if err != nil {
 		c.logError(ginCtx, "permission check failed", userID, "", err)
 		pkgerrors.RecordError(span.Span, err)
-		ginCtx.JSON(http.StatusInternalServerError, response.Error[dto.UserResponse](
-			response.ErrCodeInternalServerError,
+		status, body := response.InternalError[dto.PermissionCheckResponse](
 			"Failed to verify permissions",
-		))
+			c.errorDetail(err),
+		)
+		response.JSON(ginCtx, status, body)
 		return
 	}
 
-	if !hasPermission {
-		c.logError(ginCtx, "permission denied", userID, "", pkgerrors.New("permission denied"))
-		ginCtx.JSON(http.StatusForbidden, response.Error[dto.UserResponse](
-			response.ErrCodeForbidden,
-			"You do not have permission to perform this action.",
-		))
+	result := make(map[string]bool, len(held))
+	for permission, ok := range held {
+		result[permission.String()] = ok
+	}
+
+	response.JSON(ginCtx, http.StatusOK, response.Success(dto.PermissionCheckResponse{Permissions: result}))
+}
+
+// MyRoles returns the names of the roles assigned to the current user.
+func (c *Controller) MyRoles(ginCtx *gin.Context) {
+	ctx, span := tracing.Auto(ginCtx.Request.Context())
+	defer span.End()
+
+	auth, ok := c.requireAuth(ginCtx)
+	if !ok {
+		return
+	}
+	userID := auth.UserID
+	span.SetAttributes(attribute.String(constants.AttrKeyUserID, userID))
+
+	roles, err := c.authorizer.GetUserRoles(ctx, userID)
+	if err != nil {
+		c.logError(ginCtx, "list roles failed", userID, "", err)
+		pkgerrors.RecordError(span.Span, err)
+		status, body := response.InternalError[dto.RolesResponse](
+			"Failed to load roles",
+			c.errorDetail(err),
+		)
+		response.JSON(ginCtx, status, body)
+		return
+	}
+	if roles == nil {
+		roles = []string{}
+	}
+
+	response.JSON(ginCtx, http.StatusOK, response.Success(dto.RolesResponse{Roles: roles}))
+}
+
+func (c *Controller) UpdateUser(ginCtx *gin.Context) {
+	ctx, span := tracing.Auto(ginCtx.Request.Context())
+	defer span.End()
+
+	auth, ok := c.requirePermission(ginCtx, span, permissions.UserUpdate)
+	if !ok {
+		return
+	}
+	userID := auth.UserID
+
+	var req dto.UpdateUserRequest
+	if err := ginCtx.ShouldBindJSON(&req); err != nil {
+		c.logError(ginCtx, "invalid request body", userID, "", err)
+		pkgerrors.RecordError(span.Span, err)
+		status, body := invalidBody[dto.UserResponse](err)
+		response.JSON(ginCtx, status, body)
 		return
 	}
 
 	result, err := c.service.UpdateUser(ctx, userID, req)
 	if err != nil {
-		c.logError(ginCtx, "update user failed", userID, "", err)
+		c.respondError(ginCtx, span, "update user failed", userID, "", err)
+		return
+	}
+
+	response.JSON(ginCtx, http.StatusOK, response.Success(result))
+}
+
+func (c *Controller) DeleteUser(ginCtx *gin.Context) {
+	ctx, span := tracing.Auto(ginCtx.Request.Context())
+	defer span.End()
+
+	auth, ok := c.requirePermission(ginCtx, span, permissions.UserDelete)
+	if !ok {
+		return
+	}
+	userID := auth.UserID
+
+	err := c.service.DeleteUser(ctx, userID)
+	if err != nil {
+		c.respondError(ginCtx, span, "delete user failed", userID, "", err)
+		return
+	}
+
+	response.JSON(ginCtx, http.StatusOK, response.Success(map[string]string{"message": "user deleted successfully"}))
+}
+
+// ListUsers returns one page of users. Query parameters choose the page
+// (limit, offset), the order (sort=name,-created_at) and filters (name,
+// email, status); see dto.ListUsersRequest.
+func (c *Controller) ListUsers(ginCtx *gin.Context) {
+	ctx, span := tracing.Auto(ginCtx.Request.Context())
+	defer span.End()
+
+	auth, ok := c.requirePermission(ginCtx, span, permissions.UserList)
+	if !ok {
+		return
+	}
+	userID := auth.UserID
+
+	var req dto.ListUsersRequest
+	if err := ginCtx.ShouldBindQuery(&req); err != nil {
+		c.logError(ginCtx, "invalid request query", userID, "", err)
 		pkgerrors.RecordError(span.Span, err)
-		switch {
-		case pkgerrors.Is(err, dto.ErrUserNotFound):
-			ginCtx.JSON(http.StatusNotFound, response.Error[dto.UserResponse](
-				response.ErrCodeNotFound,
-				err.Error(),
-			))
-		default:
-			ginCtx.JSON(http.StatusInternalServerError, response.Error[dto.UserResponse](
-				response.ErrCodeInternalServerError,
-				"An unexpected error occurred. Please try again later.",
-			))
+		status, body := response.ValidationFailed[dto.ListUsersResponse](buildErrorMessage("Invalid request query", err.Error()))
+		response.JSON(ginCtx, status, body)
+		return
+	}
+
+	result, err := c.service.ListUsers(ctx, req)
+	if err != nil {
+		c.respondError(ginCtx, span, "list users failed", userID, "", err)
+		return
+	}
+
+	response.JSON(ginCtx, http.StatusOK, response.Success(result))
+}
+
+var userExportHeader = []string{"id", "name", "email", "created_at"}
+
+// ExportUsers streams every user as CSV, or as a JSON array with
+// ?format=json. Rows are written as they are read from the database, so a
+// failure after the first flush can only truncate the download rather than
+// change the status code.
+func (c *Controller) ExportUsers(ginCtx *gin.Context) {
+	ctx, span := tracing.Auto(ginCtx.Request.Context())
+	defer span.End()
+
+	auth, ok := c.requirePermission(ginCtx, span, permissions.UserList)
+	if !ok {
+		return
+	}
+	userID := auth.UserID
+
+	var export func(ctx context.Context, w io.Writer) error
+	switch format := ginCtx.DefaultQuery("format", "csv"); format {
+	case "csv":
+		ginCtx.Header("Content-Type", "text/csv; charset=utf-8")
+		ginCtx.Header("Content-Disposition", `attachment; filename="users.csv"`)
+		export = c.exportUsersCSV
+	case "json":
+		ginCtx.Header("Content-Type", "application/json; charset=utf-8")
+		ginCtx.Header("Content-Disposition", `attachment; filename="users.json"`)
+		export = c.exportUsersJSON
+	default:
+		status, body := response.ValidationFailed[any](fmt.Sprintf("unsupported export format %q", format))
+		response.JSON(ginCtx, status, body)
+		return
+	}
+	ginCtx.Status(http.StatusOK)
+
+	err := export(ctx, ginCtx.Writer)
+
+	if err != nil {
+		if !ginCtx.Writer.Written() {
+			ginCtx.Writer.Header().Del("Content-Disposition")
+			ginCtx.Writer.Header().Del("Content-Type")
+			c.respondError(ginCtx, span, "export users failed", userID, "", err)
+			return
+		}
+		c.logError(ginCtx, "export users aborted", userID, "", err)
+		pkgerrors.RecordError(span.Span, err)
+		ginCtx.Abort()
+	}
+}
+
+func (c *Controller) exportUsersCSV(ctx context.Context, w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(userExportHeader); err != nil {
+		return err
+	}
+
+	err := c.service.ExportUsers(ctx, func(user dto.UserExport) error {
+		return writer.Write([]string{
+			user.ID,
+			csvCell(user.Name),
+			csvCell(user.Email),
+			user.CreatedAt.UTC().Format(time.RFC3339),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvCell neutralizes user-supplied text that a spreadsheet would evaluate
+// as a formula by prefixing it with a quote.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// exportUsersJSON encodes users one at a time into a JSON array instead of
+// building the whole slice, so memory stays flat however many users exist.
+// Output is buffered like the CSV writer, so nothing reaches the client
+// until the first buffer fills.
+func (c *Controller) exportUsersJSON(ctx context.Context, w io.Writer) error {
+	buf := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buf)
+
+	if err := buf.WriteByte('['); err != nil {
+		return err
+	}
+
+	first := true
+	err := c.service.ExportUsers(ctx, func(user dto.UserExport) error {
+		if !first {
+			if err := buf.WriteByte(','); err != nil {
+				return err
+			}
 		}
+		first = false
+		return encoder.Encode(user)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := buf.WriteByte(']'); err != nil {
+		return err
+	}
+	return buf.Flush()
+}
+
+// RevokeSessions force-logs-out the user named in the path.
+func (c *Controller) RevokeSessions(ginCtx *gin.Context) {
+	ctx, span := tracing.Auto(ginCtx.Request.Context())
+	defer span.End()
+
+	targetID := ginCtx.Param("id")
+	span.SetAttributes(attribute.String("target_user_id", targetID))
+
+	auth, ok := c.requirePermission(ginCtx, span, permissions.UserManage)
+	if !ok {
+		return
+	}
+	userID := auth.UserID
+
+	err := c.service.RevokeSessions(ctx, targetID)
+	if err != nil {
+		c.respondError(ginCtx, span, "revoke sessions failed", userID, "", err)
 		return
 	}
 
-	ginCtx.JSON(http.StatusOK, response.Success(result))
+	c.logger.Info("user sessions revoked", constants.AttrKeyUserID, userID, "target_user_id", targetID)
+	response.JSON(ginCtx, http.StatusOK, response.Success(map[string]string{"message": "sessions revoked"}))
 }
 
-func (c *Controller) DeleteUser(ginCtx *gin.Context) {
+// ListSessions lists the caller's active sessions, flagging the one the
+// request was made with.
+func (c *Controller) ListSessions(ginCtx *gin.Context) {
 	ctx, span := tracing.Auto(ginCtx.Request.Context())
 	defer span.End()
 
-	userID := ginCtx.MustGet(constants.CtxKeyUserID).(string)
+	auth, ok := c.requireAuth(ginCtx)
+	if !ok {
+		return
+	}
+	userID := auth.UserID
 	span.SetAttributes(attribute.String(constants.AttrKeyUserID, userID))
 
-	hasPermission, err := c.authorizer.HasPermission(ctx, userID, "user.delete")
+	sessions, err := c.service.ListSessions(ctx, userID, auth.SessionID)
 	if err != nil {
-		c.logError(ginCtx, "permission check failed", userID, "", err)
-		pkgerrors.RecordError(span.Span, err)
-		ginCtx.JSON(http.StatusInternalServerError, response.Error[any](
-			response.ErrCodeInternalServerError,
-			"Failed to verify permissions",
-		))
+		c.respondError(ginCtx, span, "list sessions failed", userID, "", err)
 		return
 	}
 
-	if !hasPermission {
-		c.logError(ginCtx, "permission denied", userID, "", pkgerrors.New("permission denied"))
-		ginCtx.JSON(http.StatusForbidden, response.Error[any](
-			response.ErrCodeForbidden,
-			"You do not have permission to perform this action.",
-		))
+	response.JSON(ginCtx, http.StatusOK, response.Success(sessions))
+}
+
+// RevokeSession ends one of the caller's sessions. Revoking the current
+// session logs the caller out.
+func (c *Controller) RevokeSession(ginCtx *gin.Context) {
+	ctx, span := tracing.Auto(ginCtx.Request.Context())
+	defer span.End()
+
+	auth, ok := c.requireAuth(ginCtx)
+	if !ok {
 		return
 	}
+	userID := auth.UserID
+	sessionID := ginCtx.Param("id")
+	span.SetAttributes(
+		attribute.String(constants.AttrKeyUserID, userID),
+		attribute.String("session.id", sessionID),
+	)
 
-	err = c.service.DeleteUser(ctx, userID)
+	err := c.service.RevokeSession(ctx, userID, sessionID)
 	if err != nil {
-		c.logError(ginCtx, "delete user failed", userID, "", err)
+		c.respondError(ginCtx, span, "revoke session failed", userID, "", err)
+		return
+	}
+
+	response.JSON(ginCtx, http.StatusOK, response.Success(map[string]string{"message": "session revoked"}))
+}
+
+// eventsHeartbeatInterval is how often an idle event stream sends a comment
+// line, keeping proxies from closing the connection.
+const eventsHeartbeatInterval = 15 * time.Second
+
+// Events streams the caller's security events, such as session.revoked, as
+// Server-Sent Events until the client disconnects. Each message's event
+// field is the event type and its data the JSON event.
+func (c *Controller) Events(ginCtx *gin.Context) {
+	auth, ok := c.requireAuth(ginCtx)
+	if !ok {
+		return
+	}
+
+	events, unsubscribe := c.service.SubscribeEvents(auth.UserID)
+	defer unsubscribe()
+
+	ginCtx.Header("Content-Type", "text/event-stream")
+	ginCtx.Header("Cache-Control", "no-cache")
+	ginCtx.Header("Connection", "keep-alive")
+	// Stops nginx from buffering the stream
+	ginCtx.Header("X-Accel-Buffering", "no")
+	ginCtx.Status(http.StatusOK)
+	ginCtx.Writer.Flush()
+
+	heartbeat := time.NewTicker(eventsHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	done := ginCtx.Request.Context().Done()
+	ginCtx.Stream(func(w io.Writer) bool {
+		select {
+		case <-done:
+			return false
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			ginCtx.SSEvent(event.Type, event)
+			return true
+		case <-heartbeat.C:
+			_, err := io.WriteString(w, ": keep-alive\n\n")
+			return err == nil
+		}
+	})
+}
+
+// EnrollTwoFactor starts TOTP enrollment for the caller.
+func (c *Controller) EnrollTwoFactor(ginCtx *gin.Context) {
+	ctx, span := tracing.Auto(ginCtx.Request.Context())
+	defer span.End()
+
+	auth, ok := c.requireAuth(ginCtx)
+	if !ok {
+		return
+	}
+	userID := auth.UserID
+	span.SetAttributes(attribute.String(constants.AttrKeyUserID, userID))
+
+	result, err := c.service.EnrollTwoFactor(ctx, userID)
+	if err != nil {
+		c.respondError(ginCtx, span, "two-factor enrollment failed", userID, "", err)
+		return
+	}
+
+	response.JSON(ginCtx, http.StatusOK, response.Success(result))
+}
+
+// VerifyTwoFactor confirms the caller's enrollment with a TOTP code.
+func (c *Controller) VerifyTwoFactor(ginCtx *gin.Context) {
+	ctx, span := tracing.Auto(ginCtx.Request.Context())
+	defer span.End()
+
+	auth, ok := c.requireAuth(ginCtx)
+	if !ok {
+		return
+	}
+	userID := auth.UserID
+	span.SetAttributes(attribute.String(constants.AttrKeyUserID, userID))
+
+	var req dto.TwoFactorVerifyRequest
+	if err := ginCtx.ShouldBindJSON(&req); err != nil {
+		c.logError(ginCtx, "invalid request body", userID, "", err)
 		pkgerrors.RecordError(span.Span, err)
-		switch {
-		case pkgerrors.Is(err, dto.ErrUserNotFound):
-			ginCtx.JSON(http.StatusNotFound, response.Error[any](
-				response.ErrCodeNotFound,
-				err.Error(),
-			))
-		default:
-			ginCtx.JSON(http.StatusInternalServerError, response.Error[any](
-				response.ErrCodeInternalServerError,
-				"An unexpected error occurred. Please try again later.",
-			))
+		status, body := invalidBody[any](err)
+		response.JSON(ginCtx, status, body)
+		return
+	}
+
+	if err := c.service.VerifyTwoFactor(ctx, userID, req); err != nil {
+		c.respondError(ginCtx, span, "two-factor verification failed", userID, "", err)
+		return
+	}
+
+	response.JSON(ginCtx, http.StatusOK, response.Success(map[string]string{"message": "two-factor authentication enabled"}))
+}
+
+// RegenerateBackupCodes replaces the caller's two-factor backup codes.
+func (c *Controller) RegenerateBackupCodes(ginCtx *gin.Context) {
+	ctx, span := tracing.Auto(ginCtx.Request.Context())
+	defer span.End()
+
+	auth, ok := c.requireAuth(ginCtx)
+	if !ok {
+		return
+	}
+	userID := auth.UserID
+	span.SetAttributes(attribute.String(constants.AttrKeyUserID, userID))
+
+	result, err := c.service.RegenerateBackupCodes(ctx, userID)
+	if err != nil {
+		c.respondError(ginCtx, span, "backup code regeneration failed", userID, "", err)
+		return
+	}
+
+	response.JSON(ginCtx, http.StatusOK, response.Success(result))
+}
+
+// CacheStats reports the permission cache size, hit/miss counts and TTL for
+// diagnosing authorization behaviour.
+func (c *Controller) CacheStats(ginCtx *gin.Context) {
+	_, span := tracing.Auto(ginCtx.Request.Context())
+	defer span.End()
+
+	if _, ok := c.requirePermission(ginCtx, span, permissions.PermissionManage); !ok {
+		return
+	}
+
+	stats := c.authorizer.CacheStats()
+	response.JSON(ginCtx, http.StatusOK, response.Success(dto.CacheStatsResponse{
+		Entries:    stats.Size,
+		Hits:       response.Int64(stats.Hits),
+		Misses:     response.Int64(stats.Misses),
+		TTLSeconds: stats.TTL.Seconds(),
+	}))
+}
+
+// ListRoutes returns a handler listing every route from routes with its
+// authentication requirement, so operators can verify nothing is
+// unintentionally public.
+func (c *Controller) ListRoutes(routes func() gin.RoutesInfo, requirement func(method, fullPath string) string) gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		_, span := tracing.Auto(ginCtx.Request.Context())
+		defer span.End()
+
+		if _, ok := c.requirePermission(ginCtx, span, permissions.PermissionManage); !ok {
+			return
+		}
+
+		infos := routes()
+		result := make([]dto.RouteResponse, 0, len(infos))
+		for _, route := range infos {
+			result = append(result, dto.RouteResponse{
+				Method:  route.Method,
+				Path:    route.Path,
+				Handler: route.Handler,
+				Auth:    requirement(route.Method, route.Path),
+			})
 		}
+		sort.Slice(result, func(i, j int) bool {
+			if result[i].Path != result[j].Path {
+				return result[i].Path < result[j].Path
+			}
+			return result[i].Method < result[j].Method
+		})
+
+		response.JSON(ginCtx, http.StatusOK, response.Success(result))
+	}
+}
+
+// Maintenance reports whether maintenance mode is blocking writes.
+func (c *Controller) Maintenance(mode *middlewares.MaintenanceMode) gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		_, span := tracing.Auto(ginCtx.Request.Context())
+		defer span.End()
+
+		if _, ok := c.requirePermission(ginCtx, span, permissions.PermissionManage); !ok {
+			return
+		}
+
+		response.JSON(ginCtx, http.StatusOK, response.Success(dto.MaintenanceResponse{Enabled: mode.Enabled()}))
+	}
+}
+
+// SetMaintenance turns maintenance mode on or off at runtime. The flag is
+// shared, so other replicas follow within MAINTENANCE_SYNC_INTERVAL_SECONDS.
+func (c *Controller) SetMaintenance(mode *middlewares.MaintenanceMode) gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		_, span := tracing.Auto(ginCtx.Request.Context())
+		defer span.End()
+
+		auth, ok := c.requirePermission(ginCtx, span, permissions.PermissionManage)
+		if !ok {
+			return
+		}
+		userID := auth.UserID
+
+		var req dto.MaintenanceRequest
+		if err := ginCtx.ShouldBindJSON(&req); err != nil {
+			c.logError(ginCtx, "invalid request body", userID, "", err)
+			pkgerrors.RecordError(span.Span, err)
+			status, body := invalidBody[dto.MaintenanceResponse](err)
+			response.JSON(ginCtx, status, body)
+			return
+		}
+
+		if err := mode.SetEnabled(ginCtx.Request.Context(), *req.Enabled); err != nil {
+			c.respondError(ginCtx, span, "failed to change maintenance mode", userID, "", err)
+			return
+		}
+		span.SetAttributes(attribute.Bool("maintenance.enabled", *req.Enabled))
+
+		c.logger.Warn("maintenance mode changed", constants.AttrKeyUserID, userID, "enabled", *req.Enabled)
+		response.JSON(ginCtx, http.StatusOK, response.Success(dto.MaintenanceResponse{Enabled: *req.Enabled}))
+	}
+}
+
+// TriggerGC runs a full garbage collection and reports the heap before and
+// after it, for chasing memory leaks outside production. The collection
+// stops the world, so the route is only registered when DEBUG_GC_ENDPOINT
+// is set; see config.ShouldEnableDebugGC.
+func (c *Controller) TriggerGC(ginCtx *gin.Context) {
+	_, span := tracing.Auto(ginCtx.Request.Context())
+	defer span.End()
+
+	auth, ok := c.requirePermission(ginCtx, span, permissions.PermissionManage)
+	if !ok {
 		return
 	}
+	userID := auth.UserID
+
+	before := heapStats()
+	start := time.Now()
+	runtime.GC()
+	elapsed := time.Since(start)
+	after := heapStats()
+
+	span.SetAttributes(
+		attribute.Int64("gc.heap_alloc_before", int64(before.HeapAllocBytes)),
+		attribute.Int64("gc.heap_alloc_after", int64(after.HeapAllocBytes)),
+	)
+
+	c.logger.Info("manual garbage collection",
+		constants.AttrKeyUserID, userID,
+		"heap_alloc_before", int64(before.HeapAllocBytes),
+		"heap_alloc_after", int64(after.HeapAllocBytes),
+		"duration", elapsed,
+	)
+	response.JSON(ginCtx, http.StatusOK, response.Success(dto.GCResponse{
+		Before:     before,
+		After:      after,
+		DurationMs: float64(elapsed.Microseconds()) / 1000,
+	}))
+}
+
+func heapStats() dto.HeapStatsResponse {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return dto.HeapStatsResponse{
+		HeapAllocBytes: response.Int64(ms.HeapAlloc),
+		HeapInuseBytes: response.Int64(ms.HeapInuse),
+		HeapObjects:    response.Int64(ms.HeapObjects),
+		NumGC:          ms.NumGC,
+	}
+}
+
+// FlushCache drops every cached permission set, for use after out-of-band
+// role or permission changes in the database.
+func (c *Controller) FlushCache(ginCtx *gin.Context) {
+	_, span := tracing.Auto(ginCtx.Request.Context())
+	defer span.End()
+
+	auth, ok := c.requirePermission(ginCtx, span, permissions.PermissionManage)
+	if !ok {
+		return
+	}
+	userID := auth.UserID
+
+	cleared := c.authorizer.InvalidateAllCache()
+	span.SetAttributes(attribute.Int("cache.cleared", cleared))
 
-	ginCtx.JSON(http.StatusOK, response.Success(map[string]string{"message": "user deleted successfully"}))
+	c.logger.Info("permission cache flushed", constants.AttrKeyUserID, userID, "cleared", cleared)
+	response.JSON(ginCtx, http.StatusOK, response.Success(dto.CacheFlushResponse{Cleared: cleared}))
 }
@@ -0,0 +1,1493 @@
+package controller
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/elskow/go-microservice-template/config"
+	"github.com/elskow/go-microservice-template/middlewares"
+	"github.com/elskow/go-microservice-template/modules/account/authorization"
+	"github.com/elskow/go-microservice-template/modules/account/dto"
+	"github.com/elskow/go-microservice-template/pkg/apm"
+	"github.com/elskow/go-microservice-template/pkg/constants"
+	"github.com/elskow/go-microservice-template/pkg/database/dbtest"
+	pkgerrors "github.com/elskow/go-microservice-template/pkg/errors"
+	"github.com/elskow/go-microservice-template/pkg/eventstream"
+	"github.com/elskow/go-microservice-template/pkg/permissions"
+	"github.com/elskow/go-microservice-template/pkg/response"
+	"github.com/elskow/go-microservice-template/pkg/tracing"
+	"github.com/elskow/go-microservice-template/pkg/webhook"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Mock Service
+type mockService struct {
+	registerFunc              func(ctx context.Context, req dto.RegisterRequest) (dto.RegisterResponse, error)
+	loginFunc                 func(ctx context.Context, req dto.LoginRequest) (dto.LoginResponse, error)
+	refreshTokenFunc          func(ctx context.Context, req dto.RefreshTokenRequest) (dto.RefreshTokenResponse, error)
+	logoutFunc                func(ctx context.Context, userID string) error
+	getUserByIDFunc           func(ctx context.Context, userID string) (dto.UserResponse, error)
+	updateUserFunc            func(ctx context.Context, userID string, req dto.UpdateUserRequest) (dto.UserResponse, error)
+	deleteUserFunc            func(ctx context.Context, userID string) error
+	exportUsersFunc           func(ctx context.Context, fn func(user dto.UserExport) error) error
+	listUsersFunc             func(ctx context.Context, req dto.ListUsersRequest) (dto.ListUsersResponse, error)
+	revokeSessionsFunc        func(ctx context.Context, userID string) error
+	setUserStatusFunc         func(ctx context.Context, userID string, status string) error
+	listSessionsFunc          func(ctx context.Context, userID, currentSessionID string) ([]dto.SessionResponse, error)
+	revokeSessionFunc         func(ctx context.Context, userID, sessionID string) error
+	enrollTwoFactorFunc       func(ctx context.Context, userID string) (dto.TwoFactorEnrollResponse, error)
+	verifyTwoFactorFunc       func(ctx context.Context, userID string, req dto.TwoFactorVerifyRequest) error
+	regenerateBackupCodesFunc func(ctx context.Context, userID string) (dto.BackupCodesResponse, error)
+	verifyEmailFunc           func(ctx context.Context, req dto.VerifyEmailRequest) error
+	requestPasswordResetFunc  func(ctx context.Context, req dto.PasswordResetRequest) error
+	resetPasswordFunc         func(ctx context.Context, req dto.ResetPasswordRequest) error
+	subscribeEventsFunc       func(userID string) (<-chan webhook.Event, func())
+}
+
+func (m *mockService) SubscribeEvents(userID string) (<-chan webhook.Event, func()) {
+	if m.subscribeEventsFunc != nil {
+		return m.subscribeEventsFunc(userID)
+	}
+	return make(chan webhook.Event), func() {}
+}
+
+func (m *mockService) VerifyEmail(ctx context.Context, req dto.VerifyEmailRequest) error {
+	if m.verifyEmailFunc != nil {
+		return m.verifyEmailFunc(ctx, req)
+	}
+	return nil
+}
+
+func (m *mockService) RequestPasswordReset(ctx context.Context, req dto.PasswordResetRequest) error {
+	if m.requestPasswordResetFunc != nil {
+		return m.requestPasswordResetFunc(ctx, req)
+	}
+	return nil
+}
+
+func (m *mockService) ResetPassword(ctx context.Context, req dto.ResetPasswordRequest) error {
+	if m.resetPasswordFunc != nil {
+		return m.resetPasswordFunc(ctx, req)
+	}
+	return nil
+}
+
+func (m *mockService) Register(ctx context.Context, req dto.RegisterRequest) (dto.RegisterResponse, error) {
+	if m.registerFunc != nil {
+		return m.registerFunc(ctx, req)
+	}
+	return dto.RegisterResponse{}, nil
+}
+
+func (m *mockService) Login(ctx context.Context, req dto.LoginRequest) (dto.LoginResponse, error) {
+	if m.loginFunc != nil {
+		return m.loginFunc(ctx, req)
+	}
+	return dto.LoginResponse{}, nil
+}
+
+func (m *mockService) RefreshToken(ctx context.Context, req dto.RefreshTokenRequest) (dto.RefreshTokenResponse, error) {
+	if m.refreshTokenFunc != nil {
+		return m.refreshTokenFunc(ctx, req)
+	}
+	return dto.RefreshTokenResponse{}, nil
+}
+
+func (m *mockService) Logout(ctx context.Context, userID string) error {
+	if m.logoutFunc != nil {
+		return m.logoutFunc(ctx, userID)
+	}
+	return nil
+}
+
+func (m *mockService) GetUserByID(ctx context.Context, userID string) (dto.UserResponse, error) {
+	if m.getUserByIDFunc != nil {
+		return m.getUserByIDFunc(ctx, userID)
+	}
+	return dto.UserResponse{}, nil
+}
+
+func (m *mockService) UpdateUser(ctx context.Context, userID string, req dto.UpdateUserRequest) (dto.UserResponse, error) {
+	if m.updateUserFunc != nil {
+		return m.updateUserFunc(ctx, userID, req)
+	}
+	return dto.UserResponse{}, nil
+}
+
+func (m *mockService) DeleteUser(ctx context.Context, userID string) error {
+	if m.deleteUserFunc != nil {
+		return m.deleteUserFunc(ctx, userID)
+	}
+	return nil
+}
+
+func (m *mockService) ListUsers(ctx context.Context, req dto.ListUsersRequest) (dto.ListUsersResponse, error) {
+	if m.listUsersFunc != nil {
+		return m.listUsersFunc(ctx, req)
+	}
+	return dto.ListUsersResponse{}, nil
+}
+
+func (m *mockService) ExportUsers(ctx context.Context, fn func(user dto.UserExport) error) error {
+	if m.exportUsersFunc != nil {
+		return m.exportUsersFunc(ctx, fn)
+	}
+	return nil
+}
+
+func (m *mockService) RevokeSessions(ctx context.Context, userID string) error {
+	if m.revokeSessionsFunc != nil {
+		return m.revokeSessionsFunc(ctx, userID)
+	}
+	return nil
+}
+
+func (m *mockService) TokenVersion(ctx context.Context, userID string) (int, error) {
+	return 0, nil
+}
+
+func (m *mockService) SetUserStatus(ctx context.Context, userID string, status string) error {
+	if m.setUserStatusFunc != nil {
+		return m.setUserStatusFunc(ctx, userID, status)
+	}
+	return nil
+}
+
+func (m *mockService) ListSessions(ctx context.Context, userID, currentSessionID string) ([]dto.SessionResponse, error) {
+	if m.listSessionsFunc != nil {
+		return m.listSessionsFunc(ctx, userID, currentSessionID)
+	}
+	return nil, nil
+}
+
+func (m *mockService) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	if m.revokeSessionFunc != nil {
+		return m.revokeSessionFunc(ctx, userID, sessionID)
+	}
+	return nil
+}
+
+func (m *mockService) EnrollTwoFactor(ctx context.Context, userID string) (dto.TwoFactorEnrollResponse, error) {
+	if m.enrollTwoFactorFunc != nil {
+		return m.enrollTwoFactorFunc(ctx, userID)
+	}
+	return dto.TwoFactorEnrollResponse{}, nil
+}
+
+func (m *mockService) VerifyTwoFactor(ctx context.Context, userID string, req dto.TwoFactorVerifyRequest) error {
+	if m.verifyTwoFactorFunc != nil {
+		return m.verifyTwoFactorFunc(ctx, userID, req)
+	}
+	return nil
+}
+
+func (m *mockService) RegenerateBackupCodes(ctx context.Context, userID string) (dto.BackupCodesResponse, error) {
+	if m.regenerateBackupCodesFunc != nil {
+		return m.regenerateBackupCodesFunc(ctx, userID)
+	}
+	return dto.BackupCodesResponse{}, nil
+}
+
+func setupAuthorizer(t *testing.T) (*authorization.Authorizer, sqlmock.Sqlmock) {
+	tracedDB, mock, cleanup := dbtest.NewMockTracedDB(t)
+	t.Cleanup(cleanup)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return authorization.NewAuthorizer(tracedDB, logger), mock
+}
+
+func setupController(t *testing.T, svc *mockService) *Controller {
+	auth, _ := setupAuthorizer(t)
+	return &Controller{
+		service:    svc,
+		logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+		authorizer: auth,
+	}
+}
+
+// performRequest runs handler behind a router that injects userID the way
+// the Authenticate middleware would.
+func performRequest(handler gin.HandlerFunc, method, body, userID string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middlewares.ErrorHandler())
+	router.Handle(method, "/", func(c *gin.Context) {
+		if userID != "" {
+			c.Set(constants.CtxKeyAuth, middlewares.AuthContext{UserID: userID, Role: "user", Roles: []string{"user"}})
+		}
+		handler(c)
+	})
+
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, "/", reader)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) *response.ErrorSchema {
+	var resp response.Response[any]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.NotNil(t, resp.Error)
+	return resp.Error
+}
+
+func TestNewController(t *testing.T) {
+	auth, _ := setupAuthorizer(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	ctrl, err := NewController(&mockService{}, logger, auth)
+
+	require.NoError(t, err)
+	assert.NotNil(t, ctrl)
+}
+
+func TestNewController_NilAuthorizer(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	ctrl, err := NewController(&mockService{}, logger, nil)
+
+	assert.ErrorIs(t, err, ErrNilAuthorizer)
+	assert.Nil(t, ctrl)
+}
+
+func TestNewController_ErrorDetailsOnlyInDevelopment(t *testing.T) {
+	tests := []struct {
+		name   string
+		appEnv string
+		expose string
+		want   bool
+	}{
+		{name: "development with flag", appEnv: "development", expose: "true", want: true},
+		{name: "development without flag", appEnv: "development", expose: "false", want: false},
+		{name: "production with flag", appEnv: "production", expose: "true", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("APP_ENV", tt.appEnv)
+			t.Setenv("EXPOSE_ERROR_DETAILS", tt.expose)
+			config.Load()
+			t.Cleanup(config.Reset)
+
+			auth, _ := setupAuthorizer(t)
+			ctrl, err := NewController(&mockService{}, slog.New(slog.NewTextHandler(io.Discard, nil)), auth)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, ctrl.exposeErrorDetails)
+		})
+	}
+}
+
+func TestController_Me_InternalError_Development(t *testing.T) {
+	svc := &mockService{
+		getUserByIDFunc: func(ctx context.Context, userID string) (dto.UserResponse, error) {
+			return dto.UserResponse{}, errors.New("connection refused")
+		},
+	}
+	ctrl := setupController(t, svc)
+	ctrl.exposeErrorDetails = true
+
+	w := performRequest(ctrl.Me, http.MethodGet, "", uuid.NewString())
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	errSchema := decodeError(t, w)
+	assert.Equal(t, response.ErrCodeInternalServerError, errSchema.ErrorCode)
+	assert.Contains(t, errSchema.ErrorDetail, "connection refused")
+}
+
+func TestController_Me_InternalError_Production(t *testing.T) {
+	svc := &mockService{
+		getUserByIDFunc: func(ctx context.Context, userID string) (dto.UserResponse, error) {
+			return dto.UserResponse{}, errors.New("connection refused")
+		},
+	}
+	ctrl := setupController(t, svc)
+
+	w := performRequest(ctrl.Me, http.MethodGet, "", uuid.NewString())
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	errSchema := decodeError(t, w)
+	assert.Equal(t, response.ErrCodeInternalServerError, errSchema.ErrorCode)
+	assert.Empty(t, errSchema.ErrorDetail)
+	assert.NotContains(t, w.Body.String(), "connection refused")
+}
+
+func TestController_RespondError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "email exists", err: dto.ErrEmailAlreadyExists, wantStatus: http.StatusConflict, wantCode: response.ErrCodeConflict},
+		{name: "invalid credentials", err: dto.ErrInvalidCredentials, wantStatus: http.StatusUnauthorized, wantCode: response.ErrCodeInvalidCredentials},
+		{name: "user not found", err: dto.ErrUserNotFound, wantStatus: http.StatusNotFound, wantCode: response.ErrCodeNotFound},
+		{name: "token not found", err: dto.ErrTokenNotFound, wantStatus: http.StatusNotFound, wantCode: response.ErrCodeNotFound},
+		{name: "two-factor required", err: dto.ErrTwoFactorRequired, wantStatus: http.StatusUnauthorized, wantCode: response.ErrCodeTwoFactorRequired},
+		{name: "invalid two-factor code", err: dto.ErrInvalidTwoFactorCode, wantStatus: http.StatusUnauthorized, wantCode: response.ErrCodeInvalidTwoFactor},
+		{name: "wrapped sentinel", err: pkgerrors.Wrap(dto.ErrUserNotFound, "lookup"), wantStatus: http.StatusNotFound, wantCode: response.ErrCodeNotFound},
+		{name: "unknown error", err: errors.New("boom"), wantStatus: http.StatusInternalServerError, wantCode: response.ErrCodeInternalServerError},
+		{name: "deadline exceeded", err: pkgerrors.Wrap(context.DeadlineExceeded, "failed to get user by id"), wantStatus: http.StatusGatewayTimeout, wantCode: response.ErrCodeRequestTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := setupController(t, &mockService{})
+
+			w := performRequest(func(c *gin.Context) {
+				_, span := tracing.Auto(c.Request.Context())
+				defer span.End()
+				ctrl.respondError(c, span, "request failed", "", "", tt.err)
+			}, http.MethodGet, "", "")
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			errSchema := decodeError(t, w)
+			assert.Equal(t, tt.wantCode, errSchema.ErrorCode)
+		})
+	}
+}
+
+func TestController_RespondError_RequestPastDeadline(t *testing.T) {
+	ctrl := setupController(t, &mockService{})
+
+	w := performRequest(func(c *gin.Context) {
+		ctx, cancel := context.WithDeadline(c.Request.Context(), time.Now().Add(-time.Second))
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		_, span := tracing.Auto(ctx)
+		defer span.End()
+		// Drivers may report the cancelled query without wrapping the context error
+		ctrl.respondError(c, span, "request failed", "", "", errors.New("pq: canceling statement due to user request"))
+	}, http.MethodGet, "", "")
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Equal(t, response.ErrCodeRequestTimeout, decodeError(t, w).ErrorCode)
+}
+
+func TestController_Register_Conflict(t *testing.T) {
+	svc := &mockService{
+		registerFunc: func(ctx context.Context, req dto.RegisterRequest) (dto.RegisterResponse, error) {
+			return dto.RegisterResponse{}, dto.ErrEmailAlreadyExists
+		},
+	}
+	ctrl := setupController(t, svc)
+
+	body := `{"name":"John Doe","email":"john@example.com","password":"password123"}`
+	w := performRequest(ctrl.Register, http.MethodPost, body, "")
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	errSchema := decodeError(t, w)
+	assert.Equal(t, response.ErrCodeConflict, errSchema.ErrorCode)
+	assert.Equal(t, dto.ErrEmailAlreadyExists.Error(), errSchema.ErrorMessage)
+}
+
+func TestController_Register_OversizedBody(t *testing.T) {
+	ctrl := setupController(t, &mockService{})
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/", func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, 64)
+		ctrl.Register(c)
+	})
+
+	body := `{"name":"` + strings.Repeat("a", 256) + `","email":"john@example.com","password":"password123"}`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	errSchema := decodeError(t, w)
+	assert.Equal(t, response.ErrCodePayloadTooLarge, errSchema.ErrorCode)
+	assert.Equal(t, "Request body exceeds the 64 byte limit", errSchema.ErrorMessage)
+}
+
+func TestController_Login_TwoFactorChallenge(t *testing.T) {
+	var got dto.LoginRequest
+	svc := &mockService{
+		loginFunc: func(ctx context.Context, req dto.LoginRequest) (dto.LoginResponse, error) {
+			got = req
+			return dto.LoginResponse{}, dto.ErrTwoFactorRequired
+		},
+	}
+	ctrl := setupController(t, svc)
+
+	body := `{"email":"john@example.com","password":"password123"}`
+	w := performRequest(ctrl.Login, http.MethodPost, body, "")
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, response.ErrCodeTwoFactorRequired, decodeError(t, w).ErrorCode)
+	assert.Empty(t, got.TOTPCode)
+}
+
+func TestController_VerifyTwoFactor_RejectsMalformedCode(t *testing.T) {
+	svc := &mockService{
+		verifyTwoFactorFunc: func(ctx context.Context, userID string, req dto.TwoFactorVerifyRequest) error {
+			t.Fatal("service must not be called with a malformed code")
+			return nil
+		},
+	}
+	ctrl := setupController(t, svc)
+
+	w := performRequest(ctrl.VerifyTwoFactor, http.MethodPost, `{"code":"12ab"}`, uuid.NewString())
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, response.ErrCodeValidationFailed, decodeError(t, w).ErrorCode)
+}
+
+func expectPermissions(mock sqlmock.Sqlmock, names ...string) {
+	rows := sqlmock.NewRows([]string{"name", "resource", "action"})
+	for _, name := range names {
+		resource, action, _ := strings.Cut(name, ".")
+		rows.AddRow(name, resource, action)
+	}
+	mock.ExpectQuery("SELECT DISTINCT p.name, p.resource, p.action").WillReturnRows(rows)
+}
+
+func TestController_ExportUsers(t *testing.T) {
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	svc := &mockService{
+		exportUsersFunc: func(ctx context.Context, fn func(user dto.UserExport) error) error {
+			users := []dto.UserExport{
+				{ID: "11111111-1111-1111-1111-111111111111", Name: "Alice", Email: "alice@example.com", CreatedAt: createdAt},
+				{ID: "22222222-2222-2222-2222-222222222222", Name: "Bob, Jr.", Email: "bob@example.com", CreatedAt: createdAt.Add(time.Hour)},
+			}
+			for _, u := range users {
+				if err := fn(u); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	ctrl := setupController(t, svc)
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+	expectPermissions(mock, "user.list")
+
+	w := performRequest(ctrl.ExportUsers, http.MethodGet, "", uuid.NewString())
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="users.csv"`, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "id,name,email,created_at\n"+
+		"11111111-1111-1111-1111-111111111111,Alice,alice@example.com,2024-01-02T03:04:05Z\n"+
+		"22222222-2222-2222-2222-222222222222,\"Bob, Jr.\",bob@example.com,2024-01-02T04:04:05Z\n",
+		w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestController_ExportUsers_EscapesFormulas(t *testing.T) {
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	svc := &mockService{
+		exportUsersFunc: func(ctx context.Context, fn func(user dto.UserExport) error) error {
+			return fn(dto.UserExport{
+				ID:        "11111111-1111-1111-1111-111111111111",
+				Name:      "=HYPERLINK(\"http://evil\")",
+				Email:     "@sum(a1)@example.com",
+				CreatedAt: createdAt,
+			})
+		},
+	}
+	ctrl := setupController(t, svc)
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+	expectPermissions(mock, "user.list")
+
+	w := performRequest(ctrl.ExportUsers, http.MethodGet, "", uuid.NewString())
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "id,name,email,created_at\n"+
+		"11111111-1111-1111-1111-111111111111,\"'=HYPERLINK(\"\"http://evil\"\")\",'@sum(a1)@example.com,2024-01-02T03:04:05Z\n",
+		w.Body.String())
+}
+
+func TestCSVCell(t *testing.T) {
+	for in, want := range map[string]string{
+		"Alice": "Alice",
+		"":      "",
+		"=1+2":  "'=1+2",
+		"+1":    "'+1",
+		"-1":    "'-1",
+		"@x":    "'@x",
+		"\tx":   "'\tx",
+		"\rx":   "'\rx",
+		"a=b":   "a=b",
+	} {
+		assert.Equal(t, want, csvCell(in), in)
+	}
+}
+
+func TestController_ExportUsers_JSONStreamsLargeList(t *testing.T) {
+	const total = 5000
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	svc := &mockService{
+		exportUsersFunc: func(ctx context.Context, fn func(user dto.UserExport) error) error {
+			for i := 0; i < total; i++ {
+				err := fn(dto.UserExport{
+					ID:        uuid.NewString(),
+					Name:      fmt.Sprintf("User %d", i),
+					Email:     fmt.Sprintf("user%d@example.com", i),
+					CreatedAt: createdAt,
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	ctrl := setupController(t, svc)
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+	expectPermissions(mock, "user.list")
+
+	collector, err := apm.NewMetricsCollector(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middlewares.HTTPMetricsMiddleware(collector, nil))
+	reportedSize := -1
+	router.Use(func(c *gin.Context) {
+		c.Next()
+		reportedSize = c.Writer.Size()
+	})
+	router.GET("/users/export", func(c *gin.Context) {
+		c.Set(constants.CtxKeyAuth, middlewares.AuthContext{UserID: uuid.NewString(), Role: "admin"})
+		ctrl.ExportUsers(c)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/export?format=json", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+
+	var users []dto.UserExport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &users))
+	require.Len(t, users, total)
+	assert.Equal(t, "User 0", users[0].Name)
+	assert.Equal(t, fmt.Sprintf("user%d@example.com", total-1), users[total-1].Email)
+	assert.True(t, users[0].CreatedAt.Equal(createdAt))
+
+	assert.Equal(t, w.Body.Len(), reportedSize)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestController_ExportUsers_UnsupportedFormat(t *testing.T) {
+	svc := &mockService{
+		exportUsersFunc: func(ctx context.Context, fn func(user dto.UserExport) error) error {
+			t.Fatal("export must not run for an unsupported format")
+			return nil
+		},
+	}
+	ctrl := setupController(t, svc)
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+	expectPermissions(mock, "user.list")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/users/export", func(c *gin.Context) {
+		c.Set(constants.CtxKeyAuth, middlewares.AuthContext{UserID: uuid.NewString(), Role: "admin"})
+		ctrl.ExportUsers(c)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/export?format=xml", nil))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, response.ErrCodeValidationFailed, decodeError(t, w).ErrorCode)
+}
+
+func TestController_ExportUsers_Forbidden(t *testing.T) {
+	called := false
+	svc := &mockService{
+		exportUsersFunc: func(ctx context.Context, fn func(user dto.UserExport) error) error {
+			called = true
+			return nil
+		},
+	}
+	ctrl := setupController(t, svc)
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+	expectPermissions(mock, "user.read")
+
+	w := performRequest(ctrl.ExportUsers, http.MethodGet, "", uuid.NewString())
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.False(t, called)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// performQuery issues a GET with the given query string to handler on
+// behalf of userID.
+func performQuery(handler gin.HandlerFunc, query, userID string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", func(c *gin.Context) {
+		c.Set(constants.CtxKeyAuth, middlewares.AuthContext{UserID: userID, Role: "admin", Roles: []string{"admin"}})
+		handler(c)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?"+query, nil))
+	return w
+}
+
+func TestController_ListUsers(t *testing.T) {
+	var got dto.ListUsersRequest
+	svc := &mockService{
+		listUsersFunc: func(ctx context.Context, req dto.ListUsersRequest) (dto.ListUsersResponse, error) {
+			got = req
+			return dto.ListUsersResponse{Users: []dto.UserListItem{{ID: "1", Name: "Alice"}}}, nil
+		},
+	}
+	ctrl := setupController(t, svc)
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+	expectPermissions(mock, "user.list")
+
+	w := performQuery(ctrl.ListUsers, "sort=-created_at&limit=10&offset=20&email=example&status=active", uuid.NewString())
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, dto.ListUsersRequest{Sort: "-created_at", Limit: 10, Offset: 20, Email: "example", Status: "active"}, got)
+	var resp response.Response[dto.ListUsersResponse]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.NotNil(t, resp.Output)
+	assert.Len(t, resp.Output.Users, 1)
+}
+
+func TestController_ListUsers_PermissionCheckFailsClosed(t *testing.T) {
+	called := false
+	svc := &mockService{
+		listUsersFunc: func(ctx context.Context, req dto.ListUsersRequest) (dto.ListUsersResponse, error) {
+			called = true
+			return dto.ListUsersResponse{}, nil
+		},
+	}
+	ctrl := setupController(t, svc)
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+	mock.ExpectQuery("SELECT DISTINCT p.name, p.resource, p.action").WillReturnError(errors.New("connection refused"))
+
+	w := performQuery(ctrl.ListUsers, "", uuid.NewString())
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.False(t, called)
+}
+
+func TestController_ListUsers_PermissionCheckFailsOpen(t *testing.T) {
+	called := false
+	svc := &mockService{
+		listUsersFunc: func(ctx context.Context, req dto.ListUsersRequest) (dto.ListUsersResponse, error) {
+			called = true
+			return dto.ListUsersResponse{}, nil
+		},
+	}
+	ctrl := setupController(t, svc)
+	auth, mock := setupAuthorizer(t)
+	auth.SetFailurePolicy(permissions.UserList, authorization.FailOpen)
+	ctrl.authorizer = auth
+	mock.ExpectQuery("SELECT DISTINCT p.name, p.resource, p.action").WillReturnError(errors.New("connection refused"))
+
+	w := performQuery(ctrl.ListUsers, "", uuid.NewString())
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, called)
+}
+
+func TestController_ListUsers_InvalidSort(t *testing.T) {
+	svc := &mockService{
+		listUsersFunc: func(ctx context.Context, req dto.ListUsersRequest) (dto.ListUsersResponse, error) {
+			return dto.ListUsersResponse{}, dto.ErrInvalidListQuery
+		},
+	}
+	ctrl := setupController(t, svc)
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+	expectPermissions(mock, "user.list")
+
+	w := performQuery(ctrl.ListUsers, "sort=password", uuid.NewString())
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, response.ErrCodeValidationFailed, decodeError(t, w).ErrorCode)
+}
+
+func TestController_ListUsers_Forbidden(t *testing.T) {
+	ctrl := setupController(t, &mockService{})
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+	expectPermissions(mock, "user.read")
+
+	w := performQuery(ctrl.ListUsers, "", uuid.NewString())
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestController_CompareUsers(t *testing.T) {
+	ctrl := setupController(t, &mockService{})
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+	expectPermissions(mock, "permission.manage")
+	expectPermissions(mock, "role.read", "user.list", "user.read")
+	expectPermissions(mock, "user.delete", "user.read")
+
+	userA, userB := uuid.NewString(), uuid.NewString()
+	w := performQuery(ctrl.CompareUsers, "a="+userA+"&b="+userB, uuid.NewString())
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp response.Response[dto.PermissionDiffResponse]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.NotNil(t, resp.Output)
+	assert.Equal(t, []string{"role.read", "user.list"}, resp.Output.OnlyA)
+	assert.Equal(t, []string{"user.delete"}, resp.Output.OnlyB)
+	assert.Equal(t, []string{"user.read"}, resp.Output.Shared)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestController_CompareUsers_InvalidID(t *testing.T) {
+	ctrl := setupController(t, &mockService{})
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+	expectPermissions(mock, "permission.manage")
+
+	w := performQuery(ctrl.CompareUsers, "a=not-a-uuid&b="+uuid.NewString(), uuid.NewString())
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, response.ErrCodeValidationFailed, decodeError(t, w).ErrorCode)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestController_CompareUsers_Forbidden(t *testing.T) {
+	ctrl := setupController(t, &mockService{})
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+	expectPermissions(mock, "user.read")
+
+	w := performQuery(ctrl.CompareUsers, "a="+uuid.NewString()+"&b="+uuid.NewString(), uuid.NewString())
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestController_SimulateRole(t *testing.T) {
+	ctrl := setupController(t, &mockService{})
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+	expectPermissions(mock, "permission.manage")
+	expectPermissions(mock, "user.read")
+	mock.ExpectQuery(`SELECT EXISTS`).WithArgs("moderator").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`SELECT p.name, p.resource, p.action`).WithArgs("moderator").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "resource", "action"}).
+			AddRow("user.list", "user", "list").
+			AddRow("user.read", "user", "read"))
+
+	body := `{"user_id":"` + uuid.NewString() + `","role":"moderator"}`
+	w := performRequest(ctrl.SimulateRole, http.MethodPost, body, uuid.NewString())
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp response.Response[dto.SimulateRoleResponse]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.NotNil(t, resp.Output)
+	assert.Equal(t, []string{"user.read"}, resp.Output.Current)
+	assert.Equal(t, []string{"user.list"}, resp.Output.Gained)
+	assert.Equal(t, []string{"user.list", "user.read"}, resp.Output.Resulting)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestController_SimulateRole_UnknownRole(t *testing.T) {
+	ctrl := setupController(t, &mockService{})
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+	expectPermissions(mock, "permission.manage")
+	expectPermissions(mock, "user.read")
+	mock.ExpectQuery(`SELECT EXISTS`).WithArgs("ghost").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	body := `{"user_id":"` + uuid.NewString() + `","role":"ghost"}`
+	w := performRequest(ctrl.SimulateRole, http.MethodPost, body, uuid.NewString())
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, response.ErrCodeNotFound, decodeError(t, w).ErrorCode)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestController_Logout_CookieModeClearsCookies(t *testing.T) {
+	var loggedOut string
+	svc := &mockService{
+		logoutFunc: func(ctx context.Context, userID string) error {
+			loggedOut = userID
+			return nil
+		},
+	}
+	ctrl := setupController(t, svc)
+	ctrl.cookies = authCookies{enabled: true, access: "access_token", refresh: "refresh_token", secure: true}
+	userID := uuid.NewString()
+
+	w := performRequest(ctrl.Logout, http.MethodPost, "", userID)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, userID, loggedOut)
+	cleared := make(map[string]*http.Cookie)
+	for _, cookie := range w.Result().Cookies() {
+		cleared[cookie.Name] = cookie
+	}
+	for _, name := range []string{"access_token", "refresh_token"} {
+		cookie, ok := cleared[name]
+		require.True(t, ok, "missing Set-Cookie for %s", name)
+		assert.Empty(t, cookie.Value)
+		assert.Equal(t, -1, cookie.MaxAge, "Max-Age=0 is parsed as -1")
+		assert.True(t, cookie.HttpOnly)
+		assert.True(t, cookie.Secure)
+	}
+}
+
+func TestController_Logout_CookieModeClearsCookiesWhenLogoutFails(t *testing.T) {
+	svc := &mockService{
+		logoutFunc: func(ctx context.Context, userID string) error {
+			return errors.New("database unavailable")
+		},
+	}
+	ctrl := setupController(t, svc)
+	ctrl.cookies = authCookies{enabled: true, access: "access_token", refresh: "refresh_token"}
+
+	w := performRequest(ctrl.Logout, http.MethodPost, "", uuid.NewString())
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Len(t, w.Result().Cookies(), 2)
+	for _, cookie := range w.Result().Cookies() {
+		assert.Equal(t, -1, cookie.MaxAge, cookie.Name)
+	}
+}
+
+func TestController_Login_CookieModeSetsCookies(t *testing.T) {
+	svc := &mockService{
+		loginFunc: func(ctx context.Context, req dto.LoginRequest) (dto.LoginResponse, error) {
+			return dto.LoginResponse{Token: dto.TokenResponse{
+				AccessToken:  "access",
+				RefreshToken: "refresh",
+				TokenType:    "Bearer",
+				ExpiresIn:    900,
+			}}, nil
+		},
+	}
+	ctrl := setupController(t, svc)
+	ctrl.cookies = authCookies{enabled: true, access: "access_token", refresh: "refresh_token", secure: true, refreshMaxAge: time.Hour}
+
+	body := `{"email":"john@example.com","password":"password123"}`
+	w := performRequest(ctrl.Login, http.MethodPost, body, "")
+
+	require.Equal(t, http.StatusOK, w.Code)
+	set := make(map[string]*http.Cookie)
+	for _, cookie := range w.Result().Cookies() {
+		set[cookie.Name] = cookie
+	}
+	require.Contains(t, set, "access_token")
+	require.Contains(t, set, "refresh_token")
+	assert.Equal(t, "access", set["access_token"].Value)
+	assert.Equal(t, 900, set["access_token"].MaxAge)
+	assert.Equal(t, "refresh", set["refresh_token"].Value)
+	assert.Equal(t, 3600, set["refresh_token"].MaxAge)
+	for _, cookie := range set {
+		assert.True(t, cookie.HttpOnly)
+		assert.True(t, cookie.Secure)
+		assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+	}
+}
+
+func TestController_RefreshToken_CookieModeReadsCookie(t *testing.T) {
+	var got dto.RefreshTokenRequest
+	svc := &mockService{
+		refreshTokenFunc: func(ctx context.Context, req dto.RefreshTokenRequest) (dto.RefreshTokenResponse, error) {
+			got = req
+			return dto.RefreshTokenResponse{Token: dto.TokenResponse{AccessToken: "access", RefreshToken: "rotated"}}, nil
+		},
+	}
+	ctrl := setupController(t, svc)
+	ctrl.cookies = authCookies{enabled: true, access: "access_token", refresh: "refresh_token"}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/", ctrl.RefreshToken)
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "refresh_token", Value: "from-cookie"})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "from-cookie", got.RefreshToken)
+	var rotated bool
+	for _, cookie := range w.Result().Cookies() {
+		rotated = rotated || (cookie.Name == "refresh_token" && cookie.Value == "rotated")
+	}
+	assert.True(t, rotated, "refresh cookie not rotated")
+}
+
+func TestController_Logout_NoCookiesByDefault(t *testing.T) {
+	ctrl := setupController(t, &mockService{})
+
+	w := performRequest(ctrl.Logout, http.MethodPost, "", uuid.NewString())
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Values("Set-Cookie"))
+}
+
+func TestController_Me_MissingAuthContext(t *testing.T) {
+	ctrl := setupController(t, &mockService{})
+
+	w := performRequest(ctrl.Me, http.MethodGet, "", "")
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, response.ErrCodeUnauthorized, decodeError(t, w).ErrorCode)
+}
+
+func performRevokeSessions(ctrl *Controller, adminID, targetID string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/users/:id/revoke-sessions", func(c *gin.Context) {
+		c.Set(constants.CtxKeyAuth, middlewares.AuthContext{UserID: adminID, Role: "admin", Roles: []string{"admin"}})
+		ctrl.RevokeSessions(c)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/users/"+targetID+"/revoke-sessions", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestController_RevokeSessions(t *testing.T) {
+	var revoked string
+	svc := &mockService{
+		revokeSessionsFunc: func(ctx context.Context, userID string) error {
+			revoked = userID
+			return nil
+		},
+	}
+	ctrl := setupController(t, svc)
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+	expectPermissions(mock, "user.manage")
+
+	targetID := uuid.NewString()
+	w := performRevokeSessions(ctrl, uuid.NewString(), targetID)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, targetID, revoked)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestController_RevokeSessions_Forbidden(t *testing.T) {
+	svc := &mockService{
+		revokeSessionsFunc: func(ctx context.Context, userID string) error {
+			t.Fatal("service must not be called without user.manage")
+			return nil
+		},
+	}
+	ctrl := setupController(t, svc)
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+	expectPermissions(mock, "user.read", "user.update")
+
+	w := performRevokeSessions(ctrl, uuid.NewString(), uuid.NewString())
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestController_RevokeSessions_UserNotFound(t *testing.T) {
+	svc := &mockService{
+		revokeSessionsFunc: func(ctx context.Context, userID string) error {
+			return dto.ErrUserNotFound
+		},
+	}
+	ctrl := setupController(t, svc)
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+	expectPermissions(mock, "user.manage")
+
+	w := performRevokeSessions(ctrl, uuid.NewString(), uuid.NewString())
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// performSessionRequest runs handler for route as a caller authenticated
+// with the given session.
+func performSessionRequest(handler gin.HandlerFunc, method, route, path, userID, sessionID string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Handle(method, route, func(c *gin.Context) {
+		c.Set(constants.CtxKeyAuth, middlewares.AuthContext{UserID: userID, Role: "user", Roles: []string{"user"}, SessionID: sessionID})
+		handler(c)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func TestController_ListSessions(t *testing.T) {
+	userID, sessionID := uuid.NewString(), uuid.NewString()
+	svc := &mockService{
+		listSessionsFunc: func(ctx context.Context, uid, currentSessionID string) ([]dto.SessionResponse, error) {
+			assert.Equal(t, userID, uid)
+			assert.Equal(t, sessionID, currentSessionID)
+			return []dto.SessionResponse{{ID: sessionID, UserAgent: "Mozilla/5.0", Current: true}}, nil
+		},
+	}
+	ctrl := setupController(t, svc)
+
+	w := performSessionRequest(ctrl.ListSessions, http.MethodGet, "/sessions", "/sessions", userID, sessionID)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp response.Response[[]dto.SessionResponse]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.NotNil(t, resp.Output)
+	sessions := *resp.Output
+	require.Len(t, sessions, 1)
+	assert.True(t, sessions[0].Current)
+	assert.Equal(t, "Mozilla/5.0", sessions[0].UserAgent)
+}
+
+func TestController_ListSessions_Unauthenticated(t *testing.T) {
+	ctrl := setupController(t, &mockService{})
+
+	w := performRequest(ctrl.ListSessions, http.MethodGet, "", "")
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestController_RevokeSession(t *testing.T) {
+	userID, sessionID := uuid.NewString(), uuid.NewString()
+	var revoked string
+	svc := &mockService{
+		revokeSessionFunc: func(ctx context.Context, uid, sid string) error {
+			assert.Equal(t, userID, uid)
+			revoked = sid
+			return nil
+		},
+	}
+	ctrl := setupController(t, svc)
+
+	w := performSessionRequest(ctrl.RevokeSession, http.MethodDelete, "/sessions/:id", "/sessions/"+sessionID, userID, sessionID)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, sessionID, revoked)
+}
+
+func TestController_RevokeSession_NotFound(t *testing.T) {
+	svc := &mockService{
+		revokeSessionFunc: func(ctx context.Context, userID, sessionID string) error {
+			return dto.ErrSessionNotFound
+		},
+	}
+	ctrl := setupController(t, svc)
+
+	w := performSessionRequest(ctrl.RevokeSession, http.MethodDelete, "/sessions/:id", "/sessions/"+uuid.NewString(), uuid.NewString(), "")
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, response.ErrCodeNotFound, decodeError(t, w).ErrorCode)
+}
+
+func TestController_CacheStats(t *testing.T) {
+	ctrl := setupController(t, &mockService{})
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+
+	// one miss then one hit for another user
+	otherUser := uuid.NewString()
+	expectPermissions(mock, "user.read")
+	_, err := auth.HasPermission(context.Background(), otherUser, "user.read")
+	require.NoError(t, err)
+	_, err = auth.HasPermission(context.Background(), otherUser, "user.read")
+	require.NoError(t, err)
+
+	// the handler's own permission check is the second miss
+	expectPermissions(mock, "permission.manage")
+	w := performRequest(ctrl.CacheStats, http.MethodGet, "", uuid.NewString())
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp response.Response[dto.CacheStatsResponse]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.NotNil(t, resp.Output)
+	assert.Equal(t, 2, resp.Output.Entries)
+	assert.Equal(t, response.Int64(1), resp.Output.Hits)
+	assert.Equal(t, response.Int64(2), resp.Output.Misses)
+	assert.Equal(t, constants.DefaultCacheTTL.Seconds(), resp.Output.TTLSeconds)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestController_CacheStats_Forbidden(t *testing.T) {
+	ctrl := setupController(t, &mockService{})
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+	expectPermissions(mock, "user.read")
+
+	w := performRequest(ctrl.CacheStats, http.MethodGet, "", uuid.NewString())
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestController_FlushCache(t *testing.T) {
+	ctrl := setupController(t, &mockService{})
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+
+	for i := 0; i < 2; i++ {
+		expectPermissions(mock, "user.read")
+		_, err := auth.HasPermission(context.Background(), uuid.NewString(), "user.read")
+		require.NoError(t, err)
+	}
+
+	// the caller's own permissions are cached by the check, so three are cleared
+	expectPermissions(mock, "permission.manage")
+	w := performRequest(ctrl.FlushCache, http.MethodPost, "", uuid.NewString())
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp response.Response[dto.CacheFlushResponse]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.NotNil(t, resp.Output)
+	assert.Equal(t, 3, resp.Output.Cleared)
+	assert.Zero(t, auth.CacheSize())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestController_FlushCache_Forbidden(t *testing.T) {
+	ctrl := setupController(t, &mockService{})
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+	expectPermissions(mock, "user.read")
+
+	w := performRequest(ctrl.FlushCache, http.MethodPost, "", uuid.NewString())
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, 1, auth.CacheSize())
+}
+
+func TestController_TriggerGC(t *testing.T) {
+	ctrl := setupController(t, &mockService{})
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+	expectPermissions(mock, "permission.manage")
+
+	w := performRequest(ctrl.TriggerGC, http.MethodPost, "", uuid.NewString())
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp response.Response[dto.GCResponse]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.NotNil(t, resp.Output)
+	assert.Positive(t, int64(resp.Output.Before.HeapAllocBytes))
+	assert.Positive(t, int64(resp.Output.After.HeapAllocBytes))
+	assert.Greater(t, resp.Output.After.NumGC, resp.Output.Before.NumGC)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestController_TriggerGC_Forbidden(t *testing.T) {
+	ctrl := setupController(t, &mockService{})
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+	expectPermissions(mock, "user.read")
+
+	w := performRequest(ctrl.TriggerGC, http.MethodPost, "", uuid.NewString())
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestController_RequestPasswordReset_Accepted(t *testing.T) {
+	var got dto.PasswordResetRequest
+	ctrl := setupController(t, &mockService{
+		requestPasswordResetFunc: func(ctx context.Context, req dto.PasswordResetRequest) error {
+			got = req
+			return nil
+		},
+	})
+
+	w := performRequest(ctrl.RequestPasswordReset, http.MethodPost, `{"email":"john@example.com"}`, "")
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "john@example.com", got.Email)
+}
+
+func TestController_ResetPassword_InvalidToken(t *testing.T) {
+	ctrl := setupController(t, &mockService{
+		resetPasswordFunc: func(ctx context.Context, req dto.ResetPasswordRequest) error {
+			return dto.ErrInvalidAccountToken
+		},
+	})
+
+	w := performRequest(ctrl.ResetPassword, http.MethodPost, `{"token":"expired","password":"new-password"}`, "")
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, response.ErrCodeInvalidToken, decodeError(t, w).ErrorCode)
+}
+
+func TestController_Events_StreamsPublishedEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	broker := eventstream.NewBroker(eventstream.DefaultBufferSize)
+	ctrl := setupController(t, &mockService{subscribeEventsFunc: broker.Subscribe})
+
+	router := gin.New()
+	router.GET("/events", func(c *gin.Context) {
+		c.Set(constants.CtxKeyAuth, middlewares.AuthContext{UserID: "user-id"})
+		ctrl.Events(c)
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/events")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	require.Eventually(t, func() bool { return broker.Subscribers() == 1 }, time.Second, 5*time.Millisecond)
+
+	event := webhook.NewEvent(webhook.EventSessionRevoked, "user-id", map[string]string{"session_id": "session-id"})
+	go broker.Dispatch(context.Background(), event)
+
+	reader := bufio.NewReader(resp.Body)
+	var eventLine, dataLine string
+	for dataLine == "" {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			eventLine = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			dataLine = strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		}
+	}
+
+	assert.Equal(t, webhook.EventSessionRevoked, eventLine)
+	var got webhook.Event
+	require.NoError(t, json.Unmarshal([]byte(dataLine), &got))
+	assert.Equal(t, event.ID, got.ID)
+	assert.Equal(t, "session-id", got.Data["session_id"])
+
+	// Disconnecting unsubscribes the stream
+	resp.Body.Close()
+	assert.Eventually(t, func() bool { return broker.Subscribers() == 0 }, time.Second, 5*time.Millisecond)
+}
+
+func TestController_ListRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctrl := setupController(t, &mockService{})
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+
+	engine := gin.New()
+	routeAuth := middlewares.NewRouteAuth()
+	engine.POST("/api/account/login", ctrl.Login)
+	protected := routeAuth.Protect(engine.Group("/api/account"))
+	protected.GET("/me", ctrl.Me)
+	protected.GET("/admin/routes", ctrl.ListRoutes(engine.Routes, routeAuth.Requirement))
+
+	expectPermissions(mock, "permission.manage")
+	w := performRequest(ctrl.ListRoutes(engine.Routes, routeAuth.Requirement), http.MethodGet, "", uuid.NewString())
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp response.Response[[]dto.RouteResponse]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.NotNil(t, resp.Output)
+
+	auths := make(map[string]string)
+	for _, route := range *resp.Output {
+		auths[route.Method+" "+route.Path] = route.Auth
+	}
+	assert.Equal(t, map[string]string{
+		"POST /api/account/login":       middlewares.RouteAuthNone,
+		"GET /api/account/me":           middlewares.RouteAuthToken,
+		"GET /api/account/admin/routes": middlewares.RouteAuthToken,
+	}, auths)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestController_ListRoutes_Forbidden(t *testing.T) {
+	ctrl := setupController(t, &mockService{})
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+	expectPermissions(mock, "user.read")
+
+	routes := func() gin.RoutesInfo { return nil }
+	requirement := func(method, fullPath string) string { return middlewares.RouteAuthNone }
+	w := performRequest(ctrl.ListRoutes(routes, requirement), http.MethodGet, "", uuid.NewString())
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestController_SetMaintenance(t *testing.T) {
+	ctrl := setupController(t, &mockService{})
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+	mode := middlewares.NewMaintenanceMode(false, time.Minute, middlewares.RetryAfterSeconds, nil, nil)
+
+	expectPermissions(mock, "permission.manage")
+	w := performRequest(ctrl.SetMaintenance(mode), http.MethodPut, `{"enabled":true}`, uuid.NewString())
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, mode.Enabled())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+type failingMaintenanceStore struct{}
+
+func (failingMaintenanceStore) MaintenanceEnabled(context.Context) (bool, error) {
+	return false, errors.New("database unavailable")
+}
+
+func (failingMaintenanceStore) SetMaintenanceEnabled(context.Context, bool) error {
+	return errors.New("database unavailable")
+}
+
+func TestController_SetMaintenance_StoreFailure(t *testing.T) {
+	ctrl := setupController(t, &mockService{})
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+	mode := middlewares.NewMaintenanceMode(false, time.Minute, middlewares.RetryAfterSeconds, nil, failingMaintenanceStore{})
+
+	expectPermissions(mock, "permission.manage")
+	w := performRequest(ctrl.SetMaintenance(mode), http.MethodPut, `{"enabled":true}`, uuid.NewString())
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.False(t, mode.Enabled(), "the local flag only changes once the shared one has")
+}
+
+func TestController_SetMaintenance_RequiresEnabled(t *testing.T) {
+	ctrl := setupController(t, &mockService{})
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+	mode := middlewares.NewMaintenanceMode(true, time.Minute, middlewares.RetryAfterSeconds, nil, nil)
+
+	expectPermissions(mock, "permission.manage")
+	w := performRequest(ctrl.SetMaintenance(mode), http.MethodPut, `{}`, uuid.NewString())
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.True(t, mode.Enabled())
+}
+
+func TestController_MyPermissions(t *testing.T) {
+	ctrl := setupController(t, &mockService{})
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+	expectPermissions(mock, "user.read", "user.update")
+
+	w := performRequest(ctrl.MyPermissions, http.MethodGet, "", uuid.NewString())
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp response.Response[dto.PermissionsResponse]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.NotNil(t, resp.Output)
+	assert.Equal(t, []string{"user.read", "user.update"}, resp.Output.Permissions)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestController_MyPermissions_LookupFails(t *testing.T) {
+	ctrl := setupController(t, &mockService{})
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+	mock.ExpectQuery("SELECT DISTINCT p.name, p.resource, p.action").WillReturnError(errors.New("connection refused"))
+
+	w := performRequest(ctrl.MyPermissions, http.MethodGet, "", uuid.NewString())
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestController_MyRoles(t *testing.T) {
+	ctrl := setupController(t, &mockService{})
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+	mock.ExpectQuery("FROM user_roles ur").
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("moderator").AddRow("user"))
+
+	w := performRequest(ctrl.MyRoles, http.MethodGet, "", uuid.NewString())
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp response.Response[dto.RolesResponse]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.NotNil(t, resp.Output)
+	assert.Equal(t, []string{"moderator", "user"}, resp.Output.Roles)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestController_MyRoles_Unauthenticated(t *testing.T) {
+	ctrl := setupController(t, &mockService{})
+
+	w := performRequest(ctrl.MyRoles, http.MethodGet, "", "")
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestController_CheckMyPermissions(t *testing.T) {
+	ctrl := setupController(t, &mockService{})
+	auth, mock := setupAuthorizer(t)
+	ctrl.authorizer = auth
+	userID := uuid.NewString()
+	rows := sqlmock.NewRows([]string{"name", "resource", "action"}).
+		AddRow("user.read", "user", "read").
+		AddRow("user.update", "user", "update")
+	mock.ExpectQuery("SELECT DISTINCT p.name, p.resource, p.action").WithArgs(uuid.MustParse(userID)).WillReturnRows(rows)
+
+	w := performRequest(ctrl.CheckMyPermissions, http.MethodPost,
+		`{"permissions":["user.read","user.delete","permission.manage"]}`, userID)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp response.Response[dto.PermissionCheckResponse]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.NotNil(t, resp.Output)
+	assert.Equal(t, map[string]bool{
+		"user.read":         true,
+		"user.delete":       false,
+		"permission.manage": false,
+	}, resp.Output.Permissions)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestController_CheckMyPermissions_EmptyList(t *testing.T) {
+	ctrl := setupController(t, &mockService{})
+
+	w := performRequest(ctrl.CheckMyPermissions, http.MethodPost, `{"permissions":[]}`, uuid.NewString())
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, response.ErrCodeValidationFailed, decodeError(t, w).ErrorCode)
+}
+
+func TestController_CheckMyPermissions_Unauthenticated(t *testing.T) {
+	ctrl := setupController(t, &mockService{})
+
+	w := performRequest(ctrl.CheckMyPermissions, http.MethodPost, `{"permissions":["user.read"]}`, "")
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestController_Me_NotFoundRenderedByErrorHandler(t *testing.T) {
+	svc := &mockService{
+		getUserByIDFunc: func(ctx context.Context, userID string) (dto.UserResponse, error) {
+			return dto.UserResponse{}, dto.ErrUserNotFound
+		},
+	}
+	ctrl := setupController(t, svc)
+
+	w := performRequest(ctrl.Me, http.MethodGet, "", uuid.NewString())
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, response.ErrCodeNotFound, decodeError(t, w).ErrorCode)
+}
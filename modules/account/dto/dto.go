@@ -1,19 +1,41 @@
 package dto
 
-import "errors"
+import (
+	"errors"
+	"time"
+
+	"github.com/elskow/go-microservice-template/pkg/response"
+)
 
 var (
 	ErrEmailAlreadyExists = errors.New("email already exists")
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrUserNotFound       = errors.New("user not found")
 	ErrTokenNotFound      = errors.New("refresh token not found")
+	ErrSessionExpired     = errors.New("session expired, please log in again")
+	ErrAccountSuspended   = errors.New("account suspended")
+	ErrInvalidUserStatus  = errors.New("invalid user status")
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrInvalidListQuery   = errors.New("invalid sort or filter")
+
+	ErrTwoFactorRequired       = errors.New("two-factor code required")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication already enabled")
+	ErrTwoFactorNotEnrolled    = errors.New("two-factor authentication not enrolled")
+	ErrTwoFactorUnavailable    = errors.New("two-factor authentication is not configured")
+
+	ErrInvalidAccountToken = errors.New("invalid or expired token")
 )
 
+const TokenTypeBearer = "Bearer"
+
 type (
 	RegisterRequest struct {
 		Name     string `json:"name" binding:"required,min=2,max=100"`
 		Email    string `json:"email" binding:"required,email"`
 		Password string `json:"password" binding:"required,min=8"`
+
+		Client ClientInfo `json:"-"`
 	}
 
 	RegisterResponse struct {
@@ -24,6 +46,21 @@ type (
 	LoginRequest struct {
 		Email    string `json:"email" binding:"required,email"`
 		Password string `json:"password" binding:"required"`
+		// TOTPCode is required once the account has two-factor enabled; a
+		// login without it is answered with ErrTwoFactorRequired.
+		TOTPCode string `json:"totp_code" binding:"omitempty,len=6,numeric"`
+		// BackupCode may be given instead of TOTPCode when the authenticator
+		// is unavailable; each backup code works once.
+		BackupCode string `json:"backup_code" binding:"omitempty,max=32"`
+
+		Client ClientInfo `json:"-"`
+	}
+
+	// ClientInfo describes the device a session was started from. It is
+	// filled in by the controller from the request, not bound from JSON.
+	ClientInfo struct {
+		UserAgent string
+		IPAddress string
 	}
 
 	LoginResponse struct {
@@ -31,6 +68,40 @@ type (
 		Token TokenResponse `json:"token"`
 	}
 
+	// TwoFactorEnrollResponse carries the new TOTP secret; OTPAuthURL is the
+	// same secret in the form authenticator apps import from a QR code.
+	// BackupCodes are shown once and stored only as hashes.
+	TwoFactorEnrollResponse struct {
+		Secret      string   `json:"secret"`
+		OTPAuthURL  string   `json:"otpauth_url"`
+		BackupCodes []string `json:"backup_codes"`
+	}
+
+	// BackupCodesResponse carries a freshly generated set of backup codes;
+	// previously issued codes stop working.
+	BackupCodesResponse struct {
+		BackupCodes []string `json:"backup_codes"`
+	}
+
+	TwoFactorVerifyRequest struct {
+		Code string `json:"code" binding:"required,len=6,numeric"`
+	}
+
+	// VerifyEmailRequest is bound from the query string so the link in the
+	// verification email works when opened directly.
+	VerifyEmailRequest struct {
+		Token string `form:"token" binding:"required,max=128"`
+	}
+
+	PasswordResetRequest struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+
+	ResetPasswordRequest struct {
+		Token    string `json:"token" binding:"required,max=128"`
+		Password string `json:"password" binding:"required,min=8"`
+	}
+
 	RefreshTokenRequest struct {
 		RefreshToken string `json:"refresh_token" binding:"required"`
 	}
@@ -39,9 +110,13 @@ type (
 		Token TokenResponse `json:"token"`
 	}
 
+	// TokenResponse follows the OAuth2 token response shape; ExpiresIn is
+	// the access token lifetime in seconds.
 	TokenResponse struct {
 		AccessToken  string `json:"access_token"`
 		RefreshToken string `json:"refresh_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int64  `json:"expires_in"`
 	}
 )
 
@@ -52,6 +127,154 @@ type (
 		Email string `json:"email"`
 	}
 
+	// CacheStatsResponse reports the authorizer's permission cache.
+	CacheStatsResponse struct {
+		Entries    int            `json:"entries"`
+		Hits       response.Int64 `json:"hits"`
+		Misses     response.Int64 `json:"misses"`
+		TTLSeconds float64        `json:"ttl_seconds"`
+	}
+
+	// RouteResponse describes a registered route and how it is
+	// authenticated: "token", "global" or "none".
+	RouteResponse struct {
+		Method  string `json:"method"`
+		Path    string `json:"path"`
+		Handler string `json:"handler"`
+		Auth    string `json:"auth"`
+	}
+
+	// PermissionsResponse lists the current user's effective permissions.
+	PermissionsResponse struct {
+		Permissions []string `json:"permissions"`
+	}
+
+	// PermissionCheckRequest names the permissions to check for the current
+	// user.
+	PermissionCheckRequest struct {
+		Permissions []string `json:"permissions" binding:"required,min=1,max=100,dive,required"`
+	}
+
+	// PermissionCheckResponse maps each checked permission to whether the
+	// current user holds it.
+	PermissionCheckResponse struct {
+		Permissions map[string]bool `json:"permissions"`
+	}
+
+	// CompareUsersRequest names the two users whose permissions are diffed.
+	CompareUsersRequest struct {
+		A string `form:"a" binding:"required,uuid"`
+		B string `form:"b" binding:"required,uuid"`
+	}
+
+	// PermissionDiffResponse splits two users' effective permissions into
+	// those only A holds, those only B holds and those both hold.
+	PermissionDiffResponse struct {
+		OnlyA  []string `json:"only_a"`
+		OnlyB  []string `json:"only_b"`
+		Shared []string `json:"shared"`
+	}
+
+	// SimulateRoleRequest names a role to try granting to a user.
+	SimulateRoleRequest struct {
+		UserID string `json:"user_id" binding:"required,uuid"`
+		Role   string `json:"role" binding:"required,max=100"`
+	}
+
+	// SimulateRoleResponse is what granting the role would change: the
+	// user's permissions now, after the grant, and the difference.
+	SimulateRoleResponse struct {
+		Current   []string `json:"current"`
+		Resulting []string `json:"resulting"`
+		Gained    []string `json:"gained"`
+	}
+
+	// RolesResponse lists the current user's roles.
+	RolesResponse struct {
+		Roles []string `json:"roles"`
+	}
+
+	// MaintenanceRequest turns maintenance mode on or off.
+	MaintenanceRequest struct {
+		Enabled *bool `json:"enabled" binding:"required"`
+	}
+
+	// MaintenanceResponse reports whether maintenance mode is on.
+	MaintenanceResponse struct {
+		Enabled bool `json:"enabled"`
+	}
+
+	// HeapStatsResponse is a snapshot of the Go heap.
+	HeapStatsResponse struct {
+		HeapAllocBytes response.Int64 `json:"heap_alloc_bytes"`
+		HeapInuseBytes response.Int64 `json:"heap_inuse_bytes"`
+		HeapObjects    response.Int64 `json:"heap_objects"`
+		NumGC          uint32         `json:"num_gc"`
+	}
+
+	// GCResponse reports the heap before and after a forced collection.
+	GCResponse struct {
+		Before     HeapStatsResponse `json:"before"`
+		After      HeapStatsResponse `json:"after"`
+		DurationMs float64           `json:"duration_ms"`
+	}
+
+	// CacheFlushResponse reports how many cached entries a flush cleared.
+	CacheFlushResponse struct {
+		Cleared int `json:"cleared"`
+	}
+
+	// UserExport is the non-sensitive subset of a user written by the export.
+	UserExport struct {
+		ID        string    `json:"id"`
+		Name      string    `json:"name"`
+		Email     string    `json:"email"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+
+	// ListUsersRequest is bound from the query string. Sort is a comma-
+	// separated list of columns, each prefixed with "-" for descending;
+	// Name and Email match substrings. Pagination "cursor" (implied by a
+	// Cursor) pages newest first by the next_cursor of the previous page
+	// instead of by Offset, and cannot be combined with Sort.
+	ListUsersRequest struct {
+		Sort       string `form:"sort" binding:"max=200"`
+		Limit      int    `form:"limit" binding:"omitempty,min=1,max=100"`
+		Offset     int    `form:"offset" binding:"omitempty,min=0"`
+		Pagination string `form:"pagination" binding:"omitempty,oneof=offset cursor"`
+		Cursor     string `form:"cursor" binding:"max=512"`
+		Name       string `form:"name" binding:"max=100"`
+		Email      string `form:"email" binding:"max=255"`
+		Status     string `form:"status" binding:"omitempty,oneof=active suspended"`
+	}
+
+	// UserListItem is one row of the users listing.
+	UserListItem struct {
+		ID        string    `json:"id"`
+		Name      string    `json:"name"`
+		Email     string    `json:"email"`
+		Status    string    `json:"status"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+
+	// ListUsersResponse is one page of users. NextCursor is set in cursor
+	// mode while more pages follow.
+	ListUsersResponse struct {
+		Users      []UserListItem `json:"users"`
+		NextCursor string         `json:"next_cursor,omitempty"`
+	}
+
+	// SessionResponse is one active session; Current marks the session the
+	// request was made with.
+	SessionResponse struct {
+		ID        string    `json:"id"`
+		UserAgent string    `json:"user_agent"`
+		IPAddress string    `json:"ip_address"`
+		CreatedAt time.Time `json:"created_at"`
+		ExpiresAt time.Time `json:"expires_at"`
+		Current   bool      `json:"current"`
+	}
+
 	UpdateUserRequest struct {
 		Name  string `json:"name" binding:"omitempty,min=2,max=100"`
 		Email string `json:"email" binding:"omitempty,email"`
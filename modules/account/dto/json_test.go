@@ -0,0 +1,58 @@
+package dto
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testLoginResponse = LoginResponse{
+	User: UserResponse{
+		ID:    "0b8f2c1e-6f0a-4a57-9c1d-2f4e5a6b7c8d",
+		Name:  `Ada "<Countess>" Lovelace & co`,
+		Email: "ada@example.com",
+	},
+	Token: TokenResponse{
+		AccessToken:  "eyJhbGciOiJIUzI1NiJ9.eyJ1c2VyX2lkIjoiNDIifQ.sig",
+		RefreshToken: "q1w2e3r4t5y6u7i8o9p0+/==",
+		TokenType:    "Bearer",
+		ExpiresIn:    900,
+	},
+}
+
+func TestAppendJSON_MatchesEncodingJSON(t *testing.T) {
+	values := []interface {
+		AppendJSON(dst []byte) []byte
+	}{
+		testLoginResponse,
+		testLoginResponse.User,
+		testLoginResponse.Token,
+		RefreshTokenResponse{Token: testLoginResponse.Token},
+		UserResponse{},
+		TokenResponse{ExpiresIn: -1},
+		UserResponse{Name: "line\nbreak   \xff"},
+	}
+
+	for _, v := range values {
+		want, err := json.Marshal(v)
+		require.NoError(t, err)
+		assert.Equal(t, string(want), string(v.AppendJSON(nil)))
+	}
+}
+
+func BenchmarkLoginResponse_EncodingJSON(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		_, _ = json.Marshal(testLoginResponse)
+	}
+}
+
+func BenchmarkLoginResponse_AppendJSON(b *testing.B) {
+	buf := make([]byte, 0, 512)
+	b.ReportAllocs()
+	for b.Loop() {
+		buf = testLoginResponse.AppendJSON(buf[:0])
+	}
+}
@@ -2,44 +2,104 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
+	"github.com/elskow/go-microservice-template/config"
 	"github.com/elskow/go-microservice-template/database/entities"
 	"github.com/elskow/go-microservice-template/pkg/database"
 	pkgerrors "github.com/elskow/go-microservice-template/pkg/errors"
+	"github.com/elskow/go-microservice-template/pkg/outbox"
+	"github.com/elskow/go-microservice-template/pkg/tenant"
+	"github.com/elskow/go-microservice-template/pkg/webhook"
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type Repository interface {
 	CreateUser(ctx context.Context, user entities.User) (entities.User, error)
 	GetUserByID(ctx context.Context, userID uuid.UUID) (entities.User, error)
 	GetUserByEmail(ctx context.Context, email string) (entities.User, error)
+	EmailExists(ctx context.Context, email string) (bool, error)
+	LockEmail(ctx context.Context, email string) error
 	UpdateUser(ctx context.Context, user entities.User) (entities.User, error)
 	DeleteUser(ctx context.Context, userID uuid.UUID) error
+	SetUserStatus(ctx context.Context, userID uuid.UUID, status string) error
+	StreamUsers(ctx context.Context, fn func(user entities.User) error) error
+	ListUsers(ctx context.Context, q database.ListQuery) ([]entities.User, error)
+	ListUsersAfter(ctx context.Context, q database.ListQuery, cursor string) ([]entities.User, string, error)
 
 	CreateRefreshToken(ctx context.Context, token entities.RefreshToken) (entities.RefreshToken, error)
 	GetRefreshTokenByToken(ctx context.Context, token string) (entities.RefreshToken, error)
 	UpdateRefreshToken(ctx context.Context, tokenID uuid.UUID, newToken string, expiresAt time.Time) error
 	DeleteRefreshToken(ctx context.Context, token string) error
 	DeleteRefreshTokensByUserID(ctx context.Context, userID uuid.UUID) error
+	ListRefreshTokensByUserID(ctx context.Context, userID uuid.UUID) ([]entities.RefreshToken, error)
+	DeleteRefreshTokenByID(ctx context.Context, userID, tokenID uuid.UUID) error
+
+	GetTwoFactor(ctx context.Context, userID uuid.UUID) (entities.UserTwoFactor, error)
+	SaveTwoFactorSecret(ctx context.Context, userID uuid.UUID, secret string) error
+	EnableTwoFactor(ctx context.Context, userID uuid.UUID) error
+	UseTwoFactorStep(ctx context.Context, userID uuid.UUID, step uint64) (bool, error)
+	ReplaceBackupCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error
+	ConsumeBackupCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error)
+
+	CreateAccountToken(ctx context.Context, token entities.AccountToken) error
+	ConsumeAccountToken(ctx context.Context, purpose, tokenHash string) (uuid.UUID, error)
+	UpdatePassword(ctx context.Context, userID uuid.UUID, passwordHash string) error
+	MarkEmailVerified(ctx context.Context, userID uuid.UUID) error
+	GetTokenVersion(ctx context.Context, userID uuid.UUID) (int, error)
+	BumpTokenVersion(ctx context.Context, userID uuid.UUID) (int, error)
+	RecordLogin(ctx context.Context, userID uuid.UUID) error
+
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+	EnqueueEvent(ctx context.Context, event webhook.Event) error
 }
 
+// ErrDuplicateEmail is returned by CreateUser and UpdateUser when the email
+// is already taken in the tenant.
+var ErrDuplicateEmail = errors.New("email already registered")
+
 type repository struct {
 	db *database.TracedDB
+	// readRetry applies to reads only; writes are not retried since they are
+	// not idempotent.
+	readRetry database.RetryPolicy
+	// emailScope is config.EmailScopeTenant or config.EmailScopeGlobal.
+	emailScope string
 }
 
 func NewRepository(db *database.TracedDB) Repository {
-	return &repository{db: db}
+	cfg := config.Get()
+	return &repository{
+		db: db,
+		readRetry: database.RetryPolicy{
+			Attempts: cfg.DBReadRetryAttempts,
+			Backoff:  cfg.DBReadRetryBackoff(),
+		},
+		emailScope: cfg.UserEmailScope,
+	}
 }
 
+// CreateUser, GetUserByID, GetUserByEmail and StreamUsers are scoped to the
+// tenant in ctx (tenant.DefaultID when none), so the same email may exist
+// once per tenant.
 func (r *repository) CreateUser(ctx context.Context, user entities.User) (entities.User, error) {
 	query := `
-		INSERT INTO users (id, name, email, password, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, NOW(), NOW())
-		RETURNING id, name, email, password, created_at, updated_at
+		INSERT INTO users (id, tenant_id, name, email, password, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+		RETURNING id, tenant_id, name, email, password, created_at, updated_at
 	`
 	var created entities.User
-	err := r.db.QueryRowxContext(ctx, query, user.ID, user.Name, user.Email, user.Password).StructScan(&created)
+	err := r.db.QueryRowxContext(ctx, query, user.ID, tenant.IDOrDefault(ctx), user.Name, user.Email, user.Password).StructScan(&created)
+	if database.IsUniqueViolation(err) {
+		return entities.User{}, pkgerrors.Wrap(ErrDuplicateEmail, "failed to create user")
+	}
 	if err != nil {
 		return entities.User{}, pkgerrors.Wrap(err, "failed to create user")
 	}
@@ -48,8 +108,10 @@ func (r *repository) CreateUser(ctx context.Context, user entities.User) (entiti
 
 func (r *repository) GetUserByID(ctx context.Context, userID uuid.UUID) (entities.User, error) {
 	var user entities.User
-	query := `SELECT id, name, email, password, created_at, updated_at FROM users WHERE id = $1`
-	err := r.db.GetContext(ctx, &user, query, userID)
+	query := `SELECT id, tenant_id, name, email, password, status, token_version, created_at, updated_at FROM users WHERE id = $1 AND tenant_id = $2`
+	err := r.readRetry.Do(ctx, func() error {
+		return r.db.GetContext(ctx, &user, query, userID, tenant.IDOrDefault(ctx))
+	})
 	if err != nil {
 		return entities.User{}, pkgerrors.Wrap(err, "failed to get user by id")
 	}
@@ -58,22 +120,65 @@ func (r *repository) GetUserByID(ctx context.Context, userID uuid.UUID) (entitie
 
 func (r *repository) GetUserByEmail(ctx context.Context, email string) (entities.User, error) {
 	var user entities.User
-	query := `SELECT id, name, email, password, created_at, updated_at FROM users WHERE email = $1`
-	err := r.db.GetContext(ctx, &user, query, email)
+	query := `SELECT id, tenant_id, name, email, password, status, token_version, created_at, updated_at FROM users WHERE email = $1 AND tenant_id = $2`
+	err := r.readRetry.Do(ctx, func() error {
+		return r.db.GetContext(ctx, &user, query, email, tenant.IDOrDefault(ctx))
+	})
 	if err != nil {
 		return entities.User{}, pkgerrors.Wrap(err, "failed to get user by email")
 	}
 	return user, nil
 }
 
+// EmailExists reports whether email is registered in the request tenant, or
+// in any tenant when USER_EMAIL_SCOPE is global. The database only enforces
+// uniqueness per tenant, so under the global scope call it after LockEmail
+// in the transaction that writes the email.
+func (r *repository) EmailExists(ctx context.Context, email string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM users WHERE email = $1 AND tenant_id = $2)`
+	args := []interface{}{email, tenant.IDOrDefault(ctx)}
+	if r.emailScope == config.EmailScopeGlobal {
+		query = `SELECT EXISTS (SELECT 1 FROM users WHERE email = $1)`
+		args = args[:1]
+	}
+
+	var exists bool
+	err := r.readRetry.Do(ctx, func() error {
+		return r.db.GetContext(ctx, &exists, query, args...)
+	})
+	if err != nil {
+		return false, pkgerrors.Wrap(err, "failed to check email")
+	}
+	return exists, nil
+}
+
+// LockEmail holds a transaction-scoped advisory lock on email when
+// USER_EMAIL_SCOPE is global, so concurrent registrations of one email in
+// different tenants cannot both pass EmailExists. Under the tenant scope
+// the unique index suffices and it does nothing.
+func (r *repository) LockEmail(ctx context.Context, email string) error {
+	if r.emailScope != config.EmailScopeGlobal {
+		return nil
+	}
+
+	query := `SELECT pg_advisory_xact_lock(hashtext('users.email:' || $1))`
+	if _, err := r.db.ExecContext(ctx, query, email); err != nil {
+		return pkgerrors.Wrap(err, "failed to lock email")
+	}
+	return nil
+}
+
 func (r *repository) UpdateUser(ctx context.Context, user entities.User) (entities.User, error) {
 	query := `
 		UPDATE users SET name = $1, email = $2, updated_at = NOW()
-		WHERE id = $3
+		WHERE id = $3 AND tenant_id = $4
 		RETURNING id, name, email, password, created_at, updated_at
 	`
 	var updated entities.User
-	err := r.db.QueryRowxContext(ctx, query, user.Name, user.Email, user.ID).StructScan(&updated)
+	err := r.db.QueryRowxContext(ctx, query, user.Name, user.Email, user.ID, tenant.IDOrDefault(ctx)).StructScan(&updated)
+	if database.IsUniqueViolation(err) {
+		return entities.User{}, pkgerrors.Wrap(ErrDuplicateEmail, "failed to update user")
+	}
 	if err != nil {
 		return entities.User{}, pkgerrors.Wrap(err, "failed to update user")
 	}
@@ -81,8 +186,8 @@ func (r *repository) UpdateUser(ctx context.Context, user entities.User) (entiti
 }
 
 func (r *repository) DeleteUser(ctx context.Context, userID uuid.UUID) error {
-	query := `DELETE FROM users WHERE id = $1`
-	result, err := r.db.ExecContext(ctx, query, userID)
+	query := `DELETE FROM users WHERE id = $1 AND tenant_id = $2`
+	result, err := r.db.ExecContext(ctx, query, userID, tenant.IDOrDefault(ctx))
 	if err != nil {
 		return pkgerrors.Wrap(err, "failed to delete user")
 	}
@@ -99,14 +204,141 @@ func (r *repository) DeleteUser(ctx context.Context, userID uuid.UUID) error {
 	return nil
 }
 
+// SetUserStatus stores the user's account status. It returns a wrapped
+// sql.ErrNoRows when the user does not exist.
+func (r *repository) SetUserStatus(ctx context.Context, userID uuid.UUID, status string) error {
+	query := `UPDATE users SET status = $1, updated_at = NOW() WHERE id = $2 AND tenant_id = $3`
+	result, err := r.db.ExecContext(ctx, query, status, userID, tenant.IDOrDefault(ctx))
+	if err != nil {
+		return pkgerrors.Wrap(err, "failed to set user status")
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return pkgerrors.Wrap(err, "failed to get rows affected")
+	}
+
+	if rows == 0 {
+		return pkgerrors.Wrap(sql.ErrNoRows, "user not found")
+	}
+
+	return nil
+}
+
+// StreamUsers calls fn for every user ordered by creation time without
+// loading the whole table. The password hash is not selected.
+func (r *repository) StreamUsers(ctx context.Context, fn func(user entities.User) error) error {
+	query := `SELECT id, tenant_id, name, email, created_at, updated_at FROM users WHERE tenant_id = $1 ORDER BY created_at, id`
+	err := r.db.StreamContext(ctx, func(rows *sqlx.Rows) error {
+		var user entities.User
+		if err := rows.StructScan(&user); err != nil {
+			return err
+		}
+		return fn(user)
+	}, query, tenant.IDOrDefault(ctx))
+	if err != nil {
+		return pkgerrors.Wrap(err, "failed to stream users")
+	}
+	return nil
+}
+
+// userList is the allowlist clients may filter and sort the users listing
+// by. The password hash is never selected.
+var userList = database.ListBuilder{
+	Base: `SELECT id, tenant_id, name, email, status, created_at, updated_at FROM users`,
+	Columns: map[string]string{
+		"name":       "name",
+		"email":      "email",
+		"status":     "status",
+		"created_at": "created_at",
+	},
+	Keyset:       []string{"created_at", "id"},
+	DefaultLimit: 20,
+	MaxLimit:     100,
+}
+
+// ListUsers returns one page of the tenant's users. A query naming a column
+// outside the allowlist fails with database.ErrInvalidListQuery.
+func (r *repository) ListUsers(ctx context.Context, q database.ListQuery) ([]entities.User, error) {
+	query, args, err := userList.Build(q, database.Filter{Column: "tenant_id", Value: tenant.IDOrDefault(ctx)})
+	if err != nil {
+		return nil, err
+	}
+
+	var users []entities.User
+	err = r.readRetry.Do(ctx, func() error {
+		users = nil
+		return r.db.SelectContext(ctx, &users, query, args...)
+	})
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to list users")
+	}
+	return users, nil
+}
+
+// UserCursor marks a position in the users listing by the last row seen.
+// Clients receive it as an opaque string from Encode.
+type UserCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+}
+
+// Encode returns the cursor as URL-safe base64 JSON.
+func (c UserCursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeUserCursor parses a cursor produced by Encode. Anything else fails
+// with database.ErrInvalidListQuery.
+func DecodeUserCursor(s string) (UserCursor, error) {
+	var c UserCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(b, &c)
+	}
+	if err != nil || c.ID == uuid.Nil || c.CreatedAt.IsZero() {
+		return UserCursor{}, fmt.Errorf("%w: malformed cursor", database.ErrInvalidListQuery)
+	}
+	return c, nil
+}
+
+// ListUsersAfter returns the page of the tenant's users following cursor,
+// newest first, and the cursor for the page after it, which is empty on the
+// last page. An empty cursor starts from the newest user. Unlike offsets,
+// the position holds steady while users are created or deleted.
+func (r *repository) ListUsersAfter(ctx context.Context, q database.ListQuery, cursor string) ([]entities.User, string, error) {
+	q.Keyset = true
+	if cursor != "" {
+		after, err := DecodeUserCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		q.After = []any{after.CreatedAt, after.ID}
+	}
+
+	users, err := r.ListUsers(ctx, q)
+	if err != nil {
+		return nil, "", err
+	}
+
+	limit := userList.Limit(q.Limit)
+	if len(users) <= limit {
+		return users, "", nil
+	}
+	users = users[:limit]
+	last := users[len(users)-1]
+	return users, UserCursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode(), nil
+}
+
 func (r *repository) CreateRefreshToken(ctx context.Context, token entities.RefreshToken) (entities.RefreshToken, error) {
 	query := `
-		INSERT INTO refresh_tokens (id, user_id, token, expires_at, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, NOW(), NOW())
-		RETURNING id, user_id, token, expires_at, created_at, updated_at
+		INSERT INTO refresh_tokens (id, user_id, token, expires_at, user_agent, ip_address, session_started_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW(), NOW())
+		RETURNING id, user_id, token, expires_at, user_agent, ip_address, session_started_at, created_at, updated_at
 	`
 	var created entities.RefreshToken
-	err := r.db.QueryRowxContext(ctx, query, token.ID, token.UserID, token.Token, token.ExpiresAt).StructScan(&created)
+	err := r.db.QueryRowxContext(ctx, query, token.ID, token.UserID, token.Token, token.ExpiresAt, token.UserAgent, token.IPAddress).StructScan(&created)
 	if err != nil {
 		return entities.RefreshToken{}, pkgerrors.Wrap(err, "failed to create refresh token")
 	}
@@ -115,12 +347,14 @@ func (r *repository) CreateRefreshToken(ctx context.Context, token entities.Refr
 
 func (r *repository) GetRefreshTokenByToken(ctx context.Context, token string) (entities.RefreshToken, error) {
 	query := `
-		SELECT id, user_id, token, expires_at, created_at, updated_at
+		SELECT id, user_id, token, expires_at, user_agent, ip_address, session_started_at, created_at, updated_at
 		FROM refresh_tokens
-		WHERE token = $1
+		WHERE token = $1 AND user_id IN (SELECT id FROM users WHERE tenant_id = $2)
 	`
 	var result entities.RefreshToken
-	err := r.db.GetContext(ctx, &result, query, token)
+	err := r.readRetry.Do(ctx, func() error {
+		return r.db.GetContext(ctx, &result, query, token, tenant.IDOrDefault(ctx))
+	})
 	if err != nil {
 		return entities.RefreshToken{}, pkgerrors.Wrap(err, "failed to get refresh token")
 	}
@@ -131,9 +365,9 @@ func (r *repository) UpdateRefreshToken(ctx context.Context, tokenID uuid.UUID,
 	query := `
 		UPDATE refresh_tokens
 		SET token = $1, expires_at = $2, updated_at = NOW()
-		WHERE id = $3
+		WHERE id = $3 AND user_id IN (SELECT id FROM users WHERE tenant_id = $4)
 	`
-	_, err := r.db.ExecContext(ctx, query, newToken, expiresAt, tokenID)
+	_, err := r.db.ExecContext(ctx, query, newToken, expiresAt, tokenID, tenant.IDOrDefault(ctx))
 	if err != nil {
 		return pkgerrors.Wrap(err, "failed to update refresh token")
 	}
@@ -141,8 +375,8 @@ func (r *repository) UpdateRefreshToken(ctx context.Context, tokenID uuid.UUID,
 }
 
 func (r *repository) DeleteRefreshToken(ctx context.Context, token string) error {
-	query := `DELETE FROM refresh_tokens WHERE token = $1`
-	_, err := r.db.ExecContext(ctx, query, token)
+	query := `DELETE FROM refresh_tokens WHERE token = $1 AND user_id IN (SELECT id FROM users WHERE tenant_id = $2)`
+	_, err := r.db.ExecContext(ctx, query, token, tenant.IDOrDefault(ctx))
 	if err != nil {
 		return pkgerrors.Wrap(err, "failed to delete refresh token")
 	}
@@ -150,10 +384,262 @@ func (r *repository) DeleteRefreshToken(ctx context.Context, token string) error
 }
 
 func (r *repository) DeleteRefreshTokensByUserID(ctx context.Context, userID uuid.UUID) error {
-	query := `DELETE FROM refresh_tokens WHERE user_id = $1`
-	_, err := r.db.ExecContext(ctx, query, userID)
+	query := `DELETE FROM refresh_tokens WHERE user_id = $1 AND user_id IN (SELECT id FROM users WHERE tenant_id = $2)`
+	_, err := r.db.ExecContext(ctx, query, userID, tenant.IDOrDefault(ctx))
 	if err != nil {
 		return pkgerrors.Wrap(err, "failed to delete refresh tokens by user id")
 	}
 	return nil
 }
+
+// ListRefreshTokensByUserID returns the user's unexpired refresh tokens, one
+// per active session, oldest first.
+func (r *repository) ListRefreshTokensByUserID(ctx context.Context, userID uuid.UUID) ([]entities.RefreshToken, error) {
+	query := `
+		SELECT id, user_id, token, expires_at, user_agent, ip_address, session_started_at, created_at, updated_at
+		FROM refresh_tokens
+		WHERE user_id = $1 AND expires_at > NOW()
+			AND user_id IN (SELECT id FROM users WHERE tenant_id = $2)
+		ORDER BY created_at, id
+	`
+	var tokens []entities.RefreshToken
+	err := r.readRetry.Do(ctx, func() error {
+		tokens = nil
+		return r.db.SelectContext(ctx, &tokens, query, userID, tenant.IDOrDefault(ctx))
+	})
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to list refresh tokens")
+	}
+	return tokens, nil
+}
+
+// DeleteRefreshTokenByID deletes one of the user's refresh tokens. It returns
+// a wrapped sql.ErrNoRows when the token does not exist or belongs to
+// another user.
+func (r *repository) DeleteRefreshTokenByID(ctx context.Context, userID, tokenID uuid.UUID) error {
+	query := `DELETE FROM refresh_tokens WHERE id = $1 AND user_id = $2 AND user_id IN (SELECT id FROM users WHERE tenant_id = $3)`
+	result, err := r.db.ExecContext(ctx, query, tokenID, userID, tenant.IDOrDefault(ctx))
+	if err != nil {
+		return pkgerrors.Wrap(err, "failed to delete refresh token")
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return pkgerrors.Wrap(err, "failed to get rows affected")
+	}
+
+	if rows == 0 {
+		return pkgerrors.Wrap(sql.ErrNoRows, "refresh token not found")
+	}
+
+	return nil
+}
+
+func (r *repository) GetTwoFactor(ctx context.Context, userID uuid.UUID) (entities.UserTwoFactor, error) {
+	query := `
+		SELECT user_id, secret, enabled, created_at, updated_at
+		FROM user_two_factor
+		WHERE user_id = $1
+	`
+	var result entities.UserTwoFactor
+	err := r.readRetry.Do(ctx, func() error {
+		return r.db.GetContext(ctx, &result, query, userID)
+	})
+	if err != nil {
+		return entities.UserTwoFactor{}, pkgerrors.Wrap(err, "failed to get two-factor settings")
+	}
+	return result, nil
+}
+
+// SaveTwoFactorSecret stores a new, not yet enabled, TOTP secret for the
+// user, replacing any pending enrollment.
+func (r *repository) SaveTwoFactorSecret(ctx context.Context, userID uuid.UUID, secret string) error {
+	query := `
+		INSERT INTO user_two_factor (user_id, secret, enabled, created_at, updated_at)
+		VALUES ($1, $2, FALSE, NOW(), NOW())
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, enabled = FALSE, updated_at = NOW()
+	`
+	_, err := r.db.ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		return pkgerrors.Wrap(err, "failed to save two-factor secret")
+	}
+	return nil
+}
+
+func (r *repository) EnableTwoFactor(ctx context.Context, userID uuid.UUID) error {
+	query := `UPDATE user_two_factor SET enabled = TRUE, updated_at = NOW() WHERE user_id = $1`
+	result, err := r.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return pkgerrors.Wrap(err, "failed to enable two-factor")
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return pkgerrors.Wrap(err, "failed to get rows affected")
+	}
+
+	if rows == 0 {
+		return pkgerrors.Wrap(sql.ErrNoRows, "two-factor enrollment not found")
+	}
+
+	return nil
+}
+
+// UseTwoFactorStep records step as the user's last accepted TOTP time step.
+// It reports false, leaving the record untouched, when a code for step or a
+// later one was already accepted, so each code works once.
+func (r *repository) UseTwoFactorStep(ctx context.Context, userID uuid.UUID, step uint64) (bool, error) {
+	query := `UPDATE user_two_factor SET last_used_step = $2, updated_at = NOW() WHERE user_id = $1 AND last_used_step < $2`
+	result, err := r.db.ExecContext(ctx, query, userID, int64(step))
+	if err != nil {
+		return false, pkgerrors.Wrap(err, "failed to record two-factor step")
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, pkgerrors.Wrap(err, "failed to get rows affected")
+	}
+	return rows == 1, nil
+}
+
+// ReplaceBackupCodes swaps the user's backup codes for codeHashes in a
+// single statement, so old codes never outlive the new set.
+func (r *repository) ReplaceBackupCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	query := `
+		WITH deleted AS (DELETE FROM user_backup_codes WHERE user_id = $1)
+		INSERT INTO user_backup_codes (user_id, code_hash, created_at)
+		SELECT $1, unnest($2::text[]), NOW()
+	`
+	_, err := r.db.ExecContext(ctx, query, userID, pq.Array(codeHashes))
+	if err != nil {
+		return pkgerrors.Wrap(err, "failed to replace backup codes")
+	}
+	return nil
+}
+
+// ConsumeBackupCode marks the matching unused code as used and reports
+// whether there was one; each code therefore succeeds at most once.
+func (r *repository) ConsumeBackupCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	query := `
+		UPDATE user_backup_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+	result, err := r.db.ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		return false, pkgerrors.Wrap(err, "failed to consume backup code")
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, pkgerrors.Wrap(err, "failed to get rows affected")
+	}
+
+	return rows > 0, nil
+}
+
+func (r *repository) CreateAccountToken(ctx context.Context, token entities.AccountToken) error {
+	query := `
+		INSERT INTO account_tokens (id, user_id, purpose, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+	`
+	_, err := r.db.ExecContext(ctx, query, token.ID, token.UserID, token.Purpose, token.TokenHash, token.ExpiresAt)
+	if err != nil {
+		return pkgerrors.Wrap(err, "failed to create account token")
+	}
+	return nil
+}
+
+// ConsumeAccountToken marks the unused, unexpired token with tokenHash as
+// used and returns its user. A token that does not exist, was issued for
+// another purpose, has expired or was already used yields sql.ErrNoRows.
+func (r *repository) ConsumeAccountToken(ctx context.Context, purpose, tokenHash string) (uuid.UUID, error) {
+	query := `
+		UPDATE account_tokens
+		SET used_at = NOW()
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id
+	`
+	var userID uuid.UUID
+	if err := r.db.GetContext(ctx, &userID, query, tokenHash, purpose); err != nil {
+		return uuid.Nil, pkgerrors.Wrap(err, "failed to consume account token")
+	}
+	return userID, nil
+}
+
+func (r *repository) UpdatePassword(ctx context.Context, userID uuid.UUID, passwordHash string) error {
+	query := `UPDATE users SET password = $1, updated_at = NOW() WHERE id = $2 AND tenant_id = $3`
+	result, err := r.db.ExecContext(ctx, query, passwordHash, userID, tenant.IDOrDefault(ctx))
+	if err != nil {
+		return pkgerrors.Wrap(err, "failed to update password")
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return pkgerrors.Wrap(err, "failed to get rows affected")
+	}
+
+	if rows == 0 {
+		return pkgerrors.Wrap(sql.ErrNoRows, "user not found")
+	}
+
+	return nil
+}
+
+func (r *repository) MarkEmailVerified(ctx context.Context, userID uuid.UUID) error {
+	query := `UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW()), updated_at = NOW() WHERE id = $1 AND tenant_id = $2`
+	if _, err := r.db.ExecContext(ctx, query, userID, tenant.IDOrDefault(ctx)); err != nil {
+		return pkgerrors.Wrap(err, "failed to mark email verified")
+	}
+	return nil
+}
+
+// GetTokenVersion returns the user's current access-token version, or
+// sql.ErrNoRows when the user does not exist.
+func (r *repository) GetTokenVersion(ctx context.Context, userID uuid.UUID) (int, error) {
+	query := `SELECT token_version FROM users WHERE id = $1 AND tenant_id = $2`
+	var version int
+	err := r.readRetry.Do(ctx, func() error {
+		return r.db.GetContext(ctx, &version, query, userID, tenant.IDOrDefault(ctx))
+	})
+	if err != nil {
+		return 0, pkgerrors.Wrap(err, "failed to get token version")
+	}
+	return version, nil
+}
+
+// BumpTokenVersion increments the user's access-token version, invalidating
+// every access token minted with an older one, and returns the new version.
+func (r *repository) BumpTokenVersion(ctx context.Context, userID uuid.UUID) (int, error) {
+	query := `UPDATE users SET token_version = token_version + 1, updated_at = NOW() WHERE id = $1 AND tenant_id = $2 RETURNING token_version`
+	var version int
+	if err := r.db.GetContext(ctx, &version, query, userID, tenant.IDOrDefault(ctx)); err != nil {
+		return 0, pkgerrors.Wrap(err, "failed to bump token version")
+	}
+	return version, nil
+}
+
+// RecordLogin stamps the user's last_login_at, which the authorizer cache
+// warm-up uses to pick recently active users.
+func (r *repository) RecordLogin(ctx context.Context, userID uuid.UUID) error {
+	query := `UPDATE users SET last_login_at = NOW() WHERE id = $1 AND tenant_id = $2`
+	if _, err := r.db.ExecContext(ctx, query, userID, tenant.IDOrDefault(ctx)); err != nil {
+		return pkgerrors.Wrap(err, "failed to record login")
+	}
+	return nil
+}
+
+// WithTx runs fn in a transaction that every repository call made with the
+// context passed to fn joins.
+func (r *repository) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return r.db.WithTx(ctx, fn)
+}
+
+// EnqueueEvent writes event to the outbox for the publisher to deliver. Call
+// it inside WithTx so the event commits with the change that raised it.
+func (r *repository) EnqueueEvent(ctx context.Context, event webhook.Event) error {
+	if err := outbox.Enqueue(ctx, r.db, event); err != nil {
+		return pkgerrors.Wrap(err, "failed to enqueue event")
+	}
+	return nil
+}
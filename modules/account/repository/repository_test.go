@@ -3,34 +3,26 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"net"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/elskow/go-microservice-template/config"
 	"github.com/elskow/go-microservice-template/database/entities"
 	"github.com/elskow/go-microservice-template/pkg/database"
+	"github.com/elskow/go-microservice-template/pkg/database/dbtest"
+	"github.com/elskow/go-microservice-template/pkg/tenant"
+	"github.com/elskow/go-microservice-template/pkg/webhook"
 	"github.com/google/uuid"
-	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-func setupMockDB(t *testing.T) (*database.TracedDB, sqlmock.Sqlmock, func()) {
-	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-	require.NoError(t, err)
-
-	sqlxDB := sqlx.NewDb(mockDB, "sqlmock")
-	tracedDB := &database.TracedDB{DB: sqlxDB}
-
-	cleanup := func() {
-		mockDB.Close()
-	}
-
-	return tracedDB, mock, cleanup
-}
-
 func TestNewRepository(t *testing.T) {
-	db, _, cleanup := setupMockDB(t)
+	db, _, cleanup := dbtest.NewMockTracedDBExact(t)
 	defer cleanup()
 
 	repo := NewRepository(db)
@@ -40,7 +32,7 @@ func TestNewRepository(t *testing.T) {
 }
 
 func TestRepository_CreateUser(t *testing.T) {
-	db, mock, cleanup := setupMockDB(t)
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
 	defer cleanup()
 
 	repo := NewRepository(db)
@@ -55,16 +47,16 @@ func TestRepository_CreateUser(t *testing.T) {
 	}
 
 	query := `
-		INSERT INTO users (id, name, email, password, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, NOW(), NOW())
-		RETURNING id, name, email, password, created_at, updated_at
+		INSERT INTO users (id, tenant_id, name, email, password, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+		RETURNING id, tenant_id, name, email, password, created_at, updated_at
 	`
 
 	rows := sqlmock.NewRows([]string{"id", "name", "email", "password", "created_at", "updated_at"}).
 		AddRow(user.ID, user.Name, user.Email, user.Password, time.Now(), time.Now())
 
 	mock.ExpectQuery(query).
-		WithArgs(user.ID, user.Name, user.Email, user.Password).
+		WithArgs(user.ID, tenant.DefaultID, user.Name, user.Email, user.Password).
 		WillReturnRows(rows)
 
 	created, err := repo.CreateUser(ctx, user)
@@ -77,7 +69,7 @@ func TestRepository_CreateUser(t *testing.T) {
 }
 
 func TestRepository_CreateUser_Error(t *testing.T) {
-	db, mock, cleanup := setupMockDB(t)
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
 	defer cleanup()
 
 	repo := NewRepository(db)
@@ -91,13 +83,13 @@ func TestRepository_CreateUser_Error(t *testing.T) {
 	}
 
 	query := `
-		INSERT INTO users (id, name, email, password, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, NOW(), NOW())
-		RETURNING id, name, email, password, created_at, updated_at
+		INSERT INTO users (id, tenant_id, name, email, password, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+		RETURNING id, tenant_id, name, email, password, created_at, updated_at
 	`
 
 	mock.ExpectQuery(query).
-		WithArgs(user.ID, user.Name, user.Email, user.Password).
+		WithArgs(user.ID, tenant.DefaultID, user.Name, user.Email, user.Password).
 		WillReturnError(sql.ErrConnDone)
 
 	_, err := repo.CreateUser(ctx, user)
@@ -108,7 +100,7 @@ func TestRepository_CreateUser_Error(t *testing.T) {
 }
 
 func TestRepository_GetUserByID(t *testing.T) {
-	db, mock, cleanup := setupMockDB(t)
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
 	defer cleanup()
 
 	repo := NewRepository(db)
@@ -127,14 +119,14 @@ func TestRepository_GetUserByID(t *testing.T) {
 		},
 	}
 
-	query := `SELECT id, name, email, password, created_at, updated_at FROM users WHERE id = $1`
+	query := `SELECT id, tenant_id, name, email, password, status, token_version, created_at, updated_at FROM users WHERE id = $1 AND tenant_id = $2`
 
-	rows := sqlmock.NewRows([]string{"id", "name", "email", "password", "created_at", "updated_at"}).
-		AddRow(expectedUser.ID, expectedUser.Name, expectedUser.Email, expectedUser.Password,
+	rows := sqlmock.NewRows([]string{"id", "name", "email", "password", "status", "created_at", "updated_at"}).
+		AddRow(expectedUser.ID, expectedUser.Name, expectedUser.Email, expectedUser.Password, entities.UserStatusActive,
 			expectedUser.Timestamp.CreatedAt, expectedUser.Timestamp.UpdatedAt)
 
 	mock.ExpectQuery(query).
-		WithArgs(userID).
+		WithArgs(userID, tenant.DefaultID).
 		WillReturnRows(rows)
 
 	user, err := repo.GetUserByID(ctx, userID)
@@ -147,17 +139,17 @@ func TestRepository_GetUserByID(t *testing.T) {
 }
 
 func TestRepository_GetUserByID_NotFound(t *testing.T) {
-	db, mock, cleanup := setupMockDB(t)
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
 	defer cleanup()
 
 	repo := NewRepository(db)
 	ctx := context.Background()
 
 	userID := uuid.New()
-	query := `SELECT id, name, email, password, created_at, updated_at FROM users WHERE id = $1`
+	query := `SELECT id, tenant_id, name, email, password, status, token_version, created_at, updated_at FROM users WHERE id = $1 AND tenant_id = $2`
 
 	mock.ExpectQuery(query).
-		WithArgs(userID).
+		WithArgs(userID, tenant.DefaultID).
 		WillReturnError(sql.ErrNoRows)
 
 	_, err := repo.GetUserByID(ctx, userID)
@@ -167,8 +159,60 @@ func TestRepository_GetUserByID_NotFound(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestRepository_GetUserByID_RetriesTransientError(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	now := time.Now()
+	query := `SELECT id, tenant_id, name, email, password, status, token_version, created_at, updated_at FROM users WHERE id = $1 AND tenant_id = $2`
+
+	mock.ExpectQuery(query).
+		WithArgs(userID, tenant.DefaultID).
+		WillReturnError(&net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET})
+
+	rows := sqlmock.NewRows([]string{"id", "name", "email", "password", "created_at", "updated_at"}).
+		AddRow(userID, "John Doe", "john@example.com", "hashedpassword", now, now)
+	mock.ExpectQuery(query).
+		WithArgs(userID, tenant.DefaultID).
+		WillReturnRows(rows)
+
+	user, err := repo.GetUserByID(ctx, userID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, userID, user.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_UpdateUser_DoesNotRetry(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	user := entities.User{ID: uuid.New(), Name: "John Doe", Email: "john@example.com"}
+	query := `
+		UPDATE users SET name = $1, email = $2, updated_at = NOW()
+		WHERE id = $3 AND tenant_id = $4
+		RETURNING id, name, email, password, created_at, updated_at
+	`
+
+	mock.ExpectQuery(query).
+		WithArgs(user.Name, user.Email, user.ID, tenant.DefaultID).
+		WillReturnError(&net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET})
+
+	_, err := repo.UpdateUser(ctx, user)
+
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestRepository_GetUserByEmail(t *testing.T) {
-	db, mock, cleanup := setupMockDB(t)
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
 	defer cleanup()
 
 	repo := NewRepository(db)
@@ -187,14 +231,14 @@ func TestRepository_GetUserByEmail(t *testing.T) {
 		},
 	}
 
-	query := `SELECT id, name, email, password, created_at, updated_at FROM users WHERE email = $1`
+	query := `SELECT id, tenant_id, name, email, password, status, token_version, created_at, updated_at FROM users WHERE email = $1 AND tenant_id = $2`
 
-	rows := sqlmock.NewRows([]string{"id", "name", "email", "password", "created_at", "updated_at"}).
-		AddRow(expectedUser.ID, expectedUser.Name, expectedUser.Email, expectedUser.Password,
+	rows := sqlmock.NewRows([]string{"id", "name", "email", "password", "status", "created_at", "updated_at"}).
+		AddRow(expectedUser.ID, expectedUser.Name, expectedUser.Email, expectedUser.Password, entities.UserStatusActive,
 			expectedUser.Timestamp.CreatedAt, expectedUser.Timestamp.UpdatedAt)
 
 	mock.ExpectQuery(query).
-		WithArgs(email).
+		WithArgs(email, tenant.DefaultID).
 		WillReturnRows(rows)
 
 	user, err := repo.GetUserByEmail(ctx, email)
@@ -204,8 +248,190 @@ func TestRepository_GetUserByEmail(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestRepository_SameEmailAcrossTenants(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	acme := tenant.WithTenantID(context.Background(), "acme")
+	globex := tenant.WithTenantID(context.Background(), "globex")
+
+	email := "john@example.com"
+	acmeUser := entities.User{ID: uuid.New(), Name: "John Acme", Email: email, Password: "hash"}
+	globexUser := entities.User{ID: uuid.New(), Name: "John Globex", Email: email, Password: "hash"}
+
+	insert := `
+		INSERT INTO users (id, tenant_id, name, email, password, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+		RETURNING id, tenant_id, name, email, password, created_at, updated_at
+	`
+	columns := []string{"id", "tenant_id", "name", "email", "password", "created_at", "updated_at"}
+	for _, tc := range []struct {
+		tenantID string
+		user     entities.User
+	}{{"acme", acmeUser}, {"globex", globexUser}} {
+		mock.ExpectQuery(insert).
+			WithArgs(tc.user.ID, tc.tenantID, tc.user.Name, email, tc.user.Password).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(tc.user.ID, tc.tenantID, tc.user.Name, email, tc.user.Password, time.Now(), time.Now()))
+	}
+
+	created, err := repo.CreateUser(acme, acmeUser)
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", created.TenantID)
+
+	created, err = repo.CreateUser(globex, globexUser)
+	assert.NoError(t, err)
+	assert.Equal(t, "globex", created.TenantID)
+
+	query := `SELECT id, tenant_id, name, email, password, status, token_version, created_at, updated_at FROM users WHERE email = $1 AND tenant_id = $2`
+	mock.ExpectQuery(query).
+		WithArgs(email, "acme").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tenant_id", "name", "email"}).
+			AddRow(acmeUser.ID, "acme", acmeUser.Name, email))
+	mock.ExpectQuery(query).
+		WithArgs(email, "globex").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tenant_id", "name", "email"}).
+			AddRow(globexUser.ID, "globex", globexUser.Name, email))
+
+	found, err := repo.GetUserByEmail(acme, email)
+	assert.NoError(t, err)
+	assert.Equal(t, acmeUser.ID, found.ID)
+
+	found, err = repo.GetUserByEmail(globex, email)
+	assert.NoError(t, err)
+	assert.Equal(t, globexUser.ID, found.ID)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_GetUserByID_OtherTenant(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	ctx := tenant.WithTenantID(context.Background(), "globex")
+
+	userID := uuid.New()
+	query := `SELECT id, tenant_id, name, email, password, status, token_version, created_at, updated_at FROM users WHERE id = $1 AND tenant_id = $2`
+
+	mock.ExpectQuery(query).
+		WithArgs(userID, "globex").
+		WillReturnError(sql.ErrNoRows)
+
+	_, err := repo.GetUserByID(ctx, userID)
+
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_SetUserStatus_OtherTenant(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	ctx := tenant.WithTenantID(context.Background(), "globex")
+
+	userID := uuid.New()
+	query := `UPDATE users SET status = $1, updated_at = NOW() WHERE id = $2 AND tenant_id = $3`
+
+	mock.ExpectExec(query).
+		WithArgs(entities.UserStatusSuspended, userID, "globex").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := repo.SetUserStatus(ctx, userID, entities.UserStatusSuspended)
+
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_EmailExists_TenantScope(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
+	defer cleanup()
+
+	repo := &repository{db: db, emailScope: config.EmailScopeTenant}
+	ctx := tenant.WithTenantID(context.Background(), "acme")
+
+	query := `SELECT EXISTS (SELECT 1 FROM users WHERE email = $1 AND tenant_id = $2)`
+	mock.ExpectQuery(query).
+		WithArgs("john@example.com", "acme").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	exists, err := repo.EmailExists(ctx, "john@example.com")
+
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_EmailExists_GlobalScope(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
+	defer cleanup()
+
+	repo := &repository{db: db, emailScope: config.EmailScopeGlobal}
+	ctx := tenant.WithTenantID(context.Background(), "acme")
+
+	// Taken by another tenant, which only the global scope sees
+	query := `SELECT EXISTS (SELECT 1 FROM users WHERE email = $1)`
+	mock.ExpectQuery(query).
+		WithArgs("john@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	exists, err := repo.EmailExists(ctx, "john@example.com")
+
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_LockEmail(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
+	defer cleanup()
+
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock(hashtext('users.email:' || $1))`).
+		WithArgs("john@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	global := &repository{db: db, emailScope: config.EmailScopeGlobal}
+	require.NoError(t, global.LockEmail(context.Background(), "john@example.com"))
+
+	// The tenant scope leans on the unique index and issues no query
+	scoped := &repository{db: db, emailScope: config.EmailScopeTenant}
+	require.NoError(t, scoped.LockEmail(context.Background(), "john@example.com"))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_CreateUser_DuplicateEmail(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDB(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+
+	mock.ExpectQuery(`INSERT INTO users`).
+		WillReturnError(&pq.Error{Code: "23505"})
+
+	_, err := repo.CreateUser(context.Background(), entities.User{ID: uuid.New(), Email: "john@example.com"})
+
+	assert.ErrorIs(t, err, ErrDuplicateEmail)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNewRepository_EmailScopeFromConfig(t *testing.T) {
+	t.Setenv("USER_EMAIL_SCOPE", "global")
+	config.Load()
+	t.Cleanup(config.Reset)
+
+	db, _, cleanup := dbtest.NewMockTracedDBExact(t)
+	defer cleanup()
+
+	repo := NewRepository(db).(*repository)
+
+	assert.Equal(t, config.EmailScopeGlobal, repo.emailScope)
+}
+
 func TestRepository_UpdateUser(t *testing.T) {
-	db, mock, cleanup := setupMockDB(t)
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
 	defer cleanup()
 
 	repo := NewRepository(db)
@@ -219,7 +445,7 @@ func TestRepository_UpdateUser(t *testing.T) {
 
 	query := `
 		UPDATE users SET name = $1, email = $2, updated_at = NOW()
-		WHERE id = $3
+		WHERE id = $3 AND tenant_id = $4
 		RETURNING id, name, email, password, created_at, updated_at
 	`
 
@@ -227,7 +453,7 @@ func TestRepository_UpdateUser(t *testing.T) {
 		AddRow(user.ID, user.Name, user.Email, "hashedpassword", time.Now(), time.Now())
 
 	mock.ExpectQuery(query).
-		WithArgs(user.Name, user.Email, user.ID).
+		WithArgs(user.Name, user.Email, user.ID, tenant.DefaultID).
 		WillReturnRows(rows)
 
 	updated, err := repo.UpdateUser(ctx, user)
@@ -239,17 +465,17 @@ func TestRepository_UpdateUser(t *testing.T) {
 }
 
 func TestRepository_DeleteUser(t *testing.T) {
-	db, mock, cleanup := setupMockDB(t)
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
 	defer cleanup()
 
 	repo := NewRepository(db)
 	ctx := context.Background()
 
 	userID := uuid.New()
-	query := `DELETE FROM users WHERE id = $1`
+	query := `DELETE FROM users WHERE id = $1 AND tenant_id = $2`
 
 	mock.ExpectExec(query).
-		WithArgs(userID).
+		WithArgs(userID, tenant.DefaultID).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
 	err := repo.DeleteUser(ctx, userID)
@@ -259,17 +485,17 @@ func TestRepository_DeleteUser(t *testing.T) {
 }
 
 func TestRepository_DeleteUser_NotFound(t *testing.T) {
-	db, mock, cleanup := setupMockDB(t)
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
 	defer cleanup()
 
 	repo := NewRepository(db)
 	ctx := context.Background()
 
 	userID := uuid.New()
-	query := `DELETE FROM users WHERE id = $1`
+	query := `DELETE FROM users WHERE id = $1 AND tenant_id = $2`
 
 	mock.ExpectExec(query).
-		WithArgs(userID).
+		WithArgs(userID, tenant.DefaultID).
 		WillReturnResult(sqlmock.NewResult(0, 0))
 
 	err := repo.DeleteUser(ctx, userID)
@@ -279,8 +505,190 @@ func TestRepository_DeleteUser_NotFound(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestRepository_SetUserStatus(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	query := `UPDATE users SET status = $1, updated_at = NOW() WHERE id = $2 AND tenant_id = $3`
+
+	mock.ExpectExec(query).
+		WithArgs(entities.UserStatusSuspended, userID, tenant.DefaultID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := repo.SetUserStatus(ctx, userID, entities.UserStatusSuspended)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_SetUserStatus_NotFound(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	query := `UPDATE users SET status = $1, updated_at = NOW() WHERE id = $2 AND tenant_id = $3`
+
+	mock.ExpectExec(query).
+		WithArgs(entities.UserStatusSuspended, userID, tenant.DefaultID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := repo.SetUserStatus(ctx, userID, entities.UserStatusSuspended)
+
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_StreamUsers(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	query := `SELECT id, tenant_id, name, email, created_at, updated_at FROM users WHERE tenant_id = $1 ORDER BY created_at, id`
+
+	first, second := uuid.New(), uuid.New()
+	rows := sqlmock.NewRows([]string{"id", "name", "email", "created_at", "updated_at"}).
+		AddRow(first, "Alice", "alice@example.com", time.Now(), time.Now()).
+		AddRow(second, "Bob", "bob@example.com", time.Now(), time.Now())
+
+	mock.ExpectQuery(query).WithArgs(tenant.DefaultID).WillReturnRows(rows)
+
+	var ids []uuid.UUID
+	err := repo.StreamUsers(ctx, func(user entities.User) error {
+		assert.Empty(t, user.Password)
+		ids = append(ids, user.ID)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{first, second}, ids)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_ListUsers(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	query := `SELECT id, tenant_id, name, email, status, created_at, updated_at FROM users WHERE tenant_id = $1 AND email ILIKE '%' || $2 || '%' ORDER BY name ASC LIMIT $3 OFFSET $4`
+
+	userID := uuid.New()
+	rows := sqlmock.NewRows([]string{"id", "name", "email", "status", "created_at", "updated_at"}).
+		AddRow(userID, "Alice", "alice@example.com", entities.UserStatusActive, time.Now(), time.Now())
+
+	mock.ExpectQuery(query).WithArgs(tenant.DefaultID, "example", 10, 20).WillReturnRows(rows)
+
+	users, err := repo.ListUsers(ctx, database.ListQuery{
+		Filters: []database.Filter{{Column: "email", Op: database.OpContains, Value: "example"}},
+		Sort:    []database.SortField{{Column: "name"}},
+		Limit:   10,
+		Offset:  20,
+	})
+
+	assert.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, userID, users[0].ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_ListUsers_UnknownColumn(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+
+	_, err := repo.ListUsers(context.Background(), database.ListQuery{
+		Sort: []database.SortField{{Column: "password"}},
+	})
+
+	assert.ErrorIs(t, err, database.ErrInvalidListQuery)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserCursor_RoundTrip(t *testing.T) {
+	cursor := UserCursor{CreatedAt: time.Date(2024, 5, 6, 7, 8, 9, 123456000, time.UTC), ID: uuid.New()}
+
+	decoded, err := DecodeUserCursor(cursor.Encode())
+
+	require.NoError(t, err)
+	assert.True(t, cursor.CreatedAt.Equal(decoded.CreatedAt))
+	assert.Equal(t, cursor.ID, decoded.ID)
+}
+
+func TestDecodeUserCursor_Malformed(t *testing.T) {
+	for _, input := range []string{"not base64!", "bm90IGpzb24", "e30"} {
+		_, err := DecodeUserCursor(input)
+		assert.ErrorIs(t, err, database.ErrInvalidListQuery, input)
+	}
+}
+
+func TestRepository_ListUsersAfter(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	after := UserCursor{CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), ID: uuid.New()}
+	query := `SELECT id, tenant_id, name, email, status, created_at, updated_at FROM users WHERE tenant_id = $1 AND status = $2 AND (created_at, id) < ($3, $4) ORDER BY created_at DESC, id DESC LIMIT $5`
+
+	newest := after.CreatedAt.Add(-time.Minute)
+	first, second, extra := uuid.New(), uuid.New(), uuid.New()
+	rows := sqlmock.NewRows([]string{"id", "name", "email", "created_at", "updated_at"}).
+		AddRow(first, "Alice", "alice@example.com", newest, newest).
+		AddRow(second, "Bob", "bob@example.com", newest.Add(-time.Minute), newest).
+		AddRow(extra, "Carol", "carol@example.com", newest.Add(-2*time.Minute), newest)
+
+	mock.ExpectQuery(query).
+		WithArgs(tenant.DefaultID, entities.UserStatusActive, after.CreatedAt, after.ID, 3).
+		WillReturnRows(rows)
+
+	users, next, err := repo.ListUsersAfter(ctx, database.ListQuery{
+		Filters: []database.Filter{{Column: "status", Op: database.OpEq, Value: entities.UserStatusActive}},
+		Limit:   2,
+	}, after.Encode())
+
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	assert.Equal(t, []uuid.UUID{first, second}, []uuid.UUID{users[0].ID, users[1].ID})
+	cursor, err := DecodeUserCursor(next)
+	require.NoError(t, err)
+	assert.Equal(t, second, cursor.ID)
+	assert.True(t, newest.Add(-time.Minute).Equal(cursor.CreatedAt))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_ListUsersAfter_LastPage(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+
+	query := `SELECT id, tenant_id, name, email, status, created_at, updated_at FROM users WHERE tenant_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2`
+	rows := sqlmock.NewRows([]string{"id", "name", "email", "created_at", "updated_at"}).
+		AddRow(uuid.New(), "Alice", "alice@example.com", time.Now(), time.Now())
+	mock.ExpectQuery(query).WithArgs(tenant.DefaultID, 21).WillReturnRows(rows)
+
+	users, next, err := repo.ListUsersAfter(context.Background(), database.ListQuery{}, "")
+
+	require.NoError(t, err)
+	assert.Len(t, users, 1)
+	assert.Empty(t, next)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestRepository_CreateRefreshToken(t *testing.T) {
-	db, mock, cleanup := setupMockDB(t)
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
 	defer cleanup()
 
 	repo := NewRepository(db)
@@ -291,19 +699,21 @@ func TestRepository_CreateRefreshToken(t *testing.T) {
 		UserID:    uuid.New(),
 		Token:     "refresh_token_string",
 		ExpiresAt: time.Now().Add(7 * 24 * time.Hour),
+		UserAgent: "Mozilla/5.0",
+		IPAddress: "203.0.113.7",
 	}
 
 	query := `
-		INSERT INTO refresh_tokens (id, user_id, token, expires_at, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, NOW(), NOW())
-		RETURNING id, user_id, token, expires_at, created_at, updated_at
+		INSERT INTO refresh_tokens (id, user_id, token, expires_at, user_agent, ip_address, session_started_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW(), NOW())
+		RETURNING id, user_id, token, expires_at, user_agent, ip_address, session_started_at, created_at, updated_at
 	`
 
-	rows := sqlmock.NewRows([]string{"id", "user_id", "token", "expires_at", "created_at", "updated_at"}).
-		AddRow(token.ID, token.UserID, token.Token, token.ExpiresAt, time.Now(), time.Now())
+	rows := sqlmock.NewRows([]string{"id", "user_id", "token", "expires_at", "user_agent", "ip_address", "session_started_at", "created_at", "updated_at"}).
+		AddRow(token.ID, token.UserID, token.Token, token.ExpiresAt, token.UserAgent, token.IPAddress, time.Now(), time.Now(), time.Now())
 
 	mock.ExpectQuery(query).
-		WithArgs(token.ID, token.UserID, token.Token, token.ExpiresAt).
+		WithArgs(token.ID, token.UserID, token.Token, token.ExpiresAt, token.UserAgent, token.IPAddress).
 		WillReturnRows(rows)
 
 	created, err := repo.CreateRefreshToken(ctx, token)
@@ -315,7 +725,7 @@ func TestRepository_CreateRefreshToken(t *testing.T) {
 }
 
 func TestRepository_GetRefreshTokenByToken(t *testing.T) {
-	db, mock, cleanup := setupMockDB(t)
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
 	defer cleanup()
 
 	repo := NewRepository(db)
@@ -335,17 +745,17 @@ func TestRepository_GetRefreshTokenByToken(t *testing.T) {
 	}
 
 	query := `
-		SELECT id, user_id, token, expires_at, created_at, updated_at
+		SELECT id, user_id, token, expires_at, user_agent, ip_address, session_started_at, created_at, updated_at
 		FROM refresh_tokens
-		WHERE token = $1
+		WHERE token = $1 AND user_id IN (SELECT id FROM users WHERE tenant_id = $2)
 	`
 
-	rows := sqlmock.NewRows([]string{"id", "user_id", "token", "expires_at", "created_at", "updated_at"}).
-		AddRow(expectedToken.ID, expectedToken.UserID, expectedToken.Token, expectedToken.ExpiresAt,
-			expectedToken.Timestamp.CreatedAt, expectedToken.Timestamp.UpdatedAt)
+	rows := sqlmock.NewRows([]string{"id", "user_id", "token", "expires_at", "user_agent", "ip_address", "session_started_at", "created_at", "updated_at"}).
+		AddRow(expectedToken.ID, expectedToken.UserID, expectedToken.Token, expectedToken.ExpiresAt, "", "",
+			expectedToken.Timestamp.CreatedAt, expectedToken.Timestamp.CreatedAt, expectedToken.Timestamp.UpdatedAt)
 
 	mock.ExpectQuery(query).
-		WithArgs(tokenString).
+		WithArgs(tokenString, tenant.DefaultID).
 		WillReturnRows(rows)
 
 	token, err := repo.GetRefreshTokenByToken(ctx, tokenString)
@@ -356,7 +766,7 @@ func TestRepository_GetRefreshTokenByToken(t *testing.T) {
 }
 
 func TestRepository_UpdateRefreshToken(t *testing.T) {
-	db, mock, cleanup := setupMockDB(t)
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
 	defer cleanup()
 
 	repo := NewRepository(db)
@@ -369,11 +779,11 @@ func TestRepository_UpdateRefreshToken(t *testing.T) {
 	query := `
 		UPDATE refresh_tokens
 		SET token = $1, expires_at = $2, updated_at = NOW()
-		WHERE id = $3
+		WHERE id = $3 AND user_id IN (SELECT id FROM users WHERE tenant_id = $4)
 	`
 
 	mock.ExpectExec(query).
-		WithArgs(newToken, expiresAt, tokenID).
+		WithArgs(newToken, expiresAt, tokenID, tenant.DefaultID).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
 	err := repo.UpdateRefreshToken(ctx, tokenID, newToken, expiresAt)
@@ -383,17 +793,17 @@ func TestRepository_UpdateRefreshToken(t *testing.T) {
 }
 
 func TestRepository_DeleteRefreshToken(t *testing.T) {
-	db, mock, cleanup := setupMockDB(t)
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
 	defer cleanup()
 
 	repo := NewRepository(db)
 	ctx := context.Background()
 
 	tokenString := "refresh_token_to_delete"
-	query := `DELETE FROM refresh_tokens WHERE token = $1`
+	query := `DELETE FROM refresh_tokens WHERE token = $1 AND user_id IN (SELECT id FROM users WHERE tenant_id = $2)`
 
 	mock.ExpectExec(query).
-		WithArgs(tokenString).
+		WithArgs(tokenString, tenant.DefaultID).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
 	err := repo.DeleteRefreshToken(ctx, tokenString)
@@ -403,17 +813,17 @@ func TestRepository_DeleteRefreshToken(t *testing.T) {
 }
 
 func TestRepository_DeleteRefreshTokensByUserID(t *testing.T) {
-	db, mock, cleanup := setupMockDB(t)
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
 	defer cleanup()
 
 	repo := NewRepository(db)
 	ctx := context.Background()
 
 	userID := uuid.New()
-	query := `DELETE FROM refresh_tokens WHERE user_id = $1`
+	query := `DELETE FROM refresh_tokens WHERE user_id = $1 AND user_id IN (SELECT id FROM users WHERE tenant_id = $2)`
 
 	mock.ExpectExec(query).
-		WithArgs(userID).
+		WithArgs(userID, tenant.DefaultID).
 		WillReturnResult(sqlmock.NewResult(0, 3))
 
 	err := repo.DeleteRefreshTokensByUserID(ctx, userID)
@@ -421,3 +831,332 @@ func TestRepository_DeleteRefreshTokensByUserID(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestRepository_ListRefreshTokensByUserID(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	first, second := uuid.New(), uuid.New()
+	now := time.Now()
+	query := `
+		SELECT id, user_id, token, expires_at, user_agent, ip_address, session_started_at, created_at, updated_at
+		FROM refresh_tokens
+		WHERE user_id = $1 AND expires_at > NOW()
+			AND user_id IN (SELECT id FROM users WHERE tenant_id = $2)
+		ORDER BY created_at, id
+	`
+
+	rows := sqlmock.NewRows([]string{"id", "user_id", "token", "expires_at", "user_agent", "ip_address", "session_started_at", "created_at", "updated_at"}).
+		AddRow(first, userID, "token-1", now.Add(time.Hour), "curl/8.0", "198.51.100.1", now, now, now).
+		AddRow(second, userID, "token-2", now.Add(time.Hour), "Mozilla/5.0", "198.51.100.2", now, now, now)
+
+	mock.ExpectQuery(query).
+		WithArgs(userID, tenant.DefaultID).
+		WillReturnRows(rows)
+
+	tokens, err := repo.ListRefreshTokensByUserID(ctx, userID)
+
+	require.NoError(t, err)
+	require.Len(t, tokens, 2)
+	assert.Equal(t, first, tokens[0].ID)
+	assert.Equal(t, "Mozilla/5.0", tokens[1].UserAgent)
+	assert.Equal(t, "198.51.100.2", tokens[1].IPAddress)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_DeleteRefreshTokenByID(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	userID, tokenID := uuid.New(), uuid.New()
+	query := `DELETE FROM refresh_tokens WHERE id = $1 AND user_id = $2 AND user_id IN (SELECT id FROM users WHERE tenant_id = $3)`
+
+	mock.ExpectExec(query).
+		WithArgs(tokenID, userID, tenant.DefaultID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(query).
+		WithArgs(tokenID, userID, tenant.DefaultID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, repo.DeleteRefreshTokenByID(ctx, userID, tokenID))
+
+	err := repo.DeleteRefreshTokenByID(ctx, userID, tokenID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_GetTwoFactor(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	now := time.Now()
+	query := `
+		SELECT user_id, secret, enabled, created_at, updated_at
+		FROM user_two_factor
+		WHERE user_id = $1
+	`
+
+	mock.ExpectQuery(query).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "secret", "enabled", "created_at", "updated_at"}).
+			AddRow(userID, "encrypted", true, now, now))
+
+	twoFactor, err := repo.GetTwoFactor(ctx, userID)
+
+	require.NoError(t, err)
+	assert.Equal(t, "encrypted", twoFactor.Secret)
+	assert.True(t, twoFactor.Enabled)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_SaveTwoFactorSecret(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	query := `
+		INSERT INTO user_two_factor (user_id, secret, enabled, created_at, updated_at)
+		VALUES ($1, $2, FALSE, NOW(), NOW())
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, enabled = FALSE, updated_at = NOW()
+	`
+
+	mock.ExpectExec(query).
+		WithArgs(userID, "encrypted").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := repo.SaveTwoFactorSecret(ctx, userID, "encrypted")
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_EnableTwoFactor_NotEnrolled(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	query := `UPDATE user_two_factor SET enabled = TRUE, updated_at = NOW() WHERE user_id = $1`
+
+	mock.ExpectExec(query).
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := repo.EnableTwoFactor(ctx, userID)
+
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_UseTwoFactorStep(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	query := `UPDATE user_two_factor SET last_used_step = $2, updated_at = NOW() WHERE user_id = $1 AND last_used_step < $2`
+
+	mock.ExpectExec(query).
+		WithArgs(userID, int64(100)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// Replaying the same step matches no row
+	mock.ExpectExec(query).
+		WithArgs(userID, int64(100)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	fresh, err := repo.UseTwoFactorStep(ctx, userID, 100)
+	require.NoError(t, err)
+	assert.True(t, fresh)
+
+	fresh, err = repo.UseTwoFactorStep(ctx, userID, 100)
+	require.NoError(t, err)
+	assert.False(t, fresh)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_ReplaceBackupCodes(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	hashes := []string{"hash-1", "hash-2"}
+	query := `
+		WITH deleted AS (DELETE FROM user_backup_codes WHERE user_id = $1)
+		INSERT INTO user_backup_codes (user_id, code_hash, created_at)
+		SELECT $1, unnest($2::text[]), NOW()
+	`
+
+	mock.ExpectExec(query).
+		WithArgs(userID, pq.Array(hashes)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	err := repo.ReplaceBackupCodes(ctx, userID, hashes)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_ConsumeBackupCode(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	query := `
+		UPDATE user_backup_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+
+	mock.ExpectExec(query).
+		WithArgs(userID, "hash").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(query).
+		WithArgs(userID, "hash").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	consumed, err := repo.ConsumeBackupCode(ctx, userID, "hash")
+	require.NoError(t, err)
+	assert.True(t, consumed)
+
+	consumed, err = repo.ConsumeBackupCode(ctx, userID, "hash")
+	require.NoError(t, err)
+	assert.False(t, consumed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_EnqueueEvent_JoinsTransaction(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDB(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	event := webhook.NewEvent(webhook.EventAccountLocked, uuid.NewString(), nil)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE users SET status`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO outbox`).
+		WithArgs(event.ID, webhook.EventAccountLocked, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := repo.WithTx(context.Background(), func(ctx context.Context) error {
+		if err := repo.SetUserStatus(ctx, uuid.New(), "suspended"); err != nil {
+			return err
+		}
+		return repo.EnqueueEvent(ctx, event)
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_ConsumeAccountToken(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	query := `
+		UPDATE account_tokens
+		SET used_at = NOW()
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id
+	`
+
+	mock.ExpectQuery(query).
+		WithArgs("hash", entities.AccountTokenPurposePasswordReset).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(userID))
+	mock.ExpectQuery(query).
+		WithArgs("hash", entities.AccountTokenPurposePasswordReset).
+		WillReturnError(sql.ErrNoRows)
+
+	got, err := repo.ConsumeAccountToken(ctx, entities.AccountTokenPurposePasswordReset, "hash")
+	require.NoError(t, err)
+	assert.Equal(t, userID, got)
+
+	_, err = repo.ConsumeAccountToken(ctx, entities.AccountTokenPurposePasswordReset, "hash")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_UpdatePassword_NotFound(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	userID := uuid.New()
+
+	mock.ExpectExec(`UPDATE users SET password = $1, updated_at = NOW() WHERE id = $2 AND tenant_id = $3`).
+		WithArgs("new-hash", userID, tenant.DefaultID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := repo.UpdatePassword(context.Background(), userID, "new-hash")
+
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_BumpTokenVersion(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	userID := uuid.New()
+
+	mock.ExpectQuery(`UPDATE users SET token_version = token_version + 1, updated_at = NOW() WHERE id = $1 AND tenant_id = $2 RETURNING token_version`).
+		WithArgs(userID, tenant.DefaultID).
+		WillReturnRows(sqlmock.NewRows([]string{"token_version"}).AddRow(3))
+	mock.ExpectQuery(`SELECT token_version FROM users WHERE id = $1 AND tenant_id = $2`).
+		WithArgs(userID, tenant.DefaultID).
+		WillReturnRows(sqlmock.NewRows([]string{"token_version"}).AddRow(3))
+
+	version, err := repo.BumpTokenVersion(context.Background(), userID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, version)
+
+	version, err = repo.GetTokenVersion(context.Background(), userID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_RecordLogin(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDBExact(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	userID := uuid.New()
+
+	mock.ExpectExec(`UPDATE users SET last_login_at = NOW() WHERE id = $1 AND tenant_id = $2`).
+		WithArgs(userID, tenant.DefaultID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, repo.RecordLogin(context.Background(), userID))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
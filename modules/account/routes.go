@@ -1,30 +1,101 @@
 package account
 
 import (
+	"github.com/elskow/go-microservice-template/config"
 	"github.com/elskow/go-microservice-template/middlewares"
-	"github.com/elskow/go-microservice-template/modules/account/controller"
-	"github.com/elskow/go-microservice-template/pkg/jwt"
+	pkgerrors "github.com/elskow/go-microservice-template/pkg/errors"
 	"github.com/gin-gonic/gin"
-	"github.com/samber/do"
 )
 
-func RegisterRoutes(server gin.IRouter, injector *do.Injector) {
-	ctrl := do.MustInvokeNamed[*controller.Controller](injector, "controller")
-	jwtService := do.MustInvokeNamed[jwt.Service](injector, "jwt-service")
+// RegisterRoutes mounts the account endpoints on server, failing when a
+// dependency cannot be resolved from the container.
+func RegisterRoutes(server gin.IRouter, container Container) error {
+	ctrl, err := container.Controller()
+	if err != nil {
+		return pkgerrors.Wrap(err, "resolve controller")
+	}
+	jwtService, err := container.JWTService()
+	if err != nil {
+		return pkgerrors.Wrap(err, "resolve jwt service")
+	}
+	verifier, err := container.CaptchaVerifier()
+	if err != nil {
+		return pkgerrors.Wrap(err, "resolve captcha verifier")
+	}
+	svc, err := container.Service()
+	if err != nil {
+		return pkgerrors.Wrap(err, "resolve service")
+	}
+	routeAuth, err := container.RouteAuth()
+	if err != nil {
+		return pkgerrors.Wrap(err, "resolve route auth")
+	}
+	router, err := container.Router()
+	if err != nil {
+		return pkgerrors.Wrap(err, "resolve router")
+	}
+	maintenance, err := container.MaintenanceMode()
+	if err != nil {
+		return pkgerrors.Wrap(err, "resolve maintenance mode")
+	}
+	collector, err := container.MetricsCollector()
+	if err != nil {
+		return pkgerrors.Wrap(err, "resolve metrics collector")
+	}
+
+	cfg := config.Get()
+	captchaCheck := middlewares.Captcha(verifier, cfg.CaptchaEnabled)
+	resetWindow := cfg.PasswordResetThrottleWindow()
+	retryAfter := middlewares.RetryAfterFormatFor(cfg.RetryAfterHTTPDate)
 
 	public := server.Group("/account")
 	{
-		public.POST("/register", ctrl.Register)
-		public.POST("/login", ctrl.Login)
+		public.POST("/register", captchaCheck, ctrl.Register)
+		public.POST("/login", middlewares.Throttle(cfg.LoginThrottleLimit, cfg.LoginThrottleWindow(), retryAfter), captchaCheck, ctrl.Login)
 		public.POST("/refresh", ctrl.RefreshToken)
+		public.GET("/verify", ctrl.VerifyEmail)
+		public.POST("/password/reset-request",
+			middlewares.Throttle(cfg.PasswordResetThrottleIPLimit, resetWindow, retryAfter),
+			middlewares.ThrottleByEmail(cfg.PasswordResetThrottleEmailLimit, resetWindow, retryAfter),
+			ctrl.RequestPasswordReset,
+		)
+		public.POST("/password/reset", ctrl.ResetPassword)
 	}
 
-	protected := server.Group("/account")
-	protected.Use(middlewares.Authenticate(jwtService))
+	authenticated := server.Group("/account", middlewares.Authenticate(jwtService, svc, cfg.AccessTokenCookie(), collector))
+	protected := routeAuth.Protect(authenticated)
 	{
 		protected.POST("/logout", ctrl.Logout)
 		protected.GET("/me", ctrl.Me)
+		protected.GET("/me/permissions", ctrl.MyPermissions)
+		protected.POST("/me/permissions/check", ctrl.CheckMyPermissions)
+		protected.GET("/me/roles", ctrl.MyRoles)
 		protected.PUT("/me", ctrl.UpdateUser)
 		protected.DELETE("/me", ctrl.DeleteUser)
+		protected.GET("/sessions", ctrl.ListSessions)
+		protected.GET("/events", ctrl.Events)
+		protected.DELETE("/sessions/:id", ctrl.RevokeSession)
+		protected.POST("/2fa/enroll", ctrl.EnrollTwoFactor)
+		protected.POST("/2fa/verify", ctrl.VerifyTwoFactor)
+		protected.POST("/2fa/backup-codes", ctrl.RegenerateBackupCodes)
+		protected.GET("/users", ctrl.ListUsers)
+		protected.GET("/users/export", ctrl.ExportUsers)
+		protected.GET("/users/compare", ctrl.CompareUsers)
+		protected.POST("/authz/simulate", ctrl.SimulateRole)
+		protected.POST("/users/:id/revoke-sessions", ctrl.RevokeSessions)
+	}
+
+	admin := routeAuth.Protect(authenticated.Group("/admin", middlewares.RequireAudience(cfg.AdminTokenAudience)))
+	{
+		admin.GET("/cache-stats", ctrl.CacheStats)
+		admin.POST("/cache/flush", ctrl.FlushCache)
+		admin.GET("/routes", ctrl.ListRoutes(router.Routes, routeAuth.Requirement))
+		admin.GET("/maintenance", ctrl.Maintenance(maintenance))
+		admin.PUT("/maintenance", ctrl.SetMaintenance(maintenance))
+		if cfg.ShouldEnableDebugGC() {
+			admin.POST("/debug/gc", ctrl.TriggerGC)
+		}
 	}
+
+	return nil
 }
@@ -0,0 +1,133 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/elskow/go-microservice-template/config"
+	"github.com/elskow/go-microservice-template/middlewares"
+	"github.com/elskow/go-microservice-template/modules/account/authorization"
+	"github.com/elskow/go-microservice-template/modules/account/controller"
+	"github.com/elskow/go-microservice-template/modules/account/service"
+	"github.com/elskow/go-microservice-template/pkg/apm"
+	"github.com/elskow/go-microservice-template/pkg/captcha"
+	"github.com/elskow/go-microservice-template/pkg/jwt"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeVerifier struct{}
+
+func (fakeVerifier) Verify(context.Context, string, string) error { return nil }
+
+// fakeContainer hands out fixed dependencies; a set err fails every lookup.
+type fakeContainer struct {
+	ctrl        *controller.Controller
+	router      *gin.Engine
+	routeAuth   *middlewares.RouteAuth
+	maintenance *middlewares.MaintenanceMode
+	err         error
+}
+
+func (f *fakeContainer) Controller() (*controller.Controller, error) { return f.ctrl, f.err }
+func (f *fakeContainer) JWTService() (jwt.Service, error)            { return nil, f.err }
+func (f *fakeContainer) CaptchaVerifier() (captcha.Verifier, error)  { return fakeVerifier{}, f.err }
+func (f *fakeContainer) Service() (service.Service, error)           { return nil, f.err }
+func (f *fakeContainer) RouteAuth() (*middlewares.RouteAuth, error)  { return f.routeAuth, f.err }
+func (f *fakeContainer) Router() (*gin.Engine, error)                { return f.router, f.err }
+func (f *fakeContainer) MaintenanceMode() (*middlewares.MaintenanceMode, error) {
+	return f.maintenance, f.err
+}
+func (f *fakeContainer) MetricsCollector() (*apm.MetricsCollector, error) { return nil, f.err }
+
+func newFakeContainer(t *testing.T) *fakeContainer {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctrl, err := controller.NewController(nil, logger, authorization.NewAuthorizer(nil, logger))
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	return &fakeContainer{
+		ctrl:        ctrl,
+		router:      gin.New(),
+		routeAuth:   middlewares.NewRouteAuth(),
+		maintenance: middlewares.NewMaintenanceMode(false, time.Minute, middlewares.RetryAfterSeconds, nil, nil),
+	}
+}
+
+func TestRegisterRoutes(t *testing.T) {
+	container := newFakeContainer(t)
+
+	require.NoError(t, RegisterRoutes(container.router.Group("/api"), container))
+
+	registered := make(map[string]bool)
+	for _, route := range container.router.Routes() {
+		registered[route.Method+" "+route.Path] = true
+	}
+	for _, route := range []string{
+		"POST /api/account/login",
+		"POST /api/account/register",
+		"GET /api/account/me",
+		"GET /api/account/users",
+		"GET /api/account/admin/routes",
+		"PUT /api/account/admin/maintenance",
+	} {
+		assert.True(t, registered[route], "missing route %s", route)
+	}
+	assert.Equal(t, middlewares.RouteAuthToken, container.routeAuth.Requirement("GET", "/api/account/me"))
+}
+
+func TestRegisterRoutes_MissingDependency(t *testing.T) {
+	container := newFakeContainer(t)
+	container.err = errors.New("not provided")
+
+	err := RegisterRoutes(container.router.Group("/api"), container)
+
+	assert.Error(t, err)
+	assert.Empty(t, container.router.Routes())
+}
+
+func TestRegisterRoutes_DebugGC(t *testing.T) {
+	tests := []struct {
+		name    string
+		appEnv  string
+		enabled string
+		want    bool
+	}{
+		{name: "enabled", appEnv: "development", enabled: "true", want: true},
+		{name: "disabled", appEnv: "development", enabled: "false", want: false},
+		{name: "production", appEnv: "production", enabled: "true", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("APP_ENV", tt.appEnv)
+			t.Setenv("DEBUG_GC_ENDPOINT", tt.enabled)
+			config.Load()
+			t.Cleanup(config.Reset)
+
+			container := newFakeContainer(t)
+			require.NoError(t, RegisterRoutes(container.router.Group("/api"), container))
+
+			registered := false
+			for _, route := range container.router.Routes() {
+				if route.Method == http.MethodPost && route.Path == "/api/account/admin/debug/gc" {
+					registered = true
+				}
+			}
+			assert.Equal(t, tt.want, registered)
+
+			if !tt.want {
+				w := httptest.NewRecorder()
+				container.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/account/admin/debug/gc", nil))
+				assert.Equal(t, http.StatusNotFound, w.Code)
+			}
+		})
+	}
+}
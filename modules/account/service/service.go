@@ -2,20 +2,43 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/base32"
+	"encoding/base64"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/elskow/go-microservice-template/config"
 	"github.com/elskow/go-microservice-template/database/entities"
 	"github.com/elskow/go-microservice-template/modules/account/authorization"
 	"github.com/elskow/go-microservice-template/modules/account/dto"
 	"github.com/elskow/go-microservice-template/modules/account/repository"
+	"github.com/elskow/go-microservice-template/pkg/cache"
 	"github.com/elskow/go-microservice-template/pkg/constants"
 	"github.com/elskow/go-microservice-template/pkg/database"
+	"github.com/elskow/go-microservice-template/pkg/email"
 	pkgerrors "github.com/elskow/go-microservice-template/pkg/errors"
+	"github.com/elskow/go-microservice-template/pkg/eventstream"
 	"github.com/elskow/go-microservice-template/pkg/helpers"
 	"github.com/elskow/go-microservice-template/pkg/jwt"
+	"github.com/elskow/go-microservice-template/pkg/tenant"
+	"github.com/elskow/go-microservice-template/pkg/totp"
 	"github.com/elskow/go-microservice-template/pkg/tracing"
+	"github.com/elskow/go-microservice-template/pkg/webhook"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+var (
+	tokenTypeAccessAttr  = metric.WithAttributes(attribute.String("type", "access"))
+	tokenTypeRefreshAttr = metric.WithAttributes(attribute.String("type", "refresh"))
 )
 
 type Service interface {
@@ -27,39 +50,193 @@ type Service interface {
 	GetUserByID(ctx context.Context, userID string) (dto.UserResponse, error)
 	UpdateUser(ctx context.Context, userID string, req dto.UpdateUserRequest) (dto.UserResponse, error)
 	DeleteUser(ctx context.Context, userID string) error
+	ExportUsers(ctx context.Context, fn func(user dto.UserExport) error) error
+	ListUsers(ctx context.Context, req dto.ListUsersRequest) (dto.ListUsersResponse, error)
+	RevokeSessions(ctx context.Context, userID string) error
+	TokenVersion(ctx context.Context, userID string) (int, error)
+	ListSessions(ctx context.Context, userID, currentSessionID string) ([]dto.SessionResponse, error)
+	RevokeSession(ctx context.Context, userID, sessionID string) error
+
+	EnrollTwoFactor(ctx context.Context, userID string) (dto.TwoFactorEnrollResponse, error)
+	VerifyTwoFactor(ctx context.Context, userID string, req dto.TwoFactorVerifyRequest) error
+	RegenerateBackupCodes(ctx context.Context, userID string) (dto.BackupCodesResponse, error)
+	SetUserStatus(ctx context.Context, userID string, status string) error
+
+	VerifyEmail(ctx context.Context, req dto.VerifyEmailRequest) error
+	RequestPasswordReset(ctx context.Context, req dto.PasswordResetRequest) error
+	ResetPassword(ctx context.Context, req dto.ResetPasswordRequest) error
+
+	SubscribeEvents(userID string) (<-chan webhook.Event, func())
 }
 
 type service struct {
-	repo       repository.Repository
-	jwtService jwt.Service
-	db         *database.TracedDB
-	authorizer *authorization.Authorizer
+	repo         repository.Repository
+	jwtService   jwt.Service
+	db           *database.TracedDB
+	authorizer   *authorization.Authorizer
+	tokensIssued metric.Int64Counter
+	// sessionMaxAge is the absolute lifetime of a refresh-token chain; zero
+	// lets rotation extend a session indefinitely.
+	sessionMaxAge time.Duration
+	// twoFactorKey encrypts TOTP secrets at rest; twoFactorIssuer labels
+	// the account in authenticator apps.
+	twoFactorKey    string
+	twoFactorIssuer string
+	// mailer sends verification, password reset and notification emails
+	// rendered from templates.
+	mailer    email.Sender
+	templates *email.Templates
+	// mailing tracks emails sent in the background so Shutdown can wait
+	// for them.
+	mailing sync.WaitGroup
+	// publicURL turns a path into the absolute URL used in emailed links.
+	publicURL func(path string) string
+	// tokenVersions caches GetTokenVersion so most authenticated requests
+	// skip the database; entries are dropped when a version is bumped.
+	tokenVersions *cache.Cache[uuid.UUID, int]
+	// users caches GetUserByID results per tenant when enabled; every
+	// write to the user's row drops the entry.
+	users *cache.Cache[userCacheKey, dto.UserResponse]
+	// events streams published security events to the user's live
+	// connections.
+	events *eventstream.Broker
 }
 
-func NewService(repo repository.Repository, jwtService jwt.Service, db *database.TracedDB, authorizer *authorization.Authorizer) Service {
+func NewService(repo repository.Repository, jwtService jwt.Service, db *database.TracedDB, authorizer *authorization.Authorizer, mailer email.Sender, templates *email.Templates, events *eventstream.Broker) Service {
+	cfg := config.Get()
 	return &service{
-		repo:       repo,
-		jwtService: jwtService,
-		db:         db,
-		authorizer: authorizer,
+		repo:         repo,
+		jwtService:   jwtService,
+		db:           db,
+		authorizer:   authorizer,
+		tokensIssued: newTokensIssuedCounter(otel.Meter("account/service")),
+
+		sessionMaxAge:   cfg.RefreshSessionMaxAge(),
+		twoFactorKey:    cfg.TwoFactorKey(),
+		twoFactorIssuer: cfg.TwoFactorIssuerName(),
+		mailer:          mailer,
+		templates:       templates,
+		publicURL:       cfg.PublicURL,
+		tokenVersions:   cache.New[uuid.UUID, int](cfg.TokenVersionCacheTTL()),
+		users:           cache.New[userCacheKey, dto.UserResponse](cfg.UserCacheTTL()),
+		events:          events,
 	}
 }
 
-func (s *service) Register(ctx context.Context, req dto.RegisterRequest) (dto.RegisterResponse, error) {
-	ctx, span := tracing.Auto(ctx, attribute.String(constants.AttrKeyEmail, req.Email))
-	defer span.End()
+func newTokensIssuedCounter(meter metric.Meter) metric.Int64Counter {
+	counter, err := meter.Int64Counter(
+		"auth_tokens_issued_total",
+		metric.WithDescription("Total number of access and refresh tokens issued"),
+	)
+	if err != nil {
+		return noop.Int64Counter{}
+	}
+	return counter
+}
 
-	_, err := s.repo.GetUserByEmail(ctx, req.Email)
-	if err == nil {
-		pkgerrors.RecordError(span.Span, dto.ErrEmailAlreadyExists)
-		return dto.RegisterResponse{}, dto.ErrEmailAlreadyExists
+// userCacheKey scopes cached users to the tenant they were read under, as
+// the repository scopes its lookups.
+type userCacheKey struct {
+	tenantID string
+	userID   uuid.UUID
+}
+
+func userKey(ctx context.Context, userID uuid.UUID) userCacheKey {
+	return userCacheKey{tenantID: tenant.IDOrDefault(ctx), userID: userID}
+}
+
+// invalidateUser drops the cached profile of userID in ctx's tenant.
+func (s *service) invalidateUser(ctx context.Context, userID uuid.UUID) {
+	s.users.Delete(userKey(ctx, userID))
+}
+
+// recordTokensIssued counts one access/refresh token pair handed to a client.
+func (s *service) recordTokensIssued(ctx context.Context, flow, userID string) {
+	s.tokensIssued.Add(ctx, 1, tokenTypeAccessAttr)
+	s.tokensIssued.Add(ctx, 1, tokenTypeRefreshAttr)
+	slog.DebugContext(ctx, "tokens issued", "flow", flow, constants.AttrKeyUserID, userID)
+}
+
+// rolePrecedence orders roles from most to least privileged; the first one a
+// user holds is the role carried in their access token.
+var rolePrecedence = []string{"admin", "moderator", "user"}
+
+// currentRole returns the most privileged role assigned to userID, falling
+// back to "user" when none is assigned.
+func (s *service) currentRole(ctx context.Context, userID string) (string, error) {
+	roles, err := s.authorizer.GetUserRoles(ctx, userID)
+	if err != nil {
+		return "", err
 	}
-	if !pkgerrors.Is(err, sql.ErrNoRows) {
-		err = pkgerrors.Wrap(err, "failed to check existing email")
-		pkgerrors.RecordError(span.Span, err)
-		return dto.RegisterResponse{}, err
+
+	for _, candidate := range rolePrecedence {
+		for _, role := range roles {
+			if role == candidate {
+				return role, nil
+			}
+		}
 	}
 
+	if len(roles) > 0 {
+		return roles[0], nil
+	}
+	return "user", nil
+}
+
+// mintAccessToken issues an access token for sessionID carrying the user's
+// current role and the request tenant, so a token minted at register, login
+// or refresh reflects the same role assignments and tenant.
+func (s *service) mintAccessToken(ctx context.Context, userID, sessionID string, tokenVersion int) (string, error) {
+	role, err := s.currentRole(ctx, userID)
+	if err != nil {
+		return "", pkgerrors.Wrap(err, "failed to get user role")
+	}
+
+	accessToken, err := s.jwtService.GenerateSessionAccessToken(userID, role, tenant.IDOrDefault(ctx), sessionID, tokenVersion)
+	if err != nil {
+		return "", pkgerrors.Wrap(err, "failed to generate access token")
+	}
+	return accessToken, nil
+}
+
+func (s *service) tokenResponse(accessToken, refreshToken string) dto.TokenResponse {
+	return dto.TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    dto.TokenTypeBearer,
+		ExpiresIn:    int64(s.jwtService.AccessTokenExpiry().Seconds()),
+	}
+}
+
+// claimEmail fails with dto.ErrEmailAlreadyExists when email is taken in
+// the scope USER_EMAIL_SCOPE selects. Call it inside WithTx before writing
+// the email: under the global scope the lock it takes is what keeps two
+// tenants from registering the same address concurrently.
+func (s *service) claimEmail(ctx context.Context, email string) error {
+	if err := s.repo.LockEmail(ctx, email); err != nil {
+		return err
+	}
+
+	exists, err := s.repo.EmailExists(ctx, email)
+	if err != nil {
+		return pkgerrors.Wrap(err, "failed to check existing email")
+	}
+	if exists {
+		return dto.ErrEmailAlreadyExists
+	}
+	return nil
+}
+
+// isDuplicateEmail reports whether err says the email was taken, either by
+// claimEmail or by the unique index when a concurrent write won the race.
+func isDuplicateEmail(err error) bool {
+	return pkgerrors.Is(err, dto.ErrEmailAlreadyExists) || pkgerrors.Is(err, repository.ErrDuplicateEmail)
+}
+
+func (s *service) Register(ctx context.Context, req dto.RegisterRequest) (dto.RegisterResponse, error) {
+	ctx, span := tracing.Auto(ctx, attribute.String(constants.AttrKeyEmail, req.Email))
+	defer span.End()
+
 	hashedPassword, err := helpers.HashPassword(req.Password)
 	if err != nil {
 		err = pkgerrors.Wrap(err, "failed to hash password")
@@ -74,21 +251,44 @@ func (s *service) Register(ctx context.Context, req dto.RegisterRequest) (dto.Re
 		Password: hashedPassword,
 	}
 
-	created, err := s.repo.CreateUser(ctx, user)
+	var created entities.User
+	err = s.repo.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.claimEmail(ctx, req.Email); err != nil {
+			return err
+		}
+
+		var err error
+		created, err = s.repo.CreateUser(ctx, user)
+		if err != nil {
+			return err
+		}
+		return s.repo.EnqueueEvent(ctx, webhook.NewEvent(webhook.EventUserRegistered, created.ID.String(), nil))
+	})
+	if isDuplicateEmail(err) {
+		pkgerrors.RecordError(span.Span, dto.ErrEmailAlreadyExists)
+		return dto.RegisterResponse{}, dto.ErrEmailAlreadyExists
+	}
 	if err != nil {
 		err = pkgerrors.Wrap(err, "failed to create user")
 		pkgerrors.RecordError(span.Span, err)
 		return dto.RegisterResponse{}, err
 	}
 
-	if err := s.authorizer.AssignRole(ctx, created.ID.String(), "user"); err != nil {
+	if err := s.authorizer.AssignRole(ctx, created.ID.String(), authorization.DefaultRole); err != nil {
 		err = pkgerrors.Wrap(err, "failed to assign default role")
 		pkgerrors.RecordError(span.Span, err)
 	}
 
-	accessToken, err := s.jwtService.GenerateAccessToken(created.ID.String(), "user")
+	// The account is usable without verification, so a mail failure only
+	// means the user has to request another link.
+	if err := s.sendVerificationEmail(ctx, created); err != nil {
+		pkgerrors.RecordError(span.Span, err)
+		slog.WarnContext(ctx, "failed to send verification email", constants.AttrKeyUserID, created.ID.String(), "error", err)
+	}
+
+	sessionID := uuid.New()
+	accessToken, err := s.mintAccessToken(ctx, created.ID.String(), sessionID.String(), created.TokenVersion)
 	if err != nil {
-		err = pkgerrors.Wrap(err, "failed to generate access token")
 		pkgerrors.RecordError(span.Span, err)
 		return dto.RegisterResponse{}, err
 	}
@@ -101,10 +301,12 @@ func (s *service) Register(ctx context.Context, req dto.RegisterRequest) (dto.Re
 	}
 
 	refreshToken := entities.RefreshToken{
-		ID:        uuid.New(),
+		ID:        sessionID,
 		UserID:    created.ID,
 		Token:     refreshTokenString,
 		ExpiresAt: expiresAt,
+		UserAgent: req.Client.UserAgent,
+		IPAddress: req.Client.IPAddress,
 	}
 
 	_, err = s.repo.CreateRefreshToken(ctx, refreshToken)
@@ -114,16 +316,15 @@ func (s *service) Register(ctx context.Context, req dto.RegisterRequest) (dto.Re
 		return dto.RegisterResponse{}, err
 	}
 
+	s.recordTokensIssued(ctx, "register", created.ID.String())
+
 	return dto.RegisterResponse{
 		User: dto.UserResponse{
 			ID:    created.ID.String(),
 			Name:  created.Name,
 			Email: created.Email,
 		},
-		Token: dto.TokenResponse{
-			AccessToken:  accessToken,
-			RefreshToken: refreshTokenString,
-		},
+		Token: s.tokenResponse(accessToken, refreshTokenString),
 	}, nil
 }
 
@@ -147,9 +348,21 @@ func (s *service) Login(ctx context.Context, req dto.LoginRequest) (dto.LoginRes
 		return dto.LoginResponse{}, dto.ErrInvalidCredentials
 	}
 
-	accessToken, err := s.jwtService.GenerateAccessToken(user.ID.String(), "user")
+	if user.IsSuspended() {
+		pkgerrors.RecordError(span.Span, dto.ErrAccountSuspended)
+		return dto.LoginResponse{}, dto.ErrAccountSuspended
+	}
+
+	if err := s.checkTwoFactor(ctx, user.ID, req.TOTPCode, req.BackupCode); err != nil {
+		pkgerrors.RecordError(span.Span, err)
+		return dto.LoginResponse{}, err
+	}
+
+	newDevice := s.isNewDevice(ctx, user.ID, req.Client)
+
+	sessionID := uuid.New()
+	accessToken, err := s.mintAccessToken(ctx, user.ID.String(), sessionID.String(), user.TokenVersion)
 	if err != nil {
-		err = pkgerrors.Wrap(err, "failed to generate access token")
 		pkgerrors.RecordError(span.Span, err)
 		return dto.LoginResponse{}, err
 	}
@@ -162,18 +375,38 @@ func (s *service) Login(ctx context.Context, req dto.LoginRequest) (dto.LoginRes
 	}
 
 	refreshToken := entities.RefreshToken{
-		ID:        uuid.New(),
+		ID:        sessionID,
 		UserID:    user.ID,
 		Token:     refreshTokenString,
 		ExpiresAt: expiresAt,
+		UserAgent: req.Client.UserAgent,
+		IPAddress: req.Client.IPAddress,
 	}
 
-	_, err = s.repo.CreateRefreshToken(ctx, refreshToken)
+	err = s.repo.WithTx(ctx, func(ctx context.Context) error {
+		if _, err := s.repo.CreateRefreshToken(ctx, refreshToken); err != nil {
+			return err
+		}
+		if err := s.repo.RecordLogin(ctx, user.ID); err != nil {
+			return err
+		}
+		if !newDevice {
+			return nil
+		}
+		return s.repo.EnqueueEvent(ctx, webhook.NewEvent(webhook.EventNewDeviceLogin, user.ID.String(), map[string]string{
+			"session_id": sessionID.String(),
+			"user_agent": req.Client.UserAgent,
+			"ip_address": req.Client.IPAddress,
+		}))
+	})
 	if err != nil {
 		err = pkgerrors.Wrap(err, "failed to create refresh token")
 		pkgerrors.RecordError(span.Span, err)
 		return dto.LoginResponse{}, err
 	}
+	s.invalidateUser(ctx, user.ID)
+
+	s.recordTokensIssued(ctx, "login", user.ID.String())
 
 	return dto.LoginResponse{
 		User: dto.UserResponse{
@@ -181,10 +414,7 @@ func (s *service) Login(ctx context.Context, req dto.LoginRequest) (dto.LoginRes
 			Name:  user.Name,
 			Email: user.Email,
 		},
-		Token: dto.TokenResponse{
-			AccessToken:  accessToken,
-			RefreshToken: refreshTokenString,
-		},
+		Token: s.tokenResponse(accessToken, refreshTokenString),
 	}, nil
 }
 
@@ -208,9 +438,36 @@ func (s *service) RefreshToken(ctx context.Context, req dto.RefreshTokenRequest)
 		return dto.RefreshTokenResponse{}, dto.ErrTokenNotFound
 	}
 
-	accessToken, err := s.jwtService.GenerateAccessToken(refreshToken.UserID.String(), "user")
+	user, err := s.repo.GetUserByID(ctx, refreshToken.UserID)
+	if err != nil {
+		if pkgerrors.Is(err, sql.ErrNoRows) {
+			pkgerrors.RecordError(span.Span, dto.ErrTokenNotFound)
+			return dto.RefreshTokenResponse{}, dto.ErrTokenNotFound
+		}
+		err = pkgerrors.Wrap(err, "failed to get user by id")
+		pkgerrors.RecordError(span.Span, err)
+		return dto.RefreshTokenResponse{}, err
+	}
+	if user.IsSuspended() {
+		pkgerrors.RecordError(span.Span, dto.ErrAccountSuspended)
+		return dto.RefreshTokenResponse{}, dto.ErrAccountSuspended
+	}
+
+	var sessionEnd time.Time
+	if s.sessionMaxAge > 0 {
+		sessionEnd = refreshToken.SessionStartedAt.Add(s.sessionMaxAge)
+		if !time.Now().Before(sessionEnd) {
+			if err := s.repo.DeleteRefreshToken(ctx, refreshToken.Token); err != nil {
+				slog.WarnContext(ctx, "failed to delete refresh token of expired session", "error", err)
+			}
+			pkgerrors.RecordError(span.Span, dto.ErrSessionExpired)
+			return dto.RefreshTokenResponse{}, dto.ErrSessionExpired
+		}
+	}
+
+	// The role may have changed since login, so mint with the current one
+	accessToken, err := s.mintAccessToken(ctx, refreshToken.UserID.String(), refreshToken.ID.String(), user.TokenVersion)
 	if err != nil {
-		err = pkgerrors.Wrap(err, "failed to generate access token")
 		pkgerrors.RecordError(span.Span, err)
 		return dto.RefreshTokenResponse{}, err
 	}
@@ -221,6 +478,9 @@ func (s *service) RefreshToken(ctx context.Context, req dto.RefreshTokenRequest)
 		pkgerrors.RecordError(span.Span, err)
 		return dto.RefreshTokenResponse{}, err
 	}
+	if !sessionEnd.IsZero() && expiresAt.After(sessionEnd) {
+		expiresAt = sessionEnd
+	}
 
 	err = s.repo.UpdateRefreshToken(ctx, refreshToken.ID, newRefreshTokenString, expiresAt)
 	if err != nil {
@@ -229,11 +489,10 @@ func (s *service) RefreshToken(ctx context.Context, req dto.RefreshTokenRequest)
 		return dto.RefreshTokenResponse{}, err
 	}
 
+	s.recordTokensIssued(ctx, "refresh", refreshToken.UserID.String())
+
 	return dto.RefreshTokenResponse{
-		Token: dto.TokenResponse{
-			AccessToken:  accessToken,
-			RefreshToken: newRefreshTokenString,
-		},
+		Token: s.tokenResponse(accessToken, newRefreshTokenString),
 	}, nil
 }
 
@@ -269,6 +528,10 @@ func (s *service) GetUserByID(ctx context.Context, userID string) (dto.UserRespo
 		return dto.UserResponse{}, err
 	}
 
+	if cached, ok := s.users.Get(userKey(ctx, uid)); ok {
+		return cached, nil
+	}
+
 	user, err := s.repo.GetUserByID(ctx, uid)
 	if err != nil {
 		if pkgerrors.Is(err, sql.ErrNoRows) {
@@ -280,11 +543,13 @@ func (s *service) GetUserByID(ctx context.Context, userID string) (dto.UserRespo
 		return dto.UserResponse{}, err
 	}
 
-	return dto.UserResponse{
+	resp := dto.UserResponse{
 		ID:    user.ID.String(),
 		Name:  user.Name,
 		Email: user.Email,
-	}, nil
+	}
+	s.users.Set(userKey(ctx, uid), resp)
+	return resp, nil
 }
 
 func (s *service) UpdateUser(ctx context.Context, userID string, req dto.UpdateUserRequest) (dto.UserResponse, error) {
@@ -309,6 +574,7 @@ func (s *service) UpdateUser(ctx context.Context, userID string, req dto.UpdateU
 		return dto.UserResponse{}, err
 	}
 
+	current := user.Email
 	if req.Name != "" {
 		user.Name = req.Name
 	}
@@ -316,12 +582,28 @@ func (s *service) UpdateUser(ctx context.Context, userID string, req dto.UpdateU
 		user.Email = req.Email
 	}
 
-	updated, err := s.repo.UpdateUser(ctx, user)
+	var updated entities.User
+	err = s.repo.WithTx(ctx, func(ctx context.Context) error {
+		if req.Email != "" && req.Email != current {
+			if err := s.claimEmail(ctx, req.Email); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		updated, err = s.repo.UpdateUser(ctx, user)
+		return err
+	})
+	if isDuplicateEmail(err) {
+		pkgerrors.RecordError(span.Span, dto.ErrEmailAlreadyExists)
+		return dto.UserResponse{}, dto.ErrEmailAlreadyExists
+	}
 	if err != nil {
 		err = pkgerrors.Wrap(err, "failed to update user")
 		pkgerrors.RecordError(span.Span, err)
 		return dto.UserResponse{}, err
 	}
+	s.invalidateUser(ctx, uid)
 
 	return dto.UserResponse{
 		ID:    updated.ID.String(),
@@ -347,6 +629,790 @@ func (s *service) DeleteUser(ctx context.Context, userID string) error {
 		pkgerrors.RecordError(span.Span, err)
 		return err
 	}
+	s.invalidateUser(ctx, uid)
+
+	return nil
+}
+
+func (s *service) ExportUsers(ctx context.Context, fn func(user dto.UserExport) error) error {
+	ctx, span := tracing.Auto(ctx)
+	defer span.End()
+
+	err := s.repo.StreamUsers(ctx, func(user entities.User) error {
+		return fn(dto.UserExport{
+			ID:        user.ID.String(),
+			Name:      user.Name,
+			Email:     user.Email,
+			CreatedAt: user.CreatedAt,
+		})
+	})
+	if err != nil {
+		err = pkgerrors.Wrap(err, "failed to export users")
+		pkgerrors.RecordError(span.Span, err)
+		return err
+	}
+
+	return nil
+}
+
+// ListUsers returns one page of users filtered and sorted as requested,
+// paging by offset or, in cursor mode, by keyset. Sorting or filtering by a
+// column outside the listing's allowlist, or a malformed cursor, fails with
+// dto.ErrInvalidListQuery.
+func (s *service) ListUsers(ctx context.Context, req dto.ListUsersRequest) (dto.ListUsersResponse, error) {
+	ctx, span := tracing.Auto(ctx)
+	defer span.End()
+
+	q := database.ListQuery{
+		Sort:   database.ParseSort(req.Sort),
+		Limit:  req.Limit,
+		Offset: req.Offset,
+	}
+	if req.Name != "" {
+		q.Filters = append(q.Filters, database.Filter{Column: "name", Op: database.OpContains, Value: req.Name})
+	}
+	if req.Email != "" {
+		q.Filters = append(q.Filters, database.Filter{Column: "email", Op: database.OpContains, Value: req.Email})
+	}
+	if req.Status != "" {
+		q.Filters = append(q.Filters, database.Filter{Column: "status", Op: database.OpEq, Value: req.Status})
+	}
+
+	var (
+		users      []entities.User
+		nextCursor string
+		err        error
+	)
+	if req.Pagination == "cursor" || req.Cursor != "" {
+		users, nextCursor, err = s.repo.ListUsersAfter(ctx, q, req.Cursor)
+	} else {
+		users, err = s.repo.ListUsers(ctx, q)
+	}
+	if err != nil {
+		if pkgerrors.Is(err, database.ErrInvalidListQuery) {
+			pkgerrors.RecordError(span.Span, err)
+			return dto.ListUsersResponse{}, pkgerrors.Wrap(dto.ErrInvalidListQuery, err.Error())
+		}
+		err = pkgerrors.Wrap(err, "failed to list users")
+		pkgerrors.RecordError(span.Span, err)
+		return dto.ListUsersResponse{}, err
+	}
+
+	items := make([]dto.UserListItem, 0, len(users))
+	for _, user := range users {
+		items = append(items, dto.UserListItem{
+			ID:        user.ID.String(),
+			Name:      user.Name,
+			Email:     user.Email,
+			Status:    user.Status,
+			CreatedAt: user.CreatedAt,
+		})
+	}
+
+	return dto.ListUsersResponse{Users: items, NextCursor: nextCursor}, nil
+}
+
+// RevokeSessions logs userID out everywhere: refresh tokens are deleted,
+// the token version is bumped so outstanding access tokens stop validating,
+// cached permissions are dropped and a session-revoked event is raised.
+func (s *service) RevokeSessions(ctx context.Context, userID string) error {
+	ctx, span := tracing.Auto(ctx, attribute.String(constants.AttrKeyUserID, userID))
+	defer span.End()
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		pkgerrors.RecordError(span.Span, dto.ErrUserNotFound)
+		return dto.ErrUserNotFound
+	}
+
+	if _, err := s.repo.GetUserByID(ctx, uid); err != nil {
+		if pkgerrors.Is(err, sql.ErrNoRows) {
+			pkgerrors.RecordError(span.Span, dto.ErrUserNotFound)
+			return dto.ErrUserNotFound
+		}
+		err = pkgerrors.Wrap(err, "failed to get user by id")
+		pkgerrors.RecordError(span.Span, err)
+		return err
+	}
+
+	err = s.repo.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.DeleteRefreshTokensByUserID(ctx, uid); err != nil {
+			return err
+		}
+		if _, err := s.repo.BumpTokenVersion(ctx, uid); err != nil {
+			return err
+		}
+		return s.repo.EnqueueEvent(ctx, webhook.NewEvent(webhook.EventSessionRevoked, userID, nil))
+	})
+	if err != nil {
+		err = pkgerrors.Wrap(err, "failed to revoke sessions")
+		pkgerrors.RecordError(span.Span, err)
+		return err
+	}
+
+	s.tokenVersions.Delete(uid)
+	s.invalidateUser(ctx, uid)
+	s.authorizer.InvalidateUserCache(userID)
 
 	return nil
 }
+
+// TokenVersion returns the user's current access-token version. An unknown
+// user yields an error wrapping sql.ErrNoRows.
+func (s *service) TokenVersion(ctx context.Context, userID string) (int, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return 0, pkgerrors.Wrap(sql.ErrNoRows, "invalid user id")
+	}
+
+	if version, ok := s.tokenVersions.Get(uid); ok {
+		return version, nil
+	}
+	version, err := s.repo.GetTokenVersion(ctx, uid)
+	if err != nil {
+		return 0, err
+	}
+	s.tokenVersions.Set(uid, version)
+	return version, nil
+}
+
+// isNewDevice reports whether none of the user's active sessions was started
+// from client's user agent. Lookup failures are logged and treated as a known
+// device so a database hiccup cannot flood the webhook receivers.
+func (s *service) isNewDevice(ctx context.Context, userID uuid.UUID, client dto.ClientInfo) bool {
+	sessions, err := s.repo.ListRefreshTokensByUserID(ctx, userID)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to list sessions for device check", constants.AttrKeyUserID, userID.String(), "error", err)
+		return false
+	}
+
+	for _, session := range sessions {
+		if session.UserAgent == client.UserAgent {
+			return false
+		}
+	}
+	return true
+}
+
+// checkTwoFactor enforces the second login step for users with two-factor
+// enabled: a missing code is answered with ErrTwoFactorRequired so the
+// client can prompt for one and retry. A backup code, when given, is used
+// up in place of the TOTP code.
+func (s *service) checkTwoFactor(ctx context.Context, userID uuid.UUID, code, backupCode string) error {
+	twoFactor, err := s.repo.GetTwoFactor(ctx, userID)
+	if err != nil {
+		if pkgerrors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return pkgerrors.Wrap(err, "failed to get two-factor settings")
+	}
+
+	if !twoFactor.Enabled {
+		return nil
+	}
+	if backupCode != "" {
+		consumed, err := s.repo.ConsumeBackupCode(ctx, userID, hashBackupCode(backupCode))
+		if err != nil {
+			return pkgerrors.Wrap(err, "failed to consume backup code")
+		}
+		if !consumed {
+			return dto.ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+	if code == "" {
+		return dto.ErrTwoFactorRequired
+	}
+
+	valid, err := s.validTwoFactorCode(ctx, twoFactor, code)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return dto.ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// validTwoFactorCode reports whether code is a valid TOTP code the user has
+// not already used; accepting it consumes its time step.
+func (s *service) validTwoFactorCode(ctx context.Context, twoFactor entities.UserTwoFactor, code string) (bool, error) {
+	if s.twoFactorKey == "" {
+		return false, dto.ErrTwoFactorUnavailable
+	}
+	secret, err := helpers.Decrypt(s.twoFactorKey, twoFactor.Secret)
+	if err != nil {
+		return false, pkgerrors.Wrap(err, "failed to decrypt two-factor secret")
+	}
+
+	step, ok := totp.Match(secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	fresh, err := s.repo.UseTwoFactorStep(ctx, twoFactor.UserID, step)
+	if err != nil {
+		return false, pkgerrors.Wrap(err, "failed to record two-factor step")
+	}
+	return fresh, nil
+}
+
+// EnrollTwoFactor generates a TOTP secret for userID and stores it
+// encrypted. Two-factor is not enforced until VerifyTwoFactor confirms the
+// user's authenticator produces matching codes.
+func (s *service) EnrollTwoFactor(ctx context.Context, userID string) (dto.TwoFactorEnrollResponse, error) {
+	ctx, span := tracing.Auto(ctx, attribute.String(constants.AttrKeyUserID, userID))
+	defer span.End()
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		err = pkgerrors.Wrap(err, "invalid user id")
+		pkgerrors.RecordError(span.Span, err)
+		return dto.TwoFactorEnrollResponse{}, err
+	}
+
+	user, err := s.repo.GetUserByID(ctx, uid)
+	if err != nil {
+		if pkgerrors.Is(err, sql.ErrNoRows) {
+			pkgerrors.RecordError(span.Span, dto.ErrUserNotFound)
+			return dto.TwoFactorEnrollResponse{}, dto.ErrUserNotFound
+		}
+		err = pkgerrors.Wrap(err, "failed to get user by id")
+		pkgerrors.RecordError(span.Span, err)
+		return dto.TwoFactorEnrollResponse{}, err
+	}
+
+	existing, err := s.repo.GetTwoFactor(ctx, uid)
+	if err != nil && !pkgerrors.Is(err, sql.ErrNoRows) {
+		err = pkgerrors.Wrap(err, "failed to get two-factor settings")
+		pkgerrors.RecordError(span.Span, err)
+		return dto.TwoFactorEnrollResponse{}, err
+	}
+	if err == nil && existing.Enabled {
+		pkgerrors.RecordError(span.Span, dto.ErrTwoFactorAlreadyEnabled)
+		return dto.TwoFactorEnrollResponse{}, dto.ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		err = pkgerrors.Wrap(err, "failed to generate two-factor secret")
+		pkgerrors.RecordError(span.Span, err)
+		return dto.TwoFactorEnrollResponse{}, err
+	}
+
+	if s.twoFactorKey == "" {
+		pkgerrors.RecordError(span.Span, dto.ErrTwoFactorUnavailable)
+		return dto.TwoFactorEnrollResponse{}, dto.ErrTwoFactorUnavailable
+	}
+	encrypted, err := helpers.Encrypt(s.twoFactorKey, secret)
+	if err != nil {
+		err = pkgerrors.Wrap(err, "failed to encrypt two-factor secret")
+		pkgerrors.RecordError(span.Span, err)
+		return dto.TwoFactorEnrollResponse{}, err
+	}
+
+	if err := s.repo.SaveTwoFactorSecret(ctx, uid, encrypted); err != nil {
+		err = pkgerrors.Wrap(err, "failed to save two-factor secret")
+		pkgerrors.RecordError(span.Span, err)
+		return dto.TwoFactorEnrollResponse{}, err
+	}
+
+	backupCodes, err := s.replaceBackupCodes(ctx, uid)
+	if err != nil {
+		pkgerrors.RecordError(span.Span, err)
+		return dto.TwoFactorEnrollResponse{}, err
+	}
+
+	return dto.TwoFactorEnrollResponse{
+		Secret:      secret,
+		OTPAuthURL:  totp.URL(s.twoFactorIssuer, user.Email, secret),
+		BackupCodes: backupCodes,
+	}, nil
+}
+
+// RegenerateBackupCodes issues a new set of backup codes for a user with
+// two-factor enabled, invalidating the previous set.
+func (s *service) RegenerateBackupCodes(ctx context.Context, userID string) (dto.BackupCodesResponse, error) {
+	ctx, span := tracing.Auto(ctx, attribute.String(constants.AttrKeyUserID, userID))
+	defer span.End()
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		err = pkgerrors.Wrap(err, "invalid user id")
+		pkgerrors.RecordError(span.Span, err)
+		return dto.BackupCodesResponse{}, err
+	}
+
+	twoFactor, err := s.repo.GetTwoFactor(ctx, uid)
+	if err != nil && !pkgerrors.Is(err, sql.ErrNoRows) {
+		err = pkgerrors.Wrap(err, "failed to get two-factor settings")
+		pkgerrors.RecordError(span.Span, err)
+		return dto.BackupCodesResponse{}, err
+	}
+	if err != nil || !twoFactor.Enabled {
+		pkgerrors.RecordError(span.Span, dto.ErrTwoFactorNotEnrolled)
+		return dto.BackupCodesResponse{}, dto.ErrTwoFactorNotEnrolled
+	}
+
+	codes, err := s.replaceBackupCodes(ctx, uid)
+	if err != nil {
+		pkgerrors.RecordError(span.Span, err)
+		return dto.BackupCodesResponse{}, err
+	}
+
+	return dto.BackupCodesResponse{BackupCodes: codes}, nil
+}
+
+// backupCodeCount is how many backup codes are issued per set.
+const backupCodeCount = 10
+
+var backupCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// replaceBackupCodes generates a new set of backup codes for userID, stores
+// their hashes in place of the old set and returns the codes in clear.
+func (s *service) replaceBackupCodes(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	codes := make([]string, backupCodeCount)
+	hashes := make([]string, backupCodeCount)
+	for i := range codes {
+		code, err := generateBackupCode()
+		if err != nil {
+			return nil, pkgerrors.Wrap(err, "failed to generate backup code")
+		}
+		codes[i] = code
+		hashes[i] = hashBackupCode(code)
+	}
+
+	if err := s.repo.ReplaceBackupCodes(ctx, userID, hashes); err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to save backup codes")
+	}
+	return codes, nil
+}
+
+// generateBackupCode returns a random code formatted as two groups of five
+// characters, e.g. "k3x9p-2mqra".
+func generateBackupCode() (string, error) {
+	buf := make([]byte, 7)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(backupCodeEncoding.EncodeToString(buf))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+// hashBackupCode hashes code after normalising case and separators, so
+// "K3X9P 2MQRA" matches "k3x9p-2mqra".
+func hashBackupCode(code string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(code)))
+	return helpers.HashToken(normalized)
+}
+
+// VerifyTwoFactor confirms a pending enrollment with a code from the user's
+// authenticator, enabling two-factor for subsequent logins.
+func (s *service) VerifyTwoFactor(ctx context.Context, userID string, req dto.TwoFactorVerifyRequest) error {
+	ctx, span := tracing.Auto(ctx, attribute.String(constants.AttrKeyUserID, userID))
+	defer span.End()
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		err = pkgerrors.Wrap(err, "invalid user id")
+		pkgerrors.RecordError(span.Span, err)
+		return err
+	}
+
+	twoFactor, err := s.repo.GetTwoFactor(ctx, uid)
+	if err != nil {
+		if pkgerrors.Is(err, sql.ErrNoRows) {
+			pkgerrors.RecordError(span.Span, dto.ErrTwoFactorNotEnrolled)
+			return dto.ErrTwoFactorNotEnrolled
+		}
+		err = pkgerrors.Wrap(err, "failed to get two-factor settings")
+		pkgerrors.RecordError(span.Span, err)
+		return err
+	}
+	if twoFactor.Enabled {
+		pkgerrors.RecordError(span.Span, dto.ErrTwoFactorAlreadyEnabled)
+		return dto.ErrTwoFactorAlreadyEnabled
+	}
+
+	valid, err := s.validTwoFactorCode(ctx, twoFactor, req.Code)
+	if err != nil {
+		pkgerrors.RecordError(span.Span, err)
+		return err
+	}
+	if !valid {
+		pkgerrors.RecordError(span.Span, dto.ErrInvalidTwoFactorCode)
+		return dto.ErrInvalidTwoFactorCode
+	}
+
+	if err := s.repo.EnableTwoFactor(ctx, uid); err != nil {
+		err = pkgerrors.Wrap(err, "failed to enable two-factor")
+		pkgerrors.RecordError(span.Span, err)
+		return err
+	}
+
+	return nil
+}
+
+// ListSessions returns userID's active sessions, marking the one whose ID is
+// currentSessionID as current.
+func (s *service) ListSessions(ctx context.Context, userID, currentSessionID string) ([]dto.SessionResponse, error) {
+	ctx, span := tracing.Auto(ctx, attribute.String(constants.AttrKeyUserID, userID))
+	defer span.End()
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		err = pkgerrors.Wrap(err, "invalid user id")
+		pkgerrors.RecordError(span.Span, err)
+		return nil, err
+	}
+
+	tokens, err := s.repo.ListRefreshTokensByUserID(ctx, uid)
+	if err != nil {
+		err = pkgerrors.Wrap(err, "failed to list sessions")
+		pkgerrors.RecordError(span.Span, err)
+		return nil, err
+	}
+
+	sessions := make([]dto.SessionResponse, 0, len(tokens))
+	for _, token := range tokens {
+		sessions = append(sessions, dto.SessionResponse{
+			ID:        token.ID.String(),
+			UserAgent: token.UserAgent,
+			IPAddress: token.IPAddress,
+			CreatedAt: token.SessionStartedAt,
+			ExpiresAt: token.ExpiresAt,
+			Current:   token.ID.String() == currentSessionID,
+		})
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession ends one of userID's sessions: its refresh token is deleted,
+// the access tokens minted for it are revoked and a session-revoked event
+// is raised.
+func (s *service) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	ctx, span := tracing.Auto(ctx,
+		attribute.String(constants.AttrKeyUserID, userID),
+		attribute.String("session.id", sessionID),
+	)
+	defer span.End()
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		err = pkgerrors.Wrap(err, "invalid user id")
+		pkgerrors.RecordError(span.Span, err)
+		return err
+	}
+
+	sid, err := uuid.Parse(sessionID)
+	if err != nil {
+		pkgerrors.RecordError(span.Span, dto.ErrSessionNotFound)
+		return dto.ErrSessionNotFound
+	}
+
+	err = s.repo.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.DeleteRefreshTokenByID(ctx, uid, sid); err != nil {
+			return err
+		}
+		return s.repo.EnqueueEvent(ctx, webhook.NewEvent(webhook.EventSessionRevoked, userID, map[string]string{
+			"session_id": sessionID,
+		}))
+	})
+	if err != nil {
+		if pkgerrors.Is(err, sql.ErrNoRows) {
+			pkgerrors.RecordError(span.Span, dto.ErrSessionNotFound)
+			return dto.ErrSessionNotFound
+		}
+		err = pkgerrors.Wrap(err, "failed to delete refresh token")
+		pkgerrors.RecordError(span.Span, err)
+		return err
+	}
+
+	s.jwtService.RevokeSession(sessionID)
+
+	return nil
+}
+
+// SetUserStatus marks the account active or suspended. Suspending also
+// revokes the user's outstanding access tokens and raises an account-locked
+// security event.
+func (s *service) SetUserStatus(ctx context.Context, userID string, status string) error {
+	ctx, span := tracing.Auto(ctx,
+		attribute.String(constants.AttrKeyUserID, userID),
+		attribute.String("user.status", status),
+	)
+	defer span.End()
+
+	if status != entities.UserStatusActive && status != entities.UserStatusSuspended {
+		pkgerrors.RecordError(span.Span, dto.ErrInvalidUserStatus)
+		return dto.ErrInvalidUserStatus
+	}
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		pkgerrors.RecordError(span.Span, dto.ErrUserNotFound)
+		return dto.ErrUserNotFound
+	}
+
+	err = s.repo.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.SetUserStatus(ctx, uid, status); err != nil {
+			return err
+		}
+		if status != entities.UserStatusSuspended {
+			return nil
+		}
+		if _, err := s.repo.BumpTokenVersion(ctx, uid); err != nil {
+			return err
+		}
+		return s.repo.EnqueueEvent(ctx, webhook.NewEvent(webhook.EventAccountLocked, userID, map[string]string{
+			"status": status,
+		}))
+	})
+	if err != nil {
+		if pkgerrors.Is(err, sql.ErrNoRows) {
+			pkgerrors.RecordError(span.Span, dto.ErrUserNotFound)
+			return dto.ErrUserNotFound
+		}
+		err = pkgerrors.Wrap(err, "failed to set user status")
+		pkgerrors.RecordError(span.Span, err)
+		return err
+	}
+
+	s.invalidateUser(ctx, uid)
+	if status == entities.UserStatusSuspended {
+		s.tokenVersions.Delete(uid)
+	}
+
+	return nil
+}
+
+// Lifetimes of the single-use tokens emailed to users.
+const (
+	verificationTokenTTL  = 24 * time.Hour
+	passwordResetTokenTTL = time.Hour
+)
+
+// Paths the emailed links point to, relative to PUBLIC_BASE_URL. The
+// verification link hits the API directly; the reset link opens the page
+// that collects the new password and posts it to /api/account/password/reset.
+const (
+	verifyEmailPath   = "/api/account/verify"
+	passwordResetPath = "/account/password/reset"
+)
+
+// tokenLink returns the absolute URL of path with token as its query string.
+func (s *service) tokenLink(path, token string) string {
+	return s.publicURL(path) + "?" + url.Values{"token": {token}}.Encode()
+}
+
+// sendTemplate renders the named email template with data and sends it to
+// user.
+func (s *service) sendTemplate(ctx context.Context, user entities.User, name string, data email.TemplateData) error {
+	data.Name = user.Name
+	subject, body, err := s.templates.Render(name, data)
+	if err != nil {
+		return pkgerrors.Wrap(err, "failed to render email")
+	}
+	if err := s.mailer.Send(ctx, user.Email, subject, body); err != nil {
+		return pkgerrors.Wrap(err, "failed to send email")
+	}
+	return nil
+}
+
+// sendInBackground sends the email after the caller returns and only logs
+// a failure, so neither the mail server's latency nor its errors tell a
+// caller whether the address is registered.
+func (s *service) sendInBackground(ctx context.Context, user entities.User, name string, data email.TemplateData) {
+	ctx = context.WithoutCancel(ctx)
+	s.mailing.Add(1)
+	go func() {
+		defer s.mailing.Done()
+		if err := s.sendTemplate(ctx, user, name, data); err != nil {
+			slog.WarnContext(ctx, "failed to send email", "template", name, constants.AttrKeyUserID, user.ID.String(), "error", err)
+		}
+	}()
+}
+
+// Shutdown waits for emails still being sent in the background. It lets
+// the injector drain them on shutdown.
+func (s *service) Shutdown() error {
+	s.mailing.Wait()
+	return nil
+}
+
+// issueAccountToken stores a new single-use token for purpose and returns
+// it; only its hash is persisted.
+func (s *service) issueAccountToken(ctx context.Context, userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", pkgerrors.Wrap(err, "failed to generate account token")
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	err := s.repo.CreateAccountToken(ctx, entities.AccountToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: helpers.HashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", pkgerrors.Wrap(err, "failed to store account token")
+	}
+	return token, nil
+}
+
+func (s *service) sendVerificationEmail(ctx context.Context, user entities.User) error {
+	token, err := s.issueAccountToken(ctx, user.ID, entities.AccountTokenPurposeVerifyEmail, verificationTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.sendTemplate(ctx, user, email.TemplateVerifyEmail, email.TemplateData{
+		Link:      s.tokenLink(verifyEmailPath, token),
+		ExpiresIn: verificationTokenTTL,
+	})
+}
+
+// VerifyEmail redeems a verification token and marks the owner's address
+// verified.
+func (s *service) VerifyEmail(ctx context.Context, req dto.VerifyEmailRequest) error {
+	ctx, span := tracing.Auto(ctx)
+	defer span.End()
+
+	var userID uuid.UUID
+	err := s.repo.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		userID, err = s.repo.ConsumeAccountToken(ctx, entities.AccountTokenPurposeVerifyEmail, helpers.HashToken(req.Token))
+		if err != nil {
+			return err
+		}
+		return s.repo.MarkEmailVerified(ctx, userID)
+	})
+	if err != nil {
+		if pkgerrors.Is(err, sql.ErrNoRows) {
+			pkgerrors.RecordError(span.Span, dto.ErrInvalidAccountToken)
+			return dto.ErrInvalidAccountToken
+		}
+		err = pkgerrors.Wrap(err, "failed to verify email")
+		pkgerrors.RecordError(span.Span, err)
+		return err
+	}
+	s.invalidateUser(ctx, userID)
+
+	return nil
+}
+
+// RequestPasswordReset emails a reset token to the account registered with
+// req.Email. It succeeds whether or not the account exists, and sends the
+// email in the background, so the endpoint cannot be used to discover
+// registered addresses.
+func (s *service) RequestPasswordReset(ctx context.Context, req dto.PasswordResetRequest) error {
+	ctx, span := tracing.Auto(ctx, attribute.String(constants.AttrKeyEmail, req.Email))
+	defer span.End()
+
+	user, err := s.repo.GetUserByEmail(ctx, req.Email)
+	if err != nil {
+		if pkgerrors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		err = pkgerrors.Wrap(err, "failed to get user by email")
+		pkgerrors.RecordError(span.Span, err)
+		return err
+	}
+
+	token, err := s.issueAccountToken(ctx, user.ID, entities.AccountTokenPurposePasswordReset, passwordResetTokenTTL)
+	if err != nil {
+		pkgerrors.RecordError(span.Span, err)
+		return err
+	}
+
+	s.sendInBackground(ctx, user, email.TemplatePasswordReset, email.TemplateData{
+		Link:      s.tokenLink(passwordResetPath, token),
+		ExpiresIn: passwordResetTokenTTL,
+	})
+	return nil
+}
+
+// ResetPassword redeems a reset token and sets the new password. Every
+// session of the account is ended, since whoever held them may be the
+// reason for the reset.
+func (s *service) ResetPassword(ctx context.Context, req dto.ResetPasswordRequest) error {
+	ctx, span := tracing.Auto(ctx)
+	defer span.End()
+
+	hashedPassword, err := helpers.HashPassword(req.Password)
+	if err != nil {
+		err = pkgerrors.Wrap(err, "failed to hash password")
+		pkgerrors.RecordError(span.Span, err)
+		return err
+	}
+
+	var userID uuid.UUID
+	err = s.repo.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		userID, err = s.repo.ConsumeAccountToken(ctx, entities.AccountTokenPurposePasswordReset, helpers.HashToken(req.Token))
+		if err != nil {
+			return err
+		}
+		if err := s.repo.UpdatePassword(ctx, userID, hashedPassword); err != nil {
+			return err
+		}
+		if _, err := s.repo.BumpTokenVersion(ctx, userID); err != nil {
+			return err
+		}
+		return s.repo.EnqueueEvent(ctx, webhook.NewEvent(webhook.EventPasswordChanged, userID.String(), nil))
+	})
+	if err != nil {
+		if pkgerrors.Is(err, sql.ErrNoRows) {
+			pkgerrors.RecordError(span.Span, dto.ErrInvalidAccountToken)
+			return dto.ErrInvalidAccountToken
+		}
+		err = pkgerrors.Wrap(err, "failed to reset password")
+		pkgerrors.RecordError(span.Span, err)
+		return err
+	}
+
+	s.tokenVersions.Delete(userID)
+	s.invalidateUser(ctx, userID)
+
+	span.SetAttributes(attribute.String(constants.AttrKeyUserID, userID.String()))
+
+	if err := s.repo.DeleteRefreshTokensByUserID(ctx, userID); err != nil {
+		err = pkgerrors.Wrap(err, "failed to delete refresh tokens")
+		pkgerrors.RecordError(span.Span, err)
+		return err
+	}
+
+	if err := s.notifyPasswordChanged(ctx, userID); err != nil {
+		pkgerrors.RecordError(span.Span, err)
+		slog.WarnContext(ctx, "failed to send password changed notification", constants.AttrKeyUserID, userID.String(), "error", err)
+	}
+
+	return nil
+}
+
+// notifyPasswordChanged tells the account owner their password changed, so
+// a reset they did not ask for does not go unnoticed.
+func (s *service) notifyPasswordChanged(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return pkgerrors.Wrap(err, "failed to get user by id")
+	}
+	return s.sendTemplate(ctx, user, email.TemplateNotification, email.TemplateData{
+		Subject: "Your password was changed",
+		Message: "The password of your account was just changed and every session was signed out. " +
+			"If this was not you, reset your password immediately.",
+	})
+}
+
+// SubscribeEvents streams the security events published for userID, such
+// as session revocations, until the returned function is called.
+func (s *service) SubscribeEvents(userID string) (<-chan webhook.Event, func()) {
+	return s.events.Subscribe(userID)
+}
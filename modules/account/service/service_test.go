@@ -3,21 +3,37 @@ package service
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/elskow/go-microservice-template/config"
 	"github.com/elskow/go-microservice-template/database/entities"
 	"github.com/elskow/go-microservice-template/modules/account/authorization"
 	"github.com/elskow/go-microservice-template/modules/account/dto"
+	"github.com/elskow/go-microservice-template/modules/account/repository"
+	"github.com/elskow/go-microservice-template/pkg/cache"
 	"github.com/elskow/go-microservice-template/pkg/database"
+	"github.com/elskow/go-microservice-template/pkg/database/dbtest"
+	"github.com/elskow/go-microservice-template/pkg/email"
+	"github.com/elskow/go-microservice-template/pkg/eventstream"
+	"github.com/elskow/go-microservice-template/pkg/helpers"
+	"github.com/elskow/go-microservice-template/pkg/tenant"
+	"github.com/elskow/go-microservice-template/pkg/totp"
+	"github.com/elskow/go-microservice-template/pkg/webhook"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
-	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -26,6 +42,12 @@ type mockJWTService struct {
 	generateAccessTokenFunc  func(userID string, role string) (string, error)
 	generateRefreshTokenFunc func() (string, time.Time, error)
 	getUserIDByTokenFunc     func(token string) (string, error)
+	revokedSessionIDs        []string
+	// sessionIDs, tenantIDs and tokenVersions record the session, tenant
+	// and token version each access token was minted with.
+	sessionIDs    []string
+	tenantIDs     []string
+	tokenVersions []int
 }
 
 func (m *mockJWTService) GenerateAccessToken(userID string, role string) (string, error) {
@@ -35,6 +57,13 @@ func (m *mockJWTService) GenerateAccessToken(userID string, role string) (string
 	return "mock_access_token", nil
 }
 
+func (m *mockJWTService) GenerateSessionAccessToken(userID, role, tenantID, sessionID string, tokenVersion int) (string, error) {
+	m.tenantIDs = append(m.tenantIDs, tenantID)
+	m.sessionIDs = append(m.sessionIDs, sessionID)
+	m.tokenVersions = append(m.tokenVersions, tokenVersion)
+	return m.GenerateAccessToken(userID, role)
+}
+
 func (m *mockJWTService) GenerateRefreshToken() (string, time.Time, error) {
 	if m.generateRefreshTokenFunc != nil {
 		return m.generateRefreshTokenFunc()
@@ -46,6 +75,14 @@ func (m *mockJWTService) ValidateToken(token string) (*jwt.Token, error) {
 	return &jwt.Token{Valid: true}, nil
 }
 
+func (m *mockJWTService) AccessTokenExpiry() time.Duration {
+	return 15 * time.Minute
+}
+
+func (m *mockJWTService) RevokeSession(sessionID string) {
+	m.revokedSessionIDs = append(m.revokedSessionIDs, sessionID)
+}
+
 func (m *mockJWTService) GetUserIDByToken(token string) (string, error) {
 	if m.getUserIDByTokenFunc != nil {
 		return m.getUserIDByTokenFunc(token)
@@ -58,6 +95,7 @@ type mockRepository struct {
 	createUserFunc                  func(ctx context.Context, user entities.User) (entities.User, error)
 	getUserByIDFunc                 func(ctx context.Context, userID uuid.UUID) (entities.User, error)
 	getUserByEmailFunc              func(ctx context.Context, email string) (entities.User, error)
+	emailExistsFunc                 func(ctx context.Context, email string) (bool, error)
 	updateUserFunc                  func(ctx context.Context, user entities.User) (entities.User, error)
 	deleteUserFunc                  func(ctx context.Context, userID uuid.UUID) error
 	createRefreshTokenFunc          func(ctx context.Context, token entities.RefreshToken) (entities.RefreshToken, error)
@@ -65,8 +103,46 @@ type mockRepository struct {
 	updateRefreshTokenFunc          func(ctx context.Context, tokenID uuid.UUID, newToken string, expiresAt time.Time) error
 	deleteRefreshTokenFunc          func(ctx context.Context, token string) error
 	deleteRefreshTokensByUserIDFunc func(ctx context.Context, userID uuid.UUID) error
+	streamUsersFunc                 func(ctx context.Context, fn func(user entities.User) error) error
+	listUsersFunc                   func(ctx context.Context, q database.ListQuery) ([]entities.User, error)
+	listUsersAfterFunc              func(ctx context.Context, q database.ListQuery, cursor string) ([]entities.User, string, error)
+	setUserStatusFunc               func(ctx context.Context, userID uuid.UUID, status string) error
+	listRefreshTokensByUserIDFunc   func(ctx context.Context, userID uuid.UUID) ([]entities.RefreshToken, error)
+	deleteRefreshTokenByIDFunc      func(ctx context.Context, userID, tokenID uuid.UUID) error
+	getTwoFactorFunc                func(ctx context.Context, userID uuid.UUID) (entities.UserTwoFactor, error)
+	saveTwoFactorSecretFunc         func(ctx context.Context, userID uuid.UUID, secret string) error
+	enableTwoFactorFunc             func(ctx context.Context, userID uuid.UUID) error
+	replaceBackupCodesFunc          func(ctx context.Context, userID uuid.UUID, codeHashes []string) error
+	consumeBackupCodeFunc           func(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error)
+	withTxFunc                      func(ctx context.Context, fn func(ctx context.Context) error) error
+	enqueueEventFunc                func(ctx context.Context, event webhook.Event) error
+	createAccountTokenFunc          func(ctx context.Context, token entities.AccountToken) error
+	consumeAccountTokenFunc         func(ctx context.Context, purpose, tokenHash string) (uuid.UUID, error)
+	updatePasswordFunc              func(ctx context.Context, userID uuid.UUID, passwordHash string) error
+	markEmailVerifiedFunc           func(ctx context.Context, userID uuid.UUID) error
+	getTokenVersionFunc             func(ctx context.Context, userID uuid.UUID) (int, error)
+	recordLoginFunc                 func(ctx context.Context, userID uuid.UUID) error
+
+	// enqueued records events written to the outbox by the default
+	// EnqueueEvent, along with whether each was written inside WithTx.
+	enqueued []enqueuedEvent
+	// tokenVersions holds the versions bumped by the default
+	// BumpTokenVersion; users never bumped are at version 0.
+	tokenVersions map[uuid.UUID]int
+	// lockedEmails records the emails passed to LockEmail inside WithTx.
+	lockedEmails []string
+	// twoFactorSteps holds the last TOTP step accepted by the default
+	// UseTwoFactorStep.
+	twoFactorSteps map[uuid.UUID]uint64
+}
+
+type enqueuedEvent struct {
+	event webhook.Event
+	inTx  bool
 }
 
+type mockTxKey struct{}
+
 func (m *mockRepository) CreateUser(ctx context.Context, user entities.User) (entities.User, error) {
 	if m.createUserFunc != nil {
 		return m.createUserFunc(ctx, user)
@@ -88,6 +164,20 @@ func (m *mockRepository) GetUserByEmail(ctx context.Context, email string) (enti
 	return entities.User{}, sql.ErrNoRows
 }
 
+func (m *mockRepository) EmailExists(ctx context.Context, email string) (bool, error) {
+	if m.emailExistsFunc != nil {
+		return m.emailExistsFunc(ctx, email)
+	}
+	return false, nil
+}
+
+func (m *mockRepository) LockEmail(ctx context.Context, email string) error {
+	if inTx, _ := ctx.Value(mockTxKey{}).(bool); inTx {
+		m.lockedEmails = append(m.lockedEmails, email)
+	}
+	return nil
+}
+
 func (m *mockRepository) UpdateUser(ctx context.Context, user entities.User) (entities.User, error) {
 	if m.updateUserFunc != nil {
 		return m.updateUserFunc(ctx, user)
@@ -137,11 +227,182 @@ func (m *mockRepository) DeleteRefreshTokensByUserID(ctx context.Context, userID
 	return nil
 }
 
+func (m *mockRepository) StreamUsers(ctx context.Context, fn func(user entities.User) error) error {
+	if m.streamUsersFunc != nil {
+		return m.streamUsersFunc(ctx, fn)
+	}
+	return nil
+}
+
+func (m *mockRepository) ListUsers(ctx context.Context, q database.ListQuery) ([]entities.User, error) {
+	if m.listUsersFunc != nil {
+		return m.listUsersFunc(ctx, q)
+	}
+	return nil, nil
+}
+
+func (m *mockRepository) ListUsersAfter(ctx context.Context, q database.ListQuery, cursor string) ([]entities.User, string, error) {
+	if m.listUsersAfterFunc != nil {
+		return m.listUsersAfterFunc(ctx, q, cursor)
+	}
+	return nil, "", nil
+}
+
+func (m *mockRepository) SetUserStatus(ctx context.Context, userID uuid.UUID, status string) error {
+	if m.setUserStatusFunc != nil {
+		return m.setUserStatusFunc(ctx, userID, status)
+	}
+	return nil
+}
+
+func (m *mockRepository) ListRefreshTokensByUserID(ctx context.Context, userID uuid.UUID) ([]entities.RefreshToken, error) {
+	if m.listRefreshTokensByUserIDFunc != nil {
+		return m.listRefreshTokensByUserIDFunc(ctx, userID)
+	}
+	return nil, nil
+}
+
+func (m *mockRepository) DeleteRefreshTokenByID(ctx context.Context, userID, tokenID uuid.UUID) error {
+	if m.deleteRefreshTokenByIDFunc != nil {
+		return m.deleteRefreshTokenByIDFunc(ctx, userID, tokenID)
+	}
+	return nil
+}
+
+func (m *mockRepository) GetTwoFactor(ctx context.Context, userID uuid.UUID) (entities.UserTwoFactor, error) {
+	if m.getTwoFactorFunc != nil {
+		return m.getTwoFactorFunc(ctx, userID)
+	}
+	return entities.UserTwoFactor{}, sql.ErrNoRows
+}
+
+func (m *mockRepository) SaveTwoFactorSecret(ctx context.Context, userID uuid.UUID, secret string) error {
+	if m.saveTwoFactorSecretFunc != nil {
+		return m.saveTwoFactorSecretFunc(ctx, userID, secret)
+	}
+	return nil
+}
+
+func (m *mockRepository) UseTwoFactorStep(ctx context.Context, userID uuid.UUID, step uint64) (bool, error) {
+	if step <= m.twoFactorSteps[userID] {
+		return false, nil
+	}
+	if m.twoFactorSteps == nil {
+		m.twoFactorSteps = make(map[uuid.UUID]uint64)
+	}
+	m.twoFactorSteps[userID] = step
+	return true, nil
+}
+
+func (m *mockRepository) EnableTwoFactor(ctx context.Context, userID uuid.UUID) error {
+	if m.enableTwoFactorFunc != nil {
+		return m.enableTwoFactorFunc(ctx, userID)
+	}
+	return nil
+}
+
+func (m *mockRepository) ReplaceBackupCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	if m.replaceBackupCodesFunc != nil {
+		return m.replaceBackupCodesFunc(ctx, userID, codeHashes)
+	}
+	return nil
+}
+
+func (m *mockRepository) ConsumeBackupCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	if m.consumeBackupCodeFunc != nil {
+		return m.consumeBackupCodeFunc(ctx, userID, codeHash)
+	}
+	return false, nil
+}
+
+func (m *mockRepository) CreateAccountToken(ctx context.Context, token entities.AccountToken) error {
+	if m.createAccountTokenFunc != nil {
+		return m.createAccountTokenFunc(ctx, token)
+	}
+	return nil
+}
+
+func (m *mockRepository) ConsumeAccountToken(ctx context.Context, purpose, tokenHash string) (uuid.UUID, error) {
+	if m.consumeAccountTokenFunc != nil {
+		return m.consumeAccountTokenFunc(ctx, purpose, tokenHash)
+	}
+	return uuid.Nil, sql.ErrNoRows
+}
+
+func (m *mockRepository) UpdatePassword(ctx context.Context, userID uuid.UUID, passwordHash string) error {
+	if m.updatePasswordFunc != nil {
+		return m.updatePasswordFunc(ctx, userID, passwordHash)
+	}
+	return nil
+}
+
+func (m *mockRepository) MarkEmailVerified(ctx context.Context, userID uuid.UUID) error {
+	if m.markEmailVerifiedFunc != nil {
+		return m.markEmailVerifiedFunc(ctx, userID)
+	}
+	return nil
+}
+
+func (m *mockRepository) GetTokenVersion(ctx context.Context, userID uuid.UUID) (int, error) {
+	if m.getTokenVersionFunc != nil {
+		return m.getTokenVersionFunc(ctx, userID)
+	}
+	return m.tokenVersions[userID], nil
+}
+
+func (m *mockRepository) BumpTokenVersion(ctx context.Context, userID uuid.UUID) (int, error) {
+	if m.tokenVersions == nil {
+		m.tokenVersions = make(map[uuid.UUID]int)
+	}
+	m.tokenVersions[userID]++
+	return m.tokenVersions[userID], nil
+}
+
+func (m *mockRepository) RecordLogin(ctx context.Context, userID uuid.UUID) error {
+	if m.recordLoginFunc != nil {
+		return m.recordLoginFunc(ctx, userID)
+	}
+	return nil
+}
+
+// sentEmail is a message captured by stubSender.
+type sentEmail struct {
+	to, subject, body string
+}
+
+// stubSender records messages instead of delivering them.
+type stubSender struct {
+	sent []sentEmail
+	err  error
+}
+
+func (s *stubSender) Send(ctx context.Context, to, subject, body string) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, sentEmail{to: to, subject: subject, body: body})
+	return nil
+}
+
+func (m *mockRepository) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if m.withTxFunc != nil {
+		return m.withTxFunc(ctx, fn)
+	}
+	return fn(context.WithValue(ctx, mockTxKey{}, true))
+}
+
+func (m *mockRepository) EnqueueEvent(ctx context.Context, event webhook.Event) error {
+	if m.enqueueEventFunc != nil {
+		return m.enqueueEventFunc(ctx, event)
+	}
+	inTx, _ := ctx.Value(mockTxKey{}).(bool)
+	m.enqueued = append(m.enqueued, enqueuedEvent{event: event, inTx: inTx})
+	return nil
+}
+
 func setupTestService(t *testing.T) (*service, *mockRepository, sqlmock.Sqlmock) {
-	mockDB, mock, err := sqlmock.New()
-	require.NoError(t, err)
-	sqlxDB := sqlx.NewDb(mockDB, "sqlmock")
-	tracedDB := &database.TracedDB{DB: sqlxDB}
+	tracedDB, mock, cleanup := dbtest.NewMockTracedDB(t)
+	t.Cleanup(cleanup)
 
 	// Create a real authorizer with mocked DB for role assignment
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
@@ -150,25 +411,90 @@ func setupTestService(t *testing.T) (*service, *mockRepository, sqlmock.Sqlmock)
 	repo := &mockRepository{}
 	jwtSvc := &mockJWTService{}
 
+	templates, err := email.LoadTemplates("")
+	require.NoError(t, err)
+
 	svc := &service{
-		repo:       repo,
-		jwtService: jwtSvc,
-		db:         tracedDB,
-		authorizer: auth,
+		repo:         repo,
+		jwtService:   jwtSvc,
+		db:           tracedDB,
+		authorizer:   auth,
+		tokensIssued: noop.Int64Counter{},
+
+		twoFactorKey:    testTwoFactorKey,
+		twoFactorIssuer: "Template",
+		mailer:          &stubSender{},
+		templates:       templates,
+		publicURL:       (&config.Config{PublicBaseURL: testPublicBaseURL}).PublicURL,
+		tokenVersions:   cache.New[uuid.UUID, int](time.Minute),
+		users:           cache.New[userCacheKey, dto.UserResponse](0),
+		events:          eventstream.NewBroker(eventstream.DefaultBufferSize),
 	}
 
 	return svc, repo, mock
 }
 
+const (
+	testTwoFactorKey  = "test-two-factor-key"
+	testPublicBaseURL = "https://app.example.com"
+)
+
+// stubTwoFactor stores secret, encrypted, as userID's two-factor enrollment.
+func stubTwoFactor(t *testing.T, repo *mockRepository, secret string, enabled bool) {
+	encrypted, err := helpers.Encrypt(testTwoFactorKey, secret)
+	require.NoError(t, err)
+	repo.getTwoFactorFunc = func(ctx context.Context, userID uuid.UUID) (entities.UserTwoFactor, error) {
+		return entities.UserTwoFactor{UserID: userID, Secret: encrypted, Enabled: enabled}, nil
+	}
+}
+
+// expectUserRoles stubs the authorizer's role lookup used when minting
+// refreshed access tokens.
+func expectUserRoles(mock sqlmock.Sqlmock, roles ...string) {
+	rows := sqlmock.NewRows([]string{"name"})
+	for _, role := range roles {
+		rows.AddRow(role)
+	}
+	mock.ExpectQuery("FROM user_roles ur").WillReturnRows(rows)
+}
+
+// useTokenMetricsReader swaps the service's token counter for one backed by a
+// manual reader so tests can inspect what was recorded.
+func useTokenMetricsReader(svc *service) *sdkmetric.ManualReader {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	svc.tokensIssued = newTokensIssuedCounter(provider.Meter("test"))
+	return reader
+}
+
+func tokensIssuedByType(t *testing.T, reader *sdkmetric.ManualReader) map[string]int64 {
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+
+	counts := make(map[string]int64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "auth_tokens_issued_total" {
+				continue
+			}
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			require.True(t, ok)
+			for _, dp := range sum.DataPoints {
+				tokenType, _ := dp.Attributes.Value("type")
+				counts[tokenType.AsString()] += dp.Value
+			}
+		}
+	}
+	return counts
+}
+
 func TestService_Register_Success(t *testing.T) {
 	svc, repo, mock := setupTestService(t)
 	ctx := context.Background()
 
 	// Mock role assignment
-	mock.ExpectExec(`INSERT INTO user_roles (user_id, role_id)
-		SELECT $1, id FROM roles WHERE name = $2
-		ON CONFLICT (user_id, role_id) DO NOTHING`).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO user_roles`).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectUserRoles(mock, "user")
 
 	req := dto.RegisterRequest{
 		Name:     "John Doe",
@@ -176,8 +502,8 @@ func TestService_Register_Success(t *testing.T) {
 		Password: "password123",
 	}
 
-	repo.getUserByEmailFunc = func(ctx context.Context, email string) (entities.User, error) {
-		return entities.User{}, sql.ErrNoRows
+	repo.emailExistsFunc = func(ctx context.Context, email string) (bool, error) {
+		return false, nil
 	}
 
 	repo.createUserFunc = func(ctx context.Context, user entities.User) (entities.User, error) {
@@ -197,6 +523,13 @@ func TestService_Register_Success(t *testing.T) {
 	assert.Equal(t, req.Email, resp.User.Email)
 	assert.NotEmpty(t, resp.Token.AccessToken)
 	assert.NotEmpty(t, resp.Token.RefreshToken)
+	assert.Equal(t, "Bearer", resp.Token.TokenType)
+	assert.Equal(t, int64(900), resp.Token.ExpiresIn)
+
+	events := enqueuedEvents(t, svc)
+	require.Len(t, events, 1)
+	assert.Equal(t, webhook.EventUserRegistered, events[0].Type)
+	assert.Equal(t, resp.User.ID, events[0].UserID)
 }
 
 func TestService_Register_EmailAlreadyExists(t *testing.T) {
@@ -209,8 +542,8 @@ func TestService_Register_EmailAlreadyExists(t *testing.T) {
 		Password: "password123",
 	}
 
-	repo.getUserByEmailFunc = func(ctx context.Context, email string) (entities.User, error) {
-		return entities.User{ID: uuid.New()}, nil
+	repo.emailExistsFunc = func(ctx context.Context, email string) (bool, error) {
+		return true, nil
 	}
 
 	_, err := svc.Register(ctx, req)
@@ -219,8 +552,26 @@ func TestService_Register_EmailAlreadyExists(t *testing.T) {
 	assert.Equal(t, dto.ErrEmailAlreadyExists, err)
 }
 
-func TestService_Login_Success(t *testing.T) {
+func TestService_Register_ConcurrentDuplicate(t *testing.T) {
 	svc, repo, _ := setupTestService(t)
+
+	repo.createUserFunc = func(ctx context.Context, user entities.User) (entities.User, error) {
+		return entities.User{}, fmt.Errorf("failed to create user: %w", repository.ErrDuplicateEmail)
+	}
+
+	_, err := svc.Register(context.Background(), dto.RegisterRequest{
+		Name:     "John Doe",
+		Email:    "john@example.com",
+		Password: "password123",
+	})
+
+	assert.Equal(t, dto.ErrEmailAlreadyExists, err)
+	assert.Equal(t, []string{"john@example.com"}, repo.lockedEmails)
+}
+
+func TestService_Login_Success(t *testing.T) {
+	svc, repo, mock := setupTestService(t)
+	expectUserRoles(mock, "user")
 	ctx := context.Background()
 
 	password := "password123"
@@ -253,6 +604,8 @@ func TestService_Login_Success(t *testing.T) {
 	assert.Equal(t, existingUser.Name, resp.User.Name)
 	assert.NotEmpty(t, resp.Token.AccessToken)
 	assert.NotEmpty(t, resp.Token.RefreshToken)
+	assert.Equal(t, "Bearer", resp.Token.TokenType)
+	assert.Equal(t, int64(900), resp.Token.ExpiresIn)
 }
 
 func TestService_Login_InvalidCredentials_UserNotFound(t *testing.T) {
@@ -303,8 +656,9 @@ func TestService_Login_InvalidCredentials_WrongPassword(t *testing.T) {
 }
 
 func TestService_RefreshToken_Success(t *testing.T) {
-	svc, repo, _ := setupTestService(t)
+	svc, repo, mock := setupTestService(t)
 	ctx := context.Background()
+	expectUserRoles(mock, "user")
 
 	tokenString := "valid_refresh_token"
 	refreshToken := entities.RefreshToken{
@@ -331,6 +685,134 @@ func TestService_RefreshToken_Success(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotEmpty(t, resp.Token.AccessToken)
 	assert.NotEmpty(t, resp.Token.RefreshToken)
+	assert.Equal(t, "Bearer", resp.Token.TokenType)
+	assert.Equal(t, int64(900), resp.Token.ExpiresIn)
+}
+
+func TestService_RefreshToken_UsesCurrentRole(t *testing.T) {
+	svc, repo, mock := setupTestService(t)
+	expectUserRoles(mock, "admin", "user")
+
+	userID := uuid.New()
+	repo.getRefreshTokenByTokenFunc = func(ctx context.Context, token string) (entities.RefreshToken, error) {
+		return entities.RefreshToken{
+			ID:        uuid.New(),
+			UserID:    userID,
+			Token:     token,
+			ExpiresAt: time.Now().Add(time.Hour),
+		}, nil
+	}
+
+	var mintedRole string
+	svc.jwtService.(*mockJWTService).generateAccessTokenFunc = func(uid string, role string) (string, error) {
+		assert.Equal(t, userID.String(), uid)
+		mintedRole = role
+		return "admin_access_token", nil
+	}
+
+	_, err := svc.RefreshToken(context.Background(), dto.RefreshTokenRequest{RefreshToken: "valid_refresh_token"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "admin", mintedRole)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_Login_UsesCurrentRole(t *testing.T) {
+	svc, repo, mock := setupTestService(t)
+	expectUserRoles(mock, "admin", "user")
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password123"), 4)
+	repo.getUserByEmailFunc = func(ctx context.Context, email string) (entities.User, error) {
+		return entities.User{ID: uuid.New(), Email: email, Password: string(hashedPassword)}, nil
+	}
+
+	var mintedRole string
+	svc.jwtService.(*mockJWTService).generateAccessTokenFunc = func(uid string, role string) (string, error) {
+		mintedRole = role
+		return "admin_access_token", nil
+	}
+
+	_, err := svc.Login(context.Background(), dto.LoginRequest{Email: "john@example.com", Password: "password123"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "admin", mintedRole)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_Login_MintsRequestTenant(t *testing.T) {
+	svc, repo, mock := setupTestService(t)
+	expectUserRoles(mock, "user")
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password123"), 4)
+	repo.getUserByEmailFunc = func(ctx context.Context, email string) (entities.User, error) {
+		return entities.User{ID: uuid.New(), Email: email, Password: string(hashedPassword)}, nil
+	}
+
+	ctx := tenant.WithTenantID(context.Background(), "acme")
+	_, err := svc.Login(ctx, dto.LoginRequest{Email: "john@example.com", Password: "password123"})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"acme"}, svc.jwtService.(*mockJWTService).tenantIDs)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_RefreshToken_WithinSessionCap(t *testing.T) {
+	svc, repo, mock := setupTestService(t)
+	svc.sessionMaxAge = 30 * 24 * time.Hour
+	expectUserRoles(mock, "user")
+
+	sessionStart := time.Now().Add(-29 * 24 * time.Hour)
+	repo.getRefreshTokenByTokenFunc = func(ctx context.Context, token string) (entities.RefreshToken, error) {
+		return entities.RefreshToken{
+			ID:               uuid.New(),
+			UserID:           uuid.New(),
+			Token:            token,
+			ExpiresAt:        time.Now().Add(time.Hour),
+			SessionStartedAt: sessionStart,
+		}, nil
+	}
+
+	var rotatedExpiry time.Time
+	repo.updateRefreshTokenFunc = func(ctx context.Context, tokenID uuid.UUID, newToken string, expiresAt time.Time) error {
+		rotatedExpiry = expiresAt
+		return nil
+	}
+
+	_, err := svc.RefreshToken(context.Background(), dto.RefreshTokenRequest{RefreshToken: "valid_refresh_token"})
+
+	require.NoError(t, err)
+	// the rotated token must not outlive the session
+	assert.Equal(t, sessionStart.Add(svc.sessionMaxAge), rotatedExpiry)
+}
+
+func TestService_RefreshToken_BeyondSessionCap(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	svc.sessionMaxAge = 30 * 24 * time.Hour
+
+	repo.getRefreshTokenByTokenFunc = func(ctx context.Context, token string) (entities.RefreshToken, error) {
+		return entities.RefreshToken{
+			ID:               uuid.New(),
+			UserID:           uuid.New(),
+			Token:            token,
+			ExpiresAt:        time.Now().Add(time.Hour),
+			SessionStartedAt: time.Now().Add(-31 * 24 * time.Hour),
+		}, nil
+	}
+
+	var deleted string
+	repo.deleteRefreshTokenFunc = func(ctx context.Context, token string) error {
+		deleted = token
+		return nil
+	}
+	repo.updateRefreshTokenFunc = func(ctx context.Context, tokenID uuid.UUID, newToken string, expiresAt time.Time) error {
+		t.Fatal("refresh token must not be rotated beyond the session cap")
+		return nil
+	}
+
+	_, err := svc.RefreshToken(context.Background(), dto.RefreshTokenRequest{RefreshToken: "old_refresh_token"})
+
+	assert.ErrorIs(t, err, dto.ErrSessionExpired)
+	assert.Equal(t, "old_refresh_token", deleted)
 }
 
 func TestService_RefreshToken_NotFound(t *testing.T) {
@@ -431,48 +913,1227 @@ func TestService_GetUserByID_NotFound(t *testing.T) {
 	assert.Equal(t, dto.ErrUserNotFound, err)
 }
 
-func TestService_UpdateUser_Success(t *testing.T) {
+// stubStoredUser serves user from GetUserByID, counting the lookups, and
+// keeps it in step with UpdateUser.
+func stubStoredUser(repo *mockRepository, user *entities.User) *int {
+	var lookups int
+	repo.getUserByIDFunc = func(ctx context.Context, uid uuid.UUID) (entities.User, error) {
+		lookups++
+		return *user, nil
+	}
+	repo.updateUserFunc = func(ctx context.Context, updated entities.User) (entities.User, error) {
+		*user = updated
+		return updated, nil
+	}
+	return &lookups
+}
+
+func TestService_GetUserByID_Cached(t *testing.T) {
 	svc, repo, _ := setupTestService(t)
+	svc.users = cache.New[userCacheKey, dto.UserResponse](time.Minute)
 	ctx := context.Background()
 
-	userID := uuid.New()
-	existingUser := entities.User{
-		ID:    userID,
-		Name:  "John Doe",
-		Email: "john@example.com",
-	}
+	user := entities.User{ID: uuid.New(), Name: "John Doe", Email: "john@example.com"}
+	lookups := stubStoredUser(repo, &user)
 
-	req := dto.UpdateUserRequest{
-		Name:  "John Updated",
-		Email: "john.updated@example.com",
-	}
+	first, err := svc.GetUserByID(ctx, user.ID.String())
+	require.NoError(t, err)
+	second, err := svc.GetUserByID(ctx, user.ID.String())
+	require.NoError(t, err)
+
+	assert.Equal(t, first, second)
+	assert.Equal(t, 1, *lookups)
+}
+
+func TestService_GetUserByID_CacheInvalidatedByUpdate(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	svc.users = cache.New[userCacheKey, dto.UserResponse](time.Minute)
+	ctx := context.Background()
+
+	user := entities.User{ID: uuid.New(), Name: "John Doe", Email: "john@example.com"}
+	stubStoredUser(repo, &user)
+
+	_, err := svc.GetUserByID(ctx, user.ID.String())
+	require.NoError(t, err)
+
+	_, err = svc.UpdateUser(ctx, user.ID.String(), dto.UpdateUserRequest{Name: "John Updated"})
+	require.NoError(t, err)
+
+	resp, err := svc.GetUserByID(ctx, user.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, "John Updated", resp.Name)
+}
+
+func TestService_GetUserByID_CacheInvalidatedByDelete(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	svc.users = cache.New[userCacheKey, dto.UserResponse](time.Minute)
+	ctx := context.Background()
+
+	user := entities.User{ID: uuid.New(), Name: "John Doe", Email: "john@example.com"}
+	stubStoredUser(repo, &user)
+
+	_, err := svc.GetUserByID(ctx, user.ID.String())
+	require.NoError(t, err)
 
+	require.NoError(t, svc.DeleteUser(ctx, user.ID.String()))
 	repo.getUserByIDFunc = func(ctx context.Context, uid uuid.UUID) (entities.User, error) {
-		return existingUser, nil
+		return entities.User{}, sql.ErrNoRows
 	}
 
-	repo.updateUserFunc = func(ctx context.Context, user entities.User) (entities.User, error) {
-		return user, nil
-	}
+	_, err = svc.GetUserByID(ctx, user.ID.String())
+	assert.Equal(t, dto.ErrUserNotFound, err)
+}
 
-	resp, err := svc.UpdateUser(ctx, userID.String(), req)
+func TestService_GetUserByID_CacheScopedToTenant(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	svc.users = cache.New[userCacheKey, dto.UserResponse](time.Minute)
 
-	assert.NoError(t, err)
-	assert.Equal(t, req.Name, resp.Name)
-	assert.Equal(t, req.Email, resp.Email)
+	user := entities.User{ID: uuid.New(), Name: "John Doe", Email: "john@example.com"}
+	lookups := stubStoredUser(repo, &user)
+
+	_, err := svc.GetUserByID(tenant.WithTenantID(context.Background(), "acme"), user.ID.String())
+	require.NoError(t, err)
+	_, err = svc.GetUserByID(tenant.WithTenantID(context.Background(), "globex"), user.ID.String())
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, *lookups)
 }
 
-func TestService_DeleteUser_Success(t *testing.T) {
+func TestService_GetUserByID_CacheInvalidatedByStatusChange(t *testing.T) {
 	svc, repo, _ := setupTestService(t)
+	svc.users = cache.New[userCacheKey, dto.UserResponse](time.Minute)
 	ctx := context.Background()
 
-	userID := uuid.New()
+	user := entities.User{ID: uuid.New(), Name: "John Doe", Email: "john@example.com"}
+	lookups := stubStoredUser(repo, &user)
 
-	repo.deleteUserFunc = func(ctx context.Context, uid uuid.UUID) error {
-		return nil
-	}
+	_, err := svc.GetUserByID(ctx, user.ID.String())
+	require.NoError(t, err)
 
-	err := svc.DeleteUser(ctx, userID.String())
+	require.NoError(t, svc.SetUserStatus(ctx, user.ID.String(), entities.UserStatusSuspended))
 
-	assert.NoError(t, err)
+	_, err = svc.GetUserByID(ctx, user.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, 2, *lookups)
+}
+
+func TestService_GetUserByID_CacheDisabled(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	ctx := context.Background()
+
+	user := entities.User{ID: uuid.New(), Name: "John Doe", Email: "john@example.com"}
+	lookups := stubStoredUser(repo, &user)
+
+	for i := 0; i < 3; i++ {
+		_, err := svc.GetUserByID(ctx, user.ID.String())
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 3, *lookups)
+}
+
+func TestService_UpdateUser_Success(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	existingUser := entities.User{
+		ID:    userID,
+		Name:  "John Doe",
+		Email: "john@example.com",
+	}
+
+	req := dto.UpdateUserRequest{
+		Name:  "John Updated",
+		Email: "john.updated@example.com",
+	}
+
+	repo.getUserByIDFunc = func(ctx context.Context, uid uuid.UUID) (entities.User, error) {
+		return existingUser, nil
+	}
+
+	repo.updateUserFunc = func(ctx context.Context, user entities.User) (entities.User, error) {
+		return user, nil
+	}
+
+	resp, err := svc.UpdateUser(ctx, userID.String(), req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, req.Name, resp.Name)
+	assert.Equal(t, req.Email, resp.Email)
+}
+
+func TestService_UpdateUser_EmailTaken(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+
+	userID := uuid.New()
+	repo.getUserByIDFunc = func(ctx context.Context, uid uuid.UUID) (entities.User, error) {
+		return entities.User{ID: userID, Name: "John Doe", Email: "john@example.com"}, nil
+	}
+	repo.emailExistsFunc = func(ctx context.Context, email string) (bool, error) {
+		return email == "jane@example.com", nil
+	}
+	repo.updateUserFunc = func(ctx context.Context, user entities.User) (entities.User, error) {
+		t.Fatal("UpdateUser must not run for a taken email")
+		return user, nil
+	}
+
+	_, err := svc.UpdateUser(context.Background(), userID.String(), dto.UpdateUserRequest{Email: "jane@example.com"})
+
+	assert.Equal(t, dto.ErrEmailAlreadyExists, err)
+	assert.Equal(t, []string{"jane@example.com"}, repo.lockedEmails)
+}
+
+func TestService_DeleteUser_Success(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	ctx := context.Background()
+
+	userID := uuid.New()
+
+	repo.deleteUserFunc = func(ctx context.Context, uid uuid.UUID) error {
+		return nil
+	}
+
+	err := svc.DeleteUser(ctx, userID.String())
+
+	assert.NoError(t, err)
+}
+
+func TestService_Login_RecordsTokensIssued(t *testing.T) {
+	svc, repo, mock := setupTestService(t)
+	expectUserRoles(mock, "user")
+	reader := useTokenMetricsReader(svc)
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password123"), 4)
+	repo.getUserByEmailFunc = func(ctx context.Context, email string) (entities.User, error) {
+		return entities.User{ID: uuid.New(), Email: email, Password: string(hashedPassword)}, nil
+	}
+
+	_, err := svc.Login(context.Background(), dto.LoginRequest{Email: "john@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	counts := tokensIssuedByType(t, reader)
+	assert.Equal(t, int64(1), counts["access"])
+	assert.Equal(t, int64(1), counts["refresh"])
+}
+
+func TestService_RefreshToken_RecordsTokensIssued(t *testing.T) {
+	svc, repo, mock := setupTestService(t)
+	reader := useTokenMetricsReader(svc)
+	expectUserRoles(mock, "user")
+
+	repo.getRefreshTokenByTokenFunc = func(ctx context.Context, token string) (entities.RefreshToken, error) {
+		return entities.RefreshToken{
+			ID:        uuid.New(),
+			UserID:    uuid.New(),
+			Token:     token,
+			ExpiresAt: time.Now().Add(time.Hour),
+		}, nil
+	}
+
+	_, err := svc.RefreshToken(context.Background(), dto.RefreshTokenRequest{RefreshToken: "valid_refresh_token"})
+	require.NoError(t, err)
+
+	counts := tokensIssuedByType(t, reader)
+	assert.Equal(t, int64(1), counts["access"])
+	assert.Equal(t, int64(1), counts["refresh"])
+}
+
+func TestService_Login_Failure_RecordsNoTokens(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	reader := useTokenMetricsReader(svc)
+
+	repo.getUserByEmailFunc = func(ctx context.Context, email string) (entities.User, error) {
+		return entities.User{}, sql.ErrNoRows
+	}
+
+	_, err := svc.Login(context.Background(), dto.LoginRequest{Email: "john@example.com", Password: "password123"})
+	require.Error(t, err)
+
+	assert.Empty(t, tokensIssuedByType(t, reader))
+}
+
+func TestService_RevokeSessions_Success(t *testing.T) {
+	svc, repo, mock := setupTestService(t)
+	ctx := context.Background()
+
+	userID := uuid.New()
+
+	// Warm the permission cache so invalidation is observable as a reload
+	permissionsQuery := "SELECT DISTINCT p.name, p.resource, p.action"
+	permissionRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"name", "resource", "action"}).AddRow("user.read", "user", "read")
+	}
+	mock.ExpectQuery(permissionsQuery).WillReturnRows(permissionRows())
+	_, err := svc.authorizer.HasPermission(ctx, userID.String(), "user.read")
+	require.NoError(t, err)
+
+	repo.getUserByIDFunc = func(ctx context.Context, uid uuid.UUID) (entities.User, error) {
+		return entities.User{ID: uid}, nil
+	}
+	var deletedFor uuid.UUID
+	repo.deleteRefreshTokensByUserIDFunc = func(ctx context.Context, uid uuid.UUID) error {
+		deletedFor = uid
+		return nil
+	}
+
+	err = svc.RevokeSessions(ctx, userID.String())
+
+	require.NoError(t, err)
+	assert.Equal(t, userID, deletedFor)
+	assert.Equal(t, 1, repo.tokenVersions[userID])
+
+	mock.ExpectQuery(permissionsQuery).WillReturnRows(permissionRows())
+	_, err = svc.authorizer.HasPermission(ctx, userID.String(), "user.read")
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_RevokeSessions_UserNotFound(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	ctx := context.Background()
+
+	repo.getUserByIDFunc = func(ctx context.Context, uid uuid.UUID) (entities.User, error) {
+		return entities.User{}, sql.ErrNoRows
+	}
+	repo.deleteRefreshTokensByUserIDFunc = func(ctx context.Context, uid uuid.UUID) error {
+		t.Fatal("refresh tokens must not be deleted for an unknown user")
+		return nil
+	}
+
+	err := svc.RevokeSessions(ctx, uuid.New().String())
+
+	assert.ErrorIs(t, err, dto.ErrUserNotFound)
+	assert.Empty(t, repo.tokenVersions)
+}
+
+func TestService_SetUserStatus_SuspendThenLogin(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	ctx := context.Background()
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	user := entities.User{
+		ID:       uuid.New(),
+		Email:    "john@example.com",
+		Password: string(hashed),
+		Status:   entities.UserStatusActive,
+	}
+	repo.setUserStatusFunc = func(ctx context.Context, uid uuid.UUID, status string) error {
+		assert.Equal(t, user.ID, uid)
+		user.Status = status
+		return nil
+	}
+	repo.getUserByEmailFunc = func(ctx context.Context, email string) (entities.User, error) {
+		return user, nil
+	}
+
+	err = svc.SetUserStatus(ctx, user.ID.String(), entities.UserStatusSuspended)
+	require.NoError(t, err)
+	assert.Equal(t, 1, repo.tokenVersions[user.ID])
+
+	_, err = svc.Login(ctx, dto.LoginRequest{Email: user.Email, Password: "password123"})
+
+	assert.ErrorIs(t, err, dto.ErrAccountSuspended)
+}
+
+func TestService_SetUserStatus_SuspendThenRefresh(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	ctx := context.Background()
+
+	user := entities.User{ID: uuid.New(), Status: entities.UserStatusActive}
+	repo.setUserStatusFunc = func(ctx context.Context, uid uuid.UUID, status string) error {
+		user.Status = status
+		return nil
+	}
+	repo.getUserByIDFunc = func(ctx context.Context, uid uuid.UUID) (entities.User, error) {
+		return user, nil
+	}
+	repo.getRefreshTokenByTokenFunc = func(ctx context.Context, token string) (entities.RefreshToken, error) {
+		return entities.RefreshToken{
+			ID:        uuid.New(),
+			UserID:    user.ID,
+			Token:     token,
+			ExpiresAt: time.Now().Add(time.Hour),
+		}, nil
+	}
+	repo.updateRefreshTokenFunc = func(ctx context.Context, tokenID uuid.UUID, newToken string, expiresAt time.Time) error {
+		t.Fatal("a suspended user's refresh token must not be rotated")
+		return nil
+	}
+
+	err := svc.SetUserStatus(ctx, user.ID.String(), entities.UserStatusSuspended)
+	require.NoError(t, err)
+
+	_, err = svc.RefreshToken(ctx, dto.RefreshTokenRequest{RefreshToken: "valid_refresh_token"})
+
+	assert.ErrorIs(t, err, dto.ErrAccountSuspended)
+}
+
+func TestService_SetUserStatus_InvalidStatus(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+
+	repo.setUserStatusFunc = func(ctx context.Context, uid uuid.UUID, status string) error {
+		t.Fatal("invalid status must not reach the repository")
+		return nil
+	}
+
+	err := svc.SetUserStatus(context.Background(), uuid.New().String(), "deleted")
+
+	assert.ErrorIs(t, err, dto.ErrInvalidUserStatus)
+}
+
+func TestService_Login_StoresSessionClientInfo(t *testing.T) {
+	svc, repo, mock := setupTestService(t)
+	expectUserRoles(mock, "user")
+	ctx := context.Background()
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password123"), 4)
+	repo.getUserByEmailFunc = func(ctx context.Context, email string) (entities.User, error) {
+		return entities.User{ID: uuid.New(), Email: email, Password: string(hashedPassword)}, nil
+	}
+
+	var stored entities.RefreshToken
+	repo.createRefreshTokenFunc = func(ctx context.Context, token entities.RefreshToken) (entities.RefreshToken, error) {
+		stored = token
+		return token, nil
+	}
+
+	_, err := svc.Login(ctx, dto.LoginRequest{
+		Email:    "john@example.com",
+		Password: "password123",
+		Client:   dto.ClientInfo{UserAgent: "Mozilla/5.0", IPAddress: "203.0.113.7"},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "Mozilla/5.0", stored.UserAgent)
+	assert.Equal(t, "203.0.113.7", stored.IPAddress)
+	assert.Equal(t, []string{stored.ID.String()}, svc.jwtService.(*mockJWTService).sessionIDs)
+}
+
+func TestService_Login_RecordsLogin(t *testing.T) {
+	svc, repo, mock := setupTestService(t)
+	expectUserRoles(mock, "user")
+	ctx := context.Background()
+
+	userID := uuid.New()
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password123"), 4)
+	repo.getUserByEmailFunc = func(ctx context.Context, email string) (entities.User, error) {
+		return entities.User{ID: userID, Email: email, Password: string(hashedPassword)}, nil
+	}
+
+	var recorded []uuid.UUID
+	repo.recordLoginFunc = func(ctx context.Context, uid uuid.UUID) error {
+		recorded = append(recorded, uid)
+		return nil
+	}
+
+	_, err := svc.Login(ctx, dto.LoginRequest{Email: "john@example.com", Password: "wrong"})
+	require.ErrorIs(t, err, dto.ErrInvalidCredentials)
+	assert.Empty(t, recorded)
+
+	_, err = svc.Login(ctx, dto.LoginRequest{Email: "john@example.com", Password: "password123"})
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{userID}, recorded)
+}
+
+func TestService_ListSessions_MarksCurrent(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	current, other := uuid.New(), uuid.New()
+	started := time.Now().Add(-time.Hour)
+
+	repo.listRefreshTokensByUserIDFunc = func(ctx context.Context, uid uuid.UUID) ([]entities.RefreshToken, error) {
+		assert.Equal(t, userID, uid)
+		return []entities.RefreshToken{
+			{ID: other, UserID: userID, UserAgent: "curl/8.0", IPAddress: "198.51.100.1", SessionStartedAt: started},
+			{ID: current, UserID: userID, UserAgent: "Mozilla/5.0", IPAddress: "198.51.100.2", SessionStartedAt: started},
+		}, nil
+	}
+
+	sessions, err := svc.ListSessions(ctx, userID.String(), current.String())
+
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, other.String(), sessions[0].ID)
+	assert.False(t, sessions[0].Current)
+	assert.Equal(t, "curl/8.0", sessions[0].UserAgent)
+	assert.True(t, sessions[1].Current)
+	assert.Equal(t, started, sessions[1].CreatedAt)
+}
+
+func TestService_ListUsers(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+
+	userID := uuid.New()
+	var got database.ListQuery
+	repo.listUsersFunc = func(ctx context.Context, q database.ListQuery) ([]entities.User, error) {
+		got = q
+		return []entities.User{{ID: userID, Name: "Alice", Email: "alice@example.com", Status: entities.UserStatusActive}}, nil
+	}
+
+	resp, err := svc.ListUsers(context.Background(), dto.ListUsersRequest{
+		Sort:   "-created_at",
+		Limit:  10,
+		Email:  "example",
+		Status: entities.UserStatusActive,
+	})
+
+	require.NoError(t, err)
+	require.Len(t, resp.Users, 1)
+	assert.Equal(t, userID.String(), resp.Users[0].ID)
+	assert.Equal(t, database.ListQuery{
+		Filters: []database.Filter{
+			{Column: "email", Op: database.OpContains, Value: "example"},
+			{Column: "status", Op: database.OpEq, Value: entities.UserStatusActive},
+		},
+		Sort:  []database.SortField{{Column: "created_at", Desc: true}},
+		Limit: 10,
+	}, got)
+}
+
+func TestService_ListUsers_CursorMode(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	repo.listUsersFunc = func(ctx context.Context, q database.ListQuery) ([]entities.User, error) {
+		t.Fatal("offset listing used in cursor mode")
+		return nil, nil
+	}
+
+	var gotCursor string
+	repo.listUsersAfterFunc = func(ctx context.Context, q database.ListQuery, cursor string) ([]entities.User, string, error) {
+		gotCursor = cursor
+		assert.Equal(t, 5, q.Limit)
+		return []entities.User{{ID: uuid.New(), Name: "Alice"}}, "next-page", nil
+	}
+
+	resp, err := svc.ListUsers(context.Background(), dto.ListUsersRequest{Cursor: "this-page", Limit: 5})
+
+	require.NoError(t, err)
+	assert.Equal(t, "this-page", gotCursor)
+	assert.Len(t, resp.Users, 1)
+	assert.Equal(t, "next-page", resp.NextCursor)
+}
+
+func TestService_ListUsers_InvalidQuery(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	repo.listUsersFunc = func(ctx context.Context, q database.ListQuery) ([]entities.User, error) {
+		return nil, fmt.Errorf("%w: unknown sort column %q", database.ErrInvalidListQuery, "password")
+	}
+
+	_, err := svc.ListUsers(context.Background(), dto.ListUsersRequest{Sort: "password"})
+
+	assert.ErrorIs(t, err, dto.ErrInvalidListQuery)
+}
+
+func TestService_RevokeSession_Success(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	ctx := context.Background()
+
+	userID, sessionID := uuid.New(), uuid.New()
+
+	var deleted uuid.UUID
+	repo.deleteRefreshTokenByIDFunc = func(ctx context.Context, uid, tokenID uuid.UUID) error {
+		assert.Equal(t, userID, uid)
+		deleted = tokenID
+		return nil
+	}
+
+	err := svc.RevokeSession(ctx, userID.String(), sessionID.String())
+
+	require.NoError(t, err)
+	assert.Equal(t, sessionID, deleted)
+	assert.Equal(t, []string{sessionID.String()}, svc.jwtService.(*mockJWTService).revokedSessionIDs)
+}
+
+func TestService_RevokeSession_NotFound(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	ctx := context.Background()
+
+	repo.deleteRefreshTokenByIDFunc = func(ctx context.Context, userID, tokenID uuid.UUID) error {
+		return sql.ErrNoRows
+	}
+
+	err := svc.RevokeSession(ctx, uuid.New().String(), uuid.New().String())
+	assert.Equal(t, dto.ErrSessionNotFound, err)
+
+	err = svc.RevokeSession(ctx, uuid.New().String(), "not-a-uuid")
+	assert.Equal(t, dto.ErrSessionNotFound, err)
+
+	assert.Empty(t, svc.jwtService.(*mockJWTService).revokedSessionIDs)
+}
+
+func TestService_EnrollTwoFactor(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	repo.getUserByIDFunc = func(ctx context.Context, uid uuid.UUID) (entities.User, error) {
+		return entities.User{ID: uid, Email: "john@example.com"}, nil
+	}
+
+	var stored string
+	repo.saveTwoFactorSecretFunc = func(ctx context.Context, uid uuid.UUID, secret string) error {
+		assert.Equal(t, userID, uid)
+		stored = secret
+		return nil
+	}
+
+	resp, err := svc.EnrollTwoFactor(ctx, userID.String())
+
+	require.NoError(t, err)
+	assert.NotEmpty(t, resp.Secret)
+	assert.Contains(t, resp.OTPAuthURL, "secret="+resp.Secret)
+	assert.Contains(t, resp.OTPAuthURL, "Template:john@example.com")
+
+	assert.Len(t, resp.BackupCodes, backupCodeCount)
+	assert.NotEqual(t, resp.Secret, stored, "secret must be stored encrypted")
+	decrypted, err := helpers.Decrypt(testTwoFactorKey, stored)
+	require.NoError(t, err)
+	assert.Equal(t, resp.Secret, decrypted)
+}
+
+func TestService_EnrollTwoFactor_AlreadyEnabled(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	ctx := context.Background()
+
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	stubTwoFactor(t, repo, secret, true)
+	repo.saveTwoFactorSecretFunc = func(ctx context.Context, userID uuid.UUID, secret string) error {
+		t.Fatal("an enabled secret must not be replaced")
+		return nil
+	}
+
+	_, err = svc.EnrollTwoFactor(ctx, uuid.NewString())
+
+	assert.Equal(t, dto.ErrTwoFactorAlreadyEnabled, err)
+}
+
+func TestService_VerifyTwoFactor_CorrectCode(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	ctx := context.Background()
+
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	stubTwoFactor(t, repo, secret, false)
+
+	enabled := false
+	repo.enableTwoFactorFunc = func(ctx context.Context, userID uuid.UUID) error {
+		enabled = true
+		return nil
+	}
+
+	code, err := totp.Code(secret, time.Now())
+	require.NoError(t, err)
+
+	err = svc.VerifyTwoFactor(ctx, uuid.NewString(), dto.TwoFactorVerifyRequest{Code: code})
+
+	require.NoError(t, err)
+	assert.True(t, enabled)
+}
+
+func TestService_VerifyTwoFactor_IncorrectCode(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	ctx := context.Background()
+
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	stubTwoFactor(t, repo, secret, false)
+	repo.enableTwoFactorFunc = func(ctx context.Context, userID uuid.UUID) error {
+		t.Fatal("two-factor must not be enabled with a wrong code")
+		return nil
+	}
+
+	code, err := totp.Code(secret, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+
+	err = svc.VerifyTwoFactor(ctx, uuid.NewString(), dto.TwoFactorVerifyRequest{Code: code})
+
+	assert.Equal(t, dto.ErrInvalidTwoFactorCode, err)
+}
+
+func TestService_VerifyTwoFactor_NotEnrolled(t *testing.T) {
+	svc, _, _ := setupTestService(t)
+
+	err := svc.VerifyTwoFactor(context.Background(), uuid.NewString(), dto.TwoFactorVerifyRequest{Code: "123456"})
+
+	assert.Equal(t, dto.ErrTwoFactorNotEnrolled, err)
+}
+
+func TestService_Login_TwoFactor(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	validCode, err := totp.Code(secret, time.Now())
+	require.NoError(t, err)
+	wrongCode, err := totp.Code(secret, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		code    string
+		wantErr error
+	}{
+		{name: "challenge without code", code: "", wantErr: dto.ErrTwoFactorRequired},
+		{name: "correct code", code: validCode},
+		{name: "incorrect code", code: wrongCode, wantErr: dto.ErrInvalidTwoFactorCode},
+	}
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password123"), 4)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, repo, mock := setupTestService(t)
+			expectUserRoles(mock, "user")
+			stubTwoFactor(t, repo, secret, true)
+			repo.getUserByEmailFunc = func(ctx context.Context, email string) (entities.User, error) {
+				return entities.User{ID: uuid.New(), Email: email, Password: string(hashedPassword)}, nil
+			}
+
+			issued := false
+			repo.createRefreshTokenFunc = func(ctx context.Context, token entities.RefreshToken) (entities.RefreshToken, error) {
+				issued = true
+				return token, nil
+			}
+
+			_, err := svc.Login(context.Background(), dto.LoginRequest{
+				Email:    "john@example.com",
+				Password: "password123",
+				TOTPCode: tt.code,
+			})
+
+			if tt.wantErr != nil {
+				assert.Equal(t, tt.wantErr, err)
+				assert.False(t, issued, "no session may be created before the second step")
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, issued)
+		})
+	}
+}
+
+func TestService_Login_TwoFactorCodeReplay(t *testing.T) {
+	svc, repo, mock := setupTestService(t)
+	expectUserRoles(mock, "user")
+
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	stubTwoFactor(t, repo, secret, true)
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password123"), 4)
+	userID := uuid.New()
+	repo.getUserByEmailFunc = func(ctx context.Context, email string) (entities.User, error) {
+		return entities.User{ID: userID, Email: email, Password: string(hashedPassword)}, nil
+	}
+
+	code, err := totp.Code(secret, time.Now())
+	require.NoError(t, err)
+	req := dto.LoginRequest{Email: "john@example.com", Password: "password123", TOTPCode: code}
+
+	_, err = svc.Login(context.Background(), req)
+	require.NoError(t, err)
+
+	_, err = svc.Login(context.Background(), req)
+	assert.Equal(t, dto.ErrInvalidTwoFactorCode, err)
+}
+
+func TestService_EnrollTwoFactor_NoKey(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	svc.twoFactorKey = ""
+	repo.getUserByIDFunc = func(ctx context.Context, uid uuid.UUID) (entities.User, error) {
+		return entities.User{ID: uid, Email: "john@example.com"}, nil
+	}
+
+	_, err := svc.EnrollTwoFactor(context.Background(), uuid.NewString())
+
+	assert.Equal(t, dto.ErrTwoFactorUnavailable, err)
+}
+
+// stubBackupCodes keeps backup code hashes in memory, mirroring the
+// single-use semantics of the repository.
+func stubBackupCodes(repo *mockRepository) {
+	unused := make(map[string]bool)
+	repo.replaceBackupCodesFunc = func(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+		clear(unused)
+		for _, hash := range codeHashes {
+			unused[hash] = true
+		}
+		return nil
+	}
+	repo.consumeBackupCodeFunc = func(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+		if !unused[codeHash] {
+			return false, nil
+		}
+		delete(unused, codeHash)
+		return true, nil
+	}
+}
+
+func loginWithBackupCode(svc *service, code string) error {
+	_, err := svc.Login(context.Background(), dto.LoginRequest{
+		Email:      "john@example.com",
+		Password:   "password123",
+		BackupCode: code,
+	})
+	return err
+}
+
+func setupBackupCodeLogin(t *testing.T) (*service, sqlmock.Sqlmock, []string) {
+	svc, repo, mock := setupTestService(t)
+
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	stubTwoFactor(t, repo, secret, true)
+	stubBackupCodes(repo)
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password123"), 4)
+	repo.getUserByEmailFunc = func(ctx context.Context, email string) (entities.User, error) {
+		return entities.User{ID: uuid.New(), Email: email, Password: string(hashedPassword)}, nil
+	}
+
+	resp, err := svc.RegenerateBackupCodes(context.Background(), uuid.NewString())
+	require.NoError(t, err)
+	require.Len(t, resp.BackupCodes, backupCodeCount)
+
+	return svc, mock, resp.BackupCodes
+}
+
+func TestService_Login_BackupCodeWorksOnce(t *testing.T) {
+	svc, mock, codes := setupBackupCodeLogin(t)
+	expectUserRoles(mock, "user")
+	expectUserRoles(mock, "user")
+
+	require.NoError(t, loginWithBackupCode(svc, codes[0]))
+
+	assert.Equal(t, dto.ErrInvalidTwoFactorCode, loginWithBackupCode(svc, codes[0]))
+	assert.NoError(t, loginWithBackupCode(svc, strings.ToUpper(codes[1])), "codes are case-insensitive")
+}
+
+func TestService_Login_InvalidBackupCode(t *testing.T) {
+	svc, _, _ := setupBackupCodeLogin(t)
+
+	assert.Equal(t, dto.ErrInvalidTwoFactorCode, loginWithBackupCode(svc, "aaaaa-bbbbb"))
+}
+
+func TestService_RegenerateBackupCodes_InvalidatesPreviousSet(t *testing.T) {
+	svc, mock, old := setupBackupCodeLogin(t)
+	expectUserRoles(mock, "user")
+
+	resp, err := svc.RegenerateBackupCodes(context.Background(), uuid.NewString())
+	require.NoError(t, err)
+
+	assert.Equal(t, dto.ErrInvalidTwoFactorCode, loginWithBackupCode(svc, old[0]))
+	assert.NoError(t, loginWithBackupCode(svc, resp.BackupCodes[0]))
+}
+
+func TestService_RegenerateBackupCodes_RequiresTwoFactor(t *testing.T) {
+	svc, _, _ := setupTestService(t)
+
+	_, err := svc.RegenerateBackupCodes(context.Background(), uuid.NewString())
+
+	assert.Equal(t, dto.ErrTwoFactorNotEnrolled, err)
+}
+
+// enqueuedEvents returns the events svc wrote to the outbox, failing the
+// test if any was written outside a transaction.
+func enqueuedEvents(t *testing.T, svc *service) []webhook.Event {
+	var events []webhook.Event
+	for _, e := range svc.repo.(*mockRepository).enqueued {
+		assert.True(t, e.inTx, "event %s enqueued outside a transaction", e.event.Type)
+		events = append(events, e.event)
+	}
+	return events
+}
+
+func loginFrom(t *testing.T, userAgent string, sessions []entities.RefreshToken) (*service, entities.User) {
+	svc, repo, mock := setupTestService(t)
+	expectUserRoles(mock, "user")
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password123"), 4)
+	user := entities.User{ID: uuid.New(), Email: "john@example.com", Password: string(hashedPassword)}
+	repo.getUserByEmailFunc = func(ctx context.Context, email string) (entities.User, error) {
+		return user, nil
+	}
+	repo.listRefreshTokensByUserIDFunc = func(ctx context.Context, userID uuid.UUID) ([]entities.RefreshToken, error) {
+		return sessions, nil
+	}
+
+	_, err := svc.Login(context.Background(), dto.LoginRequest{
+		Email:    user.Email,
+		Password: "password123",
+		Client:   dto.ClientInfo{UserAgent: userAgent, IPAddress: "203.0.113.7"},
+	})
+	require.NoError(t, err)
+
+	return svc, user
+}
+
+func TestService_Login_NewDeviceEnqueuesEvent(t *testing.T) {
+	svc, user := loginFrom(t, "Mozilla/5.0", []entities.RefreshToken{{UserAgent: "curl/8.0"}})
+
+	events := enqueuedEvents(t, svc)
+	require.Len(t, events, 1)
+	assert.Equal(t, webhook.EventNewDeviceLogin, events[0].Type)
+	assert.Equal(t, user.ID.String(), events[0].UserID)
+	assert.Equal(t, "Mozilla/5.0", events[0].Data["user_agent"])
+	assert.Equal(t, "203.0.113.7", events[0].Data["ip_address"])
+	assert.Equal(t, svc.jwtService.(*mockJWTService).sessionIDs[0], events[0].Data["session_id"])
+}
+
+func TestService_Login_KnownDeviceEnqueuesNothing(t *testing.T) {
+	svc, _ := loginFrom(t, "Mozilla/5.0", []entities.RefreshToken{{UserAgent: "Mozilla/5.0"}})
+
+	assert.Empty(t, enqueuedEvents(t, svc))
+}
+
+func TestService_SetUserStatus_SuspendEnqueuesAccountLocked(t *testing.T) {
+	svc, _, _ := setupTestService(t)
+	userID := uuid.NewString()
+
+	require.NoError(t, svc.SetUserStatus(context.Background(), userID, entities.UserStatusSuspended))
+	require.NoError(t, svc.SetUserStatus(context.Background(), userID, entities.UserStatusActive))
+
+	events := enqueuedEvents(t, svc)
+	require.Len(t, events, 1)
+	assert.Equal(t, webhook.EventAccountLocked, events[0].Type)
+	assert.Equal(t, userID, events[0].UserID)
+	assert.Equal(t, entities.UserStatusSuspended, events[0].Data["status"])
+}
+
+func TestService_SetUserStatus_EnqueueFailureRollsBack(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	repo.enqueueEventFunc = func(ctx context.Context, event webhook.Event) error {
+		return errors.New("outbox unavailable")
+	}
+
+	err := svc.SetUserStatus(context.Background(), uuid.NewString(), entities.UserStatusSuspended)
+
+	assert.Error(t, err)
+}
+
+func sentEmails(svc *service) []sentEmail {
+	return svc.mailer.(*stubSender).sent
+}
+
+// captureAccountTokens records the hashes stored by CreateAccountToken.
+func captureAccountTokens(repo *mockRepository) *[]entities.AccountToken {
+	var stored []entities.AccountToken
+	repo.createAccountTokenFunc = func(ctx context.Context, token entities.AccountToken) error {
+		stored = append(stored, token)
+		return nil
+	}
+	return &stored
+}
+
+var emailLinkPattern = regexp.MustCompile(`href="([^"?]*)\?token=([^"]+)"`)
+
+// linkFromBody returns the URL without query and the token of the link in
+// an email body, checking the token is the one whose hash was stored.
+func linkFromBody(t *testing.T, body string, hash string) (link, token string) {
+	match := emailLinkPattern.FindStringSubmatch(body)
+	require.NotNil(t, match, "email body has no token link:\n%s", body)
+	assert.Equal(t, hash, helpers.HashToken(match[2]), "emailed token does not match the stored hash")
+	return match[1], match[2]
+}
+
+func TestService_Register_SendsVerificationEmail(t *testing.T) {
+	svc, repo, mock := setupTestService(t)
+	mock.ExpectExec(`INSERT INTO user_roles`).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectUserRoles(mock, "user")
+	stored := captureAccountTokens(repo)
+
+	_, err := svc.Register(context.Background(), dto.RegisterRequest{
+		Name:     "John Doe",
+		Email:    "john@example.com",
+		Password: "password123",
+	})
+	require.NoError(t, err)
+
+	require.Len(t, *stored, 1)
+	assert.Equal(t, entities.AccountTokenPurposeVerifyEmail, (*stored)[0].Purpose)
+	assert.WithinDuration(t, time.Now().Add(verificationTokenTTL), (*stored)[0].ExpiresAt, time.Minute)
+
+	emails := sentEmails(svc)
+	require.Len(t, emails, 1)
+	assert.Equal(t, "john@example.com", emails[0].to)
+	assert.Equal(t, "Verify your email address", emails[0].subject)
+	assert.Contains(t, emails[0].body, "Hi John Doe")
+	assert.Contains(t, emails[0].body, "expires in 24 hours")
+	link, _ := linkFromBody(t, emails[0].body, (*stored)[0].TokenHash)
+	assert.Equal(t, testPublicBaseURL+verifyEmailPath, link)
+}
+
+func TestService_Register_MailFailureDoesNotFail(t *testing.T) {
+	svc, _, mock := setupTestService(t)
+	mock.ExpectExec(`INSERT INTO user_roles`).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectUserRoles(mock, "user")
+	svc.mailer.(*stubSender).err = errors.New("smtp unavailable")
+
+	_, err := svc.Register(context.Background(), dto.RegisterRequest{
+		Name:     "John Doe",
+		Email:    "john@example.com",
+		Password: "password123",
+	})
+
+	assert.NoError(t, err)
+}
+
+func TestService_VerifyEmail(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	userID := uuid.New()
+	repo.consumeAccountTokenFunc = func(ctx context.Context, purpose, tokenHash string) (uuid.UUID, error) {
+		if purpose == entities.AccountTokenPurposeVerifyEmail && tokenHash == helpers.HashToken("good-token") {
+			return userID, nil
+		}
+		return uuid.Nil, sql.ErrNoRows
+	}
+	var verified uuid.UUID
+	repo.markEmailVerifiedFunc = func(ctx context.Context, id uuid.UUID) error {
+		verified = id
+		return nil
+	}
+
+	require.NoError(t, svc.VerifyEmail(context.Background(), dto.VerifyEmailRequest{Token: "good-token"}))
+	assert.Equal(t, userID, verified)
+
+	err := svc.VerifyEmail(context.Background(), dto.VerifyEmailRequest{Token: "bad-token"})
+	assert.Equal(t, dto.ErrInvalidAccountToken, err)
+}
+
+func TestService_RequestPasswordReset_SendsResetEmail(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	user := entities.User{ID: uuid.New(), Name: "John Doe", Email: "john@example.com"}
+	repo.getUserByEmailFunc = func(ctx context.Context, email string) (entities.User, error) {
+		return user, nil
+	}
+	stored := captureAccountTokens(repo)
+
+	require.NoError(t, svc.RequestPasswordReset(context.Background(), dto.PasswordResetRequest{Email: user.Email}))
+	require.NoError(t, svc.Shutdown())
+
+	require.Len(t, *stored, 1)
+	assert.Equal(t, user.ID, (*stored)[0].UserID)
+	assert.Equal(t, entities.AccountTokenPurposePasswordReset, (*stored)[0].Purpose)
+	assert.WithinDuration(t, time.Now().Add(passwordResetTokenTTL), (*stored)[0].ExpiresAt, time.Minute)
+
+	emails := sentEmails(svc)
+	require.Len(t, emails, 1)
+	assert.Equal(t, user.Email, emails[0].to)
+	assert.Equal(t, "Reset your password", emails[0].subject)
+	assert.Contains(t, emails[0].body, "expires in 1 hour")
+	link, _ := linkFromBody(t, emails[0].body, (*stored)[0].TokenHash)
+	assert.Equal(t, testPublicBaseURL+passwordResetPath, link)
+}
+
+func TestService_RequestPasswordReset_MailFailureIsHidden(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	repo.getUserByEmailFunc = func(ctx context.Context, email string) (entities.User, error) {
+		return entities.User{ID: uuid.New(), Name: "John Doe", Email: email}, nil
+	}
+	svc.mailer.(*stubSender).err = errors.New("smtp unavailable")
+
+	err := svc.RequestPasswordReset(context.Background(), dto.PasswordResetRequest{Email: "john@example.com"})
+
+	assert.NoError(t, err)
+	require.NoError(t, svc.Shutdown())
+}
+
+func TestService_RequestPasswordReset_UnknownEmail(t *testing.T) {
+	svc, _, _ := setupTestService(t)
+
+	err := svc.RequestPasswordReset(context.Background(), dto.PasswordResetRequest{Email: "nobody@example.com"})
+
+	assert.NoError(t, err)
+	assert.Empty(t, sentEmails(svc))
+}
+
+func TestService_ResetPassword(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	userID := uuid.New()
+	repo.consumeAccountTokenFunc = func(ctx context.Context, purpose, tokenHash string) (uuid.UUID, error) {
+		assert.Equal(t, entities.AccountTokenPurposePasswordReset, purpose)
+		return userID, nil
+	}
+	repo.getUserByIDFunc = func(ctx context.Context, id uuid.UUID) (entities.User, error) {
+		return entities.User{ID: id, Name: "John Doe", Email: "john@example.com"}, nil
+	}
+	var newHash string
+	repo.updatePasswordFunc = func(ctx context.Context, id uuid.UUID, passwordHash string) error {
+		newHash = passwordHash
+		return nil
+	}
+	var deletedFor uuid.UUID
+	repo.deleteRefreshTokensByUserIDFunc = func(ctx context.Context, id uuid.UUID) error {
+		deletedFor = id
+		return nil
+	}
+
+	err := svc.ResetPassword(context.Background(), dto.ResetPasswordRequest{Token: "token", Password: "new-password"})
+
+	require.NoError(t, err)
+	assert.True(t, helpers.CheckPassword("new-password", newHash))
+	assert.Equal(t, userID, deletedFor)
+	assert.Equal(t, 1, repo.tokenVersions[userID])
+
+	events := enqueuedEvents(t, svc)
+	require.Len(t, events, 1)
+	assert.Equal(t, webhook.EventPasswordChanged, events[0].Type)
+
+	emails := sentEmails(svc)
+	require.Len(t, emails, 1)
+	assert.Equal(t, "john@example.com", emails[0].to)
+	assert.Equal(t, "Your password was changed", emails[0].subject)
+}
+
+func TestService_ResetPassword_InvalidToken(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	repo.updatePasswordFunc = func(ctx context.Context, id uuid.UUID, passwordHash string) error {
+		t.Fatal("password updated with an invalid token")
+		return nil
+	}
+
+	err := svc.ResetPassword(context.Background(), dto.ResetPasswordRequest{Token: "token", Password: "new-password"})
+
+	assert.Equal(t, dto.ErrInvalidAccountToken, err)
+	assert.Empty(t, repo.tokenVersions)
+}
+
+func TestService_TokenVersionBumpInvalidatesIssuedTokens(t *testing.T) {
+	tests := []struct {
+		name string
+		bump func(svc *service, userID uuid.UUID) error
+	}{
+		{
+			name: "password reset",
+			bump: func(svc *service, userID uuid.UUID) error {
+				return svc.ResetPassword(context.Background(), dto.ResetPasswordRequest{Token: "token", Password: "new-password"})
+			},
+		},
+		{
+			name: "suspension",
+			bump: func(svc *service, userID uuid.UUID) error {
+				return svc.SetUserStatus(context.Background(), userID.String(), entities.UserStatusSuspended)
+			},
+		},
+		{
+			name: "session revocation",
+			bump: func(svc *service, userID uuid.UUID) error {
+				return svc.RevokeSessions(context.Background(), userID.String())
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, repo, mock := setupTestService(t)
+			expectUserRoles(mock, "user")
+			ctx := context.Background()
+
+			hashed, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+			require.NoError(t, err)
+			user := entities.User{ID: uuid.New(), Email: "john@example.com", Password: string(hashed)}
+			lookup := func() entities.User {
+				u := user
+				u.TokenVersion = repo.tokenVersions[user.ID]
+				return u
+			}
+			repo.getUserByEmailFunc = func(ctx context.Context, email string) (entities.User, error) {
+				return lookup(), nil
+			}
+			repo.getUserByIDFunc = func(ctx context.Context, id uuid.UUID) (entities.User, error) {
+				return lookup(), nil
+			}
+			repo.consumeAccountTokenFunc = func(ctx context.Context, purpose, tokenHash string) (uuid.UUID, error) {
+				return user.ID, nil
+			}
+
+			_, err = svc.Login(ctx, dto.LoginRequest{Email: user.Email, Password: "password123"})
+			require.NoError(t, err)
+			issued := svc.jwtService.(*mockJWTService).tokenVersions
+			require.Len(t, issued, 1)
+
+			require.NoError(t, tt.bump(svc, user.ID))
+
+			current, err := svc.TokenVersion(ctx, user.ID.String())
+			require.NoError(t, err)
+			assert.Greater(t, current, issued[0], "tokens minted before the bump must no longer match")
+		})
+	}
+}
+
+func TestService_TokenVersion_InvalidUserID(t *testing.T) {
+	svc, _, _ := setupTestService(t)
+
+	_, err := svc.TokenVersion(context.Background(), "not-a-uuid")
+
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestService_TokenVersion_Cached(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	ctx := context.Background()
+	userID := uuid.New()
+
+	lookups := 0
+	repo.getTokenVersionFunc = func(ctx context.Context, id uuid.UUID) (int, error) {
+		lookups++
+		return repo.tokenVersions[id], nil
+	}
+
+	for range 3 {
+		version, err := svc.TokenVersion(ctx, userID.String())
+		require.NoError(t, err)
+		assert.Equal(t, 0, version)
+	}
+	assert.Equal(t, 1, lookups, "repeat lookups must be served from the cache")
+
+	repo.consumeAccountTokenFunc = func(ctx context.Context, purpose, tokenHash string) (uuid.UUID, error) {
+		return userID, nil
+	}
+	require.NoError(t, svc.ResetPassword(ctx, dto.ResetPasswordRequest{Token: "token", Password: "new-password"}))
+
+	version, err := svc.TokenVersion(ctx, userID.String())
+	require.NoError(t, err)
+	assert.Equal(t, 1, version, "a bump must invalidate the cached version")
+	assert.Equal(t, 2, lookups)
+}
+
+func TestService_TokenVersion_ErrorsAreNotCached(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	ctx := context.Background()
+	userID := uuid.New()
+
+	lookups := 0
+	repo.getTokenVersionFunc = func(ctx context.Context, id uuid.UUID) (int, error) {
+		lookups++
+		if lookups == 1 {
+			return 0, errors.New("connection refused")
+		}
+		return 4, nil
+	}
+
+	_, err := svc.TokenVersion(ctx, userID.String())
+	require.Error(t, err)
+
+	version, err := svc.TokenVersion(ctx, userID.String())
+	require.NoError(t, err)
+	assert.Equal(t, 4, version)
+	assert.Equal(t, 2, lookups)
+}
+
+func TestService_RevokeSession_EnqueuesSessionRevoked(t *testing.T) {
+	svc, _, _ := setupTestService(t)
+	userID := uuid.NewString()
+	sessionID := uuid.NewString()
+
+	require.NoError(t, svc.RevokeSession(context.Background(), userID, sessionID))
+
+	events := enqueuedEvents(t, svc)
+	require.Len(t, events, 1)
+	assert.Equal(t, webhook.EventSessionRevoked, events[0].Type)
+	assert.Equal(t, userID, events[0].UserID)
+	assert.Equal(t, sessionID, events[0].Data["session_id"])
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"log/slog"
 	"runtime"
+	"runtime/metrics"
 	"sync"
 	"time"
 
@@ -25,6 +26,10 @@ type MetricsCollector struct {
 	runtimeMemory     metric.Int64Gauge
 	runtimeGCCount    metric.Int64Counter
 	RequestThroughput metric.Int64Counter
+	HttpInFlight      metric.Int64UpDownCounter
+	HttpShedCount     metric.Int64Counter
+	HttpStreams       metric.Int64UpDownCounter
+	AuthTokenFailures metric.Int64Counter
 	mu                sync.RWMutex
 	startTime         time.Time
 	metricsEnabled    bool
@@ -32,8 +37,19 @@ type MetricsCollector struct {
 	queryCache        map[string]string // Cache normalized queries
 	queryCacheMu      sync.RWMutex
 	stopChan          chan struct{}
+
+	// runtimeStatsSource is config.RuntimeStatsMemStats or
+	// config.RuntimeStatsRuntimeMetrics.
+	runtimeStatsSource string
 }
 
+// minMemStatsInterval bounds how often runtime.ReadMemStats runs, since each
+// call stops the world.
+const minMemStatsInterval = 5 * time.Second
+
+// readMemStats is runtime.ReadMemStats, replaced in tests.
+var readMemStats = runtime.ReadMemStats
+
 var memStatsPool = sync.Pool{
 	New: func() interface{} {
 		return &runtime.MemStats{}
@@ -81,6 +97,8 @@ func NewMetricsCollector(logger *slog.Logger) (*MetricsCollector, error) {
 		metricsEnabled: true,
 		queryCache:     make(map[string]string, 64),
 		stopChan:       make(chan struct{}),
+
+		runtimeStatsSource: config.Get().RuntimeStatsSource,
 	}
 
 	var err error
@@ -169,6 +187,38 @@ func NewMetricsCollector(logger *slog.Logger) (*MetricsCollector, error) {
 		return nil, err
 	}
 
+	mc.HttpInFlight, err = meter.Int64UpDownCounter(
+		"http_requests_in_flight",
+		metric.WithDescription("Number of HTTP requests currently being served"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	mc.HttpShedCount, err = meter.Int64Counter(
+		"http_requests_shed_total",
+		metric.WithDescription("Total number of HTTP requests rejected by load shedding"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	mc.HttpStreams, err = meter.Int64UpDownCounter(
+		"http_stream_connections",
+		metric.WithDescription("Number of open streaming (e.g. SSE) connections"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	mc.AuthTokenFailures, err = meter.Int64Counter(
+		"auth_token_validation_failures_total",
+		metric.WithDescription("Total number of rejected access tokens by reason"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
 	logger.Info("APM metrics collector initialized")
 
 	go mc.collectRuntimeMetrics()
@@ -198,13 +248,20 @@ func getMetricsCollectionInterval() time.Duration {
 	return cfg.MetricsCollectionInterval()
 }
 
+// runtimeStatsInterval returns the collection interval, raised to
+// minMemStatsInterval when stats come from runtime.ReadMemStats.
+func runtimeStatsInterval(configured time.Duration, source string) time.Duration {
+	if source == config.RuntimeStatsMemStats && configured < minMemStatsInterval {
+		return minMemStatsInterval
+	}
+	return configured
+}
+
 func (mc *MetricsCollector) collectRuntimeMetrics() {
-	interval := getMetricsCollectionInterval()
+	interval := runtimeStatsInterval(getMetricsCollectionInterval(), mc.runtimeStatsSource)
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	mc.lastNumGC = 0
-
 	for {
 		select {
 		case <-mc.stopChan:
@@ -221,18 +278,39 @@ func (mc *MetricsCollector) recordRuntimeStats() {
 	goroutines := int64(runtime.NumGoroutine())
 	mc.runtimeGoroutines.Record(ctx, goroutines)
 
-	m := getMemStats()
-	runtime.ReadMemStats(m)
+	heapBytes, numGC := mc.readHeapStats()
 
-	mc.runtimeMemory.Record(ctx, int64(m.HeapAlloc))
+	mc.runtimeMemory.Record(ctx, int64(heapBytes))
 
-	if m.NumGC > mc.lastNumGC {
-		gcDiff := int64(m.NumGC - mc.lastNumGC)
+	if numGC > mc.lastNumGC {
+		gcDiff := int64(numGC - mc.lastNumGC)
 		mc.runtimeGCCount.Add(ctx, gcDiff)
-		mc.lastNumGC = m.NumGC
+		mc.lastNumGC = numGC
 	}
+}
 
-	putMemStats(m)
+// readHeapStats returns the bytes held by live and not yet swept heap
+// objects, HeapAlloc in runtime.MemStats terms, and the completed GC cycles.
+func (mc *MetricsCollector) readHeapStats() (heapBytes uint64, numGC uint32) {
+	if mc.runtimeStatsSource == config.RuntimeStatsRuntimeMetrics {
+		samples := []metrics.Sample{
+			{Name: "/memory/classes/heap/objects:bytes"},
+			{Name: "/gc/cycles/total:gc-cycles"},
+		}
+		metrics.Read(samples)
+		if samples[0].Value.Kind() == metrics.KindUint64 {
+			heapBytes = samples[0].Value.Uint64()
+		}
+		if samples[1].Value.Kind() == metrics.KindUint64 {
+			numGC = uint32(samples[1].Value.Uint64())
+		}
+		return heapBytes, numGC
+	}
+
+	m := getMemStats()
+	defer putMemStats(m)
+	readMemStats(m)
+	return m.HeapAlloc, m.NumGC
 }
 
 func (mc *MetricsCollector) GetUptime() time.Duration {
@@ -0,0 +1,84 @@
+package apm
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/elskow/go-microservice-template/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func newTestCollector(t *testing.T, source string) (*MetricsCollector, *sdkmetric.ManualReader) {
+	t.Setenv("RUNTIME_STATS_SOURCE", source)
+	config.Reset()
+	t.Cleanup(config.Reset)
+
+	reader := sdkmetric.NewManualReader()
+	previous := otel.GetMeterProvider()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	t.Cleanup(func() { otel.SetMeterProvider(previous) })
+
+	collector, err := NewMetricsCollector(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = collector.Shutdown() })
+	return collector, reader
+}
+
+func heapGauge(t *testing.T, reader *sdkmetric.ManualReader) int64 {
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if gauge, ok := m.Data.(metricdata.Gauge[int64]); ok && m.Name == "runtime_memory_heap_bytes" {
+				require.Len(t, gauge.DataPoints, 1)
+				return gauge.DataPoints[0].Value
+			}
+		}
+	}
+	t.Fatal("runtime_memory_heap_bytes was not recorded")
+	return 0
+}
+
+func TestRecordRuntimeStats_RuntimeMetricsSkipsReadMemStats(t *testing.T) {
+	collector, reader := newTestCollector(t, config.RuntimeStatsRuntimeMetrics)
+
+	readMemStats = func(*runtime.MemStats) { t.Error("runtime.ReadMemStats called") }
+	t.Cleanup(func() { readMemStats = runtime.ReadMemStats })
+
+	runtime.GC()
+	collector.recordRuntimeStats()
+
+	assert.Positive(t, heapGauge(t, reader))
+	assert.Positive(t, collector.lastNumGC)
+}
+
+func TestRecordRuntimeStats_MemStats(t *testing.T) {
+	collector, reader := newTestCollector(t, config.RuntimeStatsMemStats)
+
+	var calls int
+	readMemStats = func(m *runtime.MemStats) {
+		calls++
+		runtime.ReadMemStats(m)
+	}
+	t.Cleanup(func() { readMemStats = runtime.ReadMemStats })
+
+	collector.recordRuntimeStats()
+
+	assert.Equal(t, 1, calls)
+	assert.Positive(t, heapGauge(t, reader))
+}
+
+func TestRuntimeStatsInterval(t *testing.T) {
+	assert.Equal(t, minMemStatsInterval, runtimeStatsInterval(time.Second, config.RuntimeStatsMemStats))
+	assert.Equal(t, 15*time.Second, runtimeStatsInterval(15*time.Second, config.RuntimeStatsMemStats))
+	assert.Equal(t, time.Second, runtimeStatsInterval(time.Second, config.RuntimeStatsRuntimeMetrics))
+}
@@ -0,0 +1,97 @@
+// Package cache provides a small in-memory key/value cache whose entries
+// expire a fixed TTL after they are set.
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+type entry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// Cache is safe for concurrent use. Expired entries are never returned; they
+// are dropped by Get and by a sweep Set runs at most once per TTL, so the map
+// cannot grow without bound.
+type Cache[K comparable, V any] struct {
+	mu        sync.RWMutex
+	items     map[K]entry[V]
+	ttl       time.Duration
+	lastSweep time.Time
+	nowFunc   func() time.Time
+}
+
+// New returns a cache holding entries for ttl. A ttl of zero or less
+// disables caching: Set stores nothing and every Get misses.
+func New[K comparable, V any](ttl time.Duration) *Cache[K, V] {
+	return &Cache[K, V]{
+		items:   make(map[K]entry[V]),
+		ttl:     ttl,
+		nowFunc: time.Now,
+	}
+}
+
+// Get returns the value cached for key and whether it was found unexpired.
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mu.RLock()
+	e, ok := c.items[key]
+	c.mu.RUnlock()
+
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	if !c.nowFunc().Before(e.expiresAt) {
+		c.mu.Lock()
+		// Only drop the entry if no Set replaced it meanwhile
+		if cur, ok := c.items[key]; ok && cur.expiresAt.Equal(e.expiresAt) {
+			delete(c.items, key)
+		}
+		c.mu.Unlock()
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+// Set caches value for key, replacing any existing entry.
+func (c *Cache[K, V]) Set(key K, value V) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	now := c.nowFunc()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Sub(c.lastSweep) >= c.ttl {
+		c.sweepLocked(now)
+		c.lastSweep = now
+	}
+	c.items[key] = entry[V]{value: value, expiresAt: now.Add(c.ttl)}
+}
+
+// Delete drops the entry for key, if any.
+func (c *Cache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	delete(c.items, key)
+	c.mu.Unlock()
+}
+
+// Len returns the number of entries held, including expired ones not yet
+// swept.
+func (c *Cache[K, V]) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.items)
+}
+
+func (c *Cache[K, V]) sweepLocked(now time.Time) {
+	for key, e := range c.items {
+		if !now.Before(e.expiresAt) {
+			delete(c.items, key)
+		}
+	}
+}
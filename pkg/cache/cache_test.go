@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCache(ttl time.Duration) (*Cache[string, int], *time.Time) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := New[string, int](ttl)
+	c.nowFunc = func() time.Time { return now }
+	return c, &now
+}
+
+func TestCache_GetSet(t *testing.T) {
+	c, _ := newTestCache(time.Minute)
+
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+
+	c.Set("a", 1)
+	got, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, got)
+
+	c.Set("a", 2)
+	got, _ = c.Get("a")
+	assert.Equal(t, 2, got)
+}
+
+func TestCache_Expiry(t *testing.T) {
+	c, now := newTestCache(time.Minute)
+	c.Set("a", 1)
+
+	*now = now.Add(59 * time.Second)
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+
+	*now = now.Add(time.Second)
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestCache_Delete(t *testing.T) {
+	c, _ := newTestCache(time.Minute)
+	c.Set("a", 1)
+
+	c.Delete("a")
+
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+}
+
+func TestCache_SetSweepsExpiredEntries(t *testing.T) {
+	c, now := newTestCache(time.Minute)
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	*now = now.Add(2 * time.Minute)
+	c.Set("c", 3)
+
+	assert.Equal(t, 1, c.Len())
+}
+
+func TestCache_DisabledWithoutTTL(t *testing.T) {
+	c, _ := newTestCache(0)
+	c.Set("a", 1)
+
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Len())
+}
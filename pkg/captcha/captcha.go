@@ -0,0 +1,78 @@
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken is returned when the provider rejects the CAPTCHA token.
+var ErrInvalidToken = errors.New("captcha token rejected")
+
+// Verifier checks a CAPTCHA token submitted by a client.
+type Verifier interface {
+	Verify(ctx context.Context, token, remoteIP string) error
+}
+
+type siteVerifyResponse struct {
+	Success    bool     `json:"success"`
+	ErrorCodes []string `json:"error-codes"`
+}
+
+type siteVerifier struct {
+	endpoint string
+	secret   string
+	client   *http.Client
+}
+
+// NewSiteVerifier returns a Verifier for providers exposing the reCAPTCHA
+// style siteverify API (reCAPTCHA, hCaptcha, Turnstile).
+func NewSiteVerifier(endpoint, secret string) Verifier {
+	return &siteVerifier{
+		endpoint: endpoint,
+		secret:   secret,
+		client:   &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (v *siteVerifier) Verify(ctx context.Context, token, remoteIP string) error {
+	form := url.Values{
+		"secret":   {v.secret},
+		"response": {token},
+	}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to build captcha request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to verify captcha: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("captcha provider returned status %d", resp.StatusCode)
+	}
+
+	var result siteVerifyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode captcha response: %w", err)
+	}
+
+	if !result.Success {
+		return fmt.Errorf("%w: %v", ErrInvalidToken, result.ErrorCodes)
+	}
+
+	return nil
+}
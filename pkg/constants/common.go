@@ -5,12 +5,15 @@ const (
 	CtxKeyToken     = "token"
 	CtxKeyUserID    = "user_id"
 	CtxKeyRequestID = "request_id"
+	CtxKeyAuth      = "auth"
+	CtxKeyTenantID  = "tenant_id"
 )
 
 // Attribute keys for tracing and logging consistency
 const (
-	AttrKeyUserID  = "user_id"
-	AttrKeyEmail   = "email"
-	AttrKeyTraceID = "trace_id"
-	AttrKeySpanID  = "span_id"
+	AttrKeyUserID   = "user_id"
+	AttrKeyEmail    = "email"
+	AttrKeyTraceID  = "trace_id"
+	AttrKeySpanID   = "span_id"
+	AttrKeyTenantID = "tenant_id"
 )
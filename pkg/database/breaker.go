@@ -0,0 +1,186 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+// ErrCircuitOpen is returned without touching the database while the
+// circuit breaker is open.
+var ErrCircuitOpen = errors.New("database circuit breaker is open")
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreaker stops sending queries to a struggling database. It opens
+// after threshold consecutive failures, fast-fails for cooldown, then lets a
+// single probe through (half-open): success closes it, failure reopens it.
+type CircuitBreaker struct {
+	threshold    int
+	cooldown     time.Duration
+	nowFunc      func() time.Time
+	logger       *slog.Logger
+	stateChanges metric.Int64Counter
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func NewCircuitBreaker(threshold int, cooldown time.Duration, logger *slog.Logger) *CircuitBreaker {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	stateChanges, err := otel.Meter("database").Int64Counter(
+		"db_circuit_breaker_state_changes_total",
+		metric.WithDescription("Total number of database circuit breaker state transitions"),
+	)
+	if err != nil {
+		stateChanges = noop.Int64Counter{}
+	}
+
+	return &CircuitBreaker{
+		threshold:    threshold,
+		cooldown:     cooldown,
+		nowFunc:      time.Now,
+		logger:       logger,
+		stateChanges: stateChanges,
+	}
+}
+
+// State returns the current breaker state.
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// allow reports whether a query may run, moving an open breaker to
+// half-open once the cooldown has elapsed.
+func (b *CircuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.nowFunc().Sub(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.transition(BreakerHalfOpen)
+		b.probing = true
+		return nil
+	case BreakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// record feeds the outcome of an allowed query back into the breaker.
+func (b *CircuitBreaker) record(err error) {
+	failed := isBreakerFailure(err)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerHalfOpen:
+		b.probing = false
+		if failed {
+			b.open()
+		} else {
+			b.failures = 0
+			b.transition(BreakerClosed)
+		}
+	case BreakerClosed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.threshold {
+			b.open()
+		}
+	}
+}
+
+func (b *CircuitBreaker) open() {
+	b.openedAt = b.nowFunc()
+	b.transition(BreakerOpen)
+}
+
+func (b *CircuitBreaker) transition(to BreakerState) {
+	from := b.state
+	if from == to {
+		return
+	}
+	b.state = to
+
+	b.stateChanges.Add(context.Background(), 1, metric.WithAttributes(
+		attribute.String("from", from.String()),
+		attribute.String("to", to.String()),
+	))
+
+	if to == BreakerOpen {
+		b.logger.Warn("database circuit breaker opened", "failures", b.failures, "cooldown", b.cooldown)
+		return
+	}
+	b.logger.Info("database circuit breaker state changed", "from", from.String(), "to", to.String())
+}
+
+// isBreakerFailure ignores outcomes that say nothing about database health,
+// including Postgres data (class 22) and integrity constraint (class 23)
+// errors, which clients can cause at will with bad input or a duplicate
+// email.
+func isBreakerFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		case "22", "23":
+			return false
+		}
+	}
+
+	return !errors.Is(err, sql.ErrNoRows) &&
+		!errors.Is(err, context.Canceled) &&
+		!errors.Is(err, ErrCircuitOpen)
+}
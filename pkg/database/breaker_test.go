@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBreaker(threshold int, cooldown time.Duration) (*CircuitBreaker, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	breaker := NewCircuitBreaker(threshold, cooldown, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	breaker.nowFunc = func() time.Time { return now }
+	return breaker, &now
+}
+
+func TestCircuitBreaker_OpensAfterThreshold(t *testing.T) {
+	breaker, _ := newTestBreaker(3, time.Minute)
+	dbErr := errors.New("connection refused")
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, breaker.allow())
+		breaker.record(dbErr)
+	}
+
+	assert.Equal(t, BreakerOpen, breaker.State())
+	assert.ErrorIs(t, breaker.allow(), ErrCircuitOpen)
+}
+
+func TestCircuitBreaker_IgnoresNoRows(t *testing.T) {
+	breaker, _ := newTestBreaker(1, time.Minute)
+
+	require.NoError(t, breaker.allow())
+	breaker.record(sql.ErrNoRows)
+
+	assert.Equal(t, BreakerClosed, breaker.State())
+}
+
+func TestCircuitBreaker_IgnoresClientCausedErrors(t *testing.T) {
+	breaker, _ := newTestBreaker(1, time.Minute)
+
+	for _, code := range []pq.ErrorCode{"23505", "22P02"} {
+		require.NoError(t, breaker.allow())
+		breaker.record(fmt.Errorf("insert user: %w", &pq.Error{Code: code}))
+	}
+
+	assert.Equal(t, BreakerClosed, breaker.State())
+}
+
+func TestCircuitBreaker_HalfOpenProbe(t *testing.T) {
+	breaker, now := newTestBreaker(1, time.Minute)
+
+	require.NoError(t, breaker.allow())
+	breaker.record(errors.New("timeout"))
+	require.Equal(t, BreakerOpen, breaker.State())
+
+	*now = now.Add(time.Minute)
+
+	// one probe goes through, concurrent callers still fail fast
+	require.NoError(t, breaker.allow())
+	assert.Equal(t, BreakerHalfOpen, breaker.State())
+	assert.ErrorIs(t, breaker.allow(), ErrCircuitOpen)
+
+	breaker.record(errors.New("still down"))
+	assert.Equal(t, BreakerOpen, breaker.State())
+
+	*now = now.Add(time.Minute)
+	require.NoError(t, breaker.allow())
+	breaker.record(nil)
+	assert.Equal(t, BreakerClosed, breaker.State())
+}
+
+func TestTracedDB_CircuitBreaker(t *testing.T) {
+	db, mock := setupMockDB(t)
+	breaker, now := newTestBreaker(2, 30*time.Second)
+	db.SetCircuitBreaker(breaker)
+	ctx := context.Background()
+
+	query := "SELECT COUNT(*) FROM users"
+	expected := regexp.QuoteMeta(query)
+	dbErr := errors.New("connection reset by peer")
+	mock.ExpectQuery(expected).WillReturnError(dbErr)
+	mock.ExpectQuery(expected).WillReturnError(dbErr)
+
+	var count int
+	assert.ErrorIs(t, db.GetContext(ctx, &count, query), dbErr)
+	assert.ErrorIs(t, db.GetContext(ctx, &count, query), dbErr)
+
+	// open: no query reaches the database
+	assert.ErrorIs(t, db.GetContext(ctx, &count, query), ErrCircuitOpen)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	*now = now.Add(30 * time.Second)
+	mock.ExpectQuery(expected).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	require.NoError(t, db.GetContext(ctx, &count, query))
+	assert.Equal(t, 3, count)
+	assert.Equal(t, BreakerClosed, breaker.State())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,43 @@
+// Package dbtest provides a TracedDB backed by sqlmock so module tests share
+// one setup and pick up TracedDB changes in a single place.
+package dbtest
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/elskow/go-microservice-template/pkg/database"
+	"github.com/jmoiron/sqlx"
+)
+
+// NewMockTracedDB returns a TracedDB over sqlmock that matches expected
+// queries as regular expressions. The returned func closes the mock
+// connection.
+func NewMockTracedDB(tb testing.TB) (*database.TracedDB, sqlmock.Sqlmock, func()) {
+	tb.Helper()
+	mockDB, mock, err := sqlmock.New()
+	return newTracedDB(tb, mockDB, mock, err)
+}
+
+// NewMockTracedDBExact is NewMockTracedDB with expected queries matched
+// verbatim, for tests that spell out the full SQL.
+func NewMockTracedDBExact(tb testing.TB) (*database.TracedDB, sqlmock.Sqlmock, func()) {
+	tb.Helper()
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	return newTracedDB(tb, mockDB, mock, err)
+}
+
+func newTracedDB(tb testing.TB, mockDB *sql.DB, mock sqlmock.Sqlmock, err error) (*database.TracedDB, sqlmock.Sqlmock, func()) {
+	tb.Helper()
+	if err != nil {
+		tb.Fatalf("failed to create sqlmock: %v", err)
+	}
+
+	db := database.NewTracedDB(sqlx.NewDb(mockDB, "sqlmock"))
+	cleanup := func() {
+		mockDB.Close()
+	}
+
+	return db, mock, cleanup
+}
@@ -0,0 +1,52 @@
+package dbtest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMockTracedDB(t *testing.T) {
+	db, mock, cleanup := NewMockTracedDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM users`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	var count int
+	err := db.GetContext(context.Background(), &count, "SELECT COUNT(*) FROM users WHERE deleted = false")
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNewMockTracedDBExact(t *testing.T) {
+	db, mock, cleanup := NewMockTracedDBExact(t)
+	defer cleanup()
+
+	query := "SELECT COUNT(*) FROM users"
+	mock.ExpectQuery(query).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	var count int
+	err := db.GetContext(context.Background(), &count, query+" WHERE deleted = false")
+	assert.Error(t, err, "exact matcher must reject a query that only shares a prefix")
+
+	err = db.GetContext(context.Background(), &count, query)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+}
+
+func TestNewMockTracedDB_Cleanup(t *testing.T) {
+	db, mock, cleanup := NewMockTracedDB(t)
+	mock.ExpectClose()
+
+	cleanup()
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Error(t, db.DB.PingContext(context.Background()))
+}
@@ -0,0 +1,263 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidListQuery is wrapped by every error ListBuilder.Build returns for
+// a query the client got wrong, such as sorting by a column that is not
+// allowlisted.
+var ErrInvalidListQuery = errors.New("invalid list query")
+
+// FilterOp is a comparison a Filter applies to its column.
+type FilterOp string
+
+const (
+	OpEq       FilterOp = "eq"
+	OpNe       FilterOp = "ne"
+	OpLt       FilterOp = "lt"
+	OpLte      FilterOp = "lte"
+	OpGt       FilterOp = "gt"
+	OpGte      FilterOp = "gte"
+	OpContains FilterOp = "contains"
+)
+
+var filterOperators = map[FilterOp]string{
+	OpEq:  "=",
+	OpNe:  "<>",
+	OpLt:  "<",
+	OpLte: "<=",
+	OpGt:  ">",
+	OpGte: ">=",
+}
+
+// Filter restricts a list to rows whose Column compares to Value under Op.
+// Column is the public name from the builder's allowlist, never raw SQL.
+type Filter struct {
+	Column string
+	Op     FilterOp
+	Value  any
+}
+
+// SortField orders a list by Column, descending when Desc is set.
+type SortField struct {
+	Column string
+	Desc   bool
+}
+
+// ListQuery describes one page of a filtered, sorted list as requested by a
+// client. A zero Limit means the builder's default.
+//
+// Keyset switches from offset to keyset pagination: rows are ordered by the
+// builder's Keyset columns, newest first, and After holds the Keyset values
+// of the last row already seen (nil for the first page). Sort and Offset
+// must be empty in that mode.
+type ListQuery struct {
+	Filters []Filter
+	Sort    []SortField
+	Limit   int
+	Offset  int
+	Keyset  bool
+	After   []any
+}
+
+// DefaultOrder orders a list when the client asks for no sort and the
+// builder sets no DefaultOrder of its own. Ending on a unique column keeps
+// rows from trading places between pages.
+var DefaultOrder = []SortField{
+	{Column: "created_at", Desc: true},
+	{Column: "id", Desc: true},
+}
+
+// ParseSort reads a comma-separated sort parameter such as
+// "name,-created_at", where a leading "-" sorts that column descending.
+// Column names are checked later, by Build.
+func ParseSort(param string) []SortField {
+	var fields []SortField
+	for _, part := range strings.Split(param, ",") {
+		part = strings.TrimSpace(part)
+		desc := strings.HasPrefix(part, "-")
+		part = strings.TrimPrefix(part, "-")
+		if part == "" {
+			continue
+		}
+		fields = append(fields, SortField{Column: part, Desc: desc})
+	}
+	return fields
+}
+
+// ListBuilder turns a ListQuery into a parameterized statement. Clients only
+// ever name columns through the allowlist and every value is bound as an
+// argument, so nothing they send is spliced into the SQL.
+type ListBuilder struct {
+	// Base is the statement up to, but excluding, WHERE, e.g.
+	// "SELECT id, name FROM users".
+	Base string
+	// Columns maps the names clients may filter and sort by to their SQL
+	// expressions.
+	Columns map[string]string
+	// Keyset is the SQL columns keyset pagination orders by, most
+	// significant first. Together they must identify a row, e.g.
+	// created_at, id.
+	Keyset []string
+	// DefaultOrder replaces the package DefaultOrder for this builder. Its
+	// columns are SQL, like Keyset, and bypass the allowlist.
+	DefaultOrder []SortField
+	// DefaultLimit applies when the query has no limit; MaxLimit caps it.
+	DefaultLimit int
+	MaxLimit     int
+}
+
+// Build returns the statement and its arguments for q. scope adds conditions
+// the caller enforces regardless of the request, such as the tenant; their
+// columns are trusted SQL and bypass the allowlist. Unknown columns and
+// operators, and a negative offset, fail with ErrInvalidListQuery.
+//
+// In keyset mode the statement fetches one row beyond the limit so callers
+// can tell whether another page follows.
+func (b *ListBuilder) Build(q ListQuery, scope ...Filter) (string, []any, error) {
+	var (
+		sql        strings.Builder
+		args       []any
+		conditions []string
+	)
+
+	bind := func(value any) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	for _, f := range scope {
+		conditions = append(conditions, f.Column+" = "+bind(f.Value))
+	}
+
+	for _, f := range q.Filters {
+		column, ok := b.Columns[f.Column]
+		if !ok {
+			return "", nil, fmt.Errorf("%w: unknown filter column %q", ErrInvalidListQuery, f.Column)
+		}
+
+		if f.Op == OpContains {
+			value, ok := f.Value.(string)
+			if !ok {
+				return "", nil, fmt.Errorf("%w: contains on %q needs a string", ErrInvalidListQuery, f.Column)
+			}
+			conditions = append(conditions, column+" ILIKE '%' || "+bind(escapeLike(value))+" || '%'")
+			continue
+		}
+
+		operator, ok := filterOperators[f.Op]
+		if !ok {
+			return "", nil, fmt.Errorf("%w: unsupported operator %q", ErrInvalidListQuery, f.Op)
+		}
+		conditions = append(conditions, column+" "+operator+" "+bind(f.Value))
+	}
+
+	if q.Keyset {
+		if len(b.Keyset) == 0 {
+			return "", nil, fmt.Errorf("%w: keyset pagination is not supported", ErrInvalidListQuery)
+		}
+		if len(q.Sort) > 0 || q.Offset != 0 {
+			return "", nil, fmt.Errorf("%w: sort and offset cannot be combined with a cursor", ErrInvalidListQuery)
+		}
+		if q.After != nil {
+			if len(q.After) != len(b.Keyset) {
+				return "", nil, fmt.Errorf("%w: cursor does not match the keyset", ErrInvalidListQuery)
+			}
+			placeholders := make([]string, len(q.After))
+			for i, value := range q.After {
+				placeholders[i] = bind(value)
+			}
+			conditions = append(conditions,
+				"("+strings.Join(b.Keyset, ", ")+") < ("+strings.Join(placeholders, ", ")+")")
+		}
+	}
+
+	sql.WriteString(b.Base)
+	if len(conditions) > 0 {
+		sql.WriteString(" WHERE ")
+		sql.WriteString(strings.Join(conditions, " AND "))
+	}
+
+	if q.Keyset {
+		order := make([]string, len(b.Keyset))
+		for i, column := range b.Keyset {
+			order[i] = orderTerm(column, true)
+		}
+		sql.WriteString(" ORDER BY ")
+		sql.WriteString(strings.Join(order, ", "))
+		sql.WriteString(" LIMIT " + bind(b.Limit(q.Limit)+1))
+		return sql.String(), args, nil
+	}
+
+	order, err := b.order(q.Sort)
+	if err != nil {
+		return "", nil, err
+	}
+	sql.WriteString(" ORDER BY ")
+	sql.WriteString(order)
+
+	if q.Offset < 0 {
+		return "", nil, fmt.Errorf("%w: negative offset", ErrInvalidListQuery)
+	}
+	sql.WriteString(" LIMIT " + bind(b.Limit(q.Limit)))
+	if q.Offset > 0 {
+		sql.WriteString(" OFFSET " + bind(q.Offset))
+	}
+
+	return sql.String(), args, nil
+}
+
+// order renders the ORDER BY list for sort, checking each column against the
+// allowlist, or the default order when sort is empty.
+func (b *ListBuilder) order(sort []SortField) (string, error) {
+	columns := make([]string, 0, len(sort))
+	if len(sort) == 0 {
+		defaults := b.DefaultOrder
+		if len(defaults) == 0 {
+			defaults = DefaultOrder
+		}
+		for _, s := range defaults {
+			columns = append(columns, orderTerm(s.Column, s.Desc))
+		}
+		return strings.Join(columns, ", "), nil
+	}
+
+	for _, s := range sort {
+		column, ok := b.Columns[s.Column]
+		if !ok {
+			return "", fmt.Errorf("%w: unknown sort column %q", ErrInvalidListQuery, s.Column)
+		}
+		columns = append(columns, orderTerm(column, s.Desc))
+	}
+	return strings.Join(columns, ", "), nil
+}
+
+func orderTerm(column string, desc bool) string {
+	if desc {
+		return column + " DESC"
+	}
+	return column + " ASC"
+}
+
+// Limit applies DefaultLimit to a missing limit and clamps it to MaxLimit.
+func (b *ListBuilder) Limit(requested int) int {
+	limit := requested
+	if limit <= 0 {
+		limit = b.DefaultLimit
+	}
+	if b.MaxLimit > 0 && limit > b.MaxLimit {
+		limit = b.MaxLimit
+	}
+	return limit
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike makes LIKE wildcards in a contains filter match literally.
+func escapeLike(value string) string {
+	return likeEscaper.Replace(value)
+}
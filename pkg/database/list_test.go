@@ -0,0 +1,143 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestListBuilder() *ListBuilder {
+	return &ListBuilder{
+		Base: "SELECT id, name FROM users",
+		Columns: map[string]string{
+			"name":       "name",
+			"created_at": "created_at",
+		},
+		Keyset:       []string{"created_at", "id"},
+		DefaultLimit: 20,
+		MaxLimit:     100,
+	}
+}
+
+func TestListBuilder_Build(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    ListQuery
+		scope    []Filter
+		wantSQL  string
+		wantArgs []any
+	}{
+		{
+			name:     "defaults",
+			wantSQL:  "SELECT id, name FROM users ORDER BY created_at DESC, id DESC LIMIT $1",
+			wantArgs: []any{20},
+		},
+		{
+			name: "filters sort and page",
+			query: ListQuery{
+				Filters: []Filter{
+					{Column: "name", Op: OpEq, Value: "Ada"},
+					{Column: "created_at", Op: OpGte, Value: "2024-01-01"},
+				},
+				Sort:   []SortField{{Column: "created_at", Desc: true}, {Column: "name"}},
+				Limit:  10,
+				Offset: 30,
+			},
+			wantSQL:  "SELECT id, name FROM users WHERE name = $1 AND created_at >= $2 ORDER BY created_at DESC, name ASC LIMIT $3 OFFSET $4",
+			wantArgs: []any{"Ada", "2024-01-01", 10, 30},
+		},
+		{
+			name:     "scope is bound before client filters",
+			query:    ListQuery{Filters: []Filter{{Column: "name", Op: OpNe, Value: "Ada"}}},
+			scope:    []Filter{{Column: "tenant_id", Value: "acme"}},
+			wantSQL:  "SELECT id, name FROM users WHERE tenant_id = $1 AND name <> $2 ORDER BY created_at DESC, id DESC LIMIT $3",
+			wantArgs: []any{"acme", "Ada", 20},
+		},
+		{
+			name:     "contains escapes wildcards",
+			query:    ListQuery{Filters: []Filter{{Column: "name", Op: OpContains, Value: "50%_off"}}},
+			wantSQL:  "SELECT id, name FROM users WHERE name ILIKE '%' || $1 || '%' ORDER BY created_at DESC, id DESC LIMIT $2",
+			wantArgs: []any{`50\%\_off`, 20},
+		},
+		{
+			name:     "keyset first page",
+			query:    ListQuery{Keyset: true, Limit: 10},
+			wantSQL:  "SELECT id, name FROM users ORDER BY created_at DESC, id DESC LIMIT $1",
+			wantArgs: []any{11},
+		},
+		{
+			name:     "keyset after cursor",
+			query:    ListQuery{Keyset: true, After: []any{"2024-01-01", "42"}, Filters: []Filter{{Column: "name", Op: OpEq, Value: "Ada"}}},
+			wantSQL:  "SELECT id, name FROM users WHERE name = $1 AND (created_at, id) < ($2, $3) ORDER BY created_at DESC, id DESC LIMIT $4",
+			wantArgs: []any{"Ada", "2024-01-01", "42", 21},
+		},
+		{
+			name:     "limit is capped",
+			query:    ListQuery{Limit: 5000},
+			wantSQL:  "SELECT id, name FROM users ORDER BY created_at DESC, id DESC LIMIT $1",
+			wantArgs: []any{100},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args, err := newTestListBuilder().Build(tt.query, tt.scope...)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSQL, sql)
+			assert.Equal(t, tt.wantArgs, args)
+		})
+	}
+}
+
+func TestListBuilder_DefaultOrder(t *testing.T) {
+	builder := newTestListBuilder()
+
+	sql, _, err := builder.Build(ListQuery{})
+	require.NoError(t, err)
+	assert.Contains(t, sql, " ORDER BY created_at DESC, id DESC ")
+
+	builder.DefaultOrder = []SortField{{Column: "name"}, {Column: "id"}}
+	sql, _, err = builder.Build(ListQuery{})
+	require.NoError(t, err)
+	assert.Contains(t, sql, " ORDER BY name ASC, id ASC ")
+
+	sql, _, err = builder.Build(ListQuery{Sort: []SortField{{Column: "created_at"}}})
+	require.NoError(t, err)
+	assert.Contains(t, sql, " ORDER BY created_at ASC ")
+}
+
+func TestListBuilder_BuildRejectsInvalidQueries(t *testing.T) {
+	tests := []struct {
+		name  string
+		query ListQuery
+	}{
+		{name: "unknown filter column", query: ListQuery{Filters: []Filter{{Column: "password", Op: OpEq, Value: "x"}}}},
+		{name: "injected sort column", query: ListQuery{Sort: []SortField{{Column: "name; DROP TABLE users"}}}},
+		{name: "unsupported operator", query: ListQuery{Filters: []Filter{{Column: "name", Op: "like", Value: "x"}}}},
+		{name: "contains on non-string", query: ListQuery{Filters: []Filter{{Column: "name", Op: OpContains, Value: 1}}}},
+		{name: "negative offset", query: ListQuery{Offset: -1}},
+		{name: "keyset with sort", query: ListQuery{Keyset: true, Sort: []SortField{{Column: "name"}}}},
+		{name: "keyset with offset", query: ListQuery{Keyset: true, Offset: 20}},
+		{name: "cursor with wrong arity", query: ListQuery{Keyset: true, After: []any{"2024-01-01"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args, err := newTestListBuilder().Build(tt.query)
+
+			assert.ErrorIs(t, err, ErrInvalidListQuery)
+			assert.Empty(t, sql)
+			assert.Nil(t, args)
+		})
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	assert.Equal(t, []SortField{
+		{Column: "name"},
+		{Column: "created_at", Desc: true},
+	}, ParseSort("name, -created_at,,"))
+	assert.Empty(t, ParseSort(""))
+}
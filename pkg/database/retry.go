@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// IsRetryable reports whether err is a transient failure worth retrying:
+// dropped connections, timeouts, and Postgres connection, serialization and
+// deadlock errors.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+
+	if errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code.Class() == "08": // connection_exception
+			return true
+		case pqErr.Code == "40001", pqErr.Code == "40P01", pqErr.Code == "57P01":
+			// serialization_failure, deadlock_detected, admin_shutdown
+			return true
+		}
+	}
+
+	return false
+}
+
+// RetryPolicy retries transient failures with a linear backoff. Only use it
+// for reads or idempotent writes.
+type RetryPolicy struct {
+	Attempts int
+	Backoff  time.Duration
+}
+
+// Do runs fn until it succeeds, fails with a non-retryable error, or the
+// attempts are exhausted. Each retry is added as an event on the span in ctx.
+func (p RetryPolicy) Do(ctx context.Context, fn func() error) error {
+	attempts := p.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = fn()
+		if err == nil || !IsRetryable(err) || attempt == attempts {
+			return err
+		}
+
+		trace.SpanFromContext(ctx).AddEvent("db.retry", trace.WithAttributes(
+			attribute.Int("db.retry.attempt", attempt),
+			attribute.String("db.retry.error", err.Error()),
+		))
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(p.Backoff * time.Duration(attempt)):
+		}
+	}
+
+	return err
+}
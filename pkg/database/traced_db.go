@@ -3,6 +3,7 @@ package database
 import (
 	"context"
 	"database/sql"
+	"log/slog"
 	"strings"
 
 	"github.com/jmoiron/sqlx"
@@ -24,6 +25,8 @@ var dbSystemAttr = attribute.String("db.system", "postgresql")
 type TracedDB struct {
 	*sqlx.DB
 	logQueries bool
+	logger     *slog.Logger
+	breaker    *CircuitBreaker
 }
 
 func NewTracedDB(db *sqlx.DB) *TracedDB {
@@ -33,6 +36,135 @@ func NewTracedDB(db *sqlx.DB) *TracedDB {
 	}
 }
 
+func (db *TracedDB) SetLogger(logger *slog.Logger) {
+	db.logger = logger
+}
+
+// SetCircuitBreaker makes every error-returning query go through breaker.
+// QueryRowContext and QueryRowxContext defer their errors to Scan and are
+// not guarded.
+func (db *TracedDB) SetCircuitBreaker(breaker *CircuitBreaker) {
+	db.breaker = breaker
+}
+
+// querier is the query surface shared by *sqlx.DB and *sqlx.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row
+	QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error)
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
+}
+
+type txKey struct{}
+
+// conn returns the transaction started by WithTx when ctx carries one, so
+// repositories join it without changes, and the pool otherwise.
+func (db *TracedDB) conn(ctx context.Context) querier {
+	if tx, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return tx
+	}
+	return db.DB
+}
+
+// WithTx runs fn in a transaction. Every query made through db with the
+// context passed to fn joins the transaction, which is committed when fn
+// returns nil and rolled back otherwise. A WithTx nested inside another
+// joins the outer transaction.
+func (db *TracedDB) WithTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if _, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return fn(ctx)
+	}
+
+	ctx, span := tracer.Start(ctx, "db.transaction",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(dbSystemAttr),
+	)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	var tx *sqlx.Tx
+	err = db.guard(func() (err error) {
+		tx, err = db.DB.BeginTxx(ctx, nil)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err = fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			db.log().ErrorContext(ctx, "failed to roll back transaction", "error", rbErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// guard runs fn through the circuit breaker when one is configured.
+func (db *TracedDB) guard(fn func() error) error {
+	if db.breaker == nil {
+		return fn()
+	}
+
+	if err := db.breaker.allow(); err != nil {
+		return err
+	}
+
+	err := fn()
+	db.breaker.record(err)
+	return err
+}
+
+func (db *TracedDB) log() *slog.Logger {
+	if db.logger == nil {
+		return slog.Default()
+	}
+	return db.logger
+}
+
+// Close closes the underlying pool inside a span and logs the outcome,
+// shadowing the untraced Close promoted from *sqlx.DB.
+func (db *TracedDB) Close() error {
+	_, span := tracer.Start(context.Background(), "db.close",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(dbSystemAttr),
+	)
+	defer span.End()
+
+	if err := db.DB.Close(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		db.log().Error("failed to close database connection", "error", err)
+		return err
+	}
+
+	db.log().Info("database connection closed")
+	return nil
+}
+
+// Shutdown implements do.Shutdownable so the pool is closed when the
+// injector shuts down.
+func (db *TracedDB) Shutdown() error {
+	return db.Close()
+}
+
 func (db *TracedDB) startSpan(ctx context.Context, operation, query string) (context.Context, trace.Span) {
 	ctx, span := tracer.Start(ctx, operation,
 		trace.WithSpanKind(trace.SpanKindClient),
@@ -60,7 +192,11 @@ func (db *TracedDB) ExecContext(ctx context.Context, query string, args ...inter
 	ctx, span := db.startSpan(ctx, "db.exec", query)
 	defer span.End()
 
-	result, err := db.DB.ExecContext(ctx, query, args...)
+	var result sql.Result
+	err := db.guard(func() (err error) {
+		result, err = db.conn(ctx).ExecContext(ctx, query, args...)
+		return err
+	})
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
@@ -72,7 +208,11 @@ func (db *TracedDB) QueryContext(ctx context.Context, query string, args ...inte
 	ctx, span := db.startSpan(ctx, "db.query", query)
 	defer span.End()
 
-	rows, err := db.DB.QueryContext(ctx, query, args...)
+	var rows *sql.Rows
+	err := db.guard(func() (err error) {
+		rows, err = db.conn(ctx).QueryContext(ctx, query, args...)
+		return err
+	})
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
@@ -84,21 +224,25 @@ func (db *TracedDB) QueryRowContext(ctx context.Context, query string, args ...i
 	ctx, span := db.startSpan(ctx, "db.query_row", query)
 	defer span.End()
 
-	return db.DB.QueryRowContext(ctx, query, args...)
+	return db.conn(ctx).QueryRowContext(ctx, query, args...)
 }
 
 func (db *TracedDB) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
 	ctx, span := db.startSpan(ctx, "db.query_row", query)
 	defer span.End()
 
-	return db.DB.QueryRowxContext(ctx, query, args...)
+	return db.conn(ctx).QueryRowxContext(ctx, query, args...)
 }
 
 func (db *TracedDB) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
 	ctx, span := db.startSpan(ctx, "db.query", query)
 	defer span.End()
 
-	rows, err := db.DB.QueryxContext(ctx, query, args...)
+	var rows *sqlx.Rows
+	err := db.guard(func() (err error) {
+		rows, err = db.conn(ctx).QueryxContext(ctx, query, args...)
+		return err
+	})
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
@@ -110,7 +254,9 @@ func (db *TracedDB) GetContext(ctx context.Context, dest interface{}, query stri
 	ctx, span := db.startSpan(ctx, "db.get", query)
 	defer span.End()
 
-	err := db.DB.GetContext(ctx, dest, query, args...)
+	err := db.guard(func() error {
+		return db.conn(ctx).GetContext(ctx, dest, query, args...)
+	})
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
@@ -122,7 +268,9 @@ func (db *TracedDB) SelectContext(ctx context.Context, dest interface{}, query s
 	ctx, span := db.startSpan(ctx, "db.select", query)
 	defer span.End()
 
-	err := db.DB.SelectContext(ctx, dest, query, args...)
+	err := db.guard(func() error {
+		return db.conn(ctx).SelectContext(ctx, dest, query, args...)
+	})
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
@@ -130,11 +278,52 @@ func (db *TracedDB) SelectContext(ctx context.Context, dest interface{}, query s
 	return err
 }
 
+// StreamContext runs query and calls scan once per row without buffering the
+// result set, for listings too large for SelectContext. The span covers the
+// whole iteration and records the number of rows visited. Iteration stops at
+// the first error returned by scan.
+func (db *TracedDB) StreamContext(ctx context.Context, scan func(rows *sqlx.Rows) error, query string, args ...interface{}) (err error) {
+	ctx, span := db.startSpan(ctx, "db.stream", query)
+	defer span.End()
+
+	var count int64
+	defer func() {
+		span.SetAttributes(attribute.Int64("db.rows_returned", count))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}()
+
+	var rows *sqlx.Rows
+	err = db.guard(func() (err error) {
+		rows, err = db.conn(ctx).QueryxContext(ctx, query, args...)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err = scan(rows); err != nil {
+			return err
+		}
+		count++
+	}
+
+	return rows.Err()
+}
+
 func (db *TracedDB) NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
 	ctx, span := db.startSpan(ctx, "db.named_exec", query)
 	defer span.End()
 
-	result, err := db.DB.NamedExecContext(ctx, query, arg)
+	var result sql.Result
+	err := db.guard(func() (err error) {
+		result, err = db.conn(ctx).NamedExecContext(ctx, query, arg)
+		return err
+	})
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
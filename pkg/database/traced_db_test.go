@@ -0,0 +1,155 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/samber/do"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupMockDB(t *testing.T) (*TracedDB, sqlmock.Sqlmock) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	return NewTracedDB(sqlx.NewDb(mockDB, "sqlmock")), mock
+}
+
+func TestTracedDB_Close(t *testing.T) {
+	db, mock := setupMockDB(t)
+	mock.ExpectClose()
+
+	err := db.Close()
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTracedDB_Close_Error(t *testing.T) {
+	db, mock := setupMockDB(t)
+	closeErr := errors.New("close failed")
+	mock.ExpectClose().WillReturnError(closeErr)
+
+	err := db.Close()
+
+	assert.ErrorIs(t, err, closeErr)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTracedDB_ClosedOnInjectorShutdown(t *testing.T) {
+	db, mock := setupMockDB(t)
+	mock.ExpectClose()
+
+	injector := do.New()
+	do.ProvideNamed(injector, "db", func(i *do.Injector) (*TracedDB, error) {
+		return db, nil
+	})
+	do.MustInvokeNamed[*TracedDB](injector, "db")
+
+	err := injector.Shutdown()
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTracedDB_StreamContext(t *testing.T) {
+	db, mock := setupMockDB(t)
+	mock.ExpectQuery("SELECT id, name FROM users").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).
+			AddRow("1", "Alice").
+			AddRow("2", "Bob").
+			AddRow("3", "Carol"))
+
+	type row struct {
+		ID   string `db:"id"`
+		Name string `db:"name"`
+	}
+
+	var got []row
+	err := db.StreamContext(context.Background(), func(rows *sqlx.Rows) error {
+		var r row
+		if err := rows.StructScan(&r); err != nil {
+			return err
+		}
+		got = append(got, r)
+		return nil
+	}, "SELECT id, name FROM users")
+
+	require.NoError(t, err)
+	assert.Equal(t, []row{{"1", "Alice"}, {"2", "Bob"}, {"3", "Carol"}}, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTracedDB_StreamContext_StopsOnScanError(t *testing.T) {
+	db, mock := setupMockDB(t)
+	mock.ExpectQuery("SELECT id FROM users").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1").AddRow("2"))
+
+	scanErr := errors.New("write failed")
+	calls := 0
+	err := db.StreamContext(context.Background(), func(rows *sqlx.Rows) error {
+		calls++
+		return scanErr
+	}, "SELECT id FROM users")
+
+	assert.ErrorIs(t, err, scanErr)
+	assert.Equal(t, 1, calls)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTracedDB_WithTx_Commit(t *testing.T) {
+	db, mock := setupMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO widgets`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO gadgets`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := db.WithTx(context.Background(), func(ctx context.Context) error {
+		if _, err := db.ExecContext(ctx, `INSERT INTO widgets VALUES (1)`); err != nil {
+			return err
+		}
+		// A nested WithTx joins the outer transaction
+		return db.WithTx(ctx, func(ctx context.Context) error {
+			_, err := db.ExecContext(ctx, `INSERT INTO gadgets VALUES (1)`)
+			return err
+		})
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTracedDB_WithTx_RollbackOnError(t *testing.T) {
+	db, mock := setupMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO widgets`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+
+	fnErr := errors.New("later step failed")
+	err := db.WithTx(context.Background(), func(ctx context.Context) error {
+		if _, err := db.ExecContext(ctx, `INSERT INTO widgets VALUES (1)`); err != nil {
+			return err
+		}
+		return fnErr
+	})
+
+	assert.ErrorIs(t, err, fnErr)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTracedDB_WithTx_RollbackOnPanic(t *testing.T) {
+	db, mock := setupMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	assert.Panics(t, func() {
+		_ = db.WithTx(context.Background(), func(ctx context.Context) error {
+			panic("boom")
+		})
+	})
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
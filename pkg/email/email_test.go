@@ -0,0 +1,43 @@
+package email
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildMessage(t *testing.T) {
+	msg, err := buildMessage("noreply@example.com", "john@example.com", "Verify your email", "Hello\nWorld")
+
+	require.NoError(t, err)
+	text := string(msg)
+	assert.Contains(t, text, "From: noreply@example.com\r\n")
+	assert.Contains(t, text, "To: john@example.com\r\n")
+	assert.Contains(t, text, "Subject: Verify your email\r\n")
+	assert.Contains(t, text, "Content-Type: text/html; charset=UTF-8\r\n")
+	assert.True(t, strings.HasSuffix(text, "\r\n\r\nHello\r\nWorld"))
+}
+
+func TestBuildMessage_RejectsHeaderInjection(t *testing.T) {
+	_, err := buildMessage("noreply@example.com", "john@example.com\r\nBcc: all@example.com", "Hi", "body")
+	assert.ErrorIs(t, err, ErrInvalidHeader)
+
+	_, err = buildMessage("noreply@example.com", "john@example.com", "Hi\nBcc: all@example.com", "body")
+	assert.ErrorIs(t, err, ErrInvalidHeader)
+}
+
+func TestSMTPSender_RejectsInvalidHeaderBeforeDialing(t *testing.T) {
+	// Port 0 would fail to dial; the header check must fail first
+	s := NewSMTPSender("localhost", 0, "", "", "noreply@example.com", 0)
+
+	err := s.Send(context.Background(), "a@example.com\nBcc: b@example.com", "Hi", "body")
+
+	assert.ErrorIs(t, err, ErrInvalidHeader)
+}
+
+func TestNopSender(t *testing.T) {
+	assert.NoError(t, NopSender{}.Send(context.Background(), "john@example.com", "Hi", "body"))
+}
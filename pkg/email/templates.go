@@ -0,0 +1,130 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed templates/*.html
+var embeddedTemplates embed.FS
+
+// Template names. Each template file defines a "subject" block alongside
+// the HTML body.
+const (
+	TemplateVerifyEmail   = "verify_email"
+	TemplatePasswordReset = "password_reset"
+	TemplateNotification  = "notification"
+)
+
+// ErrUnknownTemplate is returned by Render for a name with no template.
+var ErrUnknownTemplate = errors.New("unknown email template")
+
+// TemplateData is the per-message data templates render. Subject and
+// Message are used by the notification template only.
+type TemplateData struct {
+	Name      string
+	Link      string
+	ExpiresIn time.Duration
+	Subject   string
+	Message   string
+}
+
+// Templates is a registry of parsed email templates keyed by name.
+type Templates struct {
+	set map[string]*template.Template
+}
+
+var templateFuncs = template.FuncMap{"duration": humanDuration}
+
+// LoadTemplates parses the embedded templates. When overrideDir is set, a
+// file there named after a template (e.g. verify_email.html) replaces the
+// embedded one, so deployments can restyle mail without rebuilding.
+func LoadTemplates(overrideDir string) (*Templates, error) {
+	files, err := fs.Glob(embeddedTemplates, "templates/*.html")
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Templates{set: make(map[string]*template.Template, len(files))}
+	for _, file := range files {
+		base := path.Base(file)
+		name := strings.TrimSuffix(base, ".html")
+
+		src, err := fs.ReadFile(embeddedTemplates, file)
+		if err != nil {
+			return nil, err
+		}
+		if overrideDir != "" {
+			override, err := os.ReadFile(filepath.Join(overrideDir, base))
+			switch {
+			case err == nil:
+				src = override
+			case !errors.Is(err, fs.ErrNotExist):
+				return nil, fmt.Errorf("failed to read email template override %s: %w", base, err)
+			}
+		}
+
+		// Each file is parsed on its own since every template defines its
+		// own "subject" block.
+		tmpl, err := template.New(name).Funcs(templateFuncs).Parse(string(src))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse email template %s: %w", base, err)
+		}
+		if tmpl.Lookup("subject") == nil {
+			return nil, fmt.Errorf("email template %s does not define a subject", base)
+		}
+		t.set[name] = tmpl
+	}
+
+	return t, nil
+}
+
+// Render executes the named template, returning its subject and HTML body.
+func (t *Templates) Render(name string, data TemplateData) (subject, body string, err error) {
+	tmpl, ok := t.set[name]
+	if !ok {
+		return "", "", fmt.Errorf("%w: %s", ErrUnknownTemplate, name)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, "subject", data); err != nil {
+		return "", "", fmt.Errorf("failed to render subject of %s: %w", name, err)
+	}
+	// The subject goes into a header, not HTML, so html/template's escaping
+	// is undone
+	subject = html.UnescapeString(strings.TrimSpace(buf.String()))
+
+	buf.Reset()
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", "", fmt.Errorf("failed to render %s: %w", name, err)
+	}
+
+	return subject, buf.String(), nil
+}
+
+// humanDuration renders d in whole hours when it is one, and in minutes
+// otherwise, e.g. "24 hours" or "30 minutes".
+func humanDuration(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		return plural(int(d/time.Hour), "hour")
+	}
+	return plural(int(d/time.Minute), "minute")
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return strconv.Itoa(n) + " " + unit + "s"
+}
@@ -0,0 +1,135 @@
+package email
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplates_RenderEach(t *testing.T) {
+	templates, err := LoadTemplates("")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		data        TemplateData
+		wantSubject string
+		wantBody    []string
+	}{
+		{
+			name: TemplateVerifyEmail,
+			data: TemplateData{
+				Name:      "John Doe",
+				Link:      "https://app.example.com/api/account/verify?token=abc-123",
+				ExpiresIn: 24 * time.Hour,
+			},
+			wantSubject: "Verify your email address",
+			wantBody: []string{
+				"Hi John Doe,",
+				`href="https://app.example.com/api/account/verify?token=abc-123"`,
+				"expires in 24 hours",
+			},
+		},
+		{
+			name: TemplatePasswordReset,
+			data: TemplateData{
+				Name:      "John Doe",
+				Link:      "https://app.example.com/reset?token=abc-123",
+				ExpiresIn: time.Hour,
+			},
+			wantSubject: "Reset your password",
+			wantBody: []string{
+				"Hi John Doe,",
+				`href="https://app.example.com/reset?token=abc-123"`,
+				"expires in 1 hour.",
+			},
+		},
+		{
+			name: TemplateNotification,
+			data: TemplateData{
+				Name:    "John Doe",
+				Subject: "Your password was changed",
+				Message: "Your password was just changed.",
+			},
+			wantSubject: "Your password was changed",
+			wantBody:    []string{"Hi John Doe,", "Your password was just changed."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subject, body, err := templates.Render(tt.name, tt.data)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSubject, subject)
+			for _, want := range tt.wantBody {
+				assert.Contains(t, body, want)
+			}
+			assert.NotContains(t, body, "{{")
+		})
+	}
+}
+
+func TestTemplates_EscapesUserData(t *testing.T) {
+	templates, err := LoadTemplates("")
+	require.NoError(t, err)
+
+	subject, body, err := templates.Render(TemplateNotification, TemplateData{
+		Name:    "<script>alert(1)</script>",
+		Subject: "Tom & Jerry",
+		Link:    "javascript:alert(1)",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "Tom & Jerry", subject)
+	assert.NotContains(t, body, "<script>")
+	assert.NotContains(t, body, `href="javascript:`)
+}
+
+func TestTemplates_Override(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "verify_email.html"),
+		[]byte(`{{define "subject"}}Welcome aboard{{end}}<p>{{.Link}}</p>`), 0o600))
+
+	templates, err := LoadTemplates(dir)
+	require.NoError(t, err)
+
+	subject, body, err := templates.Render(TemplateVerifyEmail, TemplateData{Link: "https://example.com/v"})
+	require.NoError(t, err)
+	assert.Equal(t, "Welcome aboard", subject)
+	assert.Equal(t, "<p>https://example.com/v</p>", body)
+
+	// Templates without an override keep the embedded version
+	subject, _, err = templates.Render(TemplatePasswordReset, TemplateData{})
+	require.NoError(t, err)
+	assert.Equal(t, "Reset your password", subject)
+}
+
+func TestTemplates_OverrideWithoutSubject(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notification.html"), []byte(`<p>{{.Message}}</p>`), 0o600))
+
+	_, err := LoadTemplates(dir)
+
+	assert.ErrorContains(t, err, "does not define a subject")
+}
+
+func TestTemplates_Unknown(t *testing.T) {
+	templates, err := LoadTemplates("")
+	require.NoError(t, err)
+
+	_, _, err = templates.Render("missing", TemplateData{})
+
+	assert.ErrorIs(t, err, ErrUnknownTemplate)
+}
+
+func TestHumanDuration(t *testing.T) {
+	assert.Equal(t, "1 hour", humanDuration(time.Hour))
+	assert.Equal(t, "24 hours", humanDuration(24*time.Hour))
+	assert.Equal(t, "30 minutes", humanDuration(30*time.Minute))
+	assert.Equal(t, "90 minutes", humanDuration(90*time.Minute))
+}
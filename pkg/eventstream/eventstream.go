@@ -0,0 +1,138 @@
+// Package eventstream fans security events out to the live connections of
+// the user they concern, such as Server-Sent Events streams.
+//
+// The outbox publishes each event on exactly one instance, so it hands
+// events to a Notifier rather than to a Broker directly. The Notifier sends
+// them through Postgres NOTIFY and the Relay on every replica feeds them to
+// its local Broker, so a stream receives events whichever replica it is
+// connected to.
+package eventstream
+
+import (
+	"context"
+	"sync"
+
+	"github.com/elskow/go-microservice-template/pkg/webhook"
+)
+
+// DefaultBufferSize is used when NewBroker is given a buffer size below 1.
+const DefaultBufferSize = 16
+
+// Broker is a per-user registry of event subscribers. It implements
+// webhook.Dispatcher so the outbox publisher can feed it alongside webhooks.
+//
+// Each subscriber has a buffer of bufferSize events. Dispatch never waits on
+// a subscriber: one whose buffer is full is disconnected, its channel
+// closed, so a slow client cannot stall the publisher or other clients.
+// Clients are expected to reconnect, as EventSource does.
+type Broker struct {
+	mu          sync.RWMutex
+	subscribers map[string]map[*subscriber]struct{}
+	bufferSize  int
+	closed      bool
+}
+
+type subscriber struct {
+	events chan webhook.Event
+}
+
+func NewBroker(bufferSize int) *Broker {
+	if bufferSize < 1 {
+		bufferSize = DefaultBufferSize
+	}
+	return &Broker{
+		subscribers: make(map[string]map[*subscriber]struct{}),
+		bufferSize:  bufferSize,
+	}
+}
+
+// Subscribe registers a subscriber for userID's events. The returned
+// function unsubscribes and must be called once the caller stops reading.
+// The channel is closed when the subscriber falls too far behind or the
+// broker shuts down.
+func (b *Broker) Subscribe(userID string) (<-chan webhook.Event, func()) {
+	sub := &subscriber{events: make(chan webhook.Event, b.bufferSize)}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(sub.events)
+		return sub.events, func() {}
+	}
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[*subscriber]struct{})
+	}
+	b.subscribers[userID][sub] = struct{}{}
+
+	return sub.events, func() { b.remove(userID, sub, false) }
+}
+
+// remove drops sub, closing its channel when closeEvents is set. It is a
+// no-op for a subscriber already removed.
+func (b *Broker) remove(userID string, sub *subscriber, closeEvents bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[userID][sub]; !ok {
+		return
+	}
+	delete(b.subscribers[userID], sub)
+	if len(b.subscribers[userID]) == 0 {
+		delete(b.subscribers, userID)
+	}
+	if closeEvents {
+		close(sub.events)
+	}
+}
+
+// Dispatch queues event for every subscriber of event.UserID without
+// blocking, disconnecting those whose buffer is full.
+func (b *Broker) Dispatch(_ context.Context, event webhook.Event) {
+	var full []*subscriber
+
+	b.mu.RLock()
+	for sub := range b.subscribers[event.UserID] {
+		select {
+		case sub.events <- event:
+		default:
+			full = append(full, sub)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, sub := range full {
+		b.remove(event.UserID, sub, true)
+	}
+}
+
+// Subscribers returns the number of open subscriptions.
+func (b *Broker) Subscribers() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	n := 0
+	for _, subs := range b.subscribers {
+		n += len(subs)
+	}
+	return n
+}
+
+// Shutdown closes every subscriber channel so open streams end, and makes
+// later subscriptions return a closed channel.
+func (b *Broker) Shutdown() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	for userID, subs := range b.subscribers {
+		for sub := range subs {
+			close(sub.events)
+		}
+		delete(b.subscribers, userID)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package eventstream
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/elskow/go-microservice-template/pkg/database/dbtest"
+	"github.com/elskow/go-microservice-template/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func receive(t *testing.T, events <-chan webhook.Event) webhook.Event {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+		return webhook.Event{}
+	}
+}
+
+func TestBroker_DeliversToSubscribersOfTheUser(t *testing.T) {
+	b := NewBroker(DefaultBufferSize)
+	mine, unsubscribeMine := b.Subscribe("user-a")
+	defer unsubscribeMine()
+	other, unsubscribeOther := b.Subscribe("user-b")
+	defer unsubscribeOther()
+
+	event := webhook.NewEvent(webhook.EventSessionRevoked, "user-a", nil)
+	b.Dispatch(context.Background(), event)
+
+	assert.Equal(t, event.ID, receive(t, mine).ID)
+	select {
+	case <-other:
+		t.Fatal("event delivered to another user's subscriber")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestBroker_DropsSlowSubscriberWithoutBlocking(t *testing.T) {
+	b := NewBroker(2)
+	slow, unsubscribeSlow := b.Subscribe("user-a")
+	defer unsubscribeSlow()
+	healthy, unsubscribeHealthy := b.Subscribe("user-a")
+	defer unsubscribeHealthy()
+
+	dispatched := make(chan struct{})
+	go func() {
+		defer close(dispatched)
+		// The slow subscriber never reads, so its buffer overflows on the third event
+		for range 3 {
+			b.Dispatch(context.Background(), webhook.NewEvent(webhook.EventSessionRevoked, "user-a", nil))
+			receive(t, healthy)
+		}
+	}()
+
+	select {
+	case <-dispatched:
+	case <-time.After(time.Second):
+		t.Fatal("dispatch blocked on a slow subscriber")
+	}
+
+	assert.Equal(t, 1, b.Subscribers())
+	buffered := 0
+	for range slow {
+		buffered++
+	}
+	assert.Equal(t, 2, buffered, "the slow subscriber keeps its buffered events, then its channel is closed")
+}
+
+func TestBroker_UnsubscribeKeepsChannelOpen(t *testing.T) {
+	b := NewBroker(1)
+	events, unsubscribe := b.Subscribe("user-a")
+
+	unsubscribe()
+	unsubscribe()
+	b.Dispatch(context.Background(), webhook.NewEvent(webhook.EventSessionRevoked, "user-a", nil))
+
+	assert.Equal(t, 0, b.Subscribers())
+	select {
+	case <-events:
+		t.Fatal("event delivered after unsubscribing")
+	default:
+	}
+}
+
+func TestBroker_ShutdownClosesSubscriptions(t *testing.T) {
+	b := NewBroker(DefaultBufferSize)
+	events, unsubscribe := b.Subscribe("user-a")
+	defer unsubscribe()
+
+	require.NoError(t, b.Shutdown())
+
+	_, ok := <-events
+	assert.False(t, ok)
+
+	late, _ := b.Subscribe("user-a")
+	_, ok = <-late
+	assert.False(t, ok)
+}
+
+func TestNotifier_DeliverNotifiesChannel(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDB(t)
+	defer cleanup()
+
+	event := webhook.NewEvent(webhook.EventSessionRevoked, "user-a", nil)
+	payload, err := json.Marshal(event)
+	require.NoError(t, err)
+	mock.ExpectExec(`SELECT pg_notify`).
+		WithArgs(Channel, string(payload)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, NewNotifier(db).Deliver(context.Background(), event))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotifier_DeliverReturnsError(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDB(t)
+	defer cleanup()
+
+	mock.ExpectExec(`SELECT pg_notify`).WillReturnError(assert.AnError)
+
+	err := NewNotifier(db).Deliver(context.Background(), webhook.NewEvent(webhook.EventSessionRevoked, "user-a", nil))
+	assert.ErrorIs(t, err, assert.AnError)
+}
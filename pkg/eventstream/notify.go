@@ -0,0 +1,123 @@
+package eventstream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/elskow/go-microservice-template/pkg/database"
+	"github.com/elskow/go-microservice-template/pkg/webhook"
+	"github.com/lib/pq"
+)
+
+// Channel is the Postgres notification channel stream events travel on.
+const Channel = "account_events"
+
+// Notifier publishes events on Channel so the Relay of every replica hands
+// them to its local Broker. It implements webhook.Deliverer, so the outbox
+// publisher sends stream events once their transaction has committed.
+type Notifier struct {
+	db *database.TracedDB
+}
+
+func NewNotifier(db *database.TracedDB) *Notifier {
+	return &Notifier{db: db}
+}
+
+// Deliver sends event on Channel.
+func (n *Notifier) Deliver(ctx context.Context, event webhook.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode stream event: %w", err)
+	}
+	if _, err := n.db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, Channel, string(payload)); err != nil {
+		return fmt.Errorf("failed to notify stream event: %w", err)
+	}
+	return nil
+}
+
+// RouteName identifies the Notifier among the outbox's delivery routes.
+const RouteName = "eventstream"
+
+// Routes returns the Notifier as a single route, so the outbox retries it
+// apart from the webhook URLs.
+func (n *Notifier) Routes() []webhook.Route {
+	return []webhook.Route{{Name: RouteName, Deliverer: n}}
+}
+
+// Dispatch sends event on Channel, discarding the error.
+func (n *Notifier) Dispatch(ctx context.Context, event webhook.Event) {
+	_ = n.Deliver(ctx, event)
+}
+
+// Relay listens on Channel and dispatches every event it receives to a
+// local Broker, so a stream sees the events published by any replica.
+// Notifications sent while the listener is reconnecting are lost; streams
+// are a live feed and clients catch up through the API.
+type Relay struct {
+	listener *pq.Listener
+	broker   *Broker
+	logger   *slog.Logger
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewRelay starts listening on Channel over a dedicated connection to dsn.
+// The connection is opened in the background and re-established after a
+// loss, so NewRelay does not wait for the database.
+func NewRelay(dsn string, broker *Broker, logger *slog.Logger) *Relay {
+	r := &Relay{
+		broker: broker,
+		logger: logger,
+		done:   make(chan struct{}),
+	}
+	r.listener = pq.NewListener(dsn, time.Second, time.Minute, r.onConnectionEvent)
+	go r.run()
+	return r
+}
+
+func (r *Relay) onConnectionEvent(event pq.ListenerEventType, err error) {
+	if err != nil {
+		r.logger.Warn("event stream listener connection error", "error", err)
+	}
+	if event == pq.ListenerEventReconnected {
+		r.logger.Info("event stream listener reconnected")
+	}
+}
+
+func (r *Relay) run() {
+	defer close(r.done)
+
+	// Listen blocks until the server acknowledges it and fails only once
+	// the listener is closed or the server rejects the channel.
+	if err := r.listener.Listen(Channel); err != nil {
+		r.logger.Error("event stream listener stopped", "error", err)
+		return
+	}
+
+	for notification := range r.listener.Notify {
+		// A nil notification marks a reconnect
+		if notification == nil {
+			continue
+		}
+		var event webhook.Event
+		if err := json.Unmarshal([]byte(notification.Extra), &event); err != nil {
+			r.logger.Warn("dropping malformed stream event", "error", err)
+			continue
+		}
+		r.broker.Dispatch(context.Background(), event)
+	}
+}
+
+// Shutdown closes the listener and waits for the relay to stop.
+func (r *Relay) Shutdown() error {
+	var err error
+	r.stopOnce.Do(func() {
+		err = r.listener.Close()
+		<-r.done
+	})
+	return err
+}
@@ -0,0 +1,42 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptDecrypt_RoundTrip(t *testing.T) {
+	ciphertext, err := Encrypt("key", "JBSWY3DPEHPK3PXP")
+	require.NoError(t, err)
+	assert.NotContains(t, ciphertext, "JBSWY3DPEHPK3PXP")
+
+	plaintext, err := Decrypt("key", ciphertext)
+
+	require.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", plaintext)
+}
+
+func TestDecrypt_WrongKey(t *testing.T) {
+	ciphertext, err := Encrypt("key", "secret")
+	require.NoError(t, err)
+
+	_, err = Decrypt("other-key", ciphertext)
+
+	assert.Error(t, err)
+}
+
+func TestDecrypt_Malformed(t *testing.T) {
+	_, err := Decrypt("key", "c2hvcnQ=")
+	assert.Error(t, err)
+
+	_, err = Decrypt("key", "not base64!")
+	assert.Error(t, err)
+}
+
+func TestHashToken(t *testing.T) {
+	assert.Equal(t, HashToken("code"), HashToken("code"))
+	assert.NotEqual(t, HashToken("code"), HashToken("other"))
+	assert.Len(t, HashToken("code"), 64)
+}
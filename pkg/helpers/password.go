@@ -6,8 +6,13 @@ import (
 )
 
 func HashPassword(password string) (string, error) {
-	cfg := config.Get()
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), cfg.BcryptCost)
+	return HashPasswordWithCost(password, config.Get().BcryptCost)
+}
+
+// HashPasswordWithCost hashes password with an explicit bcrypt cost, for
+// callers such as seeders that must not use the configured BCRYPT_COST.
+func HashPasswordWithCost(password string, cost int) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
 	if err != nil {
 		return "", err
 	}
@@ -3,7 +3,9 @@ package jwt
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/elskow/go-microservice-template/config"
@@ -12,14 +14,33 @@ import (
 
 type Service interface {
 	GenerateAccessToken(userID string, role string) (string, error)
+	GenerateSessionAccessToken(userID, role, tenantID, sessionID string, tokenVersion int) (string, error)
 	GenerateRefreshToken() (string, time.Time, error)
 	ValidateToken(token string) (*jwt.Token, error)
 	GetUserIDByToken(token string) (string, error)
+	AccessTokenExpiry() time.Duration
+	RevokeSession(sessionID string)
 }
 
+// adminRole is the role whose access tokens carry the admin audience.
+const adminRole = "admin"
+
+// ErrTokenRevoked is returned by ValidateToken for access tokens of a revoked
+// session.
+var ErrTokenRevoked = errors.New("token has been revoked")
+
 type jwtCustomClaim struct {
 	UserID string `json:"user_id"`
 	Role   string `json:"role"`
+	// TenantID is the tenant the user belongs to. Authenticate scopes the
+	// request to it and rejects a conflicting X-Tenant-ID header.
+	TenantID string `json:"tenant_id,omitempty"`
+	// SessionID is the refresh-token session the access token was minted
+	// for, letting a session be listed as current or revoked on its own.
+	SessionID string `json:"sid,omitempty"`
+	// TokenVersion is the user's token version at issue time; Authenticate
+	// rejects the token once the stored version has moved past it.
+	TokenVersion int `json:"tv"`
 	jwt.RegisteredClaims
 }
 
@@ -28,6 +49,16 @@ type service struct {
 	issuer        string
 	accessExpiry  time.Duration
 	refreshExpiry time.Duration
+	validMethods  []string
+	nowFunc       func() time.Time
+	// adminAudience is put in the "aud" claim of admin-role access tokens
+	// and is the only audience ValidateToken accepts. Empty mints none.
+	adminAudience string
+
+	revokedMu sync.RWMutex
+	// revokedSessions maps a session ID to the moment it was revoked. Entries
+	// are dropped once every access token of the session has expired.
+	revokedSessions map[string]time.Time
 }
 
 func NewService() Service {
@@ -37,19 +68,40 @@ func NewService() Service {
 		issuer:        "Template",
 		accessExpiry:  time.Minute * 15,
 		refreshExpiry: time.Hour * 24 * 7,
+		validMethods:  cfg.JWTAllowedAlgorithmList(),
+		nowFunc:       time.Now,
+		adminAudience: cfg.AdminTokenAudience,
+
+		revokedSessions: make(map[string]time.Time),
 	}
 }
 
 func (j *service) GenerateAccessToken(userID string, role string) (string, error) {
+	return j.GenerateSessionAccessToken(userID, role, "", "", 0)
+}
+
+// GenerateSessionAccessToken mints an access token for a user of tenantID
+// tied to sessionID, the ID of the refresh token it was issued alongside,
+// carrying the user's current tokenVersion. Admin-role tokens are issued for
+// the configured admin audience.
+func (j *service) GenerateSessionAccessToken(userID, role, tenantID, sessionID string, tokenVersion int) (string, error) {
+	now := j.nowFunc()
 	claims := jwtCustomClaim{
-		userID,
-		role,
-		jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.accessExpiry)),
+		UserID:    userID,
+		Role:      role,
+		TenantID:  tenantID,
+		SessionID: sessionID,
+
+		TokenVersion: tokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(j.accessExpiry)),
 			Issuer:    j.issuer,
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
+	if role == adminRole && j.adminAudience != "" {
+		claims.Audience = jwt.ClaimStrings{j.adminAudience}
+	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tx, err := token.SignedString([]byte(j.secretKey))
@@ -59,6 +111,11 @@ func (j *service) GenerateAccessToken(userID string, role string) (string, error
 	return tx, nil
 }
 
+// AccessTokenExpiry returns how long issued access tokens stay valid.
+func (j *service) AccessTokenExpiry() time.Duration {
+	return j.accessExpiry
+}
+
 func (j *service) GenerateRefreshToken() (string, time.Time, error) {
 	b := make([]byte, 32)
 	_, err := rand.Read(b)
@@ -70,20 +127,125 @@ func (j *service) GenerateRefreshToken() (string, time.Time, error) {
 	buf := make([]byte, encodedLen)
 	base64.StdEncoding.Encode(buf, b)
 	refreshToken := string(buf)
-	expiresAt := time.Now().Add(j.refreshExpiry)
+	expiresAt := j.nowFunc().Add(j.refreshExpiry)
 
 	return refreshToken, expiresAt, nil
 }
 
 func (j *service) parseToken(t_ *jwt.Token) (any, error) {
-	if _, ok := t_.Method.(*jwt.SigningMethodHMAC); !ok {
+	if !j.isValidMethod(t_.Method.Alg()) {
 		return nil, fmt.Errorf("unexpected signing method %v", t_.Header["alg"])
 	}
 	return []byte(j.secretKey), nil
 }
 
+func (j *service) isValidMethod(alg string) bool {
+	for _, m := range j.validMethods {
+		if m == alg {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateToken parses and verifies token, rejecting any whose alg header is
+// not in the configured allowlist before a key is ever selected. Time-based
+// claims are checked against the service clock rather than jwt.TimeFunc.
 func (j *service) ValidateToken(token string) (*jwt.Token, error) {
-	return jwt.Parse(token, j.parseToken)
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(j.validMethods),
+		jwt.WithoutClaimsValidation(),
+	)
+
+	tToken, err := parser.Parse(token, j.parseToken)
+	if err != nil {
+		return tToken, err
+	}
+
+	claims := tToken.Claims.(jwt.MapClaims)
+	if err := j.validateTimeClaims(claims); err != nil {
+		tToken.Valid = false
+		return tToken, err
+	}
+
+	if err := j.validateAudience(claims); err != nil {
+		tToken.Valid = false
+		return tToken, err
+	}
+
+	if j.isRevoked(claims) {
+		tToken.Valid = false
+		return tToken, ErrTokenRevoked
+	}
+
+	return tToken, nil
+}
+
+func (j *service) validateTimeClaims(claims jwt.MapClaims) error {
+	now := j.nowFunc().Unix()
+
+	if !claims.VerifyExpiresAt(now, false) {
+		return jwt.NewValidationError("token is expired", jwt.ValidationErrorExpired)
+	}
+
+	if !claims.VerifyIssuedAt(now, false) {
+		return jwt.NewValidationError("token used before issued", jwt.ValidationErrorIssuedAt)
+	}
+
+	if !claims.VerifyNotBefore(now, false) {
+		return jwt.NewValidationError("token is not valid yet", jwt.ValidationErrorNotValidYet)
+	}
+
+	return nil
+}
+
+// validateAudience rejects a token whose "aud" claim names an audience this
+// service does not issue. Tokens without the claim are accepted.
+func (j *service) validateAudience(claims jwt.MapClaims) error {
+	if _, ok := claims["aud"]; !ok {
+		return nil
+	}
+	if j.adminAudience == "" || !claims.VerifyAudience(j.adminAudience, true) {
+		return jwt.NewValidationError("token audience is not accepted", jwt.ValidationErrorAudience)
+	}
+	return nil
+}
+
+// RevokeSession invalidates every access token already issued for sessionID.
+func (j *service) RevokeSession(sessionID string) {
+	j.revokedMu.Lock()
+	defer j.revokedMu.Unlock()
+
+	if j.revokedSessions == nil {
+		j.revokedSessions = make(map[string]time.Time)
+	}
+
+	now := j.nowFunc()
+	for id, revokedAt := range j.revokedSessions {
+		if now.Sub(revokedAt) > j.accessExpiry {
+			delete(j.revokedSessions, id)
+		}
+	}
+	j.revokedSessions[sessionID] = now
+}
+
+func (j *service) isRevoked(claims jwt.MapClaims) bool {
+	sessionID, _ := claims["sid"].(string)
+	if sessionID == "" {
+		return false
+	}
+
+	j.revokedMu.RLock()
+	_, revoked := j.revokedSessions[sessionID]
+	j.revokedMu.RUnlock()
+	return revoked
+}
+
+// TokenVersion returns the "tv" claim of an access token, or 0 for tokens
+// minted before the claim existed.
+func TokenVersion(claims jwt.MapClaims) int {
+	tv, _ := claims["tv"].(float64)
+	return int(tv)
 }
 
 func (j *service) GetUserIDByToken(token string) (string, error) {
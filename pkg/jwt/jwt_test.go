@@ -0,0 +1,251 @@
+package jwt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "test-secret"
+
+func newTestService() *service {
+	return &service{
+		secretKey:     testSecret,
+		issuer:        "Template",
+		accessExpiry:  15 * time.Minute,
+		refreshExpiry: 7 * 24 * time.Hour,
+		validMethods:  []string{"HS256"},
+		nowFunc:       time.Now,
+	}
+}
+
+func testClaims() jwtCustomClaim {
+	return jwtCustomClaim{
+		UserID: "user-id",
+		Role:   "user",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+}
+
+func TestService_ValidateToken_AllowedMethod(t *testing.T) {
+	svc := newTestService()
+
+	token, err := svc.GenerateAccessToken("user-id", "user")
+	require.NoError(t, err)
+
+	parsed, err := svc.ValidateToken(token)
+
+	require.NoError(t, err)
+	assert.True(t, parsed.Valid)
+}
+
+func TestService_ValidateToken_RejectsAlgNone(t *testing.T) {
+	svc := newTestService()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodNone, testClaims()).
+		SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+
+	parsed, err := svc.ValidateToken(token)
+
+	assert.Error(t, err)
+	if parsed != nil {
+		assert.False(t, parsed.Valid)
+	}
+}
+
+func TestService_ValidateToken_RejectsAlgNotInAllowlist(t *testing.T) {
+	svc := newTestService()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS384, testClaims()).
+		SignedString([]byte(testSecret))
+	require.NoError(t, err)
+
+	_, err = svc.ValidateToken(token)
+
+	assert.Error(t, err)
+}
+
+func TestService_ValidateToken_ConfiguredAdditionalMethod(t *testing.T) {
+	svc := newTestService()
+	svc.validMethods = []string{"HS256", "HS384"}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS384, testClaims()).
+		SignedString([]byte(testSecret))
+	require.NoError(t, err)
+
+	parsed, err := svc.ValidateToken(token)
+
+	require.NoError(t, err)
+	assert.True(t, parsed.Valid)
+}
+
+func TestService_ValidateToken_FixedClockExpiry(t *testing.T) {
+	issuedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := issuedAt
+
+	svc := newTestService()
+	svc.nowFunc = func() time.Time { return now }
+
+	token, err := svc.GenerateAccessToken("user-id", "user")
+	require.NoError(t, err)
+
+	now = issuedAt.Add(svc.accessExpiry - time.Second)
+	parsed, err := svc.ValidateToken(token)
+	require.NoError(t, err)
+	assert.True(t, parsed.Valid)
+
+	now = issuedAt.Add(svc.accessExpiry + time.Second)
+	parsed, err = svc.ValidateToken(token)
+	require.Error(t, err)
+	assert.False(t, parsed.Valid)
+
+	var validationErr *jwt.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.NotZero(t, validationErr.Errors&jwt.ValidationErrorExpired)
+}
+
+func TestService_ValidateToken_IssuedInFuture(t *testing.T) {
+	issuedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := issuedAt
+
+	svc := newTestService()
+	svc.nowFunc = func() time.Time { return now }
+
+	token, err := svc.GenerateAccessToken("user-id", "user")
+	require.NoError(t, err)
+
+	now = issuedAt.Add(-time.Minute)
+	_, err = svc.ValidateToken(token)
+
+	assert.Error(t, err)
+}
+
+func TestService_GenerateRefreshToken_UsesClock(t *testing.T) {
+	fixed := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	svc := newTestService()
+	svc.nowFunc = func() time.Time { return fixed }
+
+	_, expiresAt, err := svc.GenerateRefreshToken()
+
+	require.NoError(t, err)
+	assert.Equal(t, fixed.Add(svc.refreshExpiry), expiresAt)
+}
+
+func TestService_AccessTokenExpiry(t *testing.T) {
+	svc := newTestService()
+
+	assert.Equal(t, 15*time.Minute, svc.AccessTokenExpiry())
+}
+
+func TestService_TokenVersionClaim(t *testing.T) {
+	svc := newTestService()
+
+	token, err := svc.GenerateSessionAccessToken("user-id", "user", "", "session-id", 4)
+	require.NoError(t, err)
+	legacy, err := svc.GenerateAccessToken("user-id", "user")
+	require.NoError(t, err)
+
+	parsed, err := svc.ValidateToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, 4, TokenVersion(parsed.Claims.(jwt.MapClaims)))
+
+	parsed, err = svc.ValidateToken(legacy)
+	require.NoError(t, err)
+	assert.Equal(t, 0, TokenVersion(parsed.Claims.(jwt.MapClaims)))
+}
+
+func TestService_TenantClaim(t *testing.T) {
+	svc := newTestService()
+
+	token, err := svc.GenerateSessionAccessToken("user-id", "user", "acme", "session-id", 0)
+	require.NoError(t, err)
+
+	parsed, err := svc.ValidateToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, "acme", parsed.Claims.(jwt.MapClaims)["tenant_id"])
+}
+
+func TestService_AdminAudienceClaim(t *testing.T) {
+	svc := newTestService()
+	svc.adminAudience = "admin-api"
+
+	admin, err := svc.GenerateSessionAccessToken("user-id", "admin", "", "session-id", 0)
+	require.NoError(t, err)
+	user, err := svc.GenerateSessionAccessToken("user-id", "user", "", "session-id", 0)
+	require.NoError(t, err)
+
+	parsed, err := svc.ValidateToken(admin)
+	require.NoError(t, err)
+	assert.True(t, parsed.Claims.(jwt.MapClaims).VerifyAudience("admin-api", true))
+
+	parsed, err = svc.ValidateToken(user)
+	require.NoError(t, err)
+	assert.NotContains(t, parsed.Claims.(jwt.MapClaims), "aud")
+}
+
+func TestService_ValidateToken_RejectsForeignAudience(t *testing.T) {
+	tests := []struct {
+		name          string
+		adminAudience string
+		aud           []string
+		wantErr       bool
+	}{
+		{name: "configured audience", adminAudience: "admin-api", aud: []string{"admin-api"}},
+		{name: "configured among several", adminAudience: "admin-api", aud: []string{"web", "admin-api"}},
+		{name: "foreign audience", adminAudience: "admin-api", aud: []string{"web"}, wantErr: true},
+		{name: "audience while none configured", aud: []string{"admin-api"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService()
+			svc.adminAudience = tt.adminAudience
+
+			claims := testClaims()
+			claims.Audience = tt.aud
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+			require.NoError(t, err)
+
+			parsed, err := svc.ValidateToken(token)
+
+			if tt.wantErr {
+				var validationErr *jwt.ValidationError
+				require.ErrorAs(t, err, &validationErr)
+				assert.NotZero(t, validationErr.Errors&jwt.ValidationErrorAudience)
+				assert.False(t, parsed.Valid)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, parsed.Valid)
+		})
+	}
+}
+
+func TestService_RevokeSession(t *testing.T) {
+	svc := newTestService()
+
+	revoked, err := svc.GenerateSessionAccessToken("user-id", "user", "", "session-a", 0)
+	require.NoError(t, err)
+	other, err := svc.GenerateSessionAccessToken("user-id", "user", "", "session-b", 0)
+	require.NoError(t, err)
+	unscoped, err := svc.GenerateAccessToken("user-id", "user")
+	require.NoError(t, err)
+
+	svc.RevokeSession("session-a")
+
+	_, err = svc.ValidateToken(revoked)
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+
+	_, err = svc.ValidateToken(other)
+	assert.NoError(t, err)
+
+	_, err = svc.ValidateToken(unscoped)
+	assert.NoError(t, err)
+}
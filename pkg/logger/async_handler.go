@@ -21,21 +21,38 @@ type asyncHandler struct {
 	closed       atomic.Bool
 	droppedCount atomic.Int64
 	dropOnFull   bool
+	workers      int
+
+	// High-water monitoring, only started on the root handler by monitor.
+	stop           chan struct{}
+	warnLogger     *slog.Logger
+	highWaterMark  float64
+	aboveHighWater bool
 }
 
-func newAsyncHandler(handler slog.Handler, bufferSize int, dropOnFull bool) *asyncHandler {
+// newAsyncHandler starts workers goroutines draining a buffer of bufferSize
+// records into handler. With more than one worker records may reach handler
+// out of order, so handler must be safe for concurrent use.
+func newAsyncHandler(handler slog.Handler, bufferSize, workers int, dropOnFull bool) *asyncHandler {
 	if bufferSize <= 0 {
 		bufferSize = 5000
 	}
+	if workers <= 0 {
+		workers = 1
+	}
 
 	ah := &asyncHandler{
 		handler:    handler,
 		logChan:    make(chan logRecord, bufferSize),
 		dropOnFull: dropOnFull,
+		workers:    workers,
+		stop:       make(chan struct{}),
 	}
 
-	ah.wg.Add(1)
-	go ah.processLogs()
+	ah.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go ah.processLogs()
+	}
 
 	return ah
 }
@@ -75,6 +92,7 @@ func (h *asyncHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	return newAsyncHandler(
 		h.handler.WithAttrs(attrs),
 		cap(h.logChan),
+		h.workers,
 		h.dropOnFull,
 	)
 }
@@ -83,6 +101,7 @@ func (h *asyncHandler) WithGroup(name string) slog.Handler {
 	return newAsyncHandler(
 		h.handler.WithGroup(name),
 		cap(h.logChan),
+		h.workers,
 		h.dropOnFull,
 	)
 }
@@ -95,9 +114,62 @@ func (h *asyncHandler) processLogs() {
 	}
 }
 
+// monitor samples buffer utilization every interval and logs a warning to
+// warnLogger each time it rises past highWaterPercent. warnLogger must not
+// write through this handler.
+func (h *asyncHandler) monitor(warnLogger *slog.Logger, highWaterPercent int, interval time.Duration) {
+	if highWaterPercent <= 0 {
+		return
+	}
+
+	h.warnLogger = warnLogger
+	h.highWaterMark = float64(highWaterPercent) / 100
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				h.sampleBuffer()
+			case <-h.stop:
+				return
+			}
+		}
+	}()
+}
+
+// sampleBuffer warns once per crossing of the high-water mark; utilization
+// has to fall back below the mark before the next warning.
+func (h *asyncHandler) sampleBuffer() {
+	utilization := float64(h.BufferLen()) / float64(h.BufferCap())
+
+	if utilization < h.highWaterMark {
+		h.aboveHighWater = false
+		return
+	}
+
+	if h.aboveHighWater {
+		return
+	}
+	h.aboveHighWater = true
+
+	h.warnLogger.Warn("async log buffer above high-water mark",
+		"utilization", utilization,
+		"length", h.BufferLen(),
+		"capacity", h.BufferCap(),
+		"dropped", h.DroppedCount(),
+	)
+}
+
 func (h *asyncHandler) Shutdown(ctx context.Context) error {
 	h.stopOnce.Do(func() {
 		h.closed.Store(true)
+		close(h.stop)
 		close(h.logChan)
 	})
 
@@ -118,3 +190,28 @@ func (h *asyncHandler) Shutdown(ctx context.Context) error {
 func (h *asyncHandler) DroppedCount() int64 {
 	return h.droppedCount.Load()
 }
+
+// BufferLen returns the number of records waiting to be handled.
+func (h *asyncHandler) BufferLen() int {
+	return len(h.logChan)
+}
+
+// BufferCap returns the capacity of the record buffer.
+func (h *asyncHandler) BufferCap() int {
+	return cap(h.logChan)
+}
+
+func (h *asyncHandler) status() BufferStatus {
+	utilization := float64(h.BufferLen()) / float64(h.BufferCap())
+
+	status := StatusOK
+	if utilization >= degradedUtilization {
+		status = StatusDegraded
+	}
+
+	return BufferStatus{
+		Status:      status,
+		Dropped:     h.DroppedCount(),
+		Utilization: utilization,
+	}
+}
@@ -0,0 +1,75 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingHandler counts handled records and the peak number of concurrent
+// Handle calls.
+type countingHandler struct {
+	handled  atomic.Int64
+	inFlight atomic.Int64
+	peak     atomic.Int64
+}
+
+func (h *countingHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *countingHandler) Handle(context.Context, slog.Record) error {
+	n := h.inFlight.Add(1)
+	for {
+		peak := h.peak.Load()
+		if n <= peak || h.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+
+	time.Sleep(time.Millisecond)
+
+	h.inFlight.Add(-1)
+	h.handled.Add(1)
+	return nil
+}
+
+func (h *countingHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *countingHandler) WithGroup(string) slog.Handler      { return h }
+
+func TestAsyncHandler_MultipleWorkers(t *testing.T) {
+	const records = 200
+	inner := &countingHandler{}
+	h := newAsyncHandler(inner, records, 4, false)
+
+	for i := 0; i < records; i++ {
+		require.NoError(t, h.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "msg", 0)))
+	}
+
+	require.NoError(t, h.Shutdown(context.Background()))
+
+	assert.Equal(t, int64(records), inner.handled.Load())
+	assert.Zero(t, h.DroppedCount())
+	assert.Greater(t, inner.peak.Load(), int64(1))
+}
+
+func TestAsyncHandler_DefaultsToOneWorker(t *testing.T) {
+	h := newAsyncHandler(&countingHandler{}, 10, 0, true)
+	t.Cleanup(func() { _ = h.Shutdown(context.Background()) })
+
+	assert.Equal(t, 1, h.workers)
+}
+
+func TestAsyncHandler_WithAttrsKeepsWorkers(t *testing.T) {
+	h := newAsyncHandler(&countingHandler{}, 10, 3, true)
+	child := h.WithAttrs([]slog.Attr{slog.String("k", "v")}).(*asyncHandler)
+	t.Cleanup(func() {
+		_ = h.Shutdown(context.Background())
+		_ = child.Shutdown(context.Background())
+	})
+
+	assert.Equal(t, 3, child.workers)
+}
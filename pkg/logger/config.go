@@ -8,10 +8,17 @@ type Config struct {
 	EnableStdout   bool
 	EnableOTLP     bool
 	BufferSize     int
+	Workers        int
 	DropOnFull     bool
+	AddSource      bool
+	RedactKeys     []string
+	RenameKeys     map[string]string
+	HighWaterPct   int
 	OTLPEndpoint   string
+	LogsEndpoint   string
 	ServiceName    string
 	ServiceVersion string
+	Namespace      string
 	Environment    string
 }
 
@@ -21,10 +28,17 @@ func LoadConfig(serviceName, serviceVersion string) Config {
 		EnableStdout:   cfg.EnableStdoutLogs,
 		EnableOTLP:     cfg.EnableOTLPLogs,
 		BufferSize:     cfg.LogBufferSize,
+		Workers:        cfg.LogWorkers,
 		DropOnFull:     cfg.LogDropOnFull,
+		AddSource:      cfg.LogAddSource,
+		RedactKeys:     cfg.LogRedactKeyList(),
+		RenameKeys:     cfg.LogRenameKeyMap(),
+		HighWaterPct:   cfg.LogBufferHighWaterPercent,
 		OTLPEndpoint:   cfg.OTELExporterEndpoint,
+		LogsEndpoint:   cfg.OTELLogsEndpoint,
 		ServiceName:    serviceName,
 		ServiceVersion: serviceVersion,
+		Namespace:      cfg.ServiceNamespace,
 		Environment:    getEnvironment(cfg.AppEnv),
 	}
 }
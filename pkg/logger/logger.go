@@ -2,14 +2,19 @@ package logger
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"log/slog"
+	"net"
+	"net/url"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/elskow/go-microservice-template/pkg/telemetry"
 	"go.opentelemetry.io/contrib/bridges/otelslog"
 	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
 	sdklog "go.opentelemetry.io/otel/sdk/log"
-	"go.opentelemetry.io/otel/sdk/resource"
-	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 )
 
 var (
@@ -17,6 +22,10 @@ var (
 	globalAsyncHandler *asyncHandler
 )
 
+// bufferSampleInterval is how often the async buffer is checked against the
+// high-water mark.
+const bufferSampleInterval = time.Second
+
 func NewLogger(serviceName, serviceVersion string) *slog.Logger {
 	config := LoadConfig(serviceName, serviceVersion)
 
@@ -25,24 +34,29 @@ func NewLogger(serviceName, serviceVersion string) *slog.Logger {
 		hostname = "unknown"
 	}
 
-	level := slog.LevelInfo
-	if config.Environment == "development" || config.Environment == "dev" {
-		level = slog.LevelDebug
-	}
+	return newLogger(config, hostname, os.Stdout, os.Stderr)
+}
 
+func newLogger(config Config, hostname string, stdout, stderr io.Writer) *slog.Logger {
 	var handlers []slog.Handler
 
+	// Problems with OTLP logging are reported through stdout, or stderr when
+	// stdout logging is off, never through the OTLP pipeline itself.
+	var warnHandler slog.Handler = slog.NewJSONHandler(stderr, nil)
+
 	if config.EnableStdout {
-		stdoutHandler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-			Level: level,
-		})
+		stdoutHandler := newStdoutHandler(stdout, config)
 		handlers = append(handlers, stdoutHandler)
+		warnHandler = stdoutHandler
 	}
 
-	if config.EnableOTLP && config.OTLPEndpoint != "" {
-		otelHandler := createOTLPHandler(config, hostname)
-		if otelHandler != nil {
-			globalAsyncHandler = newAsyncHandler(otelHandler, config.BufferSize, config.DropOnFull)
+	if config.EnableOTLP && (config.OTLPEndpoint != "" || config.LogsEndpoint != "") {
+		otelHandler, err := createOTLPHandler(config, hostname)
+		if err != nil {
+			slog.New(warnHandler).Error("OTLP logging disabled", "error", err)
+		} else {
+			globalAsyncHandler = newAsyncHandler(otelHandler, config.BufferSize, config.Workers, config.DropOnFull)
+			globalAsyncHandler.monitor(slog.New(warnHandler), config.HighWaterPct, bufferSampleInterval)
 			handlers = append(handlers, globalAsyncHandler)
 		}
 	}
@@ -60,18 +74,78 @@ func NewLogger(serviceName, serviceVersion string) *slog.Logger {
 	return slog.New(handler)
 }
 
-func createOTLPHandler(config Config, hostname string) slog.Handler {
-	logEndpoint := config.OTLPEndpoint
+func isDevelopment(env string) bool {
+	return env == "development" || env == "dev"
+}
+
+// newStdoutHandler logs debug records in development, where AddSource may
+// also attach the caller's file and line.
+func newStdoutHandler(w io.Writer, config Config) slog.Handler {
+	level := slog.LevelInfo
+	if isDevelopment(config.Environment) {
+		level = slog.LevelDebug
+	}
+
+	return slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		AddSource:   config.AddSource && isDevelopment(config.Environment),
+		ReplaceAttr: newReplaceAttr(config.RedactKeys, config.RenameKeys),
+	})
+}
+
+// parseEndpoint parses an OTLP endpoint given as a URL or bare host:port.
+func parseEndpoint(endpoint string) (*url.URL, error) {
+	raw := endpoint
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q: %w", endpoint, err)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q: missing host", endpoint)
+	}
+
+	return u, nil
+}
+
+// logsEndpoint returns the OTLP logs host:port: explicit when set, otherwise
+// derived from the collector endpoint, which serves logs on 4319 when
+// metrics are sent to 4318.
+func logsEndpoint(explicit, collector string) (string, error) {
+	if explicit != "" {
+		u, err := parseEndpoint(explicit)
+		if err != nil {
+			return "", err
+		}
+		return u.Host, nil
+	}
 
-	const httpPrefix = "http://"
-	if len(logEndpoint) > len(httpPrefix) && logEndpoint[:len(httpPrefix)] == httpPrefix {
-		logEndpoint = logEndpoint[len(httpPrefix):]
+	u, err := parseEndpoint(collector)
+	if err != nil {
+		return "", err
 	}
 
 	const metricsPort = "4318"
 	const logsPort = "4319"
-	if len(logEndpoint) > len(metricsPort) && logEndpoint[len(logEndpoint)-len(metricsPort):] == metricsPort {
-		logEndpoint = logEndpoint[:len(logEndpoint)-len(metricsPort)] + logsPort
+
+	port := u.Port()
+	if port == metricsPort {
+		port = logsPort
+	}
+	if port == "" {
+		return u.Hostname(), nil
+	}
+
+	return net.JoinHostPort(u.Hostname(), port), nil
+}
+
+func createOTLPHandler(config Config, hostname string) (slog.Handler, error) {
+	logEndpoint, err := logsEndpoint(config.LogsEndpoint, config.OTLPEndpoint)
+	if err != nil {
+		return nil, err
 	}
 
 	ctx := context.Background()
@@ -81,27 +155,27 @@ func createOTLPHandler(config Config, hostname string) slog.Handler {
 		otlploghttp.WithInsecure(),
 	)
 	if err != nil {
-		return nil
-	}
-
-	res, _ := resource.New(ctx,
-		resource.WithAttributes(
-			semconv.ServiceName(config.ServiceName),
-			semconv.ServiceVersion(config.ServiceVersion),
-			semconv.ServiceInstanceID(hostname),
-		),
-		resource.WithFromEnv(),
-		resource.WithProcess(),
-		resource.WithOS(),
-		resource.WithHost(),
-	)
+		return nil, fmt.Errorf("failed to create OTLP log exporter: %w", err)
+	}
+
+	res, _ := telemetry.BuildResource(ctx, resourceConfig(config, hostname))
 
 	loggerProvider = sdklog.NewLoggerProvider(
 		sdklog.WithResource(res),
 		sdklog.WithProcessor(sdklog.NewBatchProcessor(exporter)),
 	)
 
-	return otelslog.NewHandler(config.ServiceName, otelslog.WithLoggerProvider(loggerProvider))
+	return otelslog.NewHandler(config.ServiceName, otelslog.WithLoggerProvider(loggerProvider)), nil
+}
+
+func resourceConfig(config Config, hostname string) telemetry.ResourceConfig {
+	return telemetry.ResourceConfig{
+		ServiceName:    config.ServiceName,
+		ServiceVersion: config.ServiceVersion,
+		Hostname:       hostname,
+		Namespace:      config.Namespace,
+		Environment:    config.Environment,
+	}
 }
 
 func SetDefault(logger *slog.Logger) {
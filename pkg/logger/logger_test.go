@@ -0,0 +1,179 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/elskow/go-microservice-template/pkg/telemetry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+func TestResourceConfig_MatchesTelemetry(t *testing.T) {
+	cfg := Config{ServiceName: "svc", ServiceVersion: "1.2.3", Namespace: "payments", Environment: "staging"}
+
+	res, err := telemetry.BuildResource(context.Background(), resourceConfig(cfg, "host-1"))
+	require.NoError(t, err)
+
+	value, ok := res.Set().Value(semconv.ServiceNamespaceKey)
+	require.True(t, ok)
+	assert.Equal(t, "payments", value.AsString())
+
+	value, ok = res.Set().Value(semconv.DeploymentEnvironmentKey)
+	require.True(t, ok)
+	assert.Equal(t, "staging", value.AsString())
+}
+
+func TestNewStdoutHandler_AddSource(t *testing.T) {
+	var out bytes.Buffer
+	handler := newStdoutHandler(&out, Config{Environment: "development", AddSource: true})
+
+	slog.New(handler).Info("hello")
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	source, ok := entry[slog.SourceKey].(map[string]any)
+	require.True(t, ok, "expected a source attribute")
+	assert.Contains(t, source["file"], "logger_test.go")
+}
+
+func TestNewStdoutHandler_NoSourceByDefault(t *testing.T) {
+	var out bytes.Buffer
+	handler := newStdoutHandler(&out, Config{Environment: "development"})
+
+	slog.New(handler).Info("hello")
+
+	assert.NotContains(t, out.String(), `"source"`)
+}
+
+func TestNewStdoutHandler_NoSourceOutsideDevelopment(t *testing.T) {
+	var out bytes.Buffer
+	handler := newStdoutHandler(&out, Config{Environment: "production", AddSource: true})
+
+	slog.New(handler).Info("hello")
+
+	assert.NotContains(t, out.String(), `"source"`)
+}
+
+func TestNewStdoutHandler_ReplaceAttr(t *testing.T) {
+	var out bytes.Buffer
+	handler := newStdoutHandler(&out, Config{
+		Environment: "production",
+		RedactKeys:  []string{"password"},
+		RenameKeys:  map[string]string{slog.MessageKey: "message"},
+	})
+
+	slog.New(handler).Info("login",
+		"Password", "hunter2",
+		slog.Group("request", slog.String("password", "hunter2"), slog.String("path", "/login")),
+	)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, "login", entry["message"])
+	assert.NotContains(t, entry, slog.MessageKey)
+	assert.Equal(t, redactedValue, entry["Password"])
+	assert.Equal(t, map[string]any{"password": redactedValue, "path": "/login"}, entry["request"])
+	assert.NotContains(t, out.String(), "hunter2")
+}
+
+func TestNewReplaceAttr_NilWhenUnconfigured(t *testing.T) {
+	assert.Nil(t, newReplaceAttr(nil, nil))
+}
+
+func TestLogsEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     string
+	}{
+		{endpoint: "http://otel-collector:4318", want: "otel-collector:4319"},
+		{endpoint: "otel-collector:4318", want: "otel-collector:4319"},
+		{endpoint: "https://collector.example.com:443/", want: "collector.example.com:443"},
+		{endpoint: "http://collector", want: "collector"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			got, err := logsEndpoint("", tt.endpoint)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLogsEndpoint_Malformed(t *testing.T) {
+	for _, endpoint := range []string{"http://collector:notaport", "http://:4318", "http://[::1"} {
+		t.Run(endpoint, func(t *testing.T) {
+			_, err := logsEndpoint("", endpoint)
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLogsEndpoint_Explicit(t *testing.T) {
+	got, err := logsEndpoint("http://logs-collector:9000", "http://otel-collector:4318")
+
+	require.NoError(t, err)
+	assert.Equal(t, "logs-collector:9000", got)
+}
+
+func TestLogsEndpoint_ExplicitKeepsPort4318(t *testing.T) {
+	got, err := logsEndpoint("logs-collector:4318", "otel-collector:4318")
+
+	require.NoError(t, err)
+	assert.Equal(t, "logs-collector:4318", got)
+}
+
+func TestLogsEndpoint_ExplicitMalformed(t *testing.T) {
+	_, err := logsEndpoint("http://logs-collector:bad", "otel-collector:4318")
+
+	assert.Error(t, err)
+}
+
+func TestNewLogger_MalformedOTLPEndpointFallsBackToStdout(t *testing.T) {
+	original := globalAsyncHandler
+	globalAsyncHandler = nil
+	t.Cleanup(func() { globalAsyncHandler = original })
+
+	var stdout, stderr bytes.Buffer
+	cfg := Config{
+		ServiceName:  "svc",
+		EnableStdout: true,
+		EnableOTLP:   true,
+		OTLPEndpoint: "http://collector:notaport",
+	}
+
+	logger := newLogger(cfg, "host-1", &stdout, &stderr)
+	logger.Info("still logging")
+
+	assert.Nil(t, globalAsyncHandler)
+	assert.Contains(t, stdout.String(), `"level":"ERROR"`)
+	assert.Contains(t, stdout.String(), "OTLP logging disabled")
+	assert.Contains(t, stdout.String(), "still logging")
+	assert.Empty(t, stderr.String())
+}
+
+func TestNewLogger_MalformedOTLPEndpointReportsToStderrWithoutStdout(t *testing.T) {
+	original := globalAsyncHandler
+	globalAsyncHandler = nil
+	t.Cleanup(func() { globalAsyncHandler = original })
+
+	var stdout, stderr bytes.Buffer
+	cfg := Config{
+		ServiceName:  "svc",
+		EnableOTLP:   true,
+		OTLPEndpoint: "http://:4318",
+	}
+
+	newLogger(cfg, "host-1", &stdout, &stderr)
+
+	assert.Nil(t, globalAsyncHandler)
+	assert.Empty(t, stdout.String())
+	assert.Contains(t, stderr.String(), "OTLP logging disabled")
+}
@@ -0,0 +1,36 @@
+package logger
+
+import (
+	"log/slog"
+	"strings"
+)
+
+const redactedValue = "[REDACTED]"
+
+// newReplaceAttr returns a slog ReplaceAttr hook that redacts the values of
+// redactKeys at any depth and renames top-level keys per renameKeys. It
+// returns nil when there is nothing to replace, keeping slog's fast path.
+func newReplaceAttr(redactKeys []string, renameKeys map[string]string) func([]string, slog.Attr) slog.Attr {
+	if len(redactKeys) == 0 && len(renameKeys) == 0 {
+		return nil
+	}
+
+	redact := make(map[string]struct{}, len(redactKeys))
+	for _, key := range redactKeys {
+		redact[strings.ToLower(key)] = struct{}{}
+	}
+
+	return func(groups []string, a slog.Attr) slog.Attr {
+		if _, ok := redact[strings.ToLower(a.Key)]; ok {
+			a.Value = slog.StringValue(redactedValue)
+		}
+
+		if len(groups) == 0 {
+			if renamed, ok := renameKeys[a.Key]; ok {
+				a.Key = renamed
+			}
+		}
+
+		return a
+	}
+}
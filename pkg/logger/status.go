@@ -0,0 +1,26 @@
+package logger
+
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+)
+
+// degradedUtilization is the buffer fill ratio at which the async OTLP
+// handler is reported as degraded: records are about to be dropped.
+const degradedUtilization = 0.9
+
+// BufferStatus describes the async OTLP log buffer for readiness reporting.
+type BufferStatus struct {
+	Status      string  `json:"status"`
+	Dropped     int64   `json:"dropped"`
+	Utilization float64 `json:"buffer_utilization"`
+}
+
+// Status reports the state of the async OTLP log buffer. It is always ok
+// when OTLP logging is disabled.
+func Status() BufferStatus {
+	if globalAsyncHandler == nil {
+		return BufferStatus{Status: StatusOK}
+	}
+	return globalAsyncHandler.status()
+}
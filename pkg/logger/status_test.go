@@ -0,0 +1,114 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingHandler holds every record until release is closed, standing in
+// for a stalled OTLP exporter.
+type blockingHandler struct {
+	release chan struct{}
+}
+
+func (h *blockingHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *blockingHandler) Handle(context.Context, slog.Record) error {
+	<-h.release
+	return nil
+}
+
+func (h *blockingHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *blockingHandler) WithGroup(string) slog.Handler      { return h }
+
+func newBlockedAsyncHandler(t *testing.T, bufferSize int) *asyncHandler {
+	inner := &blockingHandler{release: make(chan struct{})}
+	h := newAsyncHandler(inner, bufferSize, 1, true)
+	t.Cleanup(func() {
+		close(inner.release)
+		_ = h.Shutdown(context.Background())
+	})
+	return h
+}
+
+func TestStatus_SaturatedBufferIsDegraded(t *testing.T) {
+	const bufferSize = 10
+	h := newBlockedAsyncHandler(t, bufferSize)
+
+	for i := 0; i < bufferSize+5; i++ {
+		require.NoError(t, h.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "msg", 0)))
+	}
+
+	status := h.status()
+
+	assert.Equal(t, StatusDegraded, status.Status)
+	assert.Positive(t, status.Dropped)
+	assert.GreaterOrEqual(t, status.Utilization, degradedUtilization)
+}
+
+func TestStatus_IdleBufferIsOK(t *testing.T) {
+	h := newBlockedAsyncHandler(t, 10)
+
+	status := h.status()
+
+	assert.Equal(t, StatusOK, status.Status)
+	assert.Zero(t, status.Dropped)
+	assert.Zero(t, status.Utilization)
+}
+
+func TestStatus_OTLPDisabled(t *testing.T) {
+	original := globalAsyncHandler
+	globalAsyncHandler = nil
+	t.Cleanup(func() { globalAsyncHandler = original })
+
+	assert.Equal(t, BufferStatus{Status: StatusOK}, Status())
+}
+
+func TestSampleBuffer_WarnsOncePerCrossing(t *testing.T) {
+	var out bytes.Buffer
+	h := &asyncHandler{
+		logChan:       make(chan logRecord, 10),
+		warnLogger:    slog.New(slog.NewJSONHandler(&out, nil)),
+		highWaterMark: 0.8,
+	}
+	fill := func(n int) {
+		for i := 0; i < n; i++ {
+			h.logChan <- logRecord{ctx: context.Background()}
+		}
+	}
+	drain := func() {
+		for len(h.logChan) > 0 {
+			<-h.logChan
+		}
+	}
+
+	fill(7)
+	h.sampleBuffer()
+	assert.Zero(t, strings.Count(out.String(), "high-water mark"))
+
+	fill(2)
+	h.sampleBuffer()
+	h.sampleBuffer()
+	assert.Equal(t, 1, strings.Count(out.String(), "high-water mark"))
+
+	drain()
+	h.sampleBuffer()
+	fill(10)
+	h.sampleBuffer()
+	assert.Equal(t, 2, strings.Count(out.String(), "high-water mark"))
+}
+
+func TestMonitor_DisabledAtZero(t *testing.T) {
+	h := newBlockedAsyncHandler(t, 10)
+
+	h.monitor(slog.New(newDiscardHandler()), 0, time.Millisecond)
+
+	assert.Nil(t, h.warnLogger)
+}
@@ -0,0 +1,48 @@
+// Package maintenance stores the maintenance mode flag in the database so
+// every replica serving the API sees the same state.
+package maintenance
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/elskow/go-microservice-template/pkg/database"
+)
+
+// Store reads and writes the single maintenance_state row.
+type Store struct {
+	db *database.TracedDB
+}
+
+func NewStore(db *database.TracedDB) *Store {
+	return &Store{db: db}
+}
+
+// MaintenanceEnabled returns the shared flag. A missing row reads as
+// disabled.
+func (s *Store) MaintenanceEnabled(ctx context.Context) (bool, error) {
+	var enabled bool
+	err := s.db.GetContext(ctx, &enabled, `SELECT enabled FROM maintenance_state WHERE id`)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read maintenance state: %w", err)
+	}
+	return enabled, nil
+}
+
+// SetMaintenanceEnabled stores the shared flag.
+func (s *Store) SetMaintenanceEnabled(ctx context.Context, enabled bool) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO maintenance_state (id, enabled, updated_at) VALUES (TRUE, $1, NOW())
+		ON CONFLICT (id) DO UPDATE SET enabled = EXCLUDED.enabled, updated_at = EXCLUDED.updated_at`,
+		enabled,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store maintenance state: %w", err)
+	}
+	return nil
+}
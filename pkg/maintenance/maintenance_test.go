@@ -0,0 +1,65 @@
+package maintenance
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/elskow/go-microservice-template/pkg/database/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const selectState = `SELECT enabled FROM maintenance_state WHERE id`
+
+func TestStore_MaintenanceEnabled(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(regexp.QuoteMeta(selectState)).
+		WillReturnRows(sqlmock.NewRows([]string{"enabled"}).AddRow(true))
+
+	enabled, err := NewStore(db).MaintenanceEnabled(context.Background())
+
+	require.NoError(t, err)
+	assert.True(t, enabled)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStore_MaintenanceEnabled_MissingRowIsDisabled(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(regexp.QuoteMeta(selectState)).WillReturnError(sql.ErrNoRows)
+
+	enabled, err := NewStore(db).MaintenanceEnabled(context.Background())
+
+	require.NoError(t, err)
+	assert.False(t, enabled)
+}
+
+func TestStore_MaintenanceEnabled_QueryError(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(regexp.QuoteMeta(selectState)).WillReturnError(errors.New("connection refused"))
+
+	_, err := NewStore(db).MaintenanceEnabled(context.Background())
+
+	assert.ErrorContains(t, err, "failed to read maintenance state")
+}
+
+func TestStore_SetMaintenanceEnabled(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDB(t)
+	defer cleanup()
+
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO maintenance_state`)).
+		WithArgs(true).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, NewStore(db).SetMaintenanceEnabled(context.Background(), true))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
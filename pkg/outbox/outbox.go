@@ -0,0 +1,285 @@
+// Package outbox implements a transactional outbox: events are written to
+// the outbox table in the same transaction as the state change that raised
+// them, and a Publisher later hands them to a webhook.Deliverer. An event
+// is therefore published if and only if its transaction commits.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/elskow/go-microservice-template/pkg/database"
+	"github.com/elskow/go-microservice-template/pkg/webhook"
+	"github.com/lib/pq"
+)
+
+// Enqueue writes event to the outbox. Called with a context from
+// database.TracedDB.WithTx, the row is part of that transaction.
+func Enqueue(ctx context.Context, db *database.TracedDB, event webhook.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode outbox event: %w", err)
+	}
+
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO outbox (id, event_type, payload, created_at) VALUES ($1, $2, $3, NOW())`,
+		event.ID, event.Type, payload,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue outbox event: %w", err)
+	}
+	return nil
+}
+
+type row struct {
+	ID          string         `db:"id"`
+	Payload     []byte         `db:"payload"`
+	Attempts    int            `db:"attempts"`
+	DeliveredTo pq.StringArray `db:"delivered_to"`
+	CreatedAt   time.Time      `db:"created_at"`
+}
+
+// MaxRetryBackoff caps the delay before a failed event is retried.
+const MaxRetryBackoff = time.Hour
+
+// Publisher polls the outbox for due events, delivers them in creation
+// order and marks each sent once delivered. Events are claimed for a lease
+// in a short statement and delivered outside any transaction, so slow
+// endpoints hold neither row locks nor a pooled connection. Each of the
+// deliverer's routes is tracked on its own: a failed event is retried with
+// exponential backoff, starting at the poll interval, only to the routes
+// that have not received it yet, without holding up the events behind it.
+// Delivery is at-least-once: an event whose lease runs out before its
+// outcome is recorded is delivered again. Sent events are deleted once
+// older than the retention.
+type Publisher struct {
+	db        *database.TracedDB
+	routes    []webhook.Route
+	interval  time.Duration
+	batchSize int
+	lease     time.Duration
+	retention time.Duration
+	logger    *slog.Logger
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// DefaultBatchSize is used when NewPublisher is given a batch size below 1,
+// which would otherwise never publish anything.
+const DefaultBatchSize = 100
+
+// DefaultLease is used when NewPublisher is given a lease below 1ns, which
+// would let every poll claim events still being delivered.
+const DefaultLease = 5 * time.Minute
+
+// NewPublisher returns a publisher delivering through the routes of
+// deliverer. Claimed events are not picked up again, by this or another
+// instance, for lease; it should cover delivering a whole batch. A
+// retention of zero keeps sent events forever.
+func NewPublisher(db *database.TracedDB, deliverer webhook.Deliverer, interval time.Duration, batchSize int, lease, retention time.Duration, logger *slog.Logger) *Publisher {
+	if batchSize < 1 {
+		batchSize = DefaultBatchSize
+	}
+	if lease <= 0 {
+		lease = DefaultLease
+	}
+	return &Publisher{
+		db:        db,
+		routes:    webhook.RoutesOf(deliverer),
+		interval:  interval,
+		batchSize: batchSize,
+		lease:     lease,
+		retention: retention,
+		logger:    logger,
+	}
+}
+
+// Start polls the outbox every interval until Shutdown is called.
+func (p *Publisher) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+
+	ticker := time.NewTicker(p.interval)
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := p.PublishPending(ctx); err != nil {
+					p.logger.WarnContext(ctx, "failed to publish outbox events", "error", err)
+				}
+				if _, err := p.Prune(ctx); err != nil {
+					p.logger.WarnContext(ctx, "failed to prune outbox events", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// PublishPending delivers up to batchSize due events, returning how many
+// were delivered and marked sent. Events that fail are rescheduled. Rows are
+// claimed with SKIP LOCKED and pushed past the lease, so several instances
+// can publish concurrently without sending an event twice.
+func (p *Publisher) PublishPending(ctx context.Context) (int, error) {
+	rows, err := p.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for _, r := range rows {
+		sent, err := p.publish(ctx, r)
+		if err != nil {
+			return published, err
+		}
+		if sent {
+			published++
+		}
+	}
+	return published, nil
+}
+
+// claim leases up to batchSize due events, oldest first.
+func (p *Publisher) claim(ctx context.Context) ([]row, error) {
+	var rows []row
+	err := p.db.SelectContext(ctx, &rows, `
+		WITH due AS (
+			SELECT id
+			FROM outbox
+			WHERE sent_at IS NULL AND next_attempt_at <= NOW()
+			ORDER BY created_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE outbox o
+		SET next_attempt_at = NOW() + $2 * INTERVAL '1 millisecond'
+		FROM due
+		WHERE o.id = due.id
+		RETURNING o.id, o.payload, o.attempts, o.delivered_to, o.created_at
+	`, p.batchSize, p.lease.Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+
+	// RETURNING does not keep the order of the subquery
+	sort.SliceStable(rows, func(i, j int) bool {
+		if !rows[i].CreatedAt.Equal(rows[j].CreatedAt) {
+			return rows[i].CreatedAt.Before(rows[j].CreatedAt)
+		}
+		return rows[i].ID < rows[j].ID
+	})
+	return rows, nil
+}
+
+// publish delivers r to the routes that have not received it yet and
+// records the outcome, reporting whether the event is now sent.
+func (p *Publisher) publish(ctx context.Context, r row) (bool, error) {
+	var event webhook.Event
+	if err := json.Unmarshal(r.Payload, &event); err != nil {
+		// A payload that cannot be decoded never will be; mark it sent so
+		// it is not retried forever.
+		p.logger.ErrorContext(ctx, "dropping undecodable outbox event", "id", r.ID, "error", err)
+		return true, p.markSent(ctx, r.ID, r.DeliveredTo)
+	}
+
+	// Never nil: pq.Array writes a nil slice as NULL, which delivered_to
+	// rejects.
+	delivered := append([]string{}, r.DeliveredTo...)
+	var errs []error
+	for _, route := range p.routes {
+		if slices.Contains(r.DeliveredTo, route.Name) {
+			continue
+		}
+		if err := route.Deliver(ctx, event); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", route.Name, err))
+			continue
+		}
+		delivered = append(delivered, route.Name)
+	}
+
+	if len(errs) == 0 {
+		return true, p.markSent(ctx, r.ID, delivered)
+	}
+
+	err := errors.Join(errs...)
+	backoff := p.retryBackoff(r.Attempts + 1)
+	p.logger.WarnContext(ctx, "outbox event delivery failed",
+		"id", r.ID, "event", event.Type, "attempts", r.Attempts+1, "retry_in", backoff, "error", err)
+	return false, p.reschedule(ctx, r.ID, delivered, backoff, err)
+}
+
+func (p *Publisher) markSent(ctx context.Context, id string, delivered []string) error {
+	_, err := p.db.ExecContext(ctx,
+		`UPDATE outbox SET sent_at = NOW(), delivered_to = $2 WHERE id = $1`,
+		id, pq.Array(delivered),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox event sent: %w", err)
+	}
+	return nil
+}
+
+// retryBackoff returns the delay before the attempt following the given
+// number of failed ones: the poll interval doubled per failure, capped at
+// MaxRetryBackoff.
+func (p *Publisher) retryBackoff(attempts int) time.Duration {
+	backoff := p.interval
+	for i := 1; i < attempts && backoff < MaxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, MaxRetryBackoff)
+}
+
+// reschedule records a failed attempt, keeping the routes that did receive
+// the event so the retry skips them.
+func (p *Publisher) reschedule(ctx context.Context, id string, delivered []string, backoff time.Duration, cause error) error {
+	_, err := p.db.ExecContext(ctx, `
+		UPDATE outbox
+		SET attempts = attempts + 1, next_attempt_at = NOW() + $2 * INTERVAL '1 millisecond', last_error = $3,
+			delivered_to = $4
+		WHERE id = $1
+	`, id, backoff.Milliseconds(), cause.Error(), pq.Array(delivered))
+	if err != nil {
+		return fmt.Errorf("failed to reschedule outbox event: %w", err)
+	}
+	return nil
+}
+
+// Prune deletes events sent longer ago than the retention, returning how
+// many were removed. It does nothing when the retention is zero.
+func (p *Publisher) Prune(ctx context.Context) (int64, error) {
+	if p.retention <= 0 {
+		return 0, nil
+	}
+
+	result, err := p.db.ExecContext(ctx,
+		`DELETE FROM outbox WHERE sent_at < NOW() - $1 * INTERVAL '1 millisecond'`,
+		p.retention.Milliseconds(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune outbox: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// Shutdown stops polling and waits for an in-progress batch to finish. It
+// lets the injector stop the publisher on shutdown.
+func (p *Publisher) Shutdown() error {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.wg.Wait()
+	return nil
+}
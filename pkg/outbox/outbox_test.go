@@ -0,0 +1,227 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/elskow/go-microservice-template/pkg/database/dbtest"
+	"github.com/elskow/go-microservice-template/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingDeliverer struct {
+	events []webhook.Event
+	// fail makes delivery of the events with these IDs fail.
+	fail map[string]bool
+}
+
+func (d *recordingDeliverer) Deliver(_ context.Context, event webhook.Event) error {
+	if d.fail[event.ID] {
+		return errors.New("endpoint unavailable")
+	}
+	d.events = append(d.events, event)
+	return nil
+}
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestEnqueue_WritesInCallerTransaction(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDB(t)
+	defer cleanup()
+	event := webhook.NewEvent(webhook.EventUserRegistered, "user-id", nil)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO users`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO outbox`)).
+		WithArgs(event.ID, webhook.EventUserRegistered, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := db.WithTx(context.Background(), func(ctx context.Context) error {
+		if _, err := db.ExecContext(ctx, `INSERT INTO users VALUES (1)`); err != nil {
+			return err
+		}
+		return Enqueue(ctx, db, event)
+	})
+
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// route names a recordingDeliverer so tests can tell destinations apart.
+type route struct {
+	name string
+	*recordingDeliverer
+}
+
+func (r route) Dispatch(ctx context.Context, event webhook.Event) {
+	_ = r.Deliver(ctx, event)
+}
+
+func (r route) Routes() []webhook.Route {
+	return []webhook.Route{{Name: r.name, Deliverer: r.recordingDeliverer}}
+}
+
+var claimColumns = []string{"id", "payload", "attempts", "delivered_to", "created_at"}
+
+func expectClaim(mock sqlmock.Sqlmock, rows *sqlmock.Rows) {
+	mock.ExpectQuery(regexp.QuoteMeta(`FOR UPDATE SKIP LOCKED`)).
+		WithArgs(10, DefaultLease.Milliseconds()).
+		WillReturnRows(rows)
+}
+
+func TestPublisher_PublishPending_MarksSent(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDB(t)
+	defer cleanup()
+	first := webhook.NewEvent(webhook.EventUserRegistered, "user-a", nil)
+	second := webhook.NewEvent(webhook.EventAccountLocked, "user-b", map[string]string{"status": "suspended"})
+	firstPayload, _ := json.Marshal(first)
+	secondPayload, _ := json.Marshal(second)
+	now := time.Now()
+
+	// Claimed in a statement of its own, not a transaction held across
+	// delivery; RETURNING may list the rows in any order
+	expectClaim(mock, sqlmock.NewRows(claimColumns).
+		AddRow(second.ID, secondPayload, 0, "{}", now).
+		AddRow(first.ID, firstPayload, 0, "{}", now.Add(-time.Second)))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE outbox SET sent_at = NOW(), delivered_to = $2 WHERE id = $1`)).
+		WithArgs(first.ID, `{"primary"}`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE outbox SET sent_at = NOW(), delivered_to = $2 WHERE id = $1`)).
+		WithArgs(second.ID, `{"primary"}`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	deliverer := &recordingDeliverer{}
+	p := NewPublisher(db, route{"primary", deliverer}, time.Second, 10, 0, 0, discardLogger)
+
+	published, err := p.PublishPending(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, published)
+	require.Len(t, deliverer.events, 2)
+	assert.Equal(t, first.ID, deliverer.events[0].ID)
+	assert.Equal(t, "suspended", deliverer.events[1].Data["status"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPublisher_PublishPending_Empty(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDB(t)
+	defer cleanup()
+
+	expectClaim(mock, sqlmock.NewRows(claimColumns))
+
+	deliverer := &recordingDeliverer{}
+	p := NewPublisher(db, deliverer, time.Second, 10, 0, 0, discardLogger)
+
+	published, err := p.PublishPending(context.Background())
+
+	require.NoError(t, err)
+	assert.Zero(t, published)
+	assert.Empty(t, deliverer.events)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPublisher_PublishPending_ReschedulesFailures(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDB(t)
+	defer cleanup()
+	failing := webhook.NewEvent(webhook.EventUserRegistered, "user-a", nil)
+	delivered := webhook.NewEvent(webhook.EventAccountLocked, "user-b", nil)
+	failingPayload, _ := json.Marshal(failing)
+	deliveredPayload, _ := json.Marshal(delivered)
+	now := time.Now()
+
+	expectClaim(mock, sqlmock.NewRows(claimColumns).
+		AddRow(failing.ID, failingPayload, 2, "{}", now).
+		AddRow(delivered.ID, deliveredPayload, 0, "{}", now.Add(time.Second)))
+	// Third failure: the one-second interval doubled twice
+	mock.ExpectExec(regexp.QuoteMeta(`SET attempts = attempts + 1`)).
+		WithArgs(failing.ID, int64(4000), sqlmock.AnyArg(), "{}").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE outbox SET sent_at = NOW()`)).
+		WithArgs(delivered.ID, `{"primary"}`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	deliverer := &recordingDeliverer{fail: map[string]bool{failing.ID: true}}
+	p := NewPublisher(db, route{"primary", deliverer}, time.Second, 10, 0, 0, discardLogger)
+
+	published, err := p.PublishPending(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	require.Len(t, deliverer.events, 1)
+	assert.Equal(t, delivered.ID, deliverer.events[0].ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPublisher_PublishPending_RetriesOnlyFailedRoutes(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDB(t)
+	defer cleanup()
+	event := webhook.NewEvent(webhook.EventUserRegistered, "user-a", nil)
+	payload, _ := json.Marshal(event)
+
+	reachable := &recordingDeliverer{}
+	flaky := &recordingDeliverer{fail: map[string]bool{event.ID: true}}
+	p := NewPublisher(db, webhook.MultiDispatcher{route{"reachable", reachable}, route{"flaky", flaky}},
+		time.Second, 10, 0, 0, discardLogger)
+
+	// First attempt: the reachable route gets the event and is recorded
+	expectClaim(mock, sqlmock.NewRows(claimColumns).AddRow(event.ID, payload, 0, "{}", time.Now()))
+	mock.ExpectExec(regexp.QuoteMeta(`SET attempts = attempts + 1`)).
+		WithArgs(event.ID, int64(1000), sqlmock.AnyArg(), `{"reachable"}`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	published, err := p.PublishPending(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, published)
+	assert.Len(t, reachable.events, 1)
+
+	// Retry: only the flaky route is attempted
+	flaky.fail = nil
+	expectClaim(mock, sqlmock.NewRows(claimColumns).AddRow(event.ID, payload, 1, `{"reachable"}`, time.Now()))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE outbox SET sent_at = NOW()`)).
+		WithArgs(event.ID, `{"reachable","flaky"}`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	published, err = p.PublishPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	assert.Len(t, reachable.events, 1, "the reachable route does not get the event twice")
+	assert.Len(t, flaky.events, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPublisher_RetryBackoff(t *testing.T) {
+	p := NewPublisher(nil, nil, 5*time.Second, 10, 0, 0, discardLogger)
+
+	assert.Equal(t, 5*time.Second, p.retryBackoff(1))
+	assert.Equal(t, 10*time.Second, p.retryBackoff(2))
+	assert.Equal(t, MaxRetryBackoff, p.retryBackoff(50))
+}
+
+func TestPublisher_Prune(t *testing.T) {
+	db, mock, cleanup := dbtest.NewMockTracedDB(t)
+	defer cleanup()
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM outbox WHERE sent_at < NOW()`)).
+		WithArgs(int64(time.Hour.Milliseconds())).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	pruned, err := NewPublisher(db, nil, time.Second, 10, 0, time.Hour, discardLogger).Prune(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), pruned)
+
+	// Zero retention keeps every event and issues no query
+	pruned, err = NewPublisher(db, nil, time.Second, 10, 0, 0, discardLogger).Prune(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, pruned)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,41 @@
+// Package permissions is the registry of permission names shared by the
+// handlers that enforce them and the migrations that seed them.
+package permissions
+
+// Permission is the name of a row in the permissions table.
+type Permission string
+
+const (
+	UserRead   Permission = "user.read"
+	UserUpdate Permission = "user.update"
+	UserDelete Permission = "user.delete"
+	UserList   Permission = "user.list"
+	UserManage Permission = "user.manage"
+
+	RoleRead   Permission = "role.read"
+	RoleCreate Permission = "role.create"
+	RoleUpdate Permission = "role.update"
+	RoleDelete Permission = "role.delete"
+
+	PermissionManage Permission = "permission.manage"
+)
+
+// All returns every registered permission.
+func All() []Permission {
+	return []Permission{
+		UserRead,
+		UserUpdate,
+		UserDelete,
+		UserList,
+		UserManage,
+		RoleRead,
+		RoleCreate,
+		RoleUpdate,
+		RoleDelete,
+		PermissionManage,
+	}
+}
+
+func (p Permission) String() string {
+	return string(p)
+}
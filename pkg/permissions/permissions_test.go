@@ -0,0 +1,23 @@
+package permissions
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAll_NoDuplicates(t *testing.T) {
+	seen := make(map[Permission]bool)
+	for _, p := range All() {
+		assert.False(t, seen[p], "duplicate permission %q", p)
+		seen[p] = true
+	}
+}
+
+func TestAll_ResourceActionFormat(t *testing.T) {
+	format := regexp.MustCompile(`^[a-z]+\.[a-z]+$`)
+	for _, p := range All() {
+		assert.Regexp(t, format, p.String())
+	}
+}
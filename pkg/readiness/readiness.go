@@ -0,0 +1,61 @@
+// Package readiness tracks the startup work that must finish before the
+// service reports itself ready for traffic.
+package readiness
+
+import (
+	"sort"
+	"sync"
+)
+
+// Gate is closed while any registered initializer is still running. It is
+// meant for startup work; conditions that can fail later, such as missing
+// RBAC tables, are checked by readiness on their own.
+type Gate struct {
+	mu      sync.Mutex
+	pending map[string]int
+}
+
+// NewGate returns a gate with no initializers registered.
+func NewGate() *Gate {
+	return &Gate{pending: make(map[string]int)}
+}
+
+// Register records an initializer that must finish before the gate opens
+// and returns the func it calls when done. Calling done more than once has
+// no further effect.
+func (g *Gate) Register(name string) (done func()) {
+	g.mu.Lock()
+	g.pending[name]++
+	g.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			g.mu.Lock()
+			defer g.mu.Unlock()
+			if g.pending[name]--; g.pending[name] <= 0 {
+				delete(g.pending, name)
+			}
+		})
+	}
+}
+
+// Ready reports whether every registered initializer is done.
+func (g *Gate) Ready() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.pending) == 0
+}
+
+// Pending returns the names of the initializers still running, sorted.
+func (g *Gate) Pending() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	names := make([]string, 0, len(g.pending))
+	for name := range g.pending {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,39 @@
+package readiness
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGate_OpensOnceInitializersFinish(t *testing.T) {
+	gate := NewGate()
+	assert.True(t, gate.Ready())
+
+	database := gate.Register("database")
+	warmup := gate.Register("cache-warmup")
+	assert.False(t, gate.Ready())
+	assert.Equal(t, []string{"cache-warmup", "database"}, gate.Pending())
+
+	database()
+	assert.False(t, gate.Ready())
+	assert.Equal(t, []string{"cache-warmup"}, gate.Pending())
+
+	warmup()
+	assert.True(t, gate.Ready())
+	assert.Empty(t, gate.Pending())
+}
+
+func TestGate_SameNameRegisteredTwice(t *testing.T) {
+	gate := NewGate()
+
+	first := gate.Register("warmup")
+	second := gate.Register("warmup")
+
+	first()
+	first()
+	assert.Equal(t, []string{"warmup"}, gate.Pending())
+
+	second()
+	assert.True(t, gate.Ready())
+}
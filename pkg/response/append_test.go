@@ -0,0 +1,28 @@
+package response
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendString_MatchesEncodingJSON(t *testing.T) {
+	inputs := []string{
+		"",
+		"plain ascii",
+		`quote " and backslash \`,
+		"<script>alert('x') && 1</script>",
+		"control \x00\x01\b\f\n\r\t\x1f\x7f",
+		"unicode héllo 日本語 🚀",
+		"separators   and  ",
+		"invalid \xff\xfe utf-8 \xe2\x82",
+	}
+
+	for _, s := range inputs {
+		want, err := json.Marshal(s)
+		require.NoError(t, err)
+		assert.Equal(t, string(want), string(AppendString(nil, s)), "%q", s)
+	}
+}
@@ -0,0 +1,46 @@
+package response
+
+import "github.com/gin-gonic/gin"
+
+// Envelope selects how JSON and AbortWithJSON serialize a Response.
+type Envelope int
+
+const (
+	// EnvelopeWrapped nests a success under "output" and an error under
+	// "error", the layout of Response itself.
+	EnvelopeWrapped Envelope = iota
+	// EnvelopeBare serializes a success as the resource itself and an error
+	// as a flat {error_code, error_message} object.
+	EnvelopeBare
+)
+
+// envelopeKey is the gin context key UseEnvelope stores the envelope under.
+const envelopeKey = "response.envelope"
+
+// UseEnvelope returns middleware making responses written through JSON and
+// AbortWithJSON on its requests use envelope. Requests it did not run on
+// get EnvelopeWrapped.
+func UseEnvelope(envelope Envelope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(envelopeKey, envelope)
+		c.Next()
+	}
+}
+
+// envelopeOf returns the envelope UseEnvelope selected for c's request.
+func envelopeOf(c *gin.Context) Envelope {
+	value, _ := c.Get(envelopeKey)
+	envelope, _ := value.(Envelope)
+	return envelope
+}
+
+// payload returns the value r serializes as under envelope.
+func (r Response[T]) payload(envelope Envelope) any {
+	if envelope != EnvelopeBare {
+		return r
+	}
+	if r.Error != nil {
+		return r.Error
+	}
+	return r.Output
+}
@@ -0,0 +1,71 @@
+package response
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type userOutput struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestUseEnvelope(t *testing.T) {
+	tests := []struct {
+		name     string
+		envelope *Envelope
+		resp     Response[userOutput]
+		want     string
+	}{
+		{
+			name: "wrapped success",
+			resp: Success(userOutput{ID: "1", Name: "Ada"}),
+			want: `{"output":{"id":"1","name":"Ada"}}`,
+		},
+		{
+			name: "wrapped error",
+			resp: Error[userOutput](ErrCodeNotFound, "user not found"),
+			want: `{"error":{"error_code":"NOT_FOUND","error_message":"user not found"}}`,
+		},
+		{
+			name:     "bare success",
+			envelope: ptr(EnvelopeBare),
+			resp:     Success(userOutput{ID: "1", Name: "Ada"}),
+			want:     `{"id":"1","name":"Ada"}`,
+		},
+		{
+			name:     "bare error",
+			envelope: ptr(EnvelopeBare),
+			resp:     Error[userOutput](ErrCodeNotFound, "user not found"),
+			want:     `{"error_code":"NOT_FOUND","error_message":"user not found"}`,
+		},
+		{
+			name:     "explicitly wrapped",
+			envelope: ptr(EnvelopeWrapped),
+			resp:     Success(userOutput{ID: "1", Name: "Ada"}),
+			want:     `{"output":{"id":"1","name":"Ada"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			if tt.envelope != nil {
+				router.Use(UseEnvelope(*tt.envelope))
+			}
+			router.GET("/", func(c *gin.Context) { AbortWithJSON(c, http.StatusOK, tt.resp) })
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, tt.want, w.Body.String())
+		})
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
@@ -0,0 +1,50 @@
+package response
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"sync/atomic"
+)
+
+// int64AsString makes Int64 fields serialize as JSON strings.
+var int64AsString atomic.Bool
+
+// SetInt64AsString switches every Int64 field between a JSON number (the
+// default) and a decimal string. JavaScript clients lose precision on
+// numbers above 2^53, so deployments serving them can opt into strings.
+func SetInt64AsString(enabled bool) {
+	int64AsString.Store(enabled)
+}
+
+// Int64 is an integer response field, such as a counter or total, that may
+// outgrow what JavaScript numbers represent exactly. It marshals as a number
+// or a string depending on SetInt64AsString and unmarshals from either.
+type Int64 int64
+
+func (n Int64) MarshalJSON() ([]byte, error) {
+	b := strconv.AppendInt(nil, int64(n), 10)
+	if int64AsString.Load() {
+		return strconv.AppendQuote(nil, string(b)), nil
+	}
+	return b, nil
+}
+
+func (n *Int64) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		data = []byte(s)
+	}
+	v, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return err
+	}
+	*n = Int64(v)
+	return nil
+}
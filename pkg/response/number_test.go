@@ -0,0 +1,53 @@
+package response
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type totalOutput struct {
+	Total Int64 `json:"total"`
+}
+
+func TestInt64_Marshal(t *testing.T) {
+	tests := []struct {
+		name     string
+		asString bool
+		want     string
+	}{
+		{name: "number by default", asString: false, want: `{"total":9007199254740993}`},
+		{name: "string when enabled", asString: true, want: `{"total":"9007199254740993"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetInt64AsString(tt.asString)
+			t.Cleanup(func() { SetInt64AsString(false) })
+
+			b, err := json.Marshal(totalOutput{Total: 1<<53 + 1})
+			if err != nil {
+				t.Fatalf("marshal failed: %v", err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("got %s, want %s", b, tt.want)
+			}
+		})
+	}
+}
+
+func TestInt64_UnmarshalAcceptsNumberAndString(t *testing.T) {
+	for _, input := range []string{`{"total":9007199254740993}`, `{"total":"9007199254740993"}`} {
+		var out totalOutput
+		if err := json.Unmarshal([]byte(input), &out); err != nil {
+			t.Fatalf("unmarshal %s failed: %v", input, err)
+		}
+		if out.Total != 1<<53+1 {
+			t.Errorf("unmarshal %s: got %d", input, out.Total)
+		}
+	}
+
+	var out totalOutput
+	if err := json.Unmarshal([]byte(`{"total":"abc"}`), &out); err == nil {
+		t.Error("expected an error for a non-numeric string")
+	}
+}
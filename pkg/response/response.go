@@ -1,8 +1,11 @@
 package response
 
+import "net/http"
+
 type ErrorSchema struct {
 	ErrorCode    string `json:"error_code,omitempty"`
 	ErrorMessage string `json:"error_message,omitempty"`
+	ErrorDetail  string `json:"error_detail,omitempty"`
 }
 
 type Response[T any] struct {
@@ -27,19 +30,98 @@ func Error[T any](code, message string) Response[T] {
 	}
 }
 
+// ErrorWithDetail builds an error response carrying extra diagnostic detail.
+// An empty detail is omitted from the serialized response.
+func ErrorWithDetail[T any](code, message, detail string) Response[T] {
+	return Response[T]{
+		Error: &ErrorSchema{
+			ErrorCode:    code,
+			ErrorMessage: message,
+			ErrorDetail:  detail,
+		},
+		Output: nil,
+	}
+}
+
+// Unauthorized returns a 401 with ErrCodeUnauthorized, for requests without
+// valid credentials. Write the result with JSON or AbortWithJSON:
+//
+//	status, body := response.Unauthorized[any]("authentication required")
+//	response.AbortWithJSON(c, status, body)
+func Unauthorized[T any](message string) (int, Response[T]) {
+	return http.StatusUnauthorized, Error[T](ErrCodeUnauthorized, message)
+}
+
+// Forbidden returns a 403 with ErrCodeForbidden, for authenticated callers
+// lacking the permission an action needs.
+func Forbidden[T any](message string) (int, Response[T]) {
+	return http.StatusForbidden, Error[T](ErrCodeForbidden, message)
+}
+
+// NotFound returns a 404 with ErrCodeNotFound.
+func NotFound[T any](message string) (int, Response[T]) {
+	return http.StatusNotFound, Error[T](ErrCodeNotFound, message)
+}
+
+// Conflict returns a 409 with ErrCodeConflict, for writes clashing with
+// existing state such as a taken email.
+func Conflict[T any](message string) (int, Response[T]) {
+	return http.StatusConflict, Error[T](ErrCodeConflict, message)
+}
+
+// ValidationFailed returns a 400 with ErrCodeValidationFailed, for requests
+// that cannot be bound or fail validation.
+func ValidationFailed[T any](message string) (int, Response[T]) {
+	return http.StatusBadRequest, Error[T](ErrCodeValidationFailed, message)
+}
+
+// PayloadTooLarge returns a 413 with ErrCodePayloadTooLarge, for request
+// bodies cut off by a size limit.
+func PayloadTooLarge[T any](message string) (int, Response[T]) {
+	return http.StatusRequestEntityTooLarge, Error[T](ErrCodePayloadTooLarge, message)
+}
+
+// InvalidCredentials returns a 401 with ErrCodeInvalidCredentials, for a
+// failed login.
+func InvalidCredentials[T any](message string) (int, Response[T]) {
+	return http.StatusUnauthorized, Error[T](ErrCodeInvalidCredentials, message)
+}
+
+// InternalError returns a 500 with ErrCodeInternalServerError. detail is
+// omitted from the response when empty.
+func InternalError[T any](message, detail string) (int, Response[T]) {
+	return http.StatusInternalServerError, ErrorWithDetail[T](ErrCodeInternalServerError, message, detail)
+}
+
 const (
 	ErrCodeUnauthorized        = "UNAUTHORIZED"
+	ErrCodeTokenExpired        = "TOKEN_EXPIRED"
+	ErrCodeSessionExpired      = "SESSION_EXPIRED"
+	ErrCodeAccountSuspended    = "ACCOUNT_SUSPENDED"
 	ErrCodeForbidden           = "FORBIDDEN"
 	ErrCodeNotFound            = "NOT_FOUND"
 	ErrCodeConflict            = "CONFLICT"
 	ErrCodeInternalServerError = "INTERNAL_SERVER_ERROR"
 	ErrCodeValidationFailed    = "VALIDATION_FAILED"
 	ErrCodeInvalidCredentials  = "INVALID_CREDENTIALS"
+	ErrCodeTwoFactorRequired   = "TWO_FACTOR_REQUIRED"
+	ErrCodeInvalidTwoFactor    = "INVALID_TWO_FACTOR_CODE"
+	ErrCodeInvalidToken        = "INVALID_TOKEN"
+	ErrCodeTooManyRequests     = "TOO_MANY_REQUESTS"
+	ErrCodeCaptchaFailed       = "CAPTCHA_FAILED"
+	ErrCodeServiceUnavailable  = "SERVICE_UNAVAILABLE"
+	ErrCodeRequestTimeout      = "REQUEST_TIMEOUT"
+	ErrCodeHTTPSRequired       = "HTTPS_REQUIRED"
+	ErrCodePayloadTooLarge     = "PAYLOAD_TOO_LARGE"
 )
 
+// HTTPError is an error carrying the response it should render as. Handlers
+// attach one with gin's c.Error and leave writing the envelope to the
+// ErrorHandler middleware. Detail is omitted from the response when empty.
 type HTTPError struct {
 	Code       string
 	Message    string
+	Detail     string
 	StatusCode int
 }
 
@@ -2,6 +2,7 @@ package response
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 )
 
@@ -112,3 +113,103 @@ func TestErrorResponseJSONSerialization(t *testing.T) {
 		t.Error("Error response should not have output after unmarshaling")
 	}
 }
+
+func TestErrorWithDetailResponse(t *testing.T) {
+	resp := ErrorWithDetail[any]("INTERNAL_SERVER_ERROR", "Something went wrong", "dial tcp: connection refused")
+
+	if resp.Error == nil {
+		t.Fatal("Error response should have error")
+	}
+
+	if resp.Error.ErrorDetail != "dial tcp: connection refused" {
+		t.Error("Error detail mismatch")
+	}
+}
+
+func TestErrorDetailOmittedWhenEmpty(t *testing.T) {
+	resp := ErrorWithDetail[any]("INTERNAL_SERVER_ERROR", "Something went wrong", "")
+	jsonData, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatalf("Failed to marshal error response: %v", err)
+	}
+
+	if strings.Contains(string(jsonData), "error_detail") {
+		t.Error("Empty error detail should be omitted from JSON")
+	}
+}
+
+func TestUnauthorizedAndForbidden_PairStatusWithCode(t *testing.T) {
+	tests := []struct {
+		name       string
+		build      func(message string) (int, Response[any])
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "unauthorized", build: Unauthorized[any], wantStatus: 401, wantCode: ErrCodeUnauthorized},
+		{name: "forbidden", build: Forbidden[any], wantStatus: 403, wantCode: ErrCodeForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, resp := tt.build("not allowed")
+
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if resp.Error == nil {
+				t.Fatal("response should have an error")
+			}
+			if resp.Error.ErrorCode != tt.wantCode {
+				t.Errorf("code = %q, want %q", resp.Error.ErrorCode, tt.wantCode)
+			}
+			if resp.Error.ErrorMessage != "not allowed" {
+				t.Errorf("message = %q, want %q", resp.Error.ErrorMessage, "not allowed")
+			}
+			if resp.Output != nil {
+				t.Error("error response should not have output")
+			}
+		})
+	}
+}
+
+func TestTypedErrorHelpers_SetExpectedCode(t *testing.T) {
+	internalError := func(message string) (int, Response[any]) {
+		return InternalError[any](message, "")
+	}
+
+	tests := []struct {
+		name       string
+		build      func(message string) (int, Response[any])
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "not found", build: NotFound[any], wantStatus: 404, wantCode: ErrCodeNotFound},
+		{name: "conflict", build: Conflict[any], wantStatus: 409, wantCode: ErrCodeConflict},
+		{name: "validation failed", build: ValidationFailed[any], wantStatus: 400, wantCode: ErrCodeValidationFailed},
+		{name: "payload too large", build: PayloadTooLarge[any], wantStatus: 413, wantCode: ErrCodePayloadTooLarge},
+		{name: "invalid credentials", build: InvalidCredentials[any], wantStatus: 401, wantCode: ErrCodeInvalidCredentials},
+		{name: "internal error", build: internalError, wantStatus: 500, wantCode: ErrCodeInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, resp := tt.build("something went wrong")
+
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if resp.Error == nil {
+				t.Fatal("response should have an error")
+			}
+			if resp.Error.ErrorCode != tt.wantCode {
+				t.Errorf("code = %q, want %q", resp.Error.ErrorCode, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestInternalError_KeepsDetail(t *testing.T) {
+	_, resp := InternalError[any]("failed", "connection refused")
+
+	if resp.Error.ErrorDetail != "connection refused" {
+		t.Errorf("detail = %q, want %q", resp.Error.ErrorDetail, "connection refused")
+	}
+}
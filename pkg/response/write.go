@@ -0,0 +1,77 @@
+package response
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxPooledBufferSize keeps the occasional large response, such as a long
+// list, from pinning its buffer in the pool.
+const maxPooledBufferSize = 64 << 10
+
+// jsonBuffer pairs a buffer with the encoder writing into it, so neither is
+// allocated per response.
+type jsonBuffer struct {
+	buf bytes.Buffer
+	enc *json.Encoder
+	// scratch holds output appended by a JSONAppender.
+	scratch []byte
+}
+
+var jsonBufferPool = sync.Pool{
+	New: func() any {
+		b := &jsonBuffer{}
+		b.enc = json.NewEncoder(&b.buf)
+		return b
+	},
+}
+
+// JSON writes r with status code in the envelope UseEnvelope selected,
+// serializing through a pooled buffer and encoder. Under EnvelopeWrapped
+// the bytes written are identical to c.JSON's. Outputs implementing
+// JSONAppender are written without reflection.
+func JSON[T any](c *gin.Context, code int, r Response[T]) {
+	envelope := envelopeOf(c)
+	b := jsonBufferPool.Get().(*jsonBuffer)
+	defer func() {
+		if b.buf.Cap() <= maxPooledBufferSize && cap(b.scratch) <= maxPooledBufferSize {
+			jsonBufferPool.Put(b)
+		}
+	}()
+
+	if appender, ok := any(r.Output).(JSONAppender); ok && r.Output != nil && r.Error == nil {
+		body := b.scratch[:0]
+		if envelope == EnvelopeBare {
+			body = appender.AppendJSON(body)
+		} else {
+			body = append(body, `{"output":`...)
+			body = appender.AppendJSON(body)
+			body = append(body, '}')
+		}
+		b.scratch = body
+		c.Data(code, "application/json; charset=utf-8", body)
+		return
+	}
+
+	b.buf.Reset()
+	if err := b.enc.Encode(r.payload(envelope)); err != nil {
+		_ = c.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	// Encode terminates the value with a newline json.Marshal does not add
+	body := b.buf.Bytes()
+	c.Data(code, "application/json; charset=utf-8", body[:len(body)-1])
+}
+
+// AbortWithJSON writes r like JSON and stops the remaining handlers, like
+// c.AbortWithStatusJSON.
+func AbortWithJSON[T any](c *gin.Context, code int, r Response[T]) {
+	c.Abort()
+	JSON(c, code, r)
+}
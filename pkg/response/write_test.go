@@ -0,0 +1,147 @@
+package response
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type writeTestUser struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	Logins Int64  `json:"logins"`
+}
+
+// render serves the response written by handle and returns the recorder.
+func render(handle gin.HandlerFunc) *httptest.ResponseRecorder {
+	return renderIn(EnvelopeWrapped, handle)
+}
+
+// renderIn is render with envelope selected for the request.
+func renderIn(envelope Envelope, handle gin.HandlerFunc) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/me", nil)
+	c.Set(envelopeKey, envelope)
+	handle(c)
+	return w
+}
+
+// reference writes r's serialized payload the way c.JSON writes a value.
+func reference[T any](envelope Envelope, code int, r Response[T]) gin.HandlerFunc {
+	return func(c *gin.Context) { c.JSON(code, r.payload(envelope)) }
+}
+
+func TestJSON_MatchesGinJSON(t *testing.T) {
+	user := writeTestUser{ID: "42", Name: "Ada <admin> & co", Email: "ada@example.com", Logins: 1 << 60}
+
+	tests := []struct {
+		name          string
+		code          int
+		resp          Response[any]
+		int64AsString bool
+		envelope      Envelope
+	}{
+		{name: "success", code: http.StatusOK, resp: Success[any](user)},
+		{name: "error", code: http.StatusForbidden, resp: Error[any](ErrCodeForbidden, "missing permission")},
+		{name: "int64 as string", code: http.StatusOK, resp: Success[any](user), int64AsString: true},
+		{name: "bare envelope", code: http.StatusOK, resp: Success[any](user), envelope: EnvelopeBare},
+		{name: "bare error", code: http.StatusNotFound, resp: Error[any](ErrCodeNotFound, "user not found"), envelope: EnvelopeBare},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetInt64AsString(tt.int64AsString)
+			t.Cleanup(func() { SetInt64AsString(false) })
+
+			want := renderIn(tt.envelope, reference(tt.envelope, tt.code, tt.resp))
+			got := renderIn(tt.envelope, func(c *gin.Context) { JSON(c, tt.code, tt.resp) })
+
+			assert.Equal(t, want.Code, got.Code)
+			assert.Equal(t, want.Header(), got.Header())
+			assert.Equal(t, want.Body.String(), got.Body.String())
+		})
+	}
+}
+
+// appendedUser encodes writeTestUser by hand, taking JSON's fast path.
+type appendedUser writeTestUser
+
+func (u appendedUser) AppendJSON(dst []byte) []byte {
+	dst = append(dst, `{"id":`...)
+	dst = AppendString(dst, u.ID)
+	dst = append(dst, `,"name":`...)
+	dst = AppendString(dst, u.Name)
+	dst = append(dst, `,"email":`...)
+	dst = AppendString(dst, u.Email)
+	dst = append(dst, `,"logins":`...)
+	logins, _ := u.Logins.MarshalJSON()
+	dst = append(dst, logins...)
+	return append(dst, '}')
+}
+
+func TestJSON_AppenderMatchesGinJSON(t *testing.T) {
+	user := appendedUser{ID: "42", Name: "Ada <admin> & co", Email: "ada@example.com", Logins: 7}
+
+	for _, envelope := range []Envelope{EnvelopeWrapped, EnvelopeBare} {
+		// c.JSON goes through encoding/json, so it is the reference
+		want := renderIn(envelope, reference(envelope, http.StatusOK, Success(writeTestUser(user))))
+		got := renderIn(envelope, func(c *gin.Context) { JSON(c, http.StatusOK, Success(user)) })
+
+		assert.Equal(t, want.Header(), got.Header(), "envelope=%v", envelope)
+		assert.Equal(t, want.Body.String(), got.Body.String(), "envelope=%v", envelope)
+	}
+}
+
+func TestJSON_LargeBufferNotPooled(t *testing.T) {
+	large := Success(strings.Repeat("x", 2*maxPooledBufferSize))
+	want := render(func(c *gin.Context) { c.JSON(http.StatusOK, large) })
+	got := render(func(c *gin.Context) { JSON(c, http.StatusOK, large) })
+	assert.Equal(t, want.Body.String(), got.Body.String())
+
+	// A later small response starts from a buffer of sane size
+	b := jsonBufferPool.Get().(*jsonBuffer)
+	assert.LessOrEqual(t, b.buf.Cap(), maxPooledBufferSize)
+	jsonBufferPool.Put(b)
+}
+
+// discardWriter is a ResponseWriter that drops the body, so benchmarks
+// measure serialization rather than buffering.
+type discardWriter struct{ header http.Header }
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w *discardWriter) WriteHeader(int)             {}
+
+func benchmarkWrite(b *testing.B, write func(c *gin.Context, resp Response[writeTestUser])) {
+	gin.SetMode(gin.TestMode)
+	resp := Success(writeTestUser{ID: "42", Name: "Ada Lovelace", Email: "ada@example.com", Logins: 7})
+
+	router := gin.New()
+	router.GET("/me", func(c *gin.Context) { write(c, resp) })
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	w := &discardWriter{header: http.Header{}}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		router.ServeHTTP(w, req)
+	}
+}
+
+func BenchmarkJSON_GinJSON(b *testing.B) {
+	benchmarkWrite(b, func(c *gin.Context, resp Response[writeTestUser]) {
+		c.JSON(http.StatusOK, resp)
+	})
+}
+
+func BenchmarkJSON_Pooled(b *testing.B) {
+	benchmarkWrite(b, func(c *gin.Context, resp Response[writeTestUser]) {
+		JSON(c, http.StatusOK, resp)
+	})
+}
@@ -0,0 +1,44 @@
+package telemetry
+
+import (
+	"context"
+	"sort"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// attributeProcessor tags every span with a fixed set of attributes as it
+// starts, such as region or cluster, without per-call code. Unlike resource
+// attributes from OTEL_RESOURCE_ATTRIBUTES they land on the spans
+// themselves, which backends that only index span attributes need.
+type attributeProcessor struct {
+	attrs []attribute.KeyValue
+}
+
+// NewAttributeProcessor returns a span processor setting attrs on every
+// span. Attributes with the same key set when the span starts are
+// overwritten; ones set later win.
+func NewAttributeProcessor(attrs map[string]string) trace.SpanProcessor {
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	p := &attributeProcessor{attrs: make([]attribute.KeyValue, 0, len(keys))}
+	for _, key := range keys {
+		p.attrs = append(p.attrs, attribute.String(key, attrs[key]))
+	}
+	return p
+}
+
+func (p *attributeProcessor) OnStart(_ context.Context, s trace.ReadWriteSpan) {
+	s.SetAttributes(p.attrs...)
+}
+
+func (p *attributeProcessor) OnEnd(trace.ReadOnlySpan) {}
+
+func (p *attributeProcessor) Shutdown(context.Context) error { return nil }
+
+func (p *attributeProcessor) ForceFlush(context.Context) error { return nil }
@@ -0,0 +1,60 @@
+package telemetry
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/elskow/go-microservice-template/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var (
+	globalAttrSpans = tracetest.NewSpanRecorder()
+	// tracing.Auto caches its tracer per caller, so the provider is
+	// installed once for repeated runs to keep recording into it
+	installGlobalAttrProvider = sync.OnceFunc(func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(
+			sdktrace.WithSpanProcessor(NewAttributeProcessor(map[string]string{
+				"region":  "eu-west-1",
+				"cluster": "prod-a",
+			})),
+			sdktrace.WithSpanProcessor(globalAttrSpans),
+		))
+	})
+)
+
+func TestAttributeProcessor_TagsSpansFromTracingAuto(t *testing.T) {
+	installGlobalAttrProvider()
+
+	_, span := tracing.Auto(context.Background(), attribute.String("user_id", "42"))
+	span.End()
+
+	ended := globalAttrSpans.Ended()
+	require.NotEmpty(t, ended)
+	attrs := ended[len(ended)-1].Attributes()
+	assert.Contains(t, attrs, attribute.String("region", "eu-west-1"))
+	assert.Contains(t, attrs, attribute.String("cluster", "prod-a"))
+	assert.Contains(t, attrs, attribute.String("user_id", "42"))
+}
+
+func TestAttributeProcessor_LaterAttributesWin(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(NewAttributeProcessor(map[string]string{"region": "eu-west-1"})),
+		sdktrace.WithSpanProcessor(spans),
+	)
+
+	_, span := provider.Tracer("test").Start(context.Background(), "op")
+	span.SetAttributes(attribute.String("region", "us-east-1"))
+	span.End()
+
+	ended := spans.Ended()
+	require.Len(t, ended, 1)
+	assert.Equal(t, []attribute.KeyValue{attribute.String("region", "us-east-1")}, ended[0].Attributes())
+}
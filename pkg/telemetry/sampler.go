@@ -0,0 +1,75 @@
+package telemetry
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// pathSampler overrides base for request spans whose route or path is
+// listed: always ones are sampled and never ones dropped, whatever base
+// would decide. Spans with a local parent follow it, so the rest of a
+// forced trace is kept or dropped along with its request span.
+type pathSampler struct {
+	base   trace.Sampler
+	always map[string]struct{}
+	never  map[string]struct{}
+}
+
+// NewPathSampler wraps base with per-path overrides. Entries are matched
+// exactly against the http.route attribute, e.g. "/api/account/login", and
+// then url.path; never wins when a path is in both lists.
+func NewPathSampler(base trace.Sampler, always, never []string) trace.Sampler {
+	if len(always) == 0 && len(never) == 0 {
+		return base
+	}
+	return &pathSampler{base: base, always: pathSet(always), never: pathSet(never)}
+}
+
+func pathSet(paths []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(paths))
+	for _, path := range paths {
+		set[path] = struct{}{}
+	}
+	return set
+}
+
+func (s *pathSampler) ShouldSample(p trace.SamplingParameters) trace.SamplingResult {
+	parent := oteltrace.SpanContextFromContext(p.ParentContext)
+	if parent.IsValid() && !parent.IsRemote() {
+		decision := trace.Drop
+		if parent.IsSampled() {
+			decision = trace.RecordAndSample
+		}
+		return trace.SamplingResult{Decision: decision, Tracestate: parent.TraceState()}
+	}
+
+	for _, key := range []attribute.Key{semconv.HTTPRouteKey, semconv.URLPathKey} {
+		path, ok := attributeValue(p.Attributes, key)
+		if !ok {
+			continue
+		}
+		if _, never := s.never[path]; never {
+			return trace.SamplingResult{Decision: trace.Drop, Tracestate: parent.TraceState()}
+		}
+		if _, always := s.always[path]; always {
+			return trace.SamplingResult{Decision: trace.RecordAndSample, Tracestate: parent.TraceState()}
+		}
+	}
+
+	return s.base.ShouldSample(p)
+}
+
+func (s *pathSampler) Description() string {
+	return "PathSampler{" + s.base.Description() + "}"
+}
+
+func attributeValue(attrs []attribute.KeyValue, key attribute.Key) (string, bool) {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return attr.Value.AsString(), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,77 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+func TestPathSampler_OverridesBase(t *testing.T) {
+	tests := []struct {
+		name  string
+		base  trace.Sampler
+		route string
+		want  bool
+	}{
+		{name: "always path under zero ratio", base: trace.TraceIDRatioBased(0), route: "/api/account/login", want: true},
+		{name: "never path under full ratio", base: trace.TraceIDRatioBased(1), route: "/ready", want: false},
+		{name: "other path follows zero ratio", base: trace.TraceIDRatioBased(0), route: "/api/account/me", want: false},
+		{name: "other path follows full ratio", base: trace.TraceIDRatioBased(1), route: "/api/account/me", want: true},
+		{name: "never wins over always", base: trace.TraceIDRatioBased(1), route: "/api/account/register", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sampler := NewPathSampler(tt.base,
+				[]string{"/api/account/login", "/api/account/register"},
+				[]string{"/ready", "/api/account/register"},
+			)
+			provider := trace.NewTracerProvider(trace.WithSampler(sampler))
+
+			_, span := provider.Tracer("test").Start(context.Background(), "request",
+				oteltrace.WithAttributes(semconv.HTTPRoute(tt.route)))
+			span.End()
+
+			assert.Equal(t, tt.want, span.SpanContext().IsSampled())
+		})
+	}
+}
+
+func TestPathSampler_MatchesURLPathWithoutRoute(t *testing.T) {
+	sampler := NewPathSampler(trace.TraceIDRatioBased(0), []string{"/api/account/login"}, nil)
+	provider := trace.NewTracerProvider(trace.WithSampler(sampler))
+
+	_, span := provider.Tracer("test").Start(context.Background(), "request",
+		oteltrace.WithAttributes(semconv.URLPath("/api/account/login")))
+	span.End()
+
+	assert.True(t, span.SpanContext().IsSampled())
+}
+
+// Child spans carry no route, so they follow their request span rather than
+// being re-sampled by the base ratio.
+func TestPathSampler_ChildrenFollowLocalParent(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	sampler := NewPathSampler(trace.TraceIDRatioBased(0), []string{"/api/account/login"}, []string{"/ready"})
+	provider := trace.NewTracerProvider(trace.WithSampler(sampler), trace.WithSpanProcessor(spans))
+	tracer := provider.Tracer("test")
+
+	ctx, login := tracer.Start(context.Background(), "login",
+		oteltrace.WithAttributes(semconv.HTTPRoute("/api/account/login")))
+	_, query := tracer.Start(ctx, "query")
+	query.End()
+	login.End()
+
+	assert.True(t, query.SpanContext().IsSampled())
+	assert.Len(t, spans.Ended(), 2)
+}
+
+func TestNewPathSampler_NoOverridesReturnsBase(t *testing.T) {
+	base := trace.AlwaysSample()
+	assert.Equal(t, base, NewPathSampler(base, nil, nil))
+}
@@ -7,8 +7,10 @@ import (
 	"time"
 
 	"github.com/elskow/go-microservice-template/config"
+	"github.com/elskow/go-microservice-template/pkg/tracing"
 	otelpyroscope "github.com/grafana/otel-profiling-go"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	"go.opentelemetry.io/otel/exporters/prometheus"
@@ -22,6 +24,7 @@ import (
 type Telemetry struct {
 	TracerProvider *trace.TracerProvider
 	MeterProvider  *metric.MeterProvider
+	Resource       *resource.Resource
 	logger         *slog.Logger
 }
 
@@ -31,16 +34,15 @@ func InitTelemetry(ctx context.Context, serviceName, serviceVersion string, logg
 		hostname = "unknown"
 	}
 
-	res, err := resource.New(ctx,
-		resource.WithAttributes(
-			semconv.ServiceName(serviceName),
-			semconv.ServiceVersion(serviceVersion),
-			semconv.ServiceInstanceID(hostname),
-		),
-		resource.WithHost(),
-		resource.WithOS(),
-		resource.WithProcess(),
-	)
+	cfg := config.Get()
+
+	res, err := BuildResource(ctx, ResourceConfig{
+		ServiceName:    serviceName,
+		ServiceVersion: serviceVersion,
+		Hostname:       hostname,
+		Namespace:      cfg.ServiceNamespace,
+		Environment:    cfg.AppEnv,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -50,9 +52,17 @@ func InitTelemetry(ctx context.Context, serviceName, serviceVersion string, logg
 		return nil, err
 	}
 
-	meterProvider, err := initMeterProvider(ctx, res)
+	meterProvider, err := newMeterProvider(ctx, res)
 	if err != nil {
-		return nil, err
+		if !cfg.OTELAllowPartialInit {
+			_ = tracerProvider.Shutdown(ctx)
+			return nil, err
+		}
+		// Keep serving traces; spans are tagged so the missing metrics
+		// are noticed
+		logger.Warn("metrics unavailable, continuing with traces only", "error", err)
+		tracing.SetDegraded(true)
+		meterProvider = metric.NewMeterProvider(metric.WithResource(res))
 	}
 
 	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
@@ -64,7 +74,6 @@ func InitTelemetry(ctx context.Context, serviceName, serviceVersion string, logg
 
 	otel.SetMeterProvider(meterProvider)
 
-	cfg := config.Get()
 	samplingStrategy := cfg.OTELSamplingStrategy
 	samplingRate := cfg.OTELSamplingRate
 
@@ -74,15 +83,52 @@ func InitTelemetry(ctx context.Context, serviceName, serviceVersion string, logg
 		"instance", hostname,
 		"sampling_strategy", samplingStrategy,
 		"sampling_rate", samplingRate,
+		"degraded", tracing.Degraded(),
 	)
 
 	return &Telemetry{
 		TracerProvider: tracerProvider,
 		MeterProvider:  meterProvider,
+		Resource:       res,
 		logger:         logger,
 	}, nil
 }
 
+// ResourceConfig identifies the service instance emitting telemetry.
+type ResourceConfig struct {
+	ServiceName    string
+	ServiceVersion string
+	Hostname       string
+	Namespace      string
+	Environment    string
+}
+
+// BuildResource describes this service instance for every signal, so traces,
+// metrics and logs carry the same identifying attributes.
+// deployment.environment lets backends shared by several environments filter
+// on it; it and service.namespace are only set when configured.
+func BuildResource(ctx context.Context, cfg ResourceConfig) (*resource.Resource, error) {
+	attrs := []attribute.KeyValue{
+		semconv.ServiceName(cfg.ServiceName),
+		semconv.ServiceVersion(cfg.ServiceVersion),
+		semconv.ServiceInstanceID(cfg.Hostname),
+	}
+	if cfg.Environment != "" {
+		attrs = append(attrs, semconv.DeploymentEnvironment(cfg.Environment))
+	}
+	if cfg.Namespace != "" {
+		attrs = append(attrs, semconv.ServiceNamespace(cfg.Namespace))
+	}
+
+	return resource.New(ctx,
+		resource.WithAttributes(attrs...),
+		resource.WithFromEnv(),
+		resource.WithHost(),
+		resource.WithOS(),
+		resource.WithProcess(),
+	)
+}
+
 func initTracerProvider(ctx context.Context, res *resource.Resource) (*trace.TracerProvider, error) {
 	cfg := config.Get()
 	otlpEndpoint := cfg.OTELExporterEndpoint
@@ -95,9 +141,9 @@ func initTracerProvider(ctx context.Context, res *resource.Resource) (*trace.Tra
 		return nil, err
 	}
 
-	sampler := getSampler()
+	sampler := NewPathSampler(getSampler(), cfg.OTELSampleAlwaysPathList(), cfg.OTELSampleNeverPathList())
 
-	tracerProvider := trace.NewTracerProvider(
+	opts := []trace.TracerProviderOption{
 		trace.WithBatcher(traceExporter,
 			trace.WithBatchTimeout(time.Second),
 			trace.WithMaxExportBatchSize(512),
@@ -105,11 +151,29 @@ func initTracerProvider(ctx context.Context, res *resource.Resource) (*trace.Tra
 		),
 		trace.WithResource(res),
 		trace.WithSampler(sampler),
-	)
+		trace.WithRawSpanLimits(spanLimits(cfg.OTELSpanAttributeValueLengthLimit())),
+	}
+	if attrs := cfg.OTELSpanAttributeMap(); len(attrs) > 0 {
+		opts = append(opts, trace.WithSpanProcessor(NewAttributeProcessor(attrs)))
+	}
+
+	tracerProvider := trace.NewTracerProvider(opts...)
 
 	return tracerProvider, nil
 }
 
+// spanLimits returns the SDK's default span limits with string attribute
+// values capped at valueLength, or uncapped when it is -1.
+func spanLimits(valueLength int) trace.SpanLimits {
+	limits := trace.NewSpanLimits()
+	limits.AttributeValueLengthLimit = valueLength
+	return limits
+}
+
+// newMeterProvider builds the meter provider; tests replace it to simulate
+// exporter failures.
+var newMeterProvider = initMeterProvider
+
 func initMeterProvider(ctx context.Context, res *resource.Resource) (*metric.MeterProvider, error) {
 	promExporter, err := prometheus.New()
 	if err != nil {
@@ -0,0 +1,126 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/elskow/go-microservice-template/config"
+	"github.com/elskow/go-microservice-template/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+func TestBuildResource_Attributes(t *testing.T) {
+	res, err := BuildResource(context.Background(), ResourceConfig{
+		ServiceName:    "svc",
+		ServiceVersion: "1.2.3",
+		Hostname:       "host-1",
+		Namespace:      "payments",
+		Environment:    "staging",
+	})
+	require.NoError(t, err)
+
+	want := map[attribute.Key]string{
+		semconv.ServiceNameKey:           "svc",
+		semconv.ServiceVersionKey:        "1.2.3",
+		semconv.ServiceInstanceIDKey:     "host-1",
+		semconv.ServiceNamespaceKey:      "payments",
+		semconv.DeploymentEnvironmentKey: "staging",
+	}
+	for key, expected := range want {
+		value, ok := res.Set().Value(key)
+		require.True(t, ok, "missing %s", key)
+		assert.Equal(t, expected, value.AsString(), key)
+	}
+}
+
+func TestBuildResource_OptionalAttributes(t *testing.T) {
+	res, err := BuildResource(context.Background(), ResourceConfig{
+		ServiceName:    "svc",
+		ServiceVersion: "1.2.3",
+		Hostname:       "host-1",
+	})
+	require.NoError(t, err)
+
+	_, ok := res.Set().Value(semconv.ServiceNamespaceKey)
+	assert.False(t, ok)
+	_, ok = res.Set().Value(semconv.DeploymentEnvironmentKey)
+	assert.False(t, ok)
+}
+
+func TestInitTelemetry_PartialInit(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "127.0.0.1:1")
+	failing := func(context.Context, *resource.Resource) (*metric.MeterProvider, error) {
+		return nil, errors.New("prometheus exporter unavailable")
+	}
+	previous := newMeterProvider
+	newMeterProvider = failing
+	t.Cleanup(func() { newMeterProvider = previous })
+	t.Cleanup(func() { tracing.SetDegraded(false) })
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("disallowed", func(t *testing.T) {
+		t.Setenv("OTEL_ALLOW_PARTIAL_INIT", "false")
+		config.Load()
+		t.Cleanup(config.Reset)
+
+		tel, err := InitTelemetry(context.Background(), "svc", "1.0.0", logger)
+		assert.Error(t, err)
+		assert.Nil(t, tel)
+		assert.False(t, tracing.Degraded())
+	})
+
+	t.Run("allowed", func(t *testing.T) {
+		t.Setenv("OTEL_ALLOW_PARTIAL_INIT", "true")
+		config.Load()
+		t.Cleanup(config.Reset)
+
+		tel, err := InitTelemetry(context.Background(), "svc", "1.0.0", logger)
+		require.NoError(t, err)
+		require.NotNil(t, tel.MeterProvider)
+		assert.True(t, tracing.Degraded())
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		assert.NoError(t, tel.Shutdown(ctx))
+	})
+}
+
+func TestSpanLimits_TruncatesAttributeValues(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		limit int
+		want  string
+	}{
+		{name: "limited", limit: 8, want: "jane.doe"},
+		{name: "unlimited", limit: -1, want: "jane.doe@example.com"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spans := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(
+				sdktrace.WithSpanProcessor(spans),
+				sdktrace.WithRawSpanLimits(spanLimits(tc.limit)),
+			)
+
+			_, span := provider.Tracer("test").Start(context.Background(), "register")
+			span.SetAttributes(attribute.String("user.email", "jane.doe@example.com"), attribute.Int("attempt", 123456789))
+			span.End()
+
+			ended := spans.Ended()
+			require.Len(t, ended, 1)
+			attrs := ended[0].Attributes()
+			assert.Contains(t, attrs, attribute.String("user.email", tc.want))
+			assert.Contains(t, attrs, attribute.Int("attempt", 123456789), "only string values are truncated")
+		})
+	}
+}
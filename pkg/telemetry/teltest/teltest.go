@@ -0,0 +1,113 @@
+// Package teltest installs in-memory trace and metric pipelines as the
+// global OpenTelemetry providers, so tests in any package can assert on the
+// spans and metrics the code under test emits.
+//
+// Tests using it must not run in parallel with each other: the pipelines are
+// global.
+package teltest
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// The tracer provider is shared by every test in the process. tracing.Auto
+// caches a tracer per caller and the global provider only delegates to the
+// first one installed, so swapping providers between tests would strand
+// spans in an earlier one.
+var (
+	spanExporter   = tracetest.NewInMemoryExporter()
+	tracerProvider *sdktrace.TracerProvider
+	installTracer  sync.Once
+)
+
+// Recorder holds what was emitted since New.
+type Recorder struct {
+	reader *sdkmetric.ManualReader
+}
+
+// New clears previously recorded spans, installs the in-memory tracer
+// provider and a fresh meter provider as the globals, and restores the
+// previous meter provider when tb ends.
+//
+// Instruments are bound to the meter provider that was global when they
+// were created, so create the code under test after calling New.
+func New(tb testing.TB) *Recorder {
+	tb.Helper()
+
+	installTracer.Do(func() {
+		tracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSyncer(spanExporter))
+	})
+	otel.SetTracerProvider(tracerProvider)
+	spanExporter.Reset()
+
+	reader := sdkmetric.NewManualReader()
+	previous := otel.GetMeterProvider()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	tb.Cleanup(func() { otel.SetMeterProvider(previous) })
+
+	return &Recorder{reader: reader}
+}
+
+// TracerProvider returns the recording provider, for code that takes one
+// explicitly, such as otelgin.WithTracerProvider.
+func (r *Recorder) TracerProvider() *sdktrace.TracerProvider {
+	return tracerProvider
+}
+
+// Spans returns the spans ended since New, in the order they ended.
+func (r *Recorder) Spans() tracetest.SpanStubs {
+	return spanExporter.GetSpans()
+}
+
+// Span returns the last ended span named name, failing tb when there is
+// none.
+func (r *Recorder) Span(tb testing.TB, name string) tracetest.SpanStub {
+	tb.Helper()
+
+	spans := r.Spans()
+	for i := len(spans) - 1; i >= 0; i-- {
+		if spans[i].Name == name {
+			return spans[i]
+		}
+	}
+	tb.Fatalf("no span named %q among %d recorded", name, len(spans))
+	return tracetest.SpanStub{}
+}
+
+// Metrics collects every metric recorded since New, keyed by name.
+func (r *Recorder) Metrics(tb testing.TB) map[string]metricdata.Aggregation {
+	tb.Helper()
+
+	var rm metricdata.ResourceMetrics
+	if err := r.reader.Collect(context.Background(), &rm); err != nil {
+		tb.Fatalf("failed to collect metrics: %v", err)
+	}
+
+	metrics := make(map[string]metricdata.Aggregation)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m.Data
+		}
+	}
+	return metrics
+}
+
+// Metric returns the metric named name, failing tb when it was not
+// recorded.
+func (r *Recorder) Metric(tb testing.TB, name string) metricdata.Aggregation {
+	tb.Helper()
+
+	metric, ok := r.Metrics(tb)[name]
+	if !ok {
+		tb.Fatalf("no metric named %q recorded", name)
+	}
+	return metric
+}
@@ -0,0 +1,58 @@
+package teltest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/elskow/go-microservice-template/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func emit(ctx context.Context, counter metric.Int64Counter) {
+	_, span := tracing.Auto(ctx, attribute.String("order_id", "42"))
+	defer span.End()
+	counter.Add(ctx, 2, metric.WithAttributes(attribute.String("result", "ok")))
+}
+
+func TestRecorder_CapturesSpansAndMetrics(t *testing.T) {
+	rec := New(t)
+
+	counter, err := otel.Meter("teltest").Int64Counter("orders_total")
+	require.NoError(t, err)
+	emit(context.Background(), counter)
+
+	span := rec.Span(t, "unknown.emit")
+	assert.Contains(t, span.Attributes, attribute.String("order_id", "42"))
+	assert.Len(t, rec.Spans(), 1)
+
+	sum, ok := rec.Metric(t, "orders_total").(metricdata.Sum[int64])
+	require.True(t, ok)
+	require.Len(t, sum.DataPoints, 1)
+	assert.Equal(t, int64(2), sum.DataPoints[0].Value)
+	result, _ := sum.DataPoints[0].Attributes.Value("result")
+	assert.Equal(t, "ok", result.AsString())
+}
+
+// Each New starts from nothing, even though the tracer provider is shared.
+func TestRecorder_IsolatedPerTest(t *testing.T) {
+	first := New(t)
+	counter, err := otel.Meter("teltest").Int64Counter("orders_total")
+	require.NoError(t, err)
+	emit(context.Background(), counter)
+	require.Len(t, first.Spans(), 1)
+
+	second := New(t)
+	assert.Empty(t, second.Spans())
+	assert.Empty(t, second.Metrics(t))
+
+	counter, err = otel.Meter("teltest").Int64Counter("orders_total")
+	require.NoError(t, err)
+	emit(context.Background(), counter)
+	assert.Len(t, second.Spans(), 1)
+	assert.Contains(t, second.Metrics(t), "orders_total")
+}
@@ -0,0 +1,59 @@
+// Package tenant carries the tenant a request is scoped to through
+// context.Context, so layers below the HTTP handlers can read it.
+package tenant
+
+import (
+	"context"
+	"regexp"
+
+	"go.opentelemetry.io/otel/baggage"
+)
+
+// DefaultID is the tenant of requests that resolve no tenant, and of every
+// row that predates tenancy.
+const DefaultID = "default"
+
+// BaggageKey is the baggage member propagating the tenant to downstream
+// services.
+const BaggageKey = "tenant_id"
+
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+type ctxKey struct{}
+
+// Valid reports whether id is an acceptable tenant identifier.
+func Valid(id string) bool {
+	return validID.MatchString(id)
+}
+
+// WithTenantID returns a copy of ctx scoped to id, also recording it in the
+// context's baggage. id must satisfy Valid.
+func WithTenantID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, ctxKey{}, id)
+
+	member, err := baggage.NewMemberRaw(BaggageKey, id)
+	if err != nil {
+		return ctx
+	}
+	bag, err := baggage.FromContext(ctx).SetMember(member)
+	if err != nil {
+		return ctx
+	}
+
+	return baggage.ContextWithBaggage(ctx, bag)
+}
+
+// IDOrDefault returns the tenant ctx is scoped to, or DefaultID.
+func IDOrDefault(ctx context.Context) string {
+	if id, ok := FromContext(ctx); ok {
+		return id
+	}
+	return DefaultID
+}
+
+// FromContext returns the tenant ctx is scoped to. The boolean is false when
+// no tenant was resolved for the request.
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(ctxKey{}).(string)
+	return id, ok && id != ""
+}
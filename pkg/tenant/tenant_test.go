@@ -0,0 +1,31 @@
+package tenant
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+func TestWithTenantID(t *testing.T) {
+	ctx := WithTenantID(context.Background(), "acme")
+
+	id, ok := FromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "acme", id)
+	assert.Equal(t, "acme", baggage.FromContext(ctx).Member(BaggageKey).Value())
+}
+
+func TestFromContext_Missing(t *testing.T) {
+	_, ok := FromContext(context.Background())
+
+	assert.False(t, ok)
+}
+
+func TestValid(t *testing.T) {
+	assert.True(t, Valid("acme_corp-1"))
+	assert.False(t, Valid(""))
+	assert.False(t, Valid("acme corp"))
+	assert.False(t, Valid("acme;drop"))
+}
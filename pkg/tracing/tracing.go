@@ -7,6 +7,7 @@ import (
 	"runtime/debug"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
@@ -50,6 +51,28 @@ var (
 	}
 )
 
+// maxPooledAttrs bounds the capacity of slices returned to attrPool, so one
+// call with many attributes does not pin a large backing array.
+const maxPooledAttrs = 32
+
+// degraded is set when telemetry started with only some of its providers;
+// see SetDegraded.
+var degraded atomic.Bool
+
+var degradedAttr = attribute.Bool("telemetry.degraded", true)
+
+// SetDegraded marks telemetry as partially initialized, for example traces
+// exporting while metrics failed to start. Spans started by Auto while it
+// is set carry telemetry.degraded=true so the gap is visible in traces.
+func SetDegraded(d bool) {
+	degraded.Store(d)
+}
+
+// Degraded reports whether telemetry is partially initialized.
+func Degraded() bool {
+	return degraded.Load()
+}
+
 func getSpanInfo(pc uintptr) spanInfo {
 	if cached, ok := spanInfoCache.Load(pc); ok {
 		return cached.(spanInfo)
@@ -108,15 +131,62 @@ func getSpanInfo(pc uintptr) spanInfo {
 	return info
 }
 
+// unknownSpanInfo is used when the caller of Auto cannot be resolved. Its
+// spans still carry layer and domain attributes, as "unknown".
+var unknownSpanInfo = spanInfo{
+	layer:      LayerUnknown,
+	domain:     "unknown",
+	method:     "unknown",
+	tracer:     otel.Tracer("unknown"),
+	opName:     "unknown.unknown",
+	tracerName: "unknown",
+}
+
+// Auto starts a span named after the calling function and its layer. It
+// always returns a usable span: before telemetry is initialized, or when
+// the global provider is a no-op, the span records nothing but every method
+// is safe to call. A nil ctx is treated as context.Background.
 func Auto(ctx context.Context, attributes ...attribute.KeyValue) (context.Context, *Span) {
-	pc, _, _, ok := runtime.Caller(1)
-	if !ok {
-		tracer := otel.Tracer("unknown")
-		otelCtx, otelSpan := tracer.Start(ctx, "unknown.unknown")
-		return otelCtx, &Span{Span: otelSpan}
+	info := callerSpanInfo()
+	return start(ctx, info, info.opName, attributes)
+}
+
+// AutoNamed is Auto with the span named name instead of after the caller:
+//
+//	ctx, span := tracing.AutoNamed(ctx, "service.Register")
+//
+// Use it where the caller cannot be resolved, such as code reached through
+// closures or generated wrappers, or where the derived name is misleading.
+// Layer and domain are still taken from the caller.
+func AutoNamed(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, *Span) {
+	return start(ctx, callerSpanInfo(), name, attributes)
+}
+
+// AutoSubOp is Auto with label appended to the derived span name, so the
+// steps of a multi-step method get their own readable child spans:
+//
+//	ctx, span := tracing.AutoSubOp(ctx, "email-check")
+//
+// started in service.Register is named "service.Register.email-check". To
+// label a step under an explicit name, pass the full name to AutoNamed.
+func AutoSubOp(ctx context.Context, label string, attributes ...attribute.KeyValue) (context.Context, *Span) {
+	info := callerSpanInfo()
+	return start(ctx, info, info.opName+"."+label, attributes)
+}
+
+// callerSpanInfo resolves the caller of Auto, AutoNamed or AutoSubOp,
+// falling back to unknownSpanInfo.
+func callerSpanInfo() spanInfo {
+	if pc, _, _, ok := runtime.Caller(2); ok {
+		return getSpanInfo(pc)
 	}
+	return unknownSpanInfo
+}
 
-	info := getSpanInfo(pc)
+func start(ctx context.Context, info spanInfo, opName string, attributes []attribute.KeyValue) (context.Context, *Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
 
 	layerAttr := layerAttrs[info.layer]
 	domainAttr := attribute.String("domain", info.domain)
@@ -127,14 +197,23 @@ func Auto(ctx context.Context, attributes ...attribute.KeyValue) (context.Contex
 
 	attrs = append(attrs, attributes...)
 	attrs = append(attrs, layerAttr, domainAttr)
+	if degraded.Load() {
+		attrs = append(attrs, degradedAttr)
+	}
 
-	otelCtx, otelSpan := info.tracer.Start(ctx, info.opName,
+	otelCtx, otelSpan := info.tracer.Start(ctx, opName,
 		trace.WithSpanKind(trace.SpanKindInternal),
 		trace.WithAttributes(attrs...),
 	)
 
-	*attrsPtr = attrs
-	attrPool.Put(attrsPtr)
+	// The tracer copies attributes at Start. Clear the slice before pooling
+	// it so it does not keep the caller's values reachable, and drop it if a
+	// large call grew it.
+	if cap(attrs) <= maxPooledAttrs {
+		clear(attrs)
+		*attrsPtr = attrs[:0]
+		attrPool.Put(attrsPtr)
+	}
 
 	return otelCtx, &Span{Span: otelSpan}
 }
@@ -0,0 +1,181 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	recorder        = tracetest.NewSpanRecorder()
+	installRecorder = sync.OnceFunc(func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	})
+)
+
+// lastSpan ends span and returns what the recorder captured for it. Auto
+// caches a tracer per caller and the global provider only delegates to the
+// first one installed, so every test shares one recorder.
+func lastSpan(t *testing.T, span *Span) sdktrace.ReadOnlySpan {
+	t.Helper()
+	span.End()
+	ended := recorder.Ended()
+	require.NotEmpty(t, ended)
+	last := ended[len(ended)-1]
+	require.Equal(t, span.SpanContext().SpanID(), last.SpanContext().SpanID())
+	return last
+}
+
+func startAuto(ctx context.Context) (context.Context, *Span) {
+	return Auto(ctx, attribute.String("case", "test"))
+}
+
+func TestAuto_BeforeAndAfterProviderSetup(t *testing.T) {
+	// Before setup the global provider is a no-op; the span must still be
+	// safe to use
+	ctx, span := startAuto(context.Background())
+	require.NotNil(t, span)
+	require.NotNil(t, span.Span)
+	assert.NotNil(t, ctx)
+	assert.NotPanics(t, func() {
+		span.SetAttributes(attribute.Int("n", 1))
+		span.RecordError(errors.New("boom"))
+		span.End()
+	})
+
+	installRecorder()
+
+	_, span = startAuto(context.Background())
+	require.NotNil(t, span)
+	assert.True(t, span.SpanContext().IsValid())
+
+	recorded := lastSpan(t, span)
+	assert.Equal(t, "unknown.startAuto", recorded.Name())
+	assert.NotContains(t, recorded.Attributes(), degradedAttr)
+
+	SetDegraded(true)
+	t.Cleanup(func() { SetDegraded(false) })
+
+	_, span = startAuto(context.Background())
+	recorded = lastSpan(t, span)
+	assert.Contains(t, recorded.Attributes(), degradedAttr)
+	assert.Contains(t, recorded.Attributes(), attribute.String("case", "test"))
+}
+
+func TestAuto_NilContext(t *testing.T) {
+	assert.NotPanics(t, func() {
+		var nilCtx context.Context
+		ctx, span := Auto(nilCtx)
+		require.NotNil(t, ctx)
+		require.NotNil(t, span)
+		span.End()
+	})
+}
+
+func TestAutoNamed(t *testing.T) {
+	installRecorder()
+
+	_, span := AutoNamed(context.Background(), "service.ImportUsers", attribute.Int("rows", 3))
+	recorded := lastSpan(t, span)
+
+	assert.Equal(t, "service.ImportUsers", recorded.Name())
+	attrs := recorded.Attributes()
+	assert.Contains(t, attrs, attribute.Int("rows", 3))
+	assert.Contains(t, attrs, layerAttrs[LayerUnknown])
+	assert.Len(t, attrs, 3, "only the caller's attribute, layer and domain are recorded")
+}
+
+func TestAutoSubOp(t *testing.T) {
+	installRecorder()
+
+	_, span := AutoSubOp(context.Background(), "email-check", attribute.String("case", "test"))
+	recorded := lastSpan(t, span)
+
+	assert.Equal(t, "unknown.TestAutoSubOp.email-check", recorded.Name())
+	attrs := recorded.Attributes()
+	assert.Contains(t, attrs, attribute.String("case", "test"))
+	assert.Len(t, attrs, 3, "only the caller's attribute, layer and domain are recorded")
+}
+
+// Concurrent calls with varying attribute counts must each record exactly
+// their own attributes, whatever slices the pool hands out. Run with -race.
+func TestAuto_ConcurrentAttributesDoNotAlias(t *testing.T) {
+	installRecorder()
+
+	const workers, calls = 16, 50
+	type result struct {
+		spanID trace.SpanID
+		want   []attribute.KeyValue
+	}
+	results := make(chan result, workers*calls)
+
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range calls {
+				// Up to 40 attributes, past maxPooledAttrs, so grown slices
+				// are exercised too
+				attrs := make([]attribute.KeyValue, (w*calls+c)%41)
+				for i := range attrs {
+					attrs[i] = attribute.String(fmt.Sprintf("k%d", i), fmt.Sprintf("w%d-c%d-%d", w, c, i))
+				}
+				_, span := Auto(context.Background(), attrs...)
+				// Reusing the caller's slice after Auto returns must not
+				// reach the recorded span
+				for i := range attrs {
+					attrs[i] = attribute.String("mutated", "after-start")
+				}
+				span.End()
+				want := make([]attribute.KeyValue, 0, len(attrs))
+				for i := range attrs {
+					want = append(want, attribute.String(fmt.Sprintf("k%d", i), fmt.Sprintf("w%d-c%d-%d", w, c, i)))
+				}
+				results <- result{spanID: span.SpanContext().SpanID(), want: want}
+			}
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	recorded := make(map[trace.SpanID][]attribute.KeyValue)
+	for _, span := range recorder.Ended() {
+		recorded[span.SpanContext().SpanID()] = span.Attributes()
+	}
+	for r := range results {
+		attrs, ok := recorded[r.spanID]
+		require.True(t, ok)
+		require.Len(t, attrs, len(r.want)+2, "caller attributes plus layer and domain")
+		assert.Equal(t, r.want, attrs[:len(r.want)])
+	}
+}
+
+func TestAuto_PooledSlicesHoldNoAttributes(t *testing.T) {
+	for n := range 40 {
+		attrs := make([]attribute.KeyValue, n)
+		for i := range attrs {
+			attrs[i] = attribute.String("secret", "caller-data")
+		}
+		_, span := Auto(context.Background(), attrs...)
+		span.End()
+
+		pooled := attrPool.Get().(*[]attribute.KeyValue)
+		assert.Empty(t, *pooled)
+		assert.LessOrEqual(t, cap(*pooled), maxPooledAttrs)
+		for _, kv := range (*pooled)[:cap(*pooled)] {
+			assert.Equal(t, attribute.KeyValue{}, kv, "n=%d", n)
+		}
+		attrPool.Put(pooled)
+	}
+}
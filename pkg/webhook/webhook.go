@@ -0,0 +1,264 @@
+// Package webhook notifies external endpoints of security events such as
+// logins from a new device.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event types.
+const (
+	EventNewDeviceLogin  = "login.new_device"
+	EventPasswordChanged = "password.changed"
+	EventAccountLocked   = "account.locked"
+	EventUserRegistered  = "user.registered"
+	EventSessionRevoked  = "session.revoked"
+)
+
+const (
+	// SignatureHeader carries "sha256=" followed by the hex HMAC-SHA256 of
+	// the request body, keyed with the shared secret.
+	SignatureHeader = "X-Webhook-Signature"
+	// EventHeader repeats the event type so receivers can route without
+	// parsing the body.
+	EventHeader = "X-Webhook-Event"
+)
+
+// Event is the JSON payload delivered to every configured URL.
+type Event struct {
+	ID         string            `json:"id"`
+	Type       string            `json:"type"`
+	UserID     string            `json:"user_id"`
+	OccurredAt time.Time         `json:"occurred_at"`
+	Data       map[string]string `json:"data,omitempty"`
+}
+
+// NewEvent returns an event of eventType for userID stamped with a fresh ID
+// and the current time.
+func NewEvent(eventType, userID string, data map[string]string) Event {
+	return Event{
+		ID:         uuid.NewString(),
+		Type:       eventType,
+		UserID:     userID,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	}
+}
+
+// Dispatcher delivers events. Dispatch must not block the caller on
+// delivery; failures are the dispatcher's to log.
+type Dispatcher interface {
+	Dispatch(ctx context.Context, event Event)
+}
+
+// Deliverer delivers events synchronously, reporting failure so the caller
+// can retry. The outbox publisher relies on it to mark an event sent only
+// once it was delivered.
+type Deliverer interface {
+	Deliver(ctx context.Context, event Event) error
+}
+
+// Route is one destination of a Deliverer. Its Name stays the same across
+// restarts, so the outbox can record which destinations an event reached
+// and retry only the others.
+type Route struct {
+	Name string
+	Deliverer
+}
+
+// Router is a Deliverer with several destinations that can each be retried
+// on their own.
+type Router interface {
+	Routes() []Route
+}
+
+// RoutesOf returns the routes of d: those it lists when it is a Router, or
+// d itself as a single route named after its type. A nil d has none.
+func RoutesOf(d Deliverer) []Route {
+	if d == nil {
+		return nil
+	}
+	if router, ok := d.(Router); ok {
+		return router.Routes()
+	}
+	return []Route{{Name: fmt.Sprintf("%T", d), Deliverer: d}}
+}
+
+// dispatchOnly delivers through a Dispatcher that cannot report failure.
+type dispatchOnly struct{ Dispatcher }
+
+func (d dispatchOnly) Deliver(ctx context.Context, event Event) error {
+	d.Dispatch(ctx, event)
+	return nil
+}
+
+// NopDispatcher discards every event; it is used while webhooks are disabled.
+type NopDispatcher struct{}
+
+func (NopDispatcher) Dispatch(context.Context, Event) {}
+
+func (NopDispatcher) Deliver(context.Context, Event) error { return nil }
+
+// MultiDispatcher hands every event to each of its dispatchers in turn.
+type MultiDispatcher []Dispatcher
+
+func (m MultiDispatcher) Dispatch(ctx context.Context, event Event) {
+	for _, d := range m {
+		d.Dispatch(ctx, event)
+	}
+}
+
+// Deliver delivers event through every dispatcher that is a Deliverer and
+// dispatches it to the rest, returning the joined delivery errors.
+// Routes returns the routes of every dispatcher in turn. A dispatcher that
+// is not a Deliverer becomes a route that never fails.
+func (m MultiDispatcher) Routes() []Route {
+	var routes []Route
+	for _, d := range m {
+		deliverer, ok := d.(Deliverer)
+		if !ok {
+			deliverer = dispatchOnly{d}
+		}
+		routes = append(routes, RoutesOf(deliverer)...)
+	}
+	return routes
+}
+
+func (m MultiDispatcher) Deliver(ctx context.Context, event Event) error {
+	var errs []error
+	for _, d := range m {
+		if deliverer, ok := d.(Deliverer); ok {
+			errs = append(errs, deliverer.Deliver(ctx, event))
+			continue
+		}
+		d.Dispatch(ctx, event)
+	}
+	return errors.Join(errs...)
+}
+
+// HTTPDispatcher POSTs each event as signed JSON to every configured URL,
+// one goroutine per delivery.
+type HTTPDispatcher struct {
+	urls   []string
+	secret string
+	client *http.Client
+	logger *slog.Logger
+	wg     sync.WaitGroup
+}
+
+func NewHTTPDispatcher(urls []string, secret string, timeout time.Duration, logger *slog.Logger) *HTTPDispatcher {
+	return &HTTPDispatcher{
+		urls:   urls,
+		secret: secret,
+		client: &http.Client{Timeout: timeout},
+		logger: logger,
+	}
+}
+
+func (d *HTTPDispatcher) Dispatch(ctx context.Context, event Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		d.logger.ErrorContext(ctx, "failed to encode webhook event", "event", event.Type, "error", err)
+		return
+	}
+
+	// Delivery outlives the request that triggered it
+	ctx = context.WithoutCancel(ctx)
+	for _, url := range d.urls {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			if err := d.send(ctx, url, event.Type, body); err != nil {
+				d.logger.WarnContext(ctx, "webhook delivery failed", "event", event.Type, "url", url, "error", err)
+			}
+		}()
+	}
+}
+
+// Deliver POSTs event to every URL in turn, waiting for each response, and
+// returns the joined failures.
+func (d *HTTPDispatcher) Deliver(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook event: %w", err)
+	}
+
+	var errs []error
+	for _, url := range d.urls {
+		if err := d.send(ctx, url, event.Type, body); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", url, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Routes returns one route per URL, named "webhook " followed by the URL.
+func (d *HTTPDispatcher) Routes() []Route {
+	routes := make([]Route, 0, len(d.urls))
+	for _, url := range d.urls {
+		routes = append(routes, Route{Name: "webhook " + url, Deliverer: urlDeliverer{d, url}})
+	}
+	return routes
+}
+
+// urlDeliverer delivers to a single URL of an HTTPDispatcher.
+type urlDeliverer struct {
+	dispatcher *HTTPDispatcher
+	url        string
+}
+
+func (u urlDeliverer) Deliver(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook event: %w", err)
+	}
+	return u.dispatcher.send(ctx, u.url, event.Type, body)
+}
+
+func (d *HTTPDispatcher) send(ctx context.Context, url, eventType string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, eventType)
+	req.Header.Set(SignatureHeader, Sign(d.secret, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Shutdown waits for in-flight deliveries, each bounded by the client
+// timeout. It lets the injector drain webhooks on shutdown.
+func (d *HTTPDispatcher) Shutdown() error {
+	d.wg.Wait()
+	return nil
+}
+
+// Sign returns the SignatureHeader value for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,123 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type delivery struct {
+	header http.Header
+	body   []byte
+}
+
+func TestHTTPDispatcher_DeliversSignedEvent(t *testing.T) {
+	received := make(chan delivery, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- delivery{header: r.Header, body: body}
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	d := NewHTTPDispatcher([]string{server.URL}, "secret", time.Second, logger)
+
+	event := NewEvent(EventNewDeviceLogin, "user-id", map[string]string{"user_agent": "curl/8.0"})
+	d.Dispatch(context.Background(), event)
+	require.NoError(t, d.Shutdown())
+
+	got := <-received
+	assert.Equal(t, EventNewDeviceLogin, got.header.Get(EventHeader))
+	assert.Equal(t, Sign("secret", got.body), got.header.Get(SignatureHeader))
+
+	var payload Event
+	require.NoError(t, json.Unmarshal(got.body, &payload))
+	assert.Equal(t, event.ID, payload.ID)
+	assert.Equal(t, "user-id", payload.UserID)
+	assert.Equal(t, "curl/8.0", payload.Data["user_agent"])
+}
+
+func TestHTTPDispatcher_DoesNotBlockCaller(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	d := NewHTTPDispatcher([]string{server.URL}, "secret", 5*time.Second, logger)
+
+	done := make(chan struct{})
+	go func() {
+		d.Dispatch(context.Background(), NewEvent(EventAccountLocked, "user-id", nil))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Dispatch blocked on delivery")
+	}
+
+	close(release)
+	require.NoError(t, d.Shutdown())
+}
+
+func TestHTTPDispatcher_DeliverReportsFailure(t *testing.T) {
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ok.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	event := NewEvent(EventAccountLocked, "user-id", nil)
+
+	d := NewHTTPDispatcher([]string{ok.URL}, "secret", time.Second, logger)
+	assert.NoError(t, d.Deliver(context.Background(), event))
+
+	d = NewHTTPDispatcher([]string{ok.URL, failing.URL}, "secret", time.Second, logger)
+	err := d.Deliver(context.Background(), event)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), failing.URL)
+}
+
+func TestHTTPDispatcher_RoutesDeliverPerURL(t *testing.T) {
+	var okHits int
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { okHits++ }))
+	defer ok.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	d := NewHTTPDispatcher([]string{ok.URL, failing.URL}, "secret", time.Second, logger)
+	event := NewEvent(EventAccountLocked, "user-id", nil)
+
+	routes := RoutesOf(MultiDispatcher{d})
+
+	require.Len(t, routes, 2)
+	assert.Equal(t, "webhook "+ok.URL, routes[0].Name)
+	assert.Equal(t, "webhook "+failing.URL, routes[1].Name)
+	assert.NoError(t, routes[0].Deliver(context.Background(), event))
+	assert.Error(t, routes[1].Deliver(context.Background(), event))
+	assert.Equal(t, 1, okHits)
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"type":"account.locked"}`)
+
+	assert.Equal(t, Sign("secret", body), Sign("secret", body))
+	assert.NotEqual(t, Sign("secret", body), Sign("other", body))
+	assert.Contains(t, Sign("secret", body), "sha256=")
+}
@@ -8,22 +8,31 @@ import (
 	"time"
 
 	"github.com/elskow/go-microservice-template/config"
+	"github.com/elskow/go-microservice-template/middlewares"
 	"github.com/elskow/go-microservice-template/modules/account/authorization"
 	"github.com/elskow/go-microservice-template/modules/account/controller"
 	"github.com/elskow/go-microservice-template/modules/account/repository"
 	"github.com/elskow/go-microservice-template/modules/account/service"
 	"github.com/elskow/go-microservice-template/pkg/apm"
+	"github.com/elskow/go-microservice-template/pkg/captcha"
 	"github.com/elskow/go-microservice-template/pkg/constants"
 	"github.com/elskow/go-microservice-template/pkg/database"
+	"github.com/elskow/go-microservice-template/pkg/email"
+	"github.com/elskow/go-microservice-template/pkg/eventstream"
 	"github.com/elskow/go-microservice-template/pkg/jwt"
 	"github.com/elskow/go-microservice-template/pkg/logger"
+	"github.com/elskow/go-microservice-template/pkg/maintenance"
+	"github.com/elskow/go-microservice-template/pkg/outbox"
+	"github.com/elskow/go-microservice-template/pkg/readiness"
 	"github.com/elskow/go-microservice-template/pkg/telemetry"
+	"github.com/elskow/go-microservice-template/pkg/webhook"
 	"github.com/samber/do"
 )
 
 func InitLogger(injector *do.Injector) {
 	do.ProvideNamed(injector, "logger", func(i *do.Injector) (*slog.Logger, error) {
-		log := logger.NewLogger("go-microservice-template", "1.0.0")
+		cfg := config.Get()
+		log := logger.NewLogger(cfg.AppName, cfg.AppVersion)
 		logger.SetDefault(log)
 		return log, nil
 	})
@@ -31,16 +40,26 @@ func InitLogger(injector *do.Injector) {
 
 func InitDatabase(injector *do.Injector) {
 	do.ProvideNamed(injector, "db", func(i *do.Injector) (*database.TracedDB, error) {
+		log := do.MustInvokeNamed[*slog.Logger](i, "logger")
 		db := config.SetUpDatabaseConnection()
-		return database.NewTracedDB(db), nil
+		tracedDB := database.NewTracedDB(db)
+		tracedDB.SetLogger(log)
+
+		cfg := config.Get()
+		if cfg.DBBreakerEnabled {
+			tracedDB.SetCircuitBreaker(database.NewCircuitBreaker(cfg.DBBreakerFailureThreshold, cfg.DBBreakerCooldown(), log))
+		}
+
+		return tracedDB, nil
 	})
 }
 
 func InitTelemetry(injector *do.Injector) {
 	do.ProvideNamed(injector, "telemetry", func(i *do.Injector) (*telemetry.Telemetry, error) {
 		log := do.MustInvokeNamed[*slog.Logger](i, "logger")
+		cfg := config.Get()
 		ctx := context.Background()
-		return telemetry.InitTelemetry(ctx, "go-microservice-template", "1.0.0", log)
+		return telemetry.InitTelemetry(ctx, cfg.AppName, cfg.AppVersion, log)
 	})
 }
 
@@ -85,6 +104,67 @@ func RegisterDependencies(injector *do.Injector) {
 		return jwt.NewService(), nil
 	})
 
+	do.ProvideNamed(injector, "readiness-gate", func(i *do.Injector) (*readiness.Gate, error) {
+		return readiness.NewGate(), nil
+	})
+
+	do.ProvideNamed(injector, "route-auth", func(i *do.Injector) (*middlewares.RouteAuth, error) {
+		return middlewares.NewRouteAuth(), nil
+	})
+
+	do.ProvideNamed(injector, "maintenance-mode", func(i *do.Injector) (*middlewares.MaintenanceMode, error) {
+		db := do.MustInvokeNamed[*database.TracedDB](i, "db")
+		cfg := config.Get()
+		return middlewares.NewMaintenanceMode(cfg.MaintenanceMode, cfg.MaintenanceRetryAfter(), middlewares.RetryAfterFormatFor(cfg.RetryAfterHTTPDate), cfg.MaintenanceExemptPathList(), maintenance.NewStore(db)), nil
+	})
+
+	do.ProvideNamed(injector, "captcha-verifier", func(i *do.Injector) (captcha.Verifier, error) {
+		cfg := config.Get()
+		return captcha.NewSiteVerifier(cfg.CaptchaVerifyURL, cfg.CaptchaSecret), nil
+	})
+
+	do.ProvideNamed(injector, "webhook-dispatcher", func(i *do.Injector) (webhook.Dispatcher, error) {
+		cfg := config.Get()
+		if !cfg.WebhookEnabled {
+			return webhook.NopDispatcher{}, nil
+		}
+		log := do.MustInvokeNamed[*slog.Logger](i, "logger")
+		return webhook.NewHTTPDispatcher(cfg.WebhookURLList(), cfg.WebhookSecret, cfg.WebhookTimeout(), log), nil
+	})
+
+	do.ProvideNamed(injector, "email-sender", func(i *do.Injector) (email.Sender, error) {
+		cfg := config.Get()
+		if !cfg.EmailEnabled {
+			return email.NopSender{}, nil
+		}
+		return email.NewSMTPSender(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.EmailFrom, cfg.SMTPTimeout()), nil
+	})
+
+	do.ProvideNamed(injector, "email-templates", func(i *do.Injector) (*email.Templates, error) {
+		return email.LoadTemplates(config.Get().EmailTemplateDir)
+	})
+
+	do.ProvideNamed(injector, "event-broker", func(i *do.Injector) (*eventstream.Broker, error) {
+		return eventstream.NewBroker(config.Get().EventStreamBufferSize), nil
+	})
+
+	do.ProvideNamed(injector, "event-relay", func(i *do.Injector) (*eventstream.Relay, error) {
+		broker := do.MustInvokeNamed[*eventstream.Broker](i, "event-broker")
+		log := do.MustInvokeNamed[*slog.Logger](i, "logger")
+		return eventstream.NewRelay(config.Get().DatabaseDSN(), broker, log), nil
+	})
+
+	do.ProvideNamed(injector, "outbox-publisher", func(i *do.Injector) (*outbox.Publisher, error) {
+		db := do.MustInvokeNamed[*database.TracedDB](i, "db")
+		webhooks := do.MustInvokeNamed[webhook.Dispatcher](i, "webhook-dispatcher")
+		log := do.MustInvokeNamed[*slog.Logger](i, "logger")
+		cfg := config.Get()
+		dispatcher := webhook.MultiDispatcher{webhooks, eventstream.NewNotifier(db)}
+		publisher := outbox.NewPublisher(db, dispatcher, cfg.OutboxPollInterval(), cfg.OutboxBatchSize, cfg.OutboxClaimLease(), cfg.OutboxRetention(), log)
+		publisher.Start()
+		return publisher, nil
+	})
+
 	do.ProvideNamed(injector, "repository", func(i *do.Injector) (repository.Repository, error) {
 		db := do.MustInvokeNamed[*database.TracedDB](i, "db")
 		return repository.NewRepository(db), nil
@@ -95,13 +175,16 @@ func RegisterDependencies(injector *do.Injector) {
 		jwtService := do.MustInvokeNamed[jwt.Service](i, "jwt-service")
 		db := do.MustInvokeNamed[*database.TracedDB](i, "db")
 		auth := do.MustInvokeNamed[*authorization.Authorizer](i, "authorizer")
-		return service.NewService(repo, jwtService, db, auth), nil
+		sender := do.MustInvokeNamed[email.Sender](i, "email-sender")
+		templates := do.MustInvokeNamed[*email.Templates](i, "email-templates")
+		broker := do.MustInvokeNamed[*eventstream.Broker](i, "event-broker")
+		return service.NewService(repo, jwtService, db, auth, sender, templates, broker), nil
 	})
 
 	do.ProvideNamed(injector, "controller", func(i *do.Injector) (*controller.Controller, error) {
 		svc := do.MustInvokeNamed[service.Service](i, "service")
 		log := do.MustInvokeNamed[*slog.Logger](i, "logger")
 		auth := do.MustInvokeNamed[*authorization.Authorizer](i, "authorizer")
-		return controller.NewController(svc, log, auth), nil
+		return controller.NewController(svc, log, auth)
 	})
 }
@@ -0,0 +1,47 @@
+package providers
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/elskow/go-microservice-template/config"
+	"github.com/elskow/go-microservice-template/pkg/telemetry"
+	"github.com/samber/do"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+func TestInitTelemetry_UsesConfiguredServiceIdentity(t *testing.T) {
+	t.Setenv("APP_NAME", "billing-api")
+	t.Setenv("APP_VERSION", "2.3.4")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "127.0.0.1:1")
+	config.Load()
+	t.Cleanup(config.Reset)
+
+	injector := do.New()
+	do.ProvideNamed(injector, "logger", func(i *do.Injector) (*slog.Logger, error) {
+		return slog.New(slog.NewTextHandler(io.Discard, nil)), nil
+	})
+	InitTelemetry(injector)
+
+	tel := do.MustInvokeNamed[*telemetry.Telemetry](injector, "telemetry")
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = tel.Shutdown(ctx)
+	})
+
+	attrs := tel.Resource.Set()
+
+	name, ok := attrs.Value(semconv.ServiceNameKey)
+	require.True(t, ok)
+	assert.Equal(t, "billing-api", name.AsString())
+
+	version, ok := attrs.Value(semconv.ServiceVersionKey)
+	require.True(t, ok)
+	assert.Equal(t, "2.3.4", version.AsString())
+}
@@ -0,0 +1,48 @@
+package providers
+
+import (
+	"log/slog"
+
+	"github.com/elskow/go-microservice-template/modules/account/authorization"
+	"github.com/elskow/go-microservice-template/modules/account/controller"
+	"github.com/elskow/go-microservice-template/modules/account/service"
+	"github.com/elskow/go-microservice-template/pkg/apm"
+	"github.com/elskow/go-microservice-template/pkg/database"
+	pkgerrors "github.com/elskow/go-microservice-template/pkg/errors"
+	"github.com/elskow/go-microservice-template/pkg/jwt"
+	"github.com/elskow/go-microservice-template/pkg/telemetry"
+	"github.com/samber/do"
+)
+
+// criticalDependencies are the providers Validate constructs up front, in
+// the order they are invoked.
+var criticalDependencies = []struct {
+	name   string
+	invoke func(i *do.Injector, name string) error
+}{
+	{"logger", invokeAs[*slog.Logger]},
+	{"db", invokeAs[*database.TracedDB]},
+	{"telemetry", invokeAs[*telemetry.Telemetry]},
+	{"apm", invokeAs[*apm.MetricsCollector]},
+	{"authorizer", invokeAs[*authorization.Authorizer]},
+	{"jwt-service", invokeAs[jwt.Service]},
+	{"service", invokeAs[service.Service]},
+	{"controller", invokeAs[*controller.Controller]},
+}
+
+// Validate eagerly builds every critical dependency so a missing or failing
+// provider stops startup instead of surfacing on the first request that
+// needs it.
+func Validate(injector *do.Injector) error {
+	for _, dep := range criticalDependencies {
+		if err := dep.invoke(injector, dep.name); err != nil {
+			return pkgerrors.Wrapf(err, "dependency %q cannot be constructed", dep.name)
+		}
+	}
+	return nil
+}
+
+func invokeAs[T any](i *do.Injector, name string) error {
+	_, err := do.InvokeNamed[T](i, name)
+	return err
+}
@@ -0,0 +1,39 @@
+package providers
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/elskow/go-microservice-template/modules/account/controller"
+	"github.com/elskow/go-microservice-template/modules/account/service"
+	"github.com/samber/do"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate_MissingProvider(t *testing.T) {
+	injector := do.New()
+	do.ProvideNamed(injector, "logger", func(i *do.Injector) (*slog.Logger, error) {
+		return slog.New(slog.NewTextHandler(io.Discard, nil)), nil
+	})
+
+	err := Validate(injector)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `dependency "db"`)
+}
+
+func TestValidate_MissingNestedDependency(t *testing.T) {
+	injector := do.New()
+	// The controller's provider panics on its missing service; Validate must
+	// report that as an error rather than crash
+	do.ProvideNamed(injector, "controller", func(i *do.Injector) (*controller.Controller, error) {
+		do.MustInvokeNamed[service.Service](i, "service")
+		return nil, nil
+	})
+
+	err := invokeAs[*controller.Controller](injector, "controller")
+
+	assert.Error(t, err)
+}
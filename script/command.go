@@ -16,6 +16,7 @@ func Commands(injector *do.Injector) bool {
 
 	migrate := false
 	seed := false
+	verifySeed := false
 	run := false
 
 	for _, arg := range os.Args[1:] {
@@ -25,6 +26,9 @@ func Commands(injector *do.Injector) bool {
 		if arg == "--seed" {
 			seed = true
 		}
+		if arg == "--verify-seed" {
+			verifySeed = true
+		}
 		if arg == "--run" {
 			run = true
 		}
@@ -39,13 +43,35 @@ func Commands(injector *do.Injector) bool {
 	}
 
 	if seed {
-		if err := database.Seeder(db); err != nil {
+		if err := database.Seeder(db, logger); err != nil {
 			logger.Error("seeder failed", "error", err)
 			os.Exit(1)
 		}
 		logger.Info("seeder completed successfully")
 	}
 
+	if verifySeed {
+		report, err := database.VerifySeed(db)
+		if err != nil {
+			logger.Error("seed verification failed", "error", err)
+			os.Exit(1)
+		}
+
+		for _, check := range report.Checks {
+			if check.Passed {
+				logger.Info("seed check passed", "check", check.Name, "detail", check.Detail)
+			} else {
+				logger.Error("seed check failed", "check", check.Name, "detail", check.Detail)
+			}
+		}
+
+		if !report.Passed() {
+			logger.Error("seed verification failed")
+			os.Exit(1)
+		}
+		logger.Info("seed verification completed successfully")
+	}
+
 	if run {
 		return true
 	}